@@ -3,13 +3,22 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/history"
 	"github.com/ramonvermeulen/whosthere/internal/core/logging"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/core/version"
@@ -34,7 +43,8 @@ Examples:` + reset + `
 }
 
 func runDaemon(cmd *cobra.Command, _ []string) error {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	logger, err := logging.New(true)
 	if err != nil {
 		return err
@@ -52,7 +62,41 @@ func runDaemon(cmd *cobra.Command, _ []string) error {
 	}
 
 	appState := state.NewAppState(cfg, version.Version)
-	eng, err := core.BuildEngine(cfg, logger)
+	store, err := annotations.OpenDefault()
+	if err != nil {
+		return err
+	}
+	appState.SetAnnotationStore(store)
+	hist, err := core.BuildHistory(cfg, logger)
+	if err != nil {
+		return err
+	}
+	appState.SetHistory(hist)
+	// serve the devices from the previous runs until the first scan completed
+	appState.RestoreDevices(hist.Devices())
+	ruleSet, err := core.BuildRules(cfg)
+	if err != nil {
+		return err
+	}
+	var engOpts []discovery.Option
+	if ruleSet != nil {
+		engOpts = append(engOpts, discovery.WithRules(ruleSet))
+	}
+	known, err := core.BuildKnownDevices(cfg, store)
+	if err != nil {
+		return err
+	}
+	// devices seen by a previous run were reported then
+	engOpts = append(engOpts, discovery.WithKnownDevices(known), discovery.WithReportedDevices(hist.IDs()...))
+	eng, err := core.BuildEngine(cfg, logger, engOpts...)
+	if err != nil {
+		return err
+	}
+	portScanner, err := core.BuildPortScanner(cfg, eng.Iface)
+	if err != nil {
+		return err
+	}
+	autoScan, err := core.BuildAutoScan(cfg, portScanner, logger)
 	if err != nil {
 		return err
 	}
@@ -65,41 +109,120 @@ func runDaemon(cmd *cobra.Command, _ []string) error {
 		logger.Log(ctx, slog.LevelDebug, "received request", "method", r.Method, "path", r.URL.Path)
 		handleDeviceByIP(w, r, appState)
 	})
+	http.HandleFunc("/devices/{ip}/annotation", func(w http.ResponseWriter, r *http.Request) {
+		logger.Log(ctx, slog.LevelDebug, "received request", "method", r.Method, "path", r.URL.Path)
+		handleAnnotation(w, r, appState)
+	})
+	http.HandleFunc("/devices/{id}/history", func(w http.ResponseWriter, r *http.Request) {
+		logger.Log(ctx, slog.LevelDebug, "received request", "method", r.Method, "path", r.URL.Path)
+		handleDeviceHistory(w, r, appState)
+	})
+	http.HandleFunc("/annotations", func(w http.ResponseWriter, r *http.Request) {
+		logger.Log(ctx, slog.LevelDebug, "received request", "method", r.Method, "path", r.URL.Path)
+		handleAnnotations(w, r, appState)
+	})
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		logger.Log(ctx, slog.LevelDebug, "received request", "method", r.Method, "path", r.URL.Path)
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
 	})
 
+	server := &http.Server{Addr: ":" + port}
 	go func() {
-		logger.Log(context.Background(), slog.LevelInfo, "starting HTTP server", "port", port)
-		if err := http.ListenAndServe(":"+port, nil); err != nil {
-			logger.Log(context.Background(), slog.LevelError, "HTTP server failed", "error", err)
+		logger.Log(ctx, slog.LevelInfo, "starting HTTP server", "port", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log(ctx, slog.LevelError, "HTTP server failed", "error", err)
 		}
 	}()
 
+	eventsDone := make(chan struct{})
 	go func() {
+		defer close(eventsDone)
 		for event := range eng.Events {
 			switch event.Type {
 			case discovery.EventScanStarted:
 			case discovery.EventScanCompleted:
+				if err := hist.RecordScan(event.Stats); err != nil {
+					logger.Log(ctx, slog.LevelWarn, "failed to record scan in history", "error", err)
+				}
 			case discovery.EventDeviceDiscovered:
-				if event.Device != nil {
-					appState.UpsertDevice(event.Device)
+				if event.Device == nil {
+					break
+				}
+				appState.UpsertDevice(event.Device)
+				d, ok := appState.GetDevice(event.Device.IP().String())
+				if !ok {
+					break
+				}
+				// rules are evaluated again on the canonical device, which also holds port scan results
+				if ruleSet != nil {
+					for _, a := range ruleSet.Evaluate(d) {
+						logAlert(logger, a)
+					}
+				}
+				if autoScan != nil {
+					autoScan.Observe(d)
 				}
+				if err := hist.Observe(d); err != nil {
+					logger.Log(ctx, slog.LevelWarn, "failed to record device in history", "ip", d.IP().String(), "error", err)
+				}
+			case discovery.EventUnknownDevice:
+				if event.Device == nil {
+					break
+				}
+				appState.UpsertDevice(event.Device)
+				logger.Log(ctx, slog.LevelWarn, "unknown device joined", "ip", event.Device.IP().String(), "mac", event.Device.MAC())
+			case discovery.EventAlert:
+				logAlert(logger, *event.Alert)
 			case discovery.EventError:
 			default:
 			}
 		}
 	}()
 
-	eng.Start(context.Background())
+	eng.Start(ctx)
+	if ruleSet != nil {
+		logger.Log(ctx, slog.LevelInfo, "rules loaded", "count", ruleSet.Len())
+	}
+	if autoScan != nil {
+		logger.Log(ctx, slog.LevelInfo, "automatic port scanning enabled", "profile", cfg.PortScanner.Auto.Profile)
+		go autoScan.Run(ctx)
+	}
 
-	select {}
+	<-ctx.Done()
+	logger.Log(context.Background(), slog.LevelInfo, "received signal, shutting down")
+	// stop the engine and wait for its remaining events, so the history holds all of them
+	eng.Stop()
+	<-eventsDone
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Log(context.Background(), slog.LevelWarn, "failed to stop HTTP server", "error", err)
+	}
+	if err := hist.Flush(); err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	return nil
 }
 
-func handleDevices(w http.ResponseWriter, _ *http.Request, appState *state.AppState) {
+// logAlert logs an alert raised by a rule.
+func logAlert(logger discovery.Logger, a discovery.Alert) {
+	logger.Log(context.Background(), slog.LevelWarn, "rule alert: "+a.Message, "rule", a.Rule, "ip", a.Device.IP().String(), "mac", a.Device.MAC())
+}
+
+// handleDevices lists all devices. The optional certExpiresWithin query parameter
+// (in days) limits the list to devices with a TLS certificate expiring within
+// that period, including expired ones.
+func handleDevices(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
 	devices := appState.DevicesSnapshot()
+	if v := r.URL.Query().Get("certExpiresWithin"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			http.Error(w, "Invalid certExpiresWithin, expected a number of days", http.StatusBadRequest)
+			return
+		}
+		devices = filterExpiringCertificates(devices, time.Duration(days)*24*time.Hour, time.Now())
+	}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(devices); err != nil {
 		http.Error(w, "Failed to encode devices", http.StatusInternalServerError)
@@ -107,6 +230,17 @@ func handleDevices(w http.ResponseWriter, _ *http.Request, appState *state.AppSt
 	}
 }
 
+// filterExpiringCertificates keeps the devices with a certificate expiring within d of now.
+func filterExpiringCertificates(devices []*discovery.Device, within time.Duration, now time.Time) []*discovery.Device {
+	out := make([]*discovery.Device, 0, len(devices))
+	for _, d := range devices {
+		if len(d.ExpiringCertificates(within, now)) > 0 {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
 func handleDeviceByIP(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
 	ipStr := strings.TrimPrefix(r.URL.Path, "/devices/")
 	if ipStr == "" {
@@ -129,3 +263,130 @@ func handleDeviceByIP(w http.ResponseWriter, r *http.Request, appState *state.Ap
 		return
 	}
 }
+
+// handleAnnotation reads (GET), replaces (PUT) or removes (DELETE) the annotation
+// of the device in the path, see annotations.Annotation for the JSON layout.
+func handleAnnotation(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
+	ipStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/devices/"), "/annotation")
+	if net.ParseIP(ipStr) == nil {
+		http.Error(w, "Invalid IP address", http.StatusBadRequest)
+		return
+	}
+	if _, ok := appState.GetDevice(ipStr); !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var a annotations.Annotation
+	switch r.Method {
+	case http.MethodGet:
+		a, _ = appState.Annotation(ipStr)
+	case http.MethodPut:
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&a); err != nil {
+			http.Error(w, "Invalid annotation: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		fallthrough
+	case http.MethodDelete:
+		saved, err := appState.Annotate(ipStr, a)
+		if errors.Is(err, state.ErrDeviceNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to save annotation", http.StatusInternalServerError)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		a = saved
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a); err != nil {
+		http.Error(w, "Failed to encode annotation", http.StatusInternalServerError)
+		return
+	}
+}
+
+// deviceHistory is the inventory record of a device with its presence over the
+// last 24 hours and 7 days.
+type deviceHistory struct {
+	ID        string                      `json:"id"`
+	FirstSeen time.Time                   `json:"firstSeen"`
+	LastSeen  time.Time                   `json:"lastSeen"`
+	Sessions  []history.Session           `json:"sessions"`
+	Changes   []history.Change            `json:"changes"`
+	Presence  map[string]history.Presence `json:"presence"`
+}
+
+// handleDeviceHistory serves the presence sessions and changes of a device, by
+// IP address or by the identity it is recorded under, e.g. "mac:aa:bb:cc:dd:ee:ff".
+func handleDeviceHistory(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	store := appState.History()
+	if store == nil {
+		http.Error(w, "History is disabled", http.StatusNotFound)
+		return
+	}
+
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/devices/"), "/history")
+	if d, ok := appState.GetDevice(id); ok {
+		id = d.Identity()
+	}
+	rec, ok := store.Record(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := deviceHistory{
+		ID:        rec.ID,
+		FirstSeen: rec.FirstSeen,
+		LastSeen:  rec.LastSeen,
+		Sessions:  rec.Sessions,
+		Changes:   rec.Changes,
+		Presence:  map[string]history.Presence{},
+	}
+	if resp.Sessions == nil {
+		resp.Sessions = []history.Session{}
+	}
+	if resp.Changes == nil {
+		resp.Changes = []history.Change{}
+	}
+	// hourly buckets for the last day, and 6 hour buckets for the last week
+	resp.Presence["24h"], _ = store.Presence(rec.ID, 24*time.Hour, 24)
+	resp.Presence["7d"], _ = store.Presence(rec.ID, 7*24*time.Hour, 28)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "Failed to encode history", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleAnnotations lists all annotations by the MAC or IP address key they are stored under.
+func handleAnnotations(w http.ResponseWriter, r *http.Request, appState *state.AppState) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(appState.AnnotationStore().All()); err != nil {
+		http.Error(w, "Failed to encode annotations", http.StatusInternalServerError)
+		return
+	}
+}
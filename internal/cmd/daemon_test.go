@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/history"
+	"github.com/ramonvermeulen/whosthere/internal/core/state"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,3 +40,107 @@ func TestNewDaemonCommand_HasAllInheritedPersistentFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleDevices_CertExpiresWithin(t *testing.T) {
+	now := time.Now()
+	appState := state.NewAppState(config.DefaultConfig(), "")
+
+	expiring := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	expiring.AddPortResult(discovery.PortResult{
+		Port: 443, Protocol: "tcp", State: discovery.PortOpen,
+		Service: &discovery.ServiceInfo{Certificate: &discovery.CertificateInfo{NotAfter: now.Add(5 * 24 * time.Hour)}},
+	})
+	valid := discovery.NewDevice(net.ParseIP("192.168.1.11"))
+	valid.AddPortResult(discovery.PortResult{
+		Port: 443, Protocol: "tcp", State: discovery.PortOpen,
+		Service: &discovery.ServiceInfo{Certificate: &discovery.CertificateInfo{NotAfter: now.Add(365 * 24 * time.Hour)}},
+	})
+	appState.UpsertDevice(expiring)
+	appState.UpsertDevice(valid)
+	appState.UpsertDevice(discovery.NewDevice(net.ParseIP("192.168.1.12")))
+
+	rec := httptest.NewRecorder()
+	handleDevices(rec, httptest.NewRequest(http.MethodGet, "/devices?certExpiresWithin=30", nil), appState)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var got []map[string]any
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Len(t, got, 1)
+	assert.Equal(t, "192.168.1.10", got[0]["ip"])
+
+	rec = httptest.NewRecorder()
+	handleDevices(rec, httptest.NewRequest(http.MethodGet, "/devices?certExpiresWithin=soon", nil), appState)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandleAnnotation(t *testing.T) {
+	appState := state.NewAppState(config.DefaultConfig(), "")
+	appState.UpsertDevice(discovery.NewDevice(net.ParseIP("192.168.1.20")))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleAnnotation(rec, httptest.NewRequest(method, path, strings.NewReader(body)), appState)
+		return rec
+	}
+
+	rec := do(http.MethodPut, "/devices/192.168.1.20/annotation", `{"name": "Office printer", "owner": "IT", "tags": ["office"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	d, _ := appState.GetDevice("192.168.1.20")
+	assert.Equal(t, "Office printer", d.DisplayName())
+
+	rec = do(http.MethodGet, "/devices/192.168.1.20/annotation", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"name": "Office printer", "owner": "IT", "tags": ["office"]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handleAnnotations(rec, httptest.NewRequest(http.MethodGet, "/annotations", nil), appState)
+	assert.JSONEq(t, `{"ip:192.168.1.20": {"name": "Office printer", "owner": "IT", "tags": ["office"]}}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/devices/192.168.1.20/annotation", `{"nmae": "typo"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/devices/192.168.1.21/annotation", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/devices/printer/annotation", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPost, "/devices/192.168.1.20/annotation", "{}").Code)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/devices/192.168.1.20/annotation", "").Code)
+	_, ok := appState.Annotation("192.168.1.20")
+	assert.False(t, ok)
+	assert.Empty(t, d.DisplayName())
+}
+
+func TestHandleDeviceHistory(t *testing.T) {
+	appState := state.NewAppState(config.DefaultConfig(), "")
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleDeviceHistory(rec, httptest.NewRequest(method, path, nil), appState)
+		return rec
+	}
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/devices/192.168.1.20/history").Code, "history disabled")
+
+	store := history.New("")
+	appState.SetHistory(store)
+	d := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	d.SetMAC("aa:bb:cc:dd:ee:ff")
+	d.SetLastSeen(time.Now())
+	appState.UpsertDevice(d)
+	assert.NoError(t, store.Observe(d))
+	assert.NoError(t, store.RecordScan(&discovery.ScanStats{Count: 1}))
+
+	for _, path := range []string{"/devices/192.168.1.20/history", "/devices/mac:aa:bb:cc:dd:ee:ff/history"} {
+		rec := do(http.MethodGet, path)
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+
+		var got struct {
+			ID       string                     `json:"id"`
+			Sessions []map[string]time.Time     `json:"sessions"`
+			Presence map[string]json.RawMessage `json:"presence"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "mac:aa:bb:cc:dd:ee:ff", got.ID)
+		assert.Len(t, got.Sessions, 1)
+		assert.Contains(t, got.Presence, "24h")
+		assert.Contains(t, got.Presence, "7d")
+	}
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/devices/192.168.1.21/history").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPost, "/devices/192.168.1.20/history").Code)
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/output"
+	"github.com/ramonvermeulen/whosthere/internal/core/scandiff"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func NewDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <old.json> [<new.json>]",
+		Short: "Compare two scan results, or a scan result against a live scan",
+		Long: `Compare the devices of two scan results written by "whosthere scan --json"
+and report the devices that were added, removed or changed. With a single file,
+it is compared against a live discovery scan.
+
+Devices are matched by MAC and IP address, then by MAC address, then by IP
+address, so devices sharing a MAC address are told apart. Changes in IP address,
+MAC address, name, manufacturer and open ports are reported; open ports only
+when both scans include port scan results.
+
+Every difference is rated info, warning or critical. By default new devices,
+MAC address changes and port changes are warnings, everything else is info.
+Override the ratings with --severity, and use --fail-on to exit with status 1
+when any difference is rated at least that severity.` + magenta + `
+
+Examples:` + reset + `
+  whosthere diff baseline.json today.json
+  whosthere diff baseline.json --fail-on=warning
+  whosthere diff baseline.json today.json --markdown > changes.md
+  whosthere diff baseline.json today.json --json --severity=added=critical,ports=info
+`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: runDiff,
+	}
+
+	cmd.Flags().Bool("json", false, "Output the differences in JSON format")
+	cmd.Flags().Bool("pretty", false, "Pretty print JSON output")
+	cmd.Flags().Bool("markdown", false, "Output the differences as a Markdown table")
+	cmd.Flags().StringSlice("severity", nil, "Override severities as key=severity, keys: added, removed, ip, mac, name, manufacturer, ports")
+	cmd.Flags().String("fail-on", "", "Exit with status 1 when a difference is rated at least this severity: info, warning or critical")
+
+	return cmd
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	jsonFlag, _ := cmd.Flags().GetBool("json")
+	markdownFlag, _ := cmd.Flags().GetBool("markdown")
+	if jsonFlag && markdownFlag {
+		return errors.New("--json and --markdown are mutually exclusive")
+	}
+	rules, _ := cmd.Flags().GetStringSlice("severity")
+	severities, err := scandiff.ParseSeverities(rules)
+	if err != nil {
+		return err
+	}
+	var failOn scandiff.Severity
+	if name, _ := cmd.Flags().GetString("fail-on"); name != "" {
+		if failOn, err = scandiff.ParseSeverity(name); err != nil {
+			return fmt.Errorf("invalid --fail-on: %w", err)
+		}
+	}
+
+	from, err := scandiff.LoadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var to []scandiff.Device
+	if len(args) == 2 {
+		to, err = scandiff.LoadFile(args[1])
+	} else {
+		to, err = liveScan()
+	}
+	if err != nil {
+		return err
+	}
+
+	report := scandiff.Compare(from, to, severities)
+	pretty, _ := cmd.Flags().GetBool("pretty")
+	if err := writeDiff(cmd.OutOrStdout(), report, jsonFlag, markdownFlag, pretty); err != nil {
+		return err
+	}
+	if failOn == 0 {
+		return nil
+	}
+	if n := len(report.AtLeast(failOn)); n > 0 {
+		return fmt.Errorf("%d of %d differences rated %s or higher", n, len(report.Changes), failOn)
+	}
+	return nil
+}
+
+func writeDiff(w io.Writer, report *scandiff.Report, jsonFlag, markdownFlag, pretty bool) error {
+	switch {
+	case jsonFlag:
+		return scandiff.WriteJSON(w, report, pretty)
+	case markdownFlag:
+		return scandiff.WriteMarkdown(w, report)
+	default:
+		return scandiff.WriteTable(w, report)
+	}
+}
+
+// liveScan runs a single discovery scan and returns its devices, with their
+// annotations applied as in "whosthere scan".
+func liveScan() ([]scandiff.Device, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := config.LoadForMode(config.ModeCLI, whosthereFlags)
+	if err != nil {
+		return nil, err
+	}
+	store, err := annotations.OpenDefault()
+	if err != nil {
+		return nil, err
+	}
+	eng, err := core.BuildEngine(cfg, discovery.NoOpLogger{})
+	if err != nil {
+		return nil, err
+	}
+
+	// The spinner goes to stderr, so the differences can still be redirected.
+	var spinner *output.Spinner
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		spinner = output.NewSpinner(os.Stderr, "Scanning network...", cfg.ScanTimeout)
+		spinner.Start()
+	}
+	results, err := eng.Scan(ctx)
+	if spinner != nil {
+		spinner.Stop()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range results.Devices {
+		store.ApplyTo(d)
+	}
+	return scandiff.FromResults(results), nil
+}
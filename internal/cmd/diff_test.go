@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDiffCommand(t *testing.T) {
+	cmd := NewDiffCommand()
+
+	assert.Equal(t, "diff", cmd.Name())
+	assert.NotEmpty(t, cmd.Long)
+	for _, name := range []string{"json", "pretty", "markdown", "severity", "fail-on"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "flag %s should exist", name)
+	}
+	assert.Error(t, cmd.Args(cmd, nil), "a scan result is required")
+	assert.Error(t, cmd.Args(cmd, []string{"a.json", "b.json", "c.json"}), "at most two scan results")
+}
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.json")
+	newPath := filepath.Join(dir, "new.json")
+	require.NoError(t, os.WriteFile(oldPath, []byte(`{"devices":[
+		{"ip":"192.168.1.10","mac":"aa:bb:cc:00:00:01","displayName":"nas.local"},
+		{"ip":"192.168.1.11","mac":"aa:bb:cc:00:00:02"}]}`), 0o644))
+	require.NoError(t, os.WriteFile(newPath, []byte(`{"devices":[
+		{"ip":"192.168.1.10","mac":"aa:bb:cc:00:00:01","displayName":"storage.local"},
+		{"ip":"192.168.1.12","mac":"aa:bb:cc:00:00:03"}]}`), 0o644))
+
+	run := func(args ...string) (string, error) {
+		cmd := NewDiffCommand()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(&buf)
+		cmd.SetArgs(append([]string{oldPath, newPath}, args...))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	out, err := run("--markdown")
+	require.NoError(t, err)
+	assert.Contains(t, out, "**1 added, 1 removed, 1 changed**")
+	assert.Contains(t, out, "| changed | info | 192.168.1.10 | aa:bb:cc:00:00:01 | storage.local | name: nas.local → storage.local |")
+
+	_, err = run("--fail-on=warning")
+	assert.EqualError(t, err, "1 of 3 differences rated warning or higher")
+
+	_, err = run("--fail-on=warning", "--severity=added=info")
+	assert.NoError(t, err)
+
+	_, err = run("--json", "--markdown")
+	assert.Error(t, err)
+	_, err = run("--fail-on=urgent")
+	assert.Error(t, err)
+}
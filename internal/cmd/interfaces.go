@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/spf13/cobra"
+)
+
+func NewInterfacesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "interfaces",
+		Short: "List network interfaces and show which one is used by default",
+		Long: `List the network interfaces of this host.
+
+Unless an interface is configured (--interface or network_interface), the
+interface of the default route with the lowest metric is used. Without a
+routing table the interface used to reach the internet is used, and otherwise
+the first interface that is up, skipping virtual interfaces such as docker,
+veth, br- and virbr.` + magenta + `
+
+Examples:` + reset + `
+  whosthere interfaces
+`,
+		Args: cobra.NoArgs,
+		RunE: runInterfaces,
+	}
+}
+
+func runInterfaces(cmd *cobra.Command, _ []string) error {
+	cfg, err := config.LoadForMode(config.ModeCLI, whosthereFlags)
+	if err != nil {
+		return err
+	}
+
+	candidates, err := discovery.InterfaceCandidates()
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	if err := printInterfaces(w, candidates); err != nil {
+		return err
+	}
+	if cfg.NetworkInterface != "" {
+		_, err = fmt.Fprintf(w, "\nConfigured interface %s is used instead of the default interface\n", cfg.NetworkInterface)
+	}
+	return err
+}
+
+// printInterfaces prints one row per interface candidate.
+func printInterfaces(w io.Writer, candidates []discovery.InterfaceCandidate) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "NAME\tIPV4\tMAC\tDEFAULT\tREASON")
+	_, _ = fmt.Fprintln(tw, "────\t────\t───\t───────\t──────")
+
+	for _, c := range candidates {
+		ipv4 := "-"
+		if c.IPv4Net != nil {
+			ipv4 = c.IPv4Net.String()
+		}
+		mac := c.Interface.HardwareAddr.String()
+		if mac == "" {
+			mac = "-"
+		}
+		chosen := ""
+		if c.Chosen {
+			chosen = "*"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Interface.Name, ipv4, mac, chosen, c.Reason)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInterfacesCommand(t *testing.T) {
+	cmd := NewInterfacesCommand()
+
+	assert.Equal(t, "interfaces", cmd.Use)
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+	assert.NotNil(t, cmd.RunE)
+}
+
+func TestPrintInterfaces(t *testing.T) {
+	_, ipNet, err := net.ParseCIDR("192.168.1.10/24")
+	require.NoError(t, err)
+	mac, err := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, printInterfaces(&buf, []discovery.InterfaceCandidate{
+		{Interface: net.Interface{Name: "lo"}, Reason: "loopback interface"},
+		{Interface: net.Interface{Name: "eth0", HardwareAddr: mac}, IPv4Net: ipNet, Chosen: true, Reason: "default route via 192.168.1.1 (metric 100)"},
+	}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[0], "NAME")
+	assert.Regexp(t, `^lo\s+-\s+-\s+loopback interface$`, lines[2])
+	assert.Regexp(t, `^eth0\s+192\.168\.1\.0/24\s+aa:bb:cc:dd:ee:ff\s+\*\s+default route via 192\.168\.1\.1 \(metric 100\)$`, lines[3])
+}
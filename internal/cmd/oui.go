@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/oui"
+	"github.com/spf13/cobra"
+)
+
+func NewOUICommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "oui",
+		Short: "Manage the OUI registry used to resolve manufacturers",
+		Long: `Manage the OUI registry used to resolve manufacturers from MAC addresses.
+
+The registry is refreshed from oui.update_url when it is older than
+oui.max_age. Set oui.refresh to false to never reach out to the network
+automatically, and run "whosthere oui update" to refresh on demand.
+
+Vendors in oui_overrides.yaml in the config directory, or the file set via
+oui.overrides_file, take precedence over the IEEE registries.`,
+		Args: cobra.NoArgs,
+	}
+	cmd.AddCommand(newOUIUpdateCommand(), newOUILookupCommand())
+	return cmd
+}
+
+func newOUIUpdateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "update",
+		Short: "Refresh the OUI registry now",
+		Long: `Download the OUI registries from oui.update_url and cache them in the
+state directory, regardless of their age and of oui.refresh.
+
+Registries that fail to download keep their current data.` + magenta + `
+
+Examples:` + reset + `
+  whosthere oui update
+  whosthere oui update --config=/etc/whosthere/config.yaml
+`,
+		Args: cobra.NoArgs,
+		RunE: runOUIUpdate,
+	}
+}
+
+func runOUIUpdate(cmd *cobra.Command, _ []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	reg, err := loadOUIRegistry()
+	if err != nil {
+		return err
+	}
+
+	refreshErr := reg.Refresh(ctx)
+	if err := printOUISources(cmd.OutOrStdout(), reg.Sources()); err != nil {
+		return err
+	}
+	if refreshErr != nil {
+		return fmt.Errorf("refresh OUI registry: %w", refreshErr)
+	}
+	return nil
+}
+
+// loadOUIRegistry builds the OUI registry from the configuration, including the
+// config file, so its update URL and overrides file are used. The registry is
+// not refreshed automatically.
+func loadOUIRegistry() (*oui.Registry, error) {
+	cfg, err := config.LoadForMode(config.ModeApp, whosthereFlags)
+	if err != nil {
+		return nil, err
+	}
+	return core.BuildOUIRegistry(cfg, discovery.NoOpLogger{}, oui.WithAutoRefresh(false))
+}
+
+// printOUISources prints one row per registry with its number of entries and source.
+func printOUISources(w io.Writer, sources []oui.SourceInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "FILE\tENTRIES\tSOURCE")
+	_, _ = fmt.Fprintln(tw, "────\t───────\t──────")
+
+	for _, s := range sources {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\n", s.File, s.Entries, s.URL)
+	}
+
+	return tw.Flush()
+}
+
+func newOUILookupCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lookup <mac>...",
+		Short: "Look up the vendor of MAC addresses",
+		Long: `Look up the vendor of one or more MAC addresses or prefixes and show which
+source answered: the overrides file or one of the IEEE registries.` + magenta + `
+
+Examples:` + reset + `
+  whosthere oui lookup AC:84:C6:12:34:56
+  whosthere oui lookup 70-B3-D5-F1-23-45 b827eb
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runOUILookup,
+	}
+}
+
+func runOUILookup(cmd *cobra.Command, args []string) error {
+	reg, err := loadOUIRegistry()
+	if err != nil {
+		return err
+	}
+
+	return printOUILookups(cmd.OutOrStdout(), reg, args)
+}
+
+// printOUILookups prints one row per MAC address with its vendor and the source that answered.
+func printOUILookups(w io.Writer, reg *oui.Registry, macs []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "MAC\tVENDOR\tPREFIX\tSOURCE")
+	_, _ = fmt.Fprintln(tw, "───\t──────\t──────\t──────")
+
+	for _, mac := range macs {
+		m, ok := reg.Resolve(mac)
+		if !ok {
+			_, _ = fmt.Fprintf(tw, "%s\t-\t-\t-\n", mac)
+			continue
+		}
+		source := "ieee: " + m.Source
+		if m.Override {
+			source = "override: " + m.Source
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", mac, m.Organization, m.Prefix, source)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/oui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOUICommand(t *testing.T) {
+	cmd := NewOUICommand()
+
+	assert.Equal(t, "oui", cmd.Use)
+	update, _, err := cmd.Find([]string{"update"})
+	require.NoError(t, err)
+	assert.Equal(t, "update", update.Name())
+	assert.NotNil(t, update.RunE)
+	lookup, _, err := cmd.Find([]string{"lookup"})
+	require.NoError(t, err)
+	assert.Equal(t, "lookup", lookup.Name())
+}
+
+func TestPrintOUISources(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, printOUISources(&buf, []oui.SourceInfo{
+		{File: "oui.csv", URL: "https://mirror.example.com/oui/oui.csv", Entries: 38000},
+		{File: "mam.csv", URL: "https://mirror.example.com/oui28/mam.csv", Entries: 0},
+	}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[0], "ENTRIES")
+	assert.Regexp(t, `^oui\.csv\s+38000\s+https://mirror\.example\.com/oui/oui\.csv$`, lines[2])
+	assert.Regexp(t, `^mam\.csv\s+0\s+https://mirror\.example\.com/oui28/mam\.csv$`, lines[3])
+}
+
+func TestPrintOUILookups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oui_overrides.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("overrides:\n  \"B8:27:EB:12\": Acme\n"), 0o644))
+	reg, err := oui.New(context.Background(), oui.WithAutoRefresh(false), oui.WithOverridesFile(path))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, printOUILookups(&buf, reg, []string{"b8:27:eb:12:34:56", "B8-27-EB-00-00-01", "nope"}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.Regexp(t, `^b8:27:eb:12:34:56\s+Acme\s+B827EB12\s+override: `+regexp.QuoteMeta(path)+`$`, lines[2])
+	assert.Regexp(t, `^B8-27-EB-00-00-01\s+Raspberry Pi Foundation\s+B827EB\s+ieee: oui\.csv$`, lines[3])
+	assert.Regexp(t, `^nope\s+-\s+-\s+-$`, lines[4])
+}
+
+func TestOUICommands_UseConfigFile(t *testing.T) {
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(dir, "state"))
+	overrides := filepath.Join(dir, "overrides.yaml")
+	require.NoError(t, os.WriteFile(overrides, []byte("overrides:\n  \"B8:27:EB:12\": Acme\n"), 0o644))
+	configFile := filepath.Join(dir, "whosthere.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("oui:\n  update_url: "+srv.URL+"\n  overrides_file: "+overrides+"\n"), 0o644))
+
+	prev := whosthereFlags.ConfigFile
+	whosthereFlags.ConfigFile = configFile
+	defer func() { whosthereFlags.ConfigFile = prev }()
+
+	update := newOUIUpdateCommand()
+	var out bytes.Buffer
+	update.SetOut(&out)
+	assert.Error(t, runOUIUpdate(update, nil))
+	assert.Contains(t, requested, "/oui/oui.csv")
+	assert.Contains(t, out.String(), srv.URL+"/oui/oui.csv")
+
+	lookup := newOUILookupCommand()
+	out.Reset()
+	lookup.SetOut(&out)
+	require.NoError(t, runOUILookup(lookup, []string{"b8:27:eb:12:34:56"}))
+	assert.Contains(t, out.String(), "override: "+overrides)
+}
@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/output"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/ports"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// maxCIDRHosts caps the number of hosts a single CIDR target may expand to.
+const maxCIDRHosts = 1 << 16
+
+func NewPortScanCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "portscan <target>...",
+		Short: "Port scan hosts by IP, CIDR, MAC or name and output results to the console",
+		Long: `Port scan one or more hosts and output the results.
+
+Targets can be IP addresses, CIDR ranges, MAC addresses or device names. MAC
+addresses and names are resolved by running a discovery scan first.
+
+The ports to scan are taken from the port profile (--port-profile), or from
+--ports which accepts the same syntax as port profiles. All hosts share a
+single pool of --concurrency workers.
+
+Only scan hosts that you have permission to scan!` + magenta + `
+
+Examples:` + reset + `
+  whosthere portscan 192.168.1.1
+  whosthere portscan 192.168.1.0/24 --port-profile=web
+  whosthere portscan AA:BB:CC:DD:EE:FF printer --ports=T:80,443,9100,U:161
+  whosthere portscan 10.0.0.0/28 --ports=1-1024 --expect=22,443 --json
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runPortScan,
+	}
+
+	cmd.Flags().Bool("json", false, "Output results in JSON format")
+	cmd.Flags().Bool("pretty", false, "Pretty print output")
+	cmd.Flags().String("ports", "", "Ports to scan, overrides the port profile (e.g. --ports=22,80,8000-8100,U:53)")
+	cmd.Flags().Int("concurrency", core.DefaultPortScanWorkers, "Maximum number of ports probed concurrently across all hosts")
+	cmd.Flags().Bool("all", false, "Also report closed and filtered ports")
+	cmd.Flags().String("expect", "", "Ports expected to be open, exit with an error if any other port is open (e.g. --expect=22,443)")
+
+	return cmd
+}
+
+func runPortScan(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := config.LoadForMode(config.ModeApp, whosthereFlags)
+	if err != nil {
+		return err
+	}
+
+	spec, err := portScanSpec(cmd, cfg)
+	if err != nil {
+		return err
+	}
+	var expected *ports.Spec
+	if s, _ := cmd.Flags().GetString("expect"); s != "" {
+		e, err := ports.Parse(s)
+		if err != nil {
+			return fmt.Errorf("invalid --expect: %w", err)
+		}
+		expected = &e
+	}
+
+	interactive := term.IsTerminal(int(os.Stdout.Fd()))
+
+	discover := func(ctx context.Context) ([]*discovery.Device, error) {
+		eng, err := core.BuildEngine(cfg, discovery.NoOpLogger{})
+		if err != nil {
+			return nil, err
+		}
+		var spinner *output.Spinner
+		if interactive {
+			spinner = output.NewSpinner(os.Stdout, "Resolving targets...", cfg.ScanTimeout)
+			spinner.Start()
+		}
+		results, err := eng.Scan(ctx)
+		if spinner != nil {
+			spinner.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return results.Devices, nil
+	}
+
+	devices, err := resolveTargets(ctx, args, discover)
+	if err != nil {
+		return err
+	}
+
+	iface, err := discovery.NewInterfaceInfo(cfg.NetworkInterface)
+	if err != nil {
+		return err
+	}
+	workers, _ := cmd.Flags().GetInt("concurrency")
+	if workers <= 0 {
+		return errors.New("--concurrency must be > 0")
+	}
+	scanner, err := core.BuildPortScanner(cfg, iface, discovery.WithPortWorkers(workers))
+	if err != nil {
+		return err
+	}
+
+	var spinner *output.Spinner
+	if interactive {
+		total := maxPortScanDuration(len(devices)*spec.Len(), workers, cfg.PortScanner.Timeout)
+		spinner = output.NewSpinner(os.Stdout, fmt.Sprintf("Port scanning %d host(s)...", len(devices)), total)
+		spinner.Start()
+	}
+
+	all, _ := cmd.Flags().GetBool("all")
+	results, err := portScanHosts(ctx, scanner, devices, spec, all)
+
+	if spinner != nil {
+		spinner.Stop()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	names, err := cfg.PortScanner.ServiceRegistry()
+	if err != nil {
+		return err
+	}
+	format, opts := parseScanSpecificFlags(cmd)
+	out, err := output.NewOutput(format, append(opts, output.WithServiceNames(names))...)
+	if err != nil {
+		return err
+	}
+	if err := out.PrintPorts(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if expected != nil {
+		if unexpected := unexpectedOpenPorts(results.Devices, *expected); len(unexpected) > 0 {
+			return fmt.Errorf("unexpected open ports: %s", strings.Join(unexpected, ", "))
+		}
+	}
+	return nil
+}
+
+// portScanSpec returns the ports from --ports, or from the configured port profile.
+func portScanSpec(cmd *cobra.Command, cfg *config.Config) (ports.Spec, error) {
+	if s, _ := cmd.Flags().GetString("ports"); s != "" {
+		spec, err := ports.Parse(s)
+		if err != nil {
+			return ports.Spec{}, fmt.Errorf("invalid --ports: %w", err)
+		}
+		return spec, nil
+	}
+	return cfg.PortScanner.ResolveProfile(cfg.PortScanner.Profile)
+}
+
+// resolveTargets turns IPs, CIDRs, MACs and device names into a list of
+// devices to scan, deduplicated by IP and in argument order. discover is only
+// called when a target needs it (MACs and names), or to enrich IP targets with
+// names once it already ran.
+func resolveTargets(ctx context.Context, args []string, discover func(context.Context) ([]*discovery.Device, error)) ([]*discovery.Device, error) {
+	var ips []netip.Addr
+	var lookups []string
+	for _, arg := range args {
+		arg = strings.TrimSpace(arg)
+		if addr, err := netip.ParseAddr(arg); err == nil {
+			ips = append(ips, addr.Unmap())
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(arg); err == nil {
+			hosts, err := expandPrefix(prefix)
+			if err != nil {
+				return nil, err
+			}
+			ips = append(ips, hosts...)
+			continue
+		}
+		if arg == "" {
+			return nil, errors.New("port scan target cannot be empty")
+		}
+		lookups = append(lookups, arg)
+	}
+
+	var discovered []*discovery.Device
+	if len(lookups) > 0 {
+		var err error
+		if discovered, err = discover(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range lookups {
+		matches := matchDevices(discovered, name)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("could not resolve %q to a discovered device", name)
+		}
+		for _, d := range matches {
+			if addr, ok := netip.AddrFromSlice(d.IP()); ok {
+				ips = append(ips, addr.Unmap())
+			}
+		}
+	}
+
+	byIP := make(map[netip.Addr]*discovery.Device, len(discovered))
+	for _, d := range discovered {
+		if addr, ok := netip.AddrFromSlice(d.IP()); ok {
+			byIP[addr.Unmap()] = d
+		}
+	}
+
+	seen := make(map[netip.Addr]struct{}, len(ips))
+	devices := make([]*discovery.Device, 0, len(ips))
+	for _, ip := range ips {
+		if _, ok := seen[ip]; ok {
+			continue
+		}
+		seen[ip] = struct{}{}
+		if d, ok := byIP[ip]; ok {
+			devices = append(devices, d.Copy())
+			continue
+		}
+		devices = append(devices, discovery.NewDevice(net.IP(ip.AsSlice())))
+	}
+	return devices, nil
+}
+
+// matchDevices returns the devices whose MAC address or display name equals target.
+func matchDevices(devices []*discovery.Device, target string) []*discovery.Device {
+	var out []*discovery.Device
+	if mac, err := net.ParseMAC(target); err == nil {
+		for _, d := range devices {
+			if m, err := net.ParseMAC(d.MAC()); err == nil && m.String() == mac.String() {
+				out = append(out, d)
+			}
+		}
+		return out
+	}
+	for _, d := range devices {
+		if strings.EqualFold(d.DisplayName(), target) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// expandPrefix lists the host addresses in prefix. For IPv4 prefixes shorter
+// than /31 the network and broadcast addresses are skipped.
+func expandPrefix(prefix netip.Prefix) ([]netip.Addr, error) {
+	prefix = prefix.Masked()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 16 {
+		return nil, fmt.Errorf("CIDR %s is too large, at most %d hosts can be scanned at once", prefix, maxCIDRHosts)
+	}
+	skipEdges := prefix.Addr().Is4() && hostBits > 1
+
+	out := make([]netip.Addr, 0, 1<<hostBits)
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		out = append(out, addr)
+	}
+	if skipEdges {
+		out = out[1 : len(out)-1]
+	}
+	return out, nil
+}
+
+// portScanHosts scans spec on every device and records the results on the devices.
+// Unless all is set, only open and open|filtered ports are kept.
+func portScanHosts(ctx context.Context, scanner *discovery.PortScanner, devices []*discovery.Device, spec ports.Spec, all bool) (*discovery.ScanResults, error) {
+	start := time.Now()
+
+	targets := make([]string, len(devices))
+	byTarget := make(map[string]*discovery.Device, len(devices))
+	for i, d := range devices {
+		targets[i] = d.IP().String()
+		byTarget[targets[i]] = d
+		d.SetPortResults(nil)
+	}
+
+	results, err := scanner.ScanHosts(ctx, targets, spec.TCP, spec.UDP)
+	if err != nil {
+		return nil, err
+	}
+	for r := range results {
+		if !all && r.State != discovery.PortOpen && r.State != discovery.PortOpenFiltered {
+			continue
+		}
+		byTarget[r.Target].AddPortResult(r.PortResult)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, d := range devices {
+		d.SetLastPortScan(now)
+	}
+
+	return &discovery.ScanResults{
+		Devices:    devices,
+		Stats:      &discovery.ScanStats{Count: len(devices), Duration: time.Since(start)},
+		CapturedAt: now,
+	}, nil
+}
+
+// unexpectedOpenPorts lists the open ports that are not part of expected, as "ip port/proto".
+func unexpectedOpenPorts(devices []*discovery.Device, expected ports.Spec) []string {
+	allowed := map[string]map[int]bool{"tcp": {}, "udp": {}}
+	for _, p := range expected.TCP {
+		allowed["tcp"][p] = true
+	}
+	for _, p := range expected.UDP {
+		allowed["udp"][p] = true
+	}
+
+	var out []string
+	for _, d := range devices {
+		open := d.OpenPorts()
+		for _, proto := range []string{"tcp", "udp"} {
+			for _, p := range open[proto] {
+				if !allowed[proto][p] {
+					out = append(out, fmt.Sprintf("%s %d/%s", d.IP(), p, proto))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// maxPortScanDuration is the worst case duration of probing n ports with the
+// given number of workers, assuming every probe runs into the timeout.
+func maxPortScanDuration(n, workers int, timeout time.Duration) time.Duration {
+	if workers <= 0 {
+		workers = 1
+	}
+	rounds := (n + workers - 1) / workers
+	return time.Duration(rounds) * timeout
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/ports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPortScanCommand(t *testing.T) {
+	cmd := NewPortScanCommand()
+
+	assert.Equal(t, "portscan", cmd.Name())
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+	assert.NotNil(t, cmd.RunE)
+	for _, name := range []string{"json", "pretty", "ports", "concurrency", "all", "expect"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "flag %s should exist", name)
+	}
+	assert.Error(t, cmd.Args(cmd, nil), "at least one target is required")
+}
+
+func TestExpandPrefix(t *testing.T) {
+	hosts, err := expandPrefix(netip.MustParsePrefix("192.168.1.7/30"))
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("192.168.1.5"), netip.MustParseAddr("192.168.1.6")}, hosts)
+
+	hosts, err = expandPrefix(netip.MustParsePrefix("10.0.0.0/31"))
+	require.NoError(t, err)
+	assert.Len(t, hosts, 2)
+
+	hosts, err = expandPrefix(netip.MustParsePrefix("10.0.0.1/32"))
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1")}, hosts)
+
+	_, err = expandPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	assert.Error(t, err)
+}
+
+func TestResolveTargets(t *testing.T) {
+	printer := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	printer.SetDisplayName("Printer")
+	printer.SetMAC("aa:bb:cc:dd:ee:ff")
+	router := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	router.SetDisplayName("router")
+
+	calls := 0
+	discover := func(context.Context) ([]*discovery.Device, error) {
+		calls++
+		return []*discovery.Device{printer, router}, nil
+	}
+
+	t.Run("ips and cidrs skip discovery", func(t *testing.T) {
+		calls = 0
+		devices, err := resolveTargets(context.Background(), []string{"10.0.0.1", "10.0.0.0/30", "10.0.0.1"}, discover)
+		require.NoError(t, err)
+		assert.Equal(t, 0, calls)
+		require.Len(t, devices, 2)
+		assert.Equal(t, "10.0.0.1", devices[0].IP().String())
+		assert.Equal(t, "10.0.0.2", devices[1].IP().String())
+	})
+
+	t.Run("mac and name are resolved through discovery", func(t *testing.T) {
+		calls = 0
+		devices, err := resolveTargets(context.Background(), []string{"AA-BB-CC-DD-EE-FF", "ROUTER", "192.168.1.20"}, discover)
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		require.Len(t, devices, 2)
+		assert.Equal(t, "192.168.1.20", devices[0].IP().String())
+		assert.Equal(t, "Printer", devices[0].DisplayName())
+		assert.Equal(t, "192.168.1.1", devices[1].IP().String())
+	})
+
+	t.Run("unknown name", func(t *testing.T) {
+		_, err := resolveTargets(context.Background(), []string{"nas"}, discover)
+		assert.ErrorContains(t, err, `"nas"`)
+	})
+}
+
+func TestUnexpectedOpenPorts(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("10.0.0.1"))
+	d.SetPortResults([]discovery.PortResult{
+		{Port: 22, Protocol: "tcp", State: discovery.PortOpen},
+		{Port: 8080, Protocol: "tcp", State: discovery.PortOpen},
+		{Port: 443, Protocol: "tcp", State: discovery.PortClosed},
+		{Port: 161, Protocol: "udp", State: discovery.PortOpen},
+	})
+
+	expected, err := ports.Parse("22,443,U:161")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1 8080/tcp"}, unexpectedOpenPorts([]*discovery.Device{d}, expected))
+}
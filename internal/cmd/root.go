@@ -73,6 +73,11 @@ func AddCommands(root *cobra.Command) {
 		NewVersionCommand(),
 		NewDaemonCommand(),
 		NewScanCommand(),
+		NewPortScanCommand(),
+		NewInterfacesCommand(),
+		NewOUICommand(),
+		NewDiffCommand(),
+		NewViewCommand(),
 	)
 }
 
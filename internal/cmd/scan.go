@@ -2,12 +2,16 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
 	"github.com/ramonvermeulen/whosthere/internal/core/output"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/classify"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
@@ -19,19 +23,27 @@ func NewScanCommand() *cobra.Command {
 		Long: `Run exactly one discovery scan.
 
 By default, all scanners (mDNS, SSDP, ARP) and the sweeper are enabled.
-Use --no-xxx flags to disable specific scanners.` + magenta + `
+Use --no-xxx flags to disable specific scanners.
+
+Devices missing from the known devices file and not trusted or named are tagged
+"unknown". With --fail-on-unknown the command exits with status 1 when any
+unknown device is found, e.g. for cron jobs or CI checks.` + magenta + `
 
 Examples:` + reset + `
   whosthere scan
   whosthere scan --sweeper=false
   whosthere scan --mdns=false --ssdp=false
   whosthere scan --timeout=5s --json --pretty
+  whosthere scan --category=printer,camera
+  whosthere scan --fail-on-unknown --known-devices=/etc/whosthere/known_devices.yaml
 `,
 		RunE: runScan,
 	}
 
 	cmd.Flags().Bool("json", false, "Output results in JSON format")
 	cmd.Flags().Bool("pretty", false, "Pretty print output")
+	cmd.Flags().StringSlice("category", nil, "Only output devices of these categories (e.g. --category=printer,tv)")
+	cmd.Flags().Bool("fail-on-unknown", false, "Exit with status 1 when devices missing from the known devices are found")
 
 	return cmd
 }
@@ -44,7 +56,33 @@ func runScan(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	eng, err := core.BuildEngine(cfg, discovery.NoOpLogger{})
+	names, _ := cmd.Flags().GetStringSlice("category")
+	categories, err := parseCategories(names)
+	if err != nil {
+		return err
+	}
+
+	ruleSet, err := core.BuildRules(cfg)
+	if err != nil {
+		return err
+	}
+	var engOpts []discovery.Option
+	if ruleSet != nil {
+		engOpts = append(engOpts, discovery.WithRules(ruleSet))
+	}
+
+	store, err := annotations.OpenDefault()
+	if err != nil {
+		return err
+	}
+	known, err := core.BuildKnownDevices(cfg, store)
+	if err != nil {
+		return err
+	}
+	engOpts = append(engOpts, discovery.WithKnownDevices(known))
+	failOnUnknown, _ := cmd.Flags().GetBool("fail-on-unknown")
+
+	eng, err := core.BuildEngine(cfg, discovery.NoOpLogger{}, engOpts...)
 	if err != nil {
 		return err
 	}
@@ -57,15 +95,32 @@ func runScan(cmd *cobra.Command, _ []string) error {
 		spinner.Start()
 	}
 
+	alerts := collectAlerts(eng.Events)
 	results, err := eng.Scan(ctx)
 
 	if spinner != nil {
 		spinner.Stop()
 	}
+	for _, a := range alerts() {
+		_, _ = fmt.Fprintf(os.Stderr, "alert [%s] %s: %s\n", a.Rule, a.Device.IP(), a.Message)
+	}
 
 	if err != nil {
 		return err
 	}
+	for _, d := range results.Devices {
+		store.ApplyTo(d)
+	}
+	if err := recordHistory(cfg, results); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	var unknownErr error
+	if failOnUnknown {
+		unknownErr = unknownDevicesError(known.Unknown(results.Devices))
+	}
+	if len(categories) > 0 {
+		results.Devices = filterByCategory(results.Devices, categories)
+	}
 
 	format, opts := parseScanSpecificFlags(cmd)
 	out, err := output.NewOutput(format, opts...)
@@ -73,7 +128,107 @@ func runScan(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	return out.PrintDevices(os.Stdout, results)
+	if err := out.PrintDevices(os.Stdout, results); err != nil {
+		return err
+	}
+	return unknownErr
+}
+
+// recordHistory adds the scan results to the device inventory, if enabled.
+func recordHistory(cfg *config.Config, results *discovery.ScanResults) error {
+	hist, err := core.BuildHistory(cfg, discovery.NoOpLogger{})
+	if err != nil {
+		return err
+	}
+	for _, d := range results.Devices {
+		if err := hist.Observe(d); err != nil {
+			return err
+		}
+	}
+	return hist.RecordScan(results.Stats)
+}
+
+// unknownDevicesError returns an error listing the unknown devices, or nil when there are none.
+func unknownDevicesError(devices []*discovery.Device) error {
+	if len(devices) == 0 {
+		return nil
+	}
+	list := make([]string, 0, len(devices))
+	for _, d := range devices {
+		if mac := d.MAC(); mac != "" {
+			list = append(list, fmt.Sprintf("%s (%s)", d.IP(), mac))
+		} else {
+			list = append(list, d.IP().String())
+		}
+	}
+	return fmt.Errorf("unknown devices: %s", strings.Join(list, ", "))
+}
+
+// collectAlerts collects the rule alerts emitted on events in the background.
+// The returned function stops collecting, after draining the buffered events,
+// and returns the alerts.
+func collectAlerts(events <-chan discovery.Event) func() []discovery.Alert {
+	var alerts []discovery.Alert
+	collect := func(ev discovery.Event) {
+		if ev.Type == discovery.EventAlert && ev.Alert != nil {
+			alerts = append(alerts, *ev.Alert)
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case ev := <-events:
+				collect(ev)
+			case <-stop:
+				for {
+					select {
+					case ev := <-events:
+						collect(ev)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return func() []discovery.Alert {
+		close(stop)
+		<-done
+		return alerts
+	}
+}
+
+// parseCategories resolves category names as accepted by classify.Parse.
+func parseCategories(names []string) (map[classify.Category]bool, error) {
+	out := make(map[classify.Category]bool, len(names))
+	for _, name := range names {
+		c, ok := classify.Parse(name)
+		if !ok {
+			valid := make([]string, 0, len(classify.Categories()))
+			for _, c := range classify.Categories() {
+				valid = append(valid, string(c))
+			}
+			return nil, fmt.Errorf("unknown category %q (available: %s)", name, strings.Join(valid, ", "))
+		}
+		out[c] = true
+	}
+	return out, nil
+}
+
+// filterByCategory keeps the devices classified as one of categories.
+func filterByCategory(devices []*discovery.Device, categories map[classify.Category]bool) []*discovery.Device {
+	out := make([]*discovery.Device, 0, len(devices))
+	for _, d := range devices {
+		if categories[classify.Category(d.Category())] {
+			out = append(out, d)
+		}
+	}
+	return out
 }
 
 func parseScanSpecificFlags(cmd *cobra.Command) (output.Format, []output.Option) {
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"net"
 	"testing"
 
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/classify"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -49,3 +52,46 @@ func TestNewScanCommand_HasAllInheritedPersistentFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCategories(t *testing.T) {
+	got, err := parseCategories([]string{"printer", "TV"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[classify.Category]bool{classify.Printer: true, classify.Media: true}, got)
+
+	_, err = parseCategories([]string{"toaster"})
+	assert.ErrorContains(t, err, `unknown category "toaster"`)
+}
+
+func TestFilterByCategory(t *testing.T) {
+	printer := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	printer.SetCategory(string(classify.Printer), 0.9)
+	unknown := discovery.NewDevice(net.ParseIP("192.168.1.11"))
+
+	got := filterByCategory([]*discovery.Device{printer, unknown}, map[classify.Category]bool{classify.Printer: true})
+	assert.Equal(t, []*discovery.Device{printer}, got)
+}
+
+func TestCollectAlerts(t *testing.T) {
+	events := make(chan discovery.Event, 4)
+	alerts := collectAlerts(events)
+
+	d := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	events <- discovery.NewDeviceEvent(d)
+	events <- discovery.NewAlertEvent(discovery.Alert{Rule: "telnet", Message: "telnet open", Device: d})
+	events <- discovery.NewScanCompletedEvent(&discovery.ScanStats{})
+
+	got := alerts()
+	assert.Len(t, got, 1)
+	assert.Equal(t, "telnet", got[0].Rule)
+}
+
+func TestUnknownDevicesError(t *testing.T) {
+	assert.NoError(t, unknownDevicesError(nil))
+
+	withMAC := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	withMAC.SetMAC("aa:bb:cc:dd:ee:ff")
+	withoutMAC := discovery.NewDevice(net.ParseIP("192.168.1.11"))
+
+	err := unknownDevicesError([]*discovery.Device{withMAC, withoutMAC})
+	assert.EqualError(t, err, "unknown devices: 192.168.1.10 (aa:bb:cc:dd:ee:ff), 192.168.1.11")
+}
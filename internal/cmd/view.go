@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/logging"
+	"github.com/ramonvermeulen/whosthere/internal/core/version"
+	"github.com/ramonvermeulen/whosthere/internal/ui"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/spf13/cobra"
+)
+
+func NewViewCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "view <file.json>",
+		Short: "Browse saved scan results in the TUI without scanning",
+		Long: `Open scan results written by "whosthere scan --json", or exported from the TUI,
+in the TUI without discovering devices, e.g. to browse a scan of a remote site.
+
+Search, device details, copying and exporting work as usual. The header shows
+that a read-only snapshot is shown and when it was captured. Port scans,
+labels, annotations and trusting devices are unavailable.` + magenta + `
+
+Examples:` + reset + `
+  whosthere view devices.json
+  whosthere view whosthere-export-20260301-120000.json
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: runView,
+	}
+}
+
+func runView(_ *cobra.Command, args []string) error {
+	results, err := readScanResults(args[0])
+	if err != nil {
+		return err
+	}
+	logger, err := logging.New(false)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadForMode(config.ModeApp, whosthereFlags)
+	if err != nil {
+		return err
+	}
+
+	app := ui.NewSnapshotApp(cfg, logger, version.Version, results)
+	if err := app.Run(); err != nil {
+		logger.Error("app run failed", "error", err)
+		return err
+	}
+	return nil
+}
+
+// readScanResults reads the scan results stored at path.
+func readScanResults(path string) (*discovery.ScanResults, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	results, err := discovery.DecodeScanResults(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewViewCommand(t *testing.T) {
+	cmd := NewViewCommand()
+
+	assert.Equal(t, "view", cmd.Name())
+	assert.NotEmpty(t, cmd.Long)
+	assert.NotNil(t, cmd.RunE)
+	assert.Error(t, cmd.Args(cmd, nil), "a scan result is required")
+}
+
+func TestReadScanResults(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "devices.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"schemaVersion":1,"capturedAt":"2026-03-01T12:00:00Z",
+		"devices":[{"ip":"192.168.1.10","mac":"aa:bb:cc:dd:ee:ff","displayName":"nas.local"}],
+		"stats":{"count":1,"duration":"2.0s"}}`), 0o644))
+
+	results, err := readScanResults(path)
+	require.NoError(t, err)
+	require.Len(t, results.Devices, 1)
+	assert.Equal(t, "nas.local", results.Devices[0].DisplayName())
+	assert.Equal(t, "2026-03-01T12:00:00Z", results.CapturedAt.Format("2006-01-02T15:04:05Z07:00"))
+
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"devices":`), 0o644))
+	_, err = readScanResults(invalid)
+	assert.ErrorContains(t, err, invalid)
+
+	_, err = readScanResults(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
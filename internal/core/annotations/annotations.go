@@ -0,0 +1,247 @@
+// Package annotations stores the custom names, labels, notes, owners and tags
+// given to devices by hand, so they survive restarts and take precedence over discovered data.
+package annotations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/paths"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/rules"
+)
+
+const (
+	// NotesKey is the extra data key holding the notes of an annotated device.
+	NotesKey = "notes"
+	// OwnerKey is the extra data key holding the owner of an annotated device.
+	OwnerKey = "owner"
+
+	// defaultFile is the name of the annotations file in the state directory.
+	defaultFile = "annotations.json"
+)
+
+// Annotation holds the data given to a device by hand.
+type Annotation struct {
+	Name  string   `json:"name,omitempty"`
+	Label string   `json:"label,omitempty"`
+	Notes string   `json:"notes,omitempty"`
+	Owner string   `json:"owner,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// IsZero reports whether the annotation holds no data.
+func (a Annotation) IsZero() bool {
+	return a.Name == "" && a.Label == "" && a.Notes == "" && a.Owner == "" && len(a.Tags) == 0
+}
+
+// normalize trims all fields and sorts the tags, dropping empty and duplicate ones.
+func (a Annotation) normalize() Annotation {
+	out := Annotation{
+		Name:  strings.TrimSpace(a.Name),
+		Label: strings.TrimSpace(a.Label),
+		Notes: strings.TrimSpace(a.Notes),
+		Owner: strings.TrimSpace(a.Owner),
+	}
+	for _, tag := range a.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			out.Tags = append(out.Tags, tag)
+		}
+	}
+	slices.Sort(out.Tags)
+	out.Tags = slices.Compact(out.Tags)
+	return out
+}
+
+// Apply merges a into d: the name replaces the discovered display name, the
+// label replaces one set by a rule, label, notes and owner are stored in the
+// extra data and the tags are added.
+func Apply(d *discovery.Device, a Annotation) {
+	if a.Name != "" {
+		d.SetDisplayName(a.Name)
+	}
+	if a.Label != "" {
+		d.AddExtraData(rules.LabelKey, a.Label)
+	}
+	if a.Notes != "" {
+		d.AddExtraData(NotesKey, a.Notes)
+	}
+	if a.Owner != "" {
+		d.AddExtraData(OwnerKey, a.Owner)
+	}
+	for _, tag := range a.Tags {
+		d.AddTag(tag)
+	}
+}
+
+// Remove undoes Apply. The display name is cleared when it is the annotated
+// name, so the discovered name is filled in again on the next scan.
+func Remove(d *discovery.Device, a Annotation) {
+	if a.Name != "" && d.DisplayName() == a.Name {
+		d.SetDisplayName("")
+	}
+	data := d.ExtraData()
+	if a.Label != "" && data[rules.LabelKey] == a.Label {
+		delete(data, rules.LabelKey)
+	}
+	delete(data, NotesKey)
+	delete(data, OwnerKey)
+	d.SetExtraData(data)
+	for _, tag := range a.Tags {
+		d.RemoveTag(tag)
+	}
+}
+
+// Store holds annotations by device identity, see discovery.Device.Identity,
+// and persists them to a JSON file. The zero path keeps them in memory only.
+//
+// Thread-safe. A nil Store holds no annotations.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	entries map[string]Annotation
+}
+
+// DefaultPath returns the path of the annotations file in the state directory.
+func DefaultPath() (string, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, defaultFile), nil
+}
+
+// New creates an empty store persisting to path.
+func New(path string) *Store {
+	return &Store{path: path, entries: make(map[string]Annotation)}
+}
+
+// Open creates a store persisting to path, loading the annotations saved to
+// it before. A missing file holds no annotations.
+func Open(path string) (*Store, error) {
+	s := New(path)
+	if path == "" {
+		return s, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read annotations: %w", err)
+	}
+	if err := json.Unmarshal(raw, &s.entries); err != nil {
+		return nil, fmt.Errorf("parse annotations file %s: %w", path, err)
+	}
+	if s.entries == nil {
+		s.entries = make(map[string]Annotation)
+	}
+	return s, nil
+}
+
+// OpenDefault opens the store persisting to the annotations file in the state directory.
+func OpenDefault() (*Store, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Open(path)
+}
+
+// Get returns the annotation stored under key.
+func (s *Store) Get(key string) (Annotation, bool) {
+	if s == nil {
+		return Annotation{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.entries[key]
+	return a, ok
+}
+
+// All returns a copy of all annotations by key.
+func (s *Store) All() map[string]Annotation {
+	if s == nil {
+		return map[string]Annotation{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.entries)
+}
+
+// Lookup returns the annotation of d and the key it is stored under. Besides
+// the identity of d, the keys of its MAC and IP address are tried, since a
+// device could have been annotated before its MAC address or hostname was known.
+func (s *Store) Lookup(d *discovery.Device) (Annotation, string, bool) {
+	if s == nil || d == nil {
+		return Annotation{}, "", false
+	}
+	keys := []string{d.Identity()}
+	if mac := d.MAC(); mac != "" {
+		keys = append(keys, "mac:"+strings.ToLower(mac))
+	}
+	if ip := d.IP(); ip != nil {
+		keys = append(keys, "ip:"+ip.String())
+	}
+	for _, key := range keys {
+		if a, ok := s.Get(key); ok {
+			return a, key, true
+		}
+	}
+	return Annotation{}, "", false
+}
+
+// ApplyTo merges the annotation of d into d, if any, see Apply.
+func (s *Store) ApplyTo(d *discovery.Device) {
+	if a, _, ok := s.Lookup(d); ok {
+		Apply(d, a)
+	}
+}
+
+// Set stores a under key and saves the store, an empty annotation removes it.
+// It returns the normalized annotation.
+func (s *Store) Set(key string, a Annotation) (Annotation, error) {
+	if key == "" {
+		return Annotation{}, errors.New("annotation key must not be empty")
+	}
+	a = a.normalize()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a.IsZero() {
+		delete(s.entries, key)
+	} else {
+		s.entries[key] = a
+	}
+	return a, s.save()
+}
+
+// save writes the annotations to the file of the store, replacing it atomically.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("save annotations: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return fmt.Errorf("save annotations: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("save annotations: %w", err)
+	}
+	return nil
+}
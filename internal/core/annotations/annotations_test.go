@@ -0,0 +1,109 @@
+package annotations
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/rules"
+)
+
+func TestStore_SetPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "annotations.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+
+	saved, err := s.Set("mac:aa:bb:cc:dd:ee:ff", Annotation{Name: " NAS ", Owner: "ramon", Tags: []string{"storage", "", "backup", "storage"}})
+	if err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if saved.Name != "NAS" || strings.Join(saved.Tags, ",") != "backup,storage" {
+		t.Errorf("expected normalized annotation, got %+v", saved)
+	}
+	if _, err := s.Set("ip:192.168.1.9", Annotation{Notes: "temporary"}); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if _, err := s.Set("ip:192.168.1.9", Annotation{Name: "  "}); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	all := reopened.All()
+	if len(all) != 1 {
+		t.Fatalf("expected the empty annotation to be removed, got %v", all)
+	}
+	if a, ok := reopened.Get("mac:aa:bb:cc:dd:ee:ff"); !ok || a.Owner != "ramon" {
+		t.Errorf("expected persisted annotation, got %+v, %v", a, ok)
+	}
+}
+
+func TestOpen_InvalidFile(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("expected a missing file to hold no annotations, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Errorf("expected error for an invalid file")
+	}
+}
+
+func TestStore_LookupFallsBackToIP(t *testing.T) {
+	s := New("")
+	if _, err := s.Set("ip:192.168.1.20", Annotation{Name: "Printer"}); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+
+	d := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	d.SetMAC("B8:27:EB:12:34:56")
+	a, key, ok := s.Lookup(d)
+	if !ok || a.Name != "Printer" || key != "ip:192.168.1.20" {
+		t.Errorf("expected annotation by IP, got %+v, %q, %v", a, key, ok)
+	}
+
+	if _, err := s.Set("mac:b8:27:eb:12:34:56", Annotation{Name: "Pi"}); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if a, key, _ := s.Lookup(d); a.Name != "Pi" || key != "mac:b8:27:eb:12:34:56" {
+		t.Errorf("expected annotation by MAC to take precedence, got %+v, %q", a, key)
+	}
+
+	var nilStore *Store
+	if _, _, ok := nilStore.Lookup(d); ok {
+		t.Errorf("expected a nil store to hold no annotations")
+	}
+}
+
+func TestApplyAndRemove(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	d.SetDisplayName("printer.local")
+	d.AddTag("risk")
+	a := Annotation{Name: "Office printer", Label: "printers", Notes: "2nd floor", Owner: "it", Tags: []string{"office"}}
+
+	Apply(d, a)
+	if d.DisplayName() != "Office printer" {
+		t.Errorf("expected annotated name to take precedence, got %q", d.DisplayName())
+	}
+	if d.ExtraData()[NotesKey] != "2nd floor" || d.ExtraData()[OwnerKey] != "it" || d.ExtraData()[rules.LabelKey] != "printers" {
+		t.Errorf("expected label, notes and owner in extra data, got %v", d.ExtraData())
+	}
+	if got := strings.Join(d.Tags(), ","); got != "office,risk" {
+		t.Errorf("expected annotated tags, got %q", got)
+	}
+
+	Remove(d, a)
+	if d.DisplayName() != "" || len(d.ExtraData()) != 0 || strings.Join(d.Tags(), ",") != "risk" {
+		t.Errorf("expected annotation to be removed, got %q %v %v", d.DisplayName(), d.ExtraData(), d.Tags())
+	}
+}
@@ -0,0 +1,288 @@
+// Package autoscan implements the opt-in policy that automatically port scans
+// newly discovered or changed devices. Scans are bounded by a per-device
+// cooldown, a global concurrency and rate budget, and include/exclude filters.
+//
+// The cooldown starts at the last port scan of a device, automatic or manual,
+// which the history keeps across restarts. Manual scans are registered with
+// Begin and End, so a device is never scanned twice at the same time.
+package autoscan
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/devicefilter"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/ports"
+)
+
+const (
+	defaultCooldown      = 1 * time.Hour
+	defaultMaxConcurrent = 2
+	defaultRatePerMinute = 10
+
+	// queueSize bounds the number of devices waiting for a scan. Devices that
+	// do not fit are picked up again the next time discovery reports them.
+	queueSize = 256
+	// maxScanned bounds the number of devices whose last automatic scan is
+	// remembered. The device scanned longest ago is forgotten first, and only
+	// scanned again once its cooldown passed.
+	maxScanned = 4096
+)
+
+// Scanner runs the port scans, *discovery.PortScanner satisfies it.
+type Scanner interface {
+	ScanHosts(ctx context.Context, targets []string, tcp, udp []int) (<-chan discovery.HostPortResult, error)
+}
+
+// Policy decides which observed devices get port scanned and runs the scans.
+// Results are written to the observed devices themselves, so callers should
+// pass the canonical device instances (e.g. from the application state).
+type Policy struct {
+	scanner       Scanner
+	spec          ports.Spec
+	cooldown      time.Duration
+	maxConcurrent int
+	interval      time.Duration
+	include       *devicefilter.Filter
+	exclude       *devicefilter.Filter
+	logger        discovery.Logger
+	now           func() time.Time
+
+	queue chan *discovery.Device
+
+	mu        sync.Mutex
+	pending   map[string]struct{}
+	inFlight  map[string]struct{} // IPs being scanned, automatically or by hand
+	scanned   map[string]lastScan // IP -> last automatic scan, see maxScanned
+	nextStart time.Time
+}
+
+// lastScan is the last automatic scan of a device.
+type lastScan struct {
+	sig string // device signature at the scan
+	at  time.Time
+}
+
+// New creates a Policy that scans with scanner.
+func New(scanner Scanner, opts ...Option) (*Policy, error) {
+	if scanner == nil {
+		return nil, errors.New("autoscan: scanner cannot be nil")
+	}
+	p := &Policy{
+		scanner:       scanner,
+		cooldown:      defaultCooldown,
+		maxConcurrent: defaultMaxConcurrent,
+		interval:      time.Minute / defaultRatePerMinute,
+		include:       &devicefilter.Filter{},
+		exclude:       &devicefilter.Filter{},
+		logger:        discovery.NoOpLogger{},
+		now:           time.Now,
+		queue:         make(chan *discovery.Device, queueSize),
+		pending:       make(map[string]struct{}),
+		inFlight:      make(map[string]struct{}),
+		scanned:       make(map[string]lastScan),
+	}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Observe queues d for a scan when the policy allows it and reports whether it did.
+// A device qualifies when it matches the filters, is not queued or being scanned
+// already, automatically or by hand, its cooldown has passed and it was never
+// scanned automatically or changed (MAC, name or manufacturer) since.
+func (p *Policy) Observe(d *discovery.Device) bool {
+	if d == nil || d.IP() == nil {
+		return false
+	}
+	if p.include.Len() > 0 && !p.include.Match(d) {
+		return false
+	}
+	if p.exclude.Match(d) {
+		return false
+	}
+
+	ip := d.IP().String()
+	sig := signature(d)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.pending[ip]; ok {
+		return false
+	}
+	if _, ok := p.inFlight[ip]; ok {
+		return false
+	}
+	if p.coolingDown(d) {
+		return false
+	}
+	if prev, ok := p.scanned[ip]; ok && prev.sig == sig && !d.LastPortScan().IsZero() {
+		return false
+	}
+
+	select {
+	case p.queue <- d:
+		p.pending[ip] = struct{}{}
+		return true
+	default:
+		return false
+	}
+}
+
+// Begin registers a port scan of ip started by hand, so the policy does not
+// scan it at the same time, and reports whether it did. It reports false when ip
+// is being scanned already. Call End when the scan finished.
+func (p *Policy) Begin(ip string) bool {
+	if p == nil {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.inFlight[ip]; ok {
+		return false
+	}
+	p.inFlight[ip] = struct{}{}
+	return true
+}
+
+// End unregisters the port scan of ip registered by Begin.
+func (p *Policy) End(ip string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.inFlight, ip)
+}
+
+// coolingDown reports whether the last port scan of d is within the cooldown.
+func (p *Policy) coolingDown(d *discovery.Device) bool {
+	last := d.LastPortScan()
+	return !last.IsZero() && p.now().Sub(last) < p.cooldown
+}
+
+// Run scans queued devices until ctx is done, with at most MaxConcurrent scans
+// in flight and scan starts spaced according to the rate budget.
+func (p *Policy) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.maxConcurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.worker(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Policy) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-p.queue:
+			if !p.waitTurn(ctx) {
+				return
+			}
+			p.scan(ctx, d)
+		}
+	}
+}
+
+// waitTurn blocks until the rate budget allows the next scan to start.
+// It returns false when ctx is done first.
+func (p *Policy) waitTurn(ctx context.Context) bool {
+	p.mu.Lock()
+	now := p.now()
+	start := p.nextStart
+	if start.Before(now) {
+		start = now
+	}
+	p.nextStart = start.Add(p.interval)
+	p.mu.Unlock()
+
+	wait := start.Sub(now)
+	if wait <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// scan port scans d and stores the results on it, unless it is being scanned
+// by hand or was scanned while it was queued.
+func (p *Policy) scan(ctx context.Context, d *discovery.Device) {
+	ip := d.IP().String()
+	sig := signature(d)
+
+	p.mu.Lock()
+	delete(p.pending, ip)
+	_, busy := p.inFlight[ip]
+	if busy || p.coolingDown(d) {
+		p.mu.Unlock()
+		return
+	}
+	p.inFlight[ip] = struct{}{}
+	p.mu.Unlock()
+	defer p.End(ip)
+
+	p.logger.Log(ctx, slog.LevelDebug, "auto port scan started", "ip", ip)
+	start := p.now()
+	results, err := p.scanner.ScanHosts(ctx, []string{ip}, p.spec.TCP, p.spec.UDP)
+	if err != nil {
+		p.logger.Log(ctx, slog.LevelWarn, "auto port scan failed", "ip", ip, "error", err)
+		return
+	}
+	var collected []discovery.PortResult
+	for r := range results {
+		collected = append(collected, r.PortResult)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	d.SetPortResults(collected)
+	d.SetLastPortScan(start)
+
+	p.mu.Lock()
+	p.remember(ip, sig, start)
+	p.mu.Unlock()
+
+	open := d.OpenPorts()
+	p.logger.Log(ctx, slog.LevelInfo, "auto port scan finished", "ip", ip,
+		"open_tcp", len(open["tcp"]), "open_udp", len(open["udp"]), "duration", p.now().Sub(start))
+}
+
+// remember records the automatic scan of ip at at, forgetting the device scanned
+// longest ago when maxScanned devices are remembered already. The caller holds mu.
+func (p *Policy) remember(ip, sig string, at time.Time) {
+	if _, ok := p.scanned[ip]; !ok && len(p.scanned) >= maxScanned {
+		var oldest string
+		for k, v := range p.scanned {
+			if oldest == "" || v.at.Before(p.scanned[oldest].at) {
+				oldest = k
+			}
+		}
+		delete(p.scanned, oldest)
+	}
+	p.scanned[ip] = lastScan{sig: sig, at: at}
+}
+
+// signature captures the device attributes whose change triggers a rescan.
+func signature(d *discovery.Device) string {
+	return d.MAC() + "|" + d.DisplayName() + "|" + d.Manufacturer()
+}
@@ -0,0 +1,98 @@
+package autoscan
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/devicefilter"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/ports"
+)
+
+// Option configures a Policy during construction.
+type Option func(*Policy) error
+
+// WithPorts sets the ports scanned on every device.
+func WithPorts(spec ports.Spec) Option {
+	return func(p *Policy) error {
+		if spec.Len() == 0 {
+			return errors.New("autoscan: no ports to scan")
+		}
+		p.spec = spec
+		return nil
+	}
+}
+
+// WithCooldown sets the minimum time between two scans of the same device.
+//
+// Default: 1 hour
+func WithCooldown(d time.Duration) Option {
+	return func(p *Policy) error {
+		if d <= 0 {
+			return errors.New("autoscan: cooldown must be > 0")
+		}
+		p.cooldown = d
+		return nil
+	}
+}
+
+// WithMaxConcurrent sets the maximum number of devices scanned at the same time.
+//
+// Default: 2
+func WithMaxConcurrent(n int) Option {
+	return func(p *Policy) error {
+		if n <= 0 {
+			return errors.New("autoscan: max concurrent scans must be > 0")
+		}
+		p.maxConcurrent = n
+		return nil
+	}
+}
+
+// WithRatePerMinute sets the maximum number of scans started per minute.
+//
+// Default: 10
+func WithRatePerMinute(n int) Option {
+	return func(p *Policy) error {
+		if n <= 0 {
+			return errors.New("autoscan: rate per minute must be > 0")
+		}
+		p.interval = time.Minute / time.Duration(n)
+		return nil
+	}
+}
+
+// WithInclude limits scans to devices matching any of the filter rules.
+// See devicefilter.Parse for the rule syntax. No rules match every device.
+func WithInclude(rules []string) Option {
+	return func(p *Policy) error {
+		f, err := devicefilter.Parse(rules)
+		if err != nil {
+			return err
+		}
+		p.include = f
+		return nil
+	}
+}
+
+// WithExclude skips devices matching any of the filter rules, even when included.
+func WithExclude(rules []string) Option {
+	return func(p *Policy) error {
+		f, err := devicefilter.Parse(rules)
+		if err != nil {
+			return err
+		}
+		p.exclude = f
+		return nil
+	}
+}
+
+// WithLogger sets the logger used to report scans.
+func WithLogger(l discovery.Logger) Option {
+	return func(p *Policy) error {
+		if l != nil {
+			p.logger = l
+		}
+		return nil
+	}
+}
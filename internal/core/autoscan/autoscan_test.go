@@ -0,0 +1,196 @@
+package autoscan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/ports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeScanner struct {
+	mu      sync.Mutex
+	targets []string
+	scanned chan string
+}
+
+func (f *fakeScanner) ScanHosts(_ context.Context, targets []string, tcp, _ []int) (<-chan discovery.HostPortResult, error) {
+	f.mu.Lock()
+	f.targets = append(f.targets, targets...)
+	f.mu.Unlock()
+
+	out := make(chan discovery.HostPortResult, len(targets)*len(tcp))
+	for _, t := range targets {
+		for _, p := range tcp {
+			out <- discovery.HostPortResult{Target: t, PortResult: discovery.PortResult{Port: p, Protocol: "tcp", State: discovery.PortOpen}}
+		}
+	}
+	close(out)
+	if f.scanned != nil {
+		for _, t := range targets {
+			f.scanned <- t
+		}
+	}
+	return out, nil
+}
+
+func newDevice(ip, mac, manufacturer string) *discovery.Device {
+	d := discovery.NewDevice(net.ParseIP(ip))
+	d.SetMAC(mac)
+	d.SetManufacturer(manufacturer)
+	return d
+}
+
+func newPolicy(t *testing.T, opts ...Option) (*Policy, *fakeScanner) {
+	t.Helper()
+	s := &fakeScanner{}
+	p, err := New(s, append([]Option{WithPorts(ports.Spec{TCP: []int{22, 80}})}, opts...)...)
+	require.NoError(t, err)
+	return p, s
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	s := &fakeScanner{}
+	_, err := New(nil)
+	assert.Error(t, err)
+	_, err = New(s, WithPorts(ports.Spec{}))
+	assert.Error(t, err)
+	_, err = New(s, WithCooldown(0))
+	assert.Error(t, err)
+	_, err = New(s, WithMaxConcurrent(0))
+	assert.Error(t, err)
+	_, err = New(s, WithRatePerMinute(-1))
+	assert.Error(t, err)
+	_, err = New(s, WithInclude([]string{"manufacturer:"}))
+	assert.Error(t, err)
+}
+
+func TestPolicy_Observe_Filters(t *testing.T) {
+	p, _ := newPolicy(t,
+		WithInclude([]string{"192.168.1.0/24"}),
+		WithExclude([]string{"192.168.1.1", "Hikvision"}),
+	)
+
+	assert.True(t, p.Observe(newDevice("192.168.1.10", "", "")))
+	assert.False(t, p.Observe(newDevice("10.0.0.10", "", "")), "not included")
+	assert.False(t, p.Observe(newDevice("192.168.1.1", "", "")), "excluded by IP")
+	assert.False(t, p.Observe(newDevice("192.168.1.20", "", "Hikvision Digital Technology")), "excluded by manufacturer")
+	assert.False(t, p.Observe(nil))
+}
+
+func TestPolicy_Observe_PendingAndCooldown(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	p, _ := newPolicy(t, WithCooldown(time.Hour))
+	p.now = func() time.Time { return now }
+
+	d := newDevice("192.168.1.10", "aa:bb:cc:dd:ee:ff", "")
+	assert.True(t, p.Observe(d))
+	assert.False(t, p.Observe(d), "already queued")
+
+	recent := newDevice("192.168.1.11", "", "")
+	recent.SetLastPortScan(now.Add(-10 * time.Minute))
+	assert.False(t, p.Observe(recent), "within cooldown")
+
+	stale := newDevice("192.168.1.12", "", "")
+	stale.SetLastPortScan(now.Add(-2 * time.Hour))
+	assert.True(t, p.Observe(stale), "cooldown passed and never scanned automatically")
+}
+
+func TestPolicy_Run_ScansAndRescansOnlyChangedDevices(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	p, s := newPolicy(t, WithCooldown(time.Hour), WithRatePerMinute(60000))
+	var mu sync.Mutex
+	p.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	s.scanned = make(chan string, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	d := newDevice("192.168.1.10", "aa:bb:cc:dd:ee:ff", "")
+	require.True(t, p.Observe(d))
+	<-s.scanned
+	require.Eventually(t, func() bool { return !d.LastPortScan().IsZero() }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int{22, 80}, d.OpenPorts()["tcp"])
+	require.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.pending) == 0 && len(p.inFlight) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	now = now.Add(2 * time.Hour)
+	mu.Unlock()
+	assert.False(t, p.Observe(d), "unchanged since the last automatic scan")
+
+	d.SetMAC("11:22:33:44:55:66")
+	assert.True(t, p.Observe(d), "changed MAC triggers a rescan")
+	<-s.scanned
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Equal(t, []string{"192.168.1.10", "192.168.1.10"}, s.targets)
+}
+
+func TestPolicy_Begin_CoordinatesWithManualScans(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	p, s := newPolicy(t, WithCooldown(time.Hour))
+	p.now = func() time.Time { return now }
+
+	d := newDevice("192.168.1.10", "aa:bb:cc:dd:ee:ff", "")
+	require.True(t, p.Begin("192.168.1.10"))
+	assert.False(t, p.Begin("192.168.1.10"), "scanned by hand already")
+	assert.False(t, p.Observe(d), "scanned by hand")
+	p.End("192.168.1.10")
+
+	// a device queued before a manual scan is skipped once the manual scan recorded its time
+	require.True(t, p.Observe(d))
+	d.SetLastPortScan(now)
+	p.scan(context.Background(), <-p.queue)
+	assert.Empty(t, s.targets, "scanned by hand while queued")
+
+	var nilPolicy *Policy
+	assert.True(t, nilPolicy.Begin("192.168.1.10"), "no policy never blocks manual scans")
+	nilPolicy.End("192.168.1.10")
+}
+
+func TestPolicy_Remember_ForgetsOldestScans(t *testing.T) {
+	p, _ := newPolicy(t)
+	start := time.Now()
+	for i := range maxScanned {
+		p.remember(fmt.Sprintf("10.0.%d.%d", i/256, i%256), "sig", start.Add(time.Duration(i)*time.Second))
+	}
+	require.Len(t, p.scanned, maxScanned)
+
+	p.remember("10.0.0.0", "changed", start.Add(time.Hour))
+	assert.Len(t, p.scanned, maxScanned, "rescanned devices take no extra room")
+	p.remember("192.168.1.10", "sig", start.Add(time.Hour))
+	assert.Len(t, p.scanned, maxScanned)
+	assert.NotContains(t, p.scanned, "10.0.0.1", "scanned longest ago")
+	assert.Contains(t, p.scanned, "10.0.0.0")
+	assert.Contains(t, p.scanned, "192.168.1.10")
+}
+
+func TestPolicy_WaitTurn_SpacesScans(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	p, _ := newPolicy(t, WithRatePerMinute(6))
+	p.now = func() time.Time { return now }
+
+	require.True(t, p.waitTurn(context.Background()))
+	assert.Equal(t, now.Add(10*time.Second), p.nextStart)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, p.waitTurn(ctx), "second scan has to wait and ctx is done")
+	assert.Equal(t, now.Add(20*time.Second), p.nextStart)
+}
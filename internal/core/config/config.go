@@ -3,10 +3,15 @@ package config
 import (
 	"errors"
 	"net"
+	"net/url"
 	"strings"
 	"time"
 
+	"github.com/ramonvermeulen/whosthere/internal/core/devicefilter"
+	"github.com/ramonvermeulen/whosthere/internal/core/history"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/fingerprint"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/oui"
 )
 
 const (
@@ -17,12 +22,34 @@ const (
 
 	DefaultPortScanTimeout = 5 * time.Second
 
+	DefaultServiceDetectionEnabled  = false
+	DefaultServiceDetectionTimeout  = fingerprint.DefaultTimeout
+	DefaultServiceDetectionMaxBytes = fingerprint.DefaultMaxBytes
+
+	DefaultTLSInspectionEnabled = true
+
+	DefaultAutoScanEnabled       = false
+	DefaultAutoScanCooldown      = 1 * time.Hour
+	DefaultAutoScanMaxConcurrent = 2
+	DefaultAutoScanRatePerMinute = 10
+
+	DefaultOUIRefresh   = true
+	DefaultOUIMaxAge    = oui.DefaultMaxAge
+	DefaultOUIUpdateURL = oui.DefaultUpdateURL
+
+	DefaultHistoryEnabled   = true
+	DefaultHistoryRetention = history.DefaultRetention
+
 	DefaultThemeName = "default"
 	CustomThemeName  = "custom"
 )
 
 var DefaultTCPPorts = []int{21, 22, 23, 25, 80, 110, 135, 139, 143, 389, 443, 445, 993, 995, 1433, 1521, 3306, 3389, 5432, 5900, 8080, 8443, 9000, 9090, 9200, 9300, 10000, 27017}
 
+var DefaultUDPPorts = []int{53, 67, 123, 161, 514, 1900, 5353, 51820}
+
+var DefaultTLSPorts = fingerprint.DefaultTLSPorts
+
 // Config captures all configurable parameters for the application.
 type Config struct {
 	NetworkInterface string        `yaml:"network_interface"`
@@ -30,13 +57,18 @@ type Config struct {
 	// ScanDuration is deprecated.
 	//
 	// Deprecated: use ScanTimeout instead. Field will be removed in the next major release.
-	ScanDuration time.Duration     `yaml:"scan_duration"`
-	ScanTimeout  time.Duration     `yaml:"scan_timeout"`
-	Scanners     ScannerConfig     `yaml:"scanners"`
-	Sweeper      SweeperConfig     `yaml:"sweeper"`
-	PortScanner  PortScannerConfig `yaml:"port_scanner"`
-	Splash       SplashConfig      `yaml:"splash"`
-	Theme        ThemeConfig       `yaml:"theme"`
+	ScanDuration time.Duration `yaml:"scan_duration"`
+	ScanTimeout  time.Duration `yaml:"scan_timeout"`
+	RulesFile    string        `yaml:"rules_file"`
+	// KnownDevicesFile lists the devices allowed on the network, see core.BuildKnownDevices.
+	KnownDevicesFile string            `yaml:"known_devices_file"`
+	Scanners         ScannerConfig     `yaml:"scanners"`
+	Sweeper          SweeperConfig     `yaml:"sweeper"`
+	PortScanner      PortScannerConfig `yaml:"port_scanner"`
+	OUI              OUIConfig         `yaml:"oui"`
+	History          HistoryConfig     `yaml:"history"`
+	Splash           SplashConfig      `yaml:"splash"`
+	Theme            ThemeConfig       `yaml:"theme"`
 }
 
 // ScannerToggle lets users enable/disable a scanner.
@@ -58,10 +90,74 @@ type SweeperConfig struct {
 	Timeout  time.Duration `yaml:"timeout"`
 }
 
-// PortScannerConfig defines TCP ports to scan.
+// PortScannerConfig defines TCP and UDP ports to scan.
+// An empty UDP list disables UDP scanning.
+//
+// TCP and UDP make up the "default" profile. Profiles holds user-defined
+// profiles as port specs (e.g. "T:22,80-90,U:53"), and Profile selects the
+// profile used when a scan does not pick one explicitly.
+//
+// Services overrides IANA service names for ports, keyed by "port/proto" or
+// a bare port (e.g. "8081/tcp": "grafana").
 type PortScannerConfig struct {
-	TCP     []int         `yaml:"tcp"`
-	Timeout time.Duration `yaml:"timeout"`
+	TCP              PortList               `yaml:"tcp"`
+	UDP              PortList               `yaml:"udp"`
+	Profile          string                 `yaml:"profile"`
+	Profiles         map[string]string      `yaml:"profiles"`
+	Services         map[string]string      `yaml:"services"`
+	Timeout          time.Duration          `yaml:"timeout"`
+	ServiceDetection ServiceDetectionConfig `yaml:"service_detection"`
+	TLS              TLSInspectionConfig    `yaml:"tls"`
+	Auto             AutoScanConfig         `yaml:"auto"`
+}
+
+// ServiceDetectionConfig controls banner grabbing and version detection on open TCP ports.
+// Detection opens extra connections to each open port, so it is opt-in.
+// Timeout and MaxBytes also bound TLS certificate inspection.
+type ServiceDetectionConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Timeout  time.Duration `yaml:"timeout"`
+	MaxBytes int           `yaml:"max_bytes"`
+}
+
+// TLSInspectionConfig controls certificate inspection on open TLS ports.
+// Ports not listed are still inspected when service detection finds they speak TLS.
+type TLSInspectionConfig struct {
+	Enabled bool  `yaml:"enabled"`
+	Ports   []int `yaml:"ports"`
+}
+
+// AutoScanConfig controls automatic port scans of newly discovered or changed devices.
+// A device is scanned at most once per Cooldown. MaxConcurrent bounds the number of
+// devices scanned at the same time and RatePerMinute the number of scans started per minute.
+// Include and Exclude hold device filter rules (IP, CIDR, IP range, MAC, MAC prefix or
+// manufacturer); an empty Include matches every device and Exclude wins over Include.
+type AutoScanConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Profile       string        `yaml:"profile"`
+	Cooldown      time.Duration `yaml:"cooldown"`
+	MaxConcurrent int           `yaml:"max_concurrent"`
+	RatePerMinute int           `yaml:"rate_per_minute"`
+	Include       []string      `yaml:"include"`
+	Exclude       []string      `yaml:"exclude"`
+}
+
+// OUIConfig controls the registry used to look up manufacturers by MAC address.
+// Stale data is refreshed in the background from UpdateURL, which can point at
+// an internal mirror of the IEEE website; disable Refresh to never reach out.
+// OverridesFile maps MAC prefixes to vendors and is consulted before the IEEE data.
+type OUIConfig struct {
+	Refresh       bool          `yaml:"refresh"`
+	MaxAge        time.Duration `yaml:"max_age"`
+	UpdateURL     string        `yaml:"update_url"`
+	OverridesFile string        `yaml:"overrides_file"`
+}
+
+// HistoryConfig controls the inventory of devices kept across runs in the state
+// directory. Devices not seen within Retention are dropped.
+type HistoryConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Retention time.Duration `yaml:"retention"`
 }
 
 // SplashConfig controls the splash screen visibility and timing.
@@ -106,8 +202,39 @@ func DefaultConfig() *Config {
 			Timeout:  discovery.DefaultSweepTimeout,
 		},
 		PortScanner: PortScannerConfig{
-			TCP:     DefaultTCPPorts,
-			Timeout: DefaultPortScanTimeout,
+			TCP:      DefaultTCPPorts,
+			UDP:      DefaultUDPPorts,
+			Profile:  DefaultPortProfile,
+			Profiles: map[string]string{},
+			Services: map[string]string{},
+			Timeout:  DefaultPortScanTimeout,
+			ServiceDetection: ServiceDetectionConfig{
+				Enabled:  DefaultServiceDetectionEnabled,
+				Timeout:  DefaultServiceDetectionTimeout,
+				MaxBytes: DefaultServiceDetectionMaxBytes,
+			},
+			TLS: TLSInspectionConfig{
+				Enabled: DefaultTLSInspectionEnabled,
+				Ports:   DefaultTLSPorts,
+			},
+			Auto: AutoScanConfig{
+				Enabled:       DefaultAutoScanEnabled,
+				Profile:       DefaultPortProfile,
+				Cooldown:      DefaultAutoScanCooldown,
+				MaxConcurrent: DefaultAutoScanMaxConcurrent,
+				RatePerMinute: DefaultAutoScanRatePerMinute,
+				Include:       []string{},
+				Exclude:       []string{},
+			},
+		},
+		OUI: OUIConfig{
+			Refresh:   DefaultOUIRefresh,
+			MaxAge:    DefaultOUIMaxAge,
+			UpdateURL: DefaultOUIUpdateURL,
+		},
+		History: HistoryConfig{
+			Enabled:   DefaultHistoryEnabled,
+			Retention: DefaultHistoryRetention,
 		},
 		Splash: SplashConfig{
 			Enabled: DefaultSplashEnabled,
@@ -159,10 +286,81 @@ func (c *Config) normalizeBasics() error {
 		c.PortScanner.TCP = DefaultTCPPorts
 	}
 
+	for name, spec := range c.PortScanner.Profiles {
+		if _, err := parseProfiles(name + "=" + spec); err != nil {
+			errs = append(errs, "port_scanner.profiles: "+err.Error())
+			delete(c.PortScanner.Profiles, name)
+		}
+	}
+
+	for k, name := range c.PortScanner.Services {
+		if _, err := parseServices(k + "=" + name); err != nil {
+			errs = append(errs, "port_scanner.services: "+err.Error())
+			delete(c.PortScanner.Services, k)
+		}
+	}
+
+	if strings.TrimSpace(c.PortScanner.Profile) == "" {
+		c.PortScanner.Profile = DefaultPortProfile
+	}
+	if _, err := c.PortScanner.ResolveProfile(c.PortScanner.Profile); err != nil {
+		errs = append(errs, "port_scanner.profile: "+err.Error())
+		c.PortScanner.Profile = DefaultPortProfile
+	}
+
 	if c.PortScanner.Timeout <= 0 {
 		c.PortScanner.Timeout = DefaultPortScanTimeout
 	}
 
+	if c.PortScanner.ServiceDetection.Timeout <= 0 {
+		c.PortScanner.ServiceDetection.Timeout = DefaultServiceDetectionTimeout
+	}
+
+	if c.PortScanner.ServiceDetection.MaxBytes <= 0 {
+		c.PortScanner.ServiceDetection.MaxBytes = DefaultServiceDetectionMaxBytes
+	}
+
+	auto := &c.PortScanner.Auto
+	if strings.TrimSpace(auto.Profile) == "" {
+		auto.Profile = DefaultPortProfile
+	}
+	if _, err := c.PortScanner.ResolveProfile(auto.Profile); err != nil {
+		errs = append(errs, "port_scanner.auto.profile: "+err.Error())
+		auto.Profile = DefaultPortProfile
+	}
+	if auto.Cooldown <= 0 {
+		auto.Cooldown = DefaultAutoScanCooldown
+	}
+	if auto.MaxConcurrent <= 0 {
+		auto.MaxConcurrent = DefaultAutoScanMaxConcurrent
+	}
+	if auto.RatePerMinute <= 0 {
+		auto.RatePerMinute = DefaultAutoScanRatePerMinute
+	}
+	if _, err := devicefilter.Parse(auto.Include); err != nil {
+		errs = append(errs, "port_scanner.auto.include: "+err.Error())
+		auto.Include = []string{}
+	}
+	if _, err := devicefilter.Parse(auto.Exclude); err != nil {
+		errs = append(errs, "port_scanner.auto.exclude: "+err.Error())
+		auto.Exclude = []string{}
+	}
+
+	if c.OUI.MaxAge <= 0 {
+		c.OUI.MaxAge = DefaultOUIMaxAge
+	}
+	if strings.TrimSpace(c.OUI.UpdateURL) == "" {
+		c.OUI.UpdateURL = DefaultOUIUpdateURL
+	}
+	if u, err := url.Parse(c.OUI.UpdateURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, "oui.update_url must be an http(s) URL: "+c.OUI.UpdateURL)
+		c.OUI.UpdateURL = DefaultOUIUpdateURL
+	}
+
+	if c.History.Retention <= 0 {
+		c.History.Retention = DefaultHistoryRetention
+	}
+
 	if c.Sweeper.Interval <= 0 {
 		c.Sweeper.Interval = discovery.DefaultSweepInterval
 	}
@@ -174,3 +174,61 @@ splash:
 		t.Errorf("expected default splash delay %v, got %v", DefaultSplashDelay, cfg.Splash.Delay)
 	}
 }
+
+func TestValidateAndNormalizeAutoScan(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PortScanner.Auto = AutoScanConfig{
+		Profile: "missing",
+		Include: []string{"192.168.1.0/24"},
+		Exclude: []string{"192.168.1.9-192.168.1.1"},
+	}
+
+	err := cfg.validateAndNormalize()
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{"port_scanner.auto.profile", "port_scanner.auto.exclude"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s error, got %v", want, err)
+		}
+	}
+
+	auto := cfg.PortScanner.Auto
+	if auto.Profile != DefaultPortProfile {
+		t.Errorf("expected profile %q, got %q", DefaultPortProfile, auto.Profile)
+	}
+	if auto.Cooldown != DefaultAutoScanCooldown || auto.MaxConcurrent != DefaultAutoScanMaxConcurrent || auto.RatePerMinute != DefaultAutoScanRatePerMinute {
+		t.Errorf("expected budget defaults, got %+v", auto)
+	}
+	if len(auto.Include) != 1 || len(auto.Exclude) != 0 {
+		t.Errorf("expected only the invalid exclude rules to be dropped, got %+v", auto)
+	}
+}
+
+func TestValidateAndNormalizeHistory(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.History = HistoryConfig{Enabled: false, Retention: -time.Hour}
+
+	if err := cfg.validateAndNormalize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.History.Retention != DefaultHistoryRetention || cfg.History.Enabled {
+		t.Errorf("expected the default retention with history disabled, got %+v", cfg.History)
+	}
+}
+
+func TestValidateAndNormalizeOUI(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OUI = OUIConfig{Refresh: false, MaxAge: 0, UpdateURL: "ftp://mirror.example.com"}
+
+	err := cfg.validateAndNormalize()
+	if err == nil || !strings.Contains(err.Error(), "oui.update_url") {
+		t.Fatalf("expected oui.update_url error, got %v", err)
+	}
+	if cfg.OUI.MaxAge != DefaultOUIMaxAge || cfg.OUI.UpdateURL != DefaultOUIUpdateURL {
+		t.Errorf("expected OUI defaults, got %+v", cfg.OUI)
+	}
+	if cfg.OUI.Refresh {
+		t.Errorf("expected refresh to stay disabled")
+	}
+}
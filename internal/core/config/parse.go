@@ -63,3 +63,13 @@ func parseIntSlice(s string) ([]int, error) {
 	}
 	return result, nil
 }
+
+func parseStringSlice(s string) []string {
+	result := []string{}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
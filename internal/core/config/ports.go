@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/ports"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/services"
+)
+
+// DefaultPortProfile is the profile made up of port_scanner.tcp and port_scanner.udp.
+const DefaultPortProfile = "default"
+
+// PortList is a list of ports that accepts nmap-like ranges in YAML, either as
+// a sequence (`[22, 80, "8000-8100"]`) or as a single spec string ("1-1024,3389").
+type PortList []int
+
+// UnmarshalYAML implements yaml.InterfaceUnmarshaler.
+func (l *PortList) UnmarshalYAML(unmarshal func(any) error) error {
+	var raw any
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	var parts []string
+	switch v := raw.(type) {
+	case nil:
+	case string:
+		parts = append(parts, v)
+	case []any:
+		for _, item := range v {
+			parts = append(parts, fmt.Sprint(item))
+		}
+	default:
+		parts = append(parts, fmt.Sprint(v))
+	}
+
+	list, err := ports.ParseList(strings.Join(parts, ","))
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []int{}
+	}
+	*l = list
+	return nil
+}
+
+// ResolveProfile resolves a port profile by name. The default profile is made up of
+// the configured TCP and UDP lists; user-defined profiles take precedence over
+// built-in profiles with the same name.
+func (c *PortScannerConfig) ResolveProfile(name string) (ports.Spec, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, DefaultPortProfile) {
+		return ports.Spec{TCP: c.TCP, UDP: c.UDP}, nil
+	}
+	if spec, ok := c.Profiles[name]; ok {
+		return ports.Parse(spec)
+	}
+	if s, ok := ports.Profile(name); ok {
+		return s, nil
+	}
+	return ports.Spec{}, fmt.Errorf("unknown port profile %q (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
+}
+
+// ProfileNames returns the default profile followed by all built-in and
+// user-defined profile names, sorted.
+func (c *PortScannerConfig) ProfileNames() []string {
+	names := ports.Profiles()
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = slices.Compact(names)
+	return append([]string{DefaultPortProfile}, slices.DeleteFunc(names, func(n string) bool {
+		return strings.EqualFold(n, DefaultPortProfile)
+	})...)
+}
+
+// parseProfiles parses user-defined profiles from "name=spec;name=spec".
+func parseProfiles(s string) (map[string]string, error) {
+	profiles := map[string]string{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid port profile %q, expected name=spec", entry)
+		}
+		name, spec = strings.TrimSpace(name), strings.TrimSpace(spec)
+		if err := ports.ValidateProfileName(name); err != nil {
+			return nil, err
+		}
+		if _, err := ports.Parse(spec); err != nil {
+			return nil, fmt.Errorf("port profile %q: %w", name, err)
+		}
+		profiles[name] = spec
+	}
+	return profiles, nil
+}
+
+// ServiceRegistry returns the embedded IANA service name registry with the
+// configured overrides applied.
+func (c *PortScannerConfig) ServiceRegistry() (*services.Registry, error) {
+	if len(c.Services) == 0 {
+		return services.Default(), nil
+	}
+	return services.New(services.WithOverrides(c.Services))
+}
+
+// parseServices parses service name overrides from "port/proto=name;port=name".
+func parseServices(s string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		k, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid service override %q, expected port/proto=name", entry)
+		}
+		k, name = strings.TrimSpace(k), strings.TrimSpace(name)
+		if _, _, err := services.ParseKey(k); err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return nil, fmt.Errorf("service name for %q cannot be empty", k)
+		}
+		overrides[k] = name
+	}
+	return overrides, nil
+}
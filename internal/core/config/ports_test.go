@@ -0,0 +1,125 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPortList_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []int
+	}{
+		{"sequence", "tcp: [443, 22]", []int{22, 443}},
+		{"sequence with ranges", `tcp: [22, "8000-8002"]`, []int{22, 8000, 8001, 8002}},
+		{"spec string", `tcp: "1-3,3389"`, []int{1, 2, 3, 3389}},
+		{"empty", "tcp: []", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg PortScannerConfig
+			require.NoError(t, yaml.Unmarshal([]byte(tt.yaml), &cfg))
+			assert.Equal(t, tt.want, []int(cfg.TCP))
+		})
+	}
+
+	var cfg PortScannerConfig
+	assert.Error(t, yaml.Unmarshal([]byte(`tcp: "1-70000"`), &cfg))
+}
+
+func TestPortScannerConfig_ResolveProfile(t *testing.T) {
+	cfg := DefaultConfig().PortScanner
+	cfg.Profiles = map[string]string{"nas": "T:22,5000-5001,U:161", "web": "8080"}
+
+	s, err := cfg.ResolveProfile("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTCPPorts, s.TCP)
+	assert.Equal(t, DefaultUDPPorts, s.UDP)
+
+	s, err = cfg.ResolveProfile("nas")
+	require.NoError(t, err)
+	assert.Equal(t, []int{22, 5000, 5001}, s.TCP)
+	assert.Equal(t, []int{161}, s.UDP)
+
+	s, err = cfg.ResolveProfile("web")
+	require.NoError(t, err)
+	assert.Equal(t, []int{8080}, s.TCP, "user profiles override built-ins")
+
+	s, err = cfg.ResolveProfile("top100")
+	require.NoError(t, err)
+	assert.Len(t, s.TCP, 100)
+
+	_, err = cfg.ResolveProfile("nope")
+	assert.ErrorContains(t, err, "top1000")
+
+	names := cfg.ProfileNames()
+	assert.Equal(t, DefaultPortProfile, names[0])
+	assert.Contains(t, names, "nas")
+	assert.Contains(t, names, "iot")
+}
+
+func TestValidateAndNormalize_InvalidPortProfiles(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PortScanner.Profiles = map[string]string{"ok": "22", "bad": "0-10"}
+	cfg.PortScanner.Profile = "missing"
+
+	err := cfg.validateAndNormalize()
+	require.Error(t, err)
+	assert.Equal(t, map[string]string{"ok": "22"}, cfg.PortScanner.Profiles)
+	assert.Equal(t, DefaultPortProfile, cfg.PortScanner.Profile)
+}
+
+func TestSave_PersistsPortProfiles(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	cfg := DefaultConfig()
+	cfg.PortScanner.TCP = PortList{22, 8000, 8001, 8002, 8003}
+	cfg.PortScanner.Profiles = map[string]string{"nas": "22,5000-5001"}
+	cfg.PortScanner.Profile = "nas"
+	require.NoError(t, Save(cfg, configPath))
+
+	loaded, err := LoadForMode(ModeApp, &Flags{ConfigFile: configPath})
+	require.NoError(t, err)
+	assert.Equal(t, []int{22, 8000, 8001, 8002, 8003}, []int(loaded.PortScanner.TCP))
+	assert.Equal(t, map[string]string{"nas": "22,5000-5001"}, loaded.PortScanner.Profiles)
+	assert.Equal(t, "nas", loaded.PortScanner.Profile)
+}
+
+func TestPortScannerConfig_ServiceRegistry(t *testing.T) {
+	cfg := DefaultConfig()
+	reg, err := cfg.PortScanner.ServiceRegistry()
+	require.NoError(t, err)
+	assert.Equal(t, "ssh", reg.Name(22, "tcp"))
+
+	cfg.PortScanner.Services = map[string]string{"8081/tcp": "grafana", "22": "bastion"}
+	reg, err = cfg.PortScanner.ServiceRegistry()
+	require.NoError(t, err)
+	assert.Equal(t, "grafana", reg.Name(8081, "tcp"))
+	assert.Equal(t, "bastion", reg.Name(22, "tcp"))
+}
+
+func TestValidateAndNormalize_InvalidServices(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PortScanner.Services = map[string]string{"8081/tcp": "grafana", "80/sctp": "web", "0": "zero"}
+
+	err := cfg.validateAndNormalize()
+	require.Error(t, err)
+	assert.Equal(t, map[string]string{"8081/tcp": "grafana"}, cfg.PortScanner.Services)
+}
+
+func TestSave_PersistsServices(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	cfg := DefaultConfig()
+	cfg.PortScanner.Services = map[string]string{"8081/tcp": "grafana", "9999": "inventory-api"}
+	require.NoError(t, Save(cfg, configPath))
+
+	loaded, err := LoadForMode(ModeApp, &Flags{ConfigFile: configPath})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"8081/tcp": "grafana", "9999": "inventory-api"}, loaded.PortScanner.Services)
+}
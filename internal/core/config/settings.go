@@ -3,6 +3,7 @@ package config
 import (
 	"strings"
 
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/ports"
 	"github.com/spf13/cobra"
 )
 
@@ -140,6 +141,34 @@ func GlobalSettings() []GlobalSetting {
 				Comment: "Maximum timeout for each scan, recommended to be less than the scan interval",
 			},
 		},
+		{
+			YAMLKey:  "rules_file",
+			FlagName: "rules",
+			Usage:    "Path to the classification and alert rules file (e.g. --rules=/path/to/rules.yaml)",
+			Type:     FlagTypeString,
+			Sources:  all,
+			Set:      func(c *Config, v string) error { c.RulesFile = strings.TrimSpace(v); return nil },
+			Get:      func(c *Config) any { return c.RulesFile },
+			Doc: YAMLDoc{
+				Comment:      "Uncomment the next line to load rules from another file - uses rules.yaml in the config directory if it exists",
+				ExampleValue: "/path/to/rules.yaml",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey:  "known_devices_file",
+			FlagName: "known-devices",
+			Usage:    "Path to the known devices allowlist (e.g. --known-devices=/path/to/known_devices.yaml)",
+			Type:     FlagTypeString,
+			Sources:  all,
+			Set:      func(c *Config, v string) error { c.KnownDevicesFile = strings.TrimSpace(v); return nil },
+			Get:      func(c *Config) any { return c.KnownDevicesFile },
+			Doc: YAMLDoc{
+				Comment:      "Uncomment the next line to alert on devices missing from another allowlist - uses known_devices.yaml in the config directory if it exists",
+				ExampleValue: "/path/to/known_devices.yaml",
+				CommentedOut: true,
+			},
+		},
 		{
 			YAMLKey:  "scanners.mdns.enabled",
 			FlagName: "mdns",
@@ -267,17 +296,359 @@ func GlobalSettings() []GlobalSetting {
 			YAMLKey: "port_scanner.tcp",
 			Type:    FlagTypeString,
 			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				list, err := ports.ParseList(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.TCP = list
+				return nil
+			},
+			Get: func(c *Config) any { return []int(c.PortScanner.TCP) },
+			Doc: YAMLDoc{
+				Comment: "List of TCP ports to scan on discovered devices, ranges are allowed (e.g. \"8000-8100\")",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.udp",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				list, err := ports.ParseList(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.UDP = list
+				return nil
+			},
+			Get: func(c *Config) any { return []int(c.PortScanner.UDP) },
+			Doc: YAMLDoc{
+				Comment: "List of UDP ports to scan on discovered devices, probed with protocol-specific payloads\nSet to an empty list to disable UDP scanning",
+			},
+		},
+		{
+			YAMLKey:  "port_scanner.profile",
+			FlagName: "port-profile",
+			Short:    "P",
+			Usage:    "Port profile used for port scans (e.g. --port-profile=top100)",
+			Type:     FlagTypeString,
+			Sources:  all,
+			Set:      func(c *Config, v string) error { c.PortScanner.Profile = strings.TrimSpace(v); return nil },
+			Get:      func(c *Config) any { return c.PortScanner.Profile },
+			Doc: YAMLDoc{
+				Comment: "Port profile used for port scans unless another one is picked for a scan\n\"default\" scans the tcp and udp lists above, built-in profiles: web, iot, windows, databases, top100, top1000",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.profiles",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				profiles, err := parseProfiles(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.Profiles = profiles
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.Profiles },
+			Doc: YAMLDoc{
+				Comment:      "Uncomment the next line to define your own port profiles, prefix ports with T: or U: to select the protocol",
+				ExampleValue: `{nas: "22,80,443,5000-5001", printers: "T:80,443,515,631,9100,U:161"}`,
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "port_scanner.services",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				overrides, err := parseServices(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.Services = overrides
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.Services },
+			Doc: YAMLDoc{
+				Comment:      "Uncomment the next line to name in-house services on non-standard ports, keyed by port/protocol or port",
+				ExampleValue: `{"8081/tcp": "grafana", "9999": "inventory-api"}`,
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "port_scanner.service_detection.enabled",
+			Type:    FlagTypeBool,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				b, err := parseBool(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.ServiceDetection.Enabled = b
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.ServiceDetection.Enabled },
+			Doc: YAMLDoc{
+				Comment: "Grab banners and detect service versions on open TCP ports (opens extra connections)",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.service_detection.timeout",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				d, err := parseDuration(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.ServiceDetection.Timeout = d
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.ServiceDetection.Timeout },
+			Doc: YAMLDoc{},
+		},
+		{
+			YAMLKey: "port_scanner.service_detection.max_bytes",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				n, err := parseInt(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.ServiceDetection.MaxBytes = n
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.ServiceDetection.MaxBytes },
+			Doc: YAMLDoc{
+				Comment: "Maximum number of bytes read from a service per connection",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.tls.enabled",
+			Type:    FlagTypeBool,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				b, err := parseBool(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.TLS.Enabled = b
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.TLS.Enabled },
+			Doc: YAMLDoc{
+				Comment: "Record the certificate of open TLS ports (subject, issuer, validity, fingerprint)",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.tls.ports",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
 			Set: func(c *Config, v string) error {
 				ports, err := parseIntSlice(v)
 				if err != nil {
 					return err
 				}
-				c.PortScanner.TCP = ports
+				c.PortScanner.TLS.Ports = ports
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.TLS.Ports },
+			Doc: YAMLDoc{
+				Comment: "Ports that are always inspected with a TLS handshake",
+			},
+		},
+		{
+			YAMLKey:  "port_scanner.auto.enabled",
+			FlagName: "auto-portscan",
+			Usage:    "Automatically port scan new and changed devices (e.g. --auto-portscan=true)",
+			Type:     FlagTypeBool,
+			Sources:  all,
+			Set: func(c *Config, v string) error {
+				b, err := parseBool(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.Auto.Enabled = b
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.Auto.Enabled },
+			Doc: YAMLDoc{
+				Comment: "Automatically port scan newly discovered or changed devices (only scan devices you have permission to scan!)",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.auto.profile",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.PortScanner.Auto.Profile = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.PortScanner.Auto.Profile },
+			Doc: YAMLDoc{
+				Comment: "Port profile used for automatic scans",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.auto.cooldown",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				d, err := parseDuration(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.Auto.Cooldown = d
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.Auto.Cooldown },
+			Doc: YAMLDoc{
+				Comment: "Minimum time between two scans of the same device",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.auto.max_concurrent",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				n, err := parseInt(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.Auto.MaxConcurrent = n
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.Auto.MaxConcurrent },
+			Doc: YAMLDoc{
+				Comment: "Maximum number of devices scanned at the same time",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.auto.rate_per_minute",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				n, err := parseInt(v)
+				if err != nil {
+					return err
+				}
+				c.PortScanner.Auto.RatePerMinute = n
+				return nil
+			},
+			Get: func(c *Config) any { return c.PortScanner.Auto.RatePerMinute },
+			Doc: YAMLDoc{
+				Comment: "Maximum number of automatic scans started per minute",
+			},
+		},
+		{
+			YAMLKey: "port_scanner.auto.include",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.PortScanner.Auto.Include = parseStringSlice(v); return nil },
+			Get:     func(c *Config) any { return c.PortScanner.Auto.Include },
+			Doc: YAMLDoc{
+				Comment:      "Uncomment the next lines to only scan matching devices, or to never scan them\nRules are IPs, CIDRs, IP ranges, MACs, MAC prefixes or manufacturer names",
+				ExampleValue: `["192.168.1.0/24"]`,
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "port_scanner.auto.exclude",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.PortScanner.Auto.Exclude = parseStringSlice(v); return nil },
+			Get:     func(c *Config) any { return c.PortScanner.Auto.Exclude },
+			Doc: YAMLDoc{
+				ExampleValue: `["192.168.1.1", "aa:bb:cc:dd:ee:ff", "Hikvision"]`,
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "oui.refresh",
+			Type:    FlagTypeBool,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				b, err := parseBool(v)
+				if err != nil {
+					return err
+				}
+				c.OUI.Refresh = b
+				return nil
+			},
+			Get: func(c *Config) any { return c.OUI.Refresh },
+			Doc: YAMLDoc{
+				Comment: "Refresh the manufacturer (OUI) database in the background once it is older than max_age\nDisable to never reach out to the network, \"whosthere oui update\" still refreshes on demand",
+			},
+		},
+		{
+			YAMLKey: "oui.max_age",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				d, err := parseDuration(v)
+				if err != nil {
+					return err
+				}
+				c.OUI.MaxAge = d
+				return nil
+			},
+			Get: func(c *Config) any { return c.OUI.MaxAge },
+			Doc: YAMLDoc{},
+		},
+		{
+			YAMLKey: "oui.update_url",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.OUI.UpdateURL = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.OUI.UpdateURL },
+			Doc: YAMLDoc{
+				Comment: "Base URL of the IEEE registries, e.g. an internal mirror with the same layout (oui/oui.csv, oui28/mam.csv, ...)",
+			},
+		},
+		{
+			YAMLKey: "oui.overrides_file",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set:     func(c *Config, v string) error { c.OUI.OverridesFile = strings.TrimSpace(v); return nil },
+			Get:     func(c *Config) any { return c.OUI.OverridesFile },
+			Doc: YAMLDoc{
+				Comment:      "Uncomment the next line to load vendor overrides from another file - uses oui_overrides.yaml in the config directory",
+				ExampleValue: "/path/to/oui_overrides.yaml",
+				CommentedOut: true,
+			},
+		},
+		{
+			YAMLKey: "history.enabled",
+			Type:    FlagTypeBool,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				b, err := parseBool(v)
+				if err != nil {
+					return err
+				}
+				c.History.Enabled = b
+				return nil
+			},
+			Get: func(c *Config) any { return c.History.Enabled },
+			Doc: YAMLDoc{
+				Comment: "Keep an inventory of the devices seen across runs in the state directory, used to populate the device list on launch",
+			},
+		},
+		{
+			YAMLKey: "history.retention",
+			Type:    FlagTypeString,
+			Sources: yamlEnvOnly,
+			Set: func(c *Config, v string) error {
+				d, err := parseDuration(v)
+				if err != nil {
+					return err
+				}
+				c.History.Retention = d
 				return nil
 			},
-			Get: func(c *Config) any { return c.PortScanner.TCP },
+			Get: func(c *Config) any { return c.History.Retention },
 			Doc: YAMLDoc{
-				Comment: "List of TCP ports to scan on discovered devices",
+				Comment: "Devices not seen for this long are removed from the inventory",
 			},
 		},
 		{
@@ -64,6 +64,26 @@ func getSettingTestCases() []settingTestCase {
 			yamlValue:    "false",
 			expectedYAML: false,
 		},
+		{
+			yamlKey:      "rules_file",
+			envVar:       "WHOSTHERE__RULES_FILE",
+			envValue:     "/env/rules.yaml",
+			expectedEnv:  "/env/rules.yaml",
+			flagValue:    "/flag/rules.yaml",
+			expectedFlag: "/flag/rules.yaml",
+			yamlValue:    "/yaml/rules.yaml",
+			expectedYAML: "/yaml/rules.yaml",
+		},
+		{
+			yamlKey:      "known_devices_file",
+			envVar:       "WHOSTHERE__KNOWN_DEVICES_FILE",
+			envValue:     "/env/known_devices.yaml",
+			expectedEnv:  "/env/known_devices.yaml",
+			flagValue:    "/flag/known_devices.yaml",
+			expectedFlag: "/flag/known_devices.yaml",
+			yamlValue:    "/yaml/known_devices.yaml",
+			expectedYAML: "/yaml/known_devices.yaml",
+		},
 		{
 			yamlKey:      "scanners.ssdp.enabled",
 			envVar:       "WHOSTHERE__SCANNERS__SSDP__ENABLED",
@@ -134,6 +154,226 @@ func getSettingTestCases() []settingTestCase {
 			yamlValue:    "[22, 80]",
 			expectedYAML: []int{22, 80},
 		},
+		{
+			yamlKey:      "port_scanner.udp",
+			envVar:       "WHOSTHERE__PORT_SCANNER__UDP",
+			envValue:     "53,123",
+			expectedEnv:  []int{53, 123},
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "[161, 1900]",
+			expectedYAML: []int{161, 1900},
+		},
+		{
+			yamlKey:      "port_scanner.profile",
+			envVar:       "WHOSTHERE__PORT_SCANNER__PROFILE",
+			envValue:     "web",
+			expectedEnv:  "web",
+			flagValue:    "top100",
+			expectedFlag: "top100",
+			yamlValue:    "databases",
+			expectedYAML: "databases",
+		},
+		{
+			yamlKey:      "port_scanner.profiles",
+			envVar:       "WHOSTHERE__PORT_SCANNER__PROFILES",
+			envValue:     "nas=22,5000-5001;printers=T:631,U:161",
+			expectedEnv:  map[string]string{"nas": "22,5000-5001", "printers": "T:631,U:161"},
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    `{nas: "22,80"}`,
+			expectedYAML: map[string]string{"nas": "22,80"},
+		},
+		{
+			yamlKey:      "port_scanner.services",
+			envVar:       "WHOSTHERE__PORT_SCANNER__SERVICES",
+			envValue:     "8081/tcp=grafana;9999=inventory-api",
+			expectedEnv:  map[string]string{"8081/tcp": "grafana", "9999": "inventory-api"},
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    `{"8081/tcp": "grafana"}`,
+			expectedYAML: map[string]string{"8081/tcp": "grafana"},
+		},
+		{
+			yamlKey:      "port_scanner.auto.enabled",
+			envVar:       "WHOSTHERE__PORT_SCANNER__AUTO__ENABLED",
+			envValue:     "true",
+			expectedEnv:  true,
+			flagValue:    "true",
+			expectedFlag: true,
+			yamlValue:    "true",
+			expectedYAML: true,
+		},
+		{
+			yamlKey:      "port_scanner.auto.profile",
+			envVar:       "WHOSTHERE__PORT_SCANNER__AUTO__PROFILE",
+			envValue:     "web",
+			expectedEnv:  "web",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "iot",
+			expectedYAML: "iot",
+		},
+		{
+			yamlKey:      "port_scanner.auto.cooldown",
+			envVar:       "WHOSTHERE__PORT_SCANNER__AUTO__COOLDOWN",
+			envValue:     "30m",
+			expectedEnv:  30 * time.Minute,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "2h",
+			expectedYAML: 2 * time.Hour,
+		},
+		{
+			yamlKey:      "port_scanner.auto.max_concurrent",
+			envVar:       "WHOSTHERE__PORT_SCANNER__AUTO__MAX_CONCURRENT",
+			envValue:     "4",
+			expectedEnv:  4,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "1",
+			expectedYAML: 1,
+		},
+		{
+			yamlKey:      "port_scanner.auto.rate_per_minute",
+			envVar:       "WHOSTHERE__PORT_SCANNER__AUTO__RATE_PER_MINUTE",
+			envValue:     "30",
+			expectedEnv:  30,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "5",
+			expectedYAML: 5,
+		},
+		{
+			yamlKey:      "port_scanner.auto.include",
+			envVar:       "WHOSTHERE__PORT_SCANNER__AUTO__INCLUDE",
+			envValue:     "192.168.1.0/24, espressif",
+			expectedEnv:  []string{"192.168.1.0/24", "espressif"},
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    `["10.0.0.0/8"]`,
+			expectedYAML: []string{"10.0.0.0/8"},
+		},
+		{
+			yamlKey:      "port_scanner.auto.exclude",
+			envVar:       "WHOSTHERE__PORT_SCANNER__AUTO__EXCLUDE",
+			envValue:     "192.168.1.1",
+			expectedEnv:  []string{"192.168.1.1"},
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    `["aa:bb:cc"]`,
+			expectedYAML: []string{"aa:bb:cc"},
+		},
+		{
+			yamlKey:      "port_scanner.service_detection.enabled",
+			envVar:       "WHOSTHERE__PORT_SCANNER__SERVICE_DETECTION__ENABLED",
+			envValue:     "true",
+			expectedEnv:  true,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "true",
+			expectedYAML: true,
+		},
+		{
+			yamlKey:      "port_scanner.service_detection.timeout",
+			envVar:       "WHOSTHERE__PORT_SCANNER__SERVICE_DETECTION__TIMEOUT",
+			envValue:     "2s",
+			expectedEnv:  2 * time.Second,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "1500ms",
+			expectedYAML: 1500 * time.Millisecond,
+		},
+		{
+			yamlKey:      "port_scanner.service_detection.max_bytes",
+			envVar:       "WHOSTHERE__PORT_SCANNER__SERVICE_DETECTION__MAX_BYTES",
+			envValue:     "1024",
+			expectedEnv:  1024,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "512",
+			expectedYAML: 512,
+		},
+		{
+			yamlKey:      "port_scanner.tls.enabled",
+			envVar:       "WHOSTHERE__PORT_SCANNER__TLS__ENABLED",
+			envValue:     "false",
+			expectedEnv:  false,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "false",
+			expectedYAML: false,
+		},
+		{
+			yamlKey:      "port_scanner.tls.ports",
+			envVar:       "WHOSTHERE__PORT_SCANNER__TLS__PORTS",
+			envValue:     "443,5001",
+			expectedEnv:  []int{443, 5001},
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "[8443]",
+			expectedYAML: []int{8443},
+		},
+		{
+			yamlKey:      "oui.refresh",
+			envVar:       "WHOSTHERE__OUI__REFRESH",
+			envValue:     "false",
+			expectedEnv:  false,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "false",
+			expectedYAML: false,
+		},
+		{
+			yamlKey:      "oui.max_age",
+			envVar:       "WHOSTHERE__OUI__MAX_AGE",
+			envValue:     "24h",
+			expectedEnv:  24 * time.Hour,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "168h",
+			expectedYAML: 168 * time.Hour,
+		},
+		{
+			yamlKey:      "oui.update_url",
+			envVar:       "WHOSTHERE__OUI__UPDATE_URL",
+			envValue:     "https://mirror.example.com/ieee",
+			expectedEnv:  "https://mirror.example.com/ieee",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "http://10.0.0.5/ieee",
+			expectedYAML: "http://10.0.0.5/ieee",
+		},
+		{
+			yamlKey:      "oui.overrides_file",
+			envVar:       "WHOSTHERE__OUI__OVERRIDES_FILE",
+			envValue:     "/env/oui_overrides.yaml",
+			expectedEnv:  "/env/oui_overrides.yaml",
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "/yaml/oui_overrides.yaml",
+			expectedYAML: "/yaml/oui_overrides.yaml",
+		},
+		{
+			yamlKey:      "history.enabled",
+			envVar:       "WHOSTHERE__HISTORY__ENABLED",
+			envValue:     "false",
+			expectedEnv:  false,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "false",
+			expectedYAML: false,
+		},
+		{
+			yamlKey:      "history.retention",
+			envVar:       "WHOSTHERE__HISTORY__RETENTION",
+			envValue:     "72h",
+			expectedEnv:  72 * time.Hour,
+			flagValue:    "",
+			expectedFlag: nil,
+			yamlValue:    "168h",
+			expectedYAML: 168 * time.Hour,
+		},
 		{
 			yamlKey:      "splash.enabled",
 			envVar:       "WHOSTHERE__SPLASH__ENABLED",
@@ -478,6 +718,8 @@ func TestFullYAMLConfig_LoadFromFile(t *testing.T) {
 	fullYAML := `
 scan_timeout: 12s
 scan_interval: 45s
+rules_file: /etc/whosthere/rules.yaml
+known_devices_file: /etc/whosthere/known_devices.yaml
 
 scanners:
   mdns:
@@ -495,6 +737,37 @@ sweeper:
 port_scanner:
   timeout: 7s
   tcp: [22, 80, 443, 8080]
+  udp: [53, 5353]
+  profile: nas
+  profiles:
+    nas: "22,80,443,5000-5001"
+  services:
+    "8081/tcp": grafana
+  service_detection:
+    enabled: true
+    timeout: 2s
+    max_bytes: 2048
+  tls:
+    enabled: false
+    ports: [443, 5001]
+  auto:
+    enabled: true
+    profile: web
+    cooldown: 30m
+    max_concurrent: 3
+    rate_per_minute: 6
+    include: ["192.168.1.0/24"]
+    exclude: ["192.168.1.1", "Hikvision"]
+
+oui:
+  refresh: false
+  max_age: 48h
+  update_url: https://mirror.example.com/ieee
+  overrides_file: /etc/whosthere/oui_overrides.yaml
+
+history:
+  enabled: false
+  retention: 336h
 
 splash:
   enabled: false
@@ -534,6 +807,8 @@ theme:
 	}{
 		{"scan_timeout", cfg.ScanTimeout, 12 * time.Second},
 		{"scan_interval", cfg.ScanInterval, 45 * time.Second},
+		{"rules_file", cfg.RulesFile, "/etc/whosthere/rules.yaml"},
+		{"known_devices_file", cfg.KnownDevicesFile, "/etc/whosthere/known_devices.yaml"},
 		{"scanners.mdns.enabled", cfg.Scanners.MDNS.Enabled, false},
 		{"scanners.ssdp.enabled", cfg.Scanners.SSDP.Enabled, false},
 		{"scanners.arp.enabled", cfg.Scanners.ARP.Enabled, true},
@@ -541,7 +816,29 @@ theme:
 		{"sweeper.interval", cfg.Sweeper.Interval, 8 * time.Minute},
 		{"sweeper.timeout", cfg.Sweeper.Timeout, 4 * time.Second},
 		{"port_scanner.timeout", cfg.PortScanner.Timeout, 7 * time.Second},
-		{"port_scanner.tcp", cfg.PortScanner.TCP, []int{22, 80, 443, 8080}},
+		{"port_scanner.tcp", []int(cfg.PortScanner.TCP), []int{22, 80, 443, 8080}},
+		{"port_scanner.udp", []int(cfg.PortScanner.UDP), []int{53, 5353}},
+		{"port_scanner.profile", cfg.PortScanner.Profile, "nas"},
+		{"port_scanner.profiles", cfg.PortScanner.Profiles, map[string]string{"nas": "22,80,443,5000-5001"}},
+		{"port_scanner.services", cfg.PortScanner.Services, map[string]string{"8081/tcp": "grafana"}},
+		{"port_scanner.service_detection.enabled", cfg.PortScanner.ServiceDetection.Enabled, true},
+		{"port_scanner.service_detection.timeout", cfg.PortScanner.ServiceDetection.Timeout, 2 * time.Second},
+		{"port_scanner.service_detection.max_bytes", cfg.PortScanner.ServiceDetection.MaxBytes, 2048},
+		{"port_scanner.tls.enabled", cfg.PortScanner.TLS.Enabled, false},
+		{"port_scanner.tls.ports", cfg.PortScanner.TLS.Ports, []int{443, 5001}},
+		{"port_scanner.auto.enabled", cfg.PortScanner.Auto.Enabled, true},
+		{"port_scanner.auto.profile", cfg.PortScanner.Auto.Profile, "web"},
+		{"port_scanner.auto.cooldown", cfg.PortScanner.Auto.Cooldown, 30 * time.Minute},
+		{"port_scanner.auto.max_concurrent", cfg.PortScanner.Auto.MaxConcurrent, 3},
+		{"port_scanner.auto.rate_per_minute", cfg.PortScanner.Auto.RatePerMinute, 6},
+		{"port_scanner.auto.include", cfg.PortScanner.Auto.Include, []string{"192.168.1.0/24"}},
+		{"port_scanner.auto.exclude", cfg.PortScanner.Auto.Exclude, []string{"192.168.1.1", "Hikvision"}},
+		{"oui.refresh", cfg.OUI.Refresh, false},
+		{"oui.max_age", cfg.OUI.MaxAge, 48 * time.Hour},
+		{"oui.update_url", cfg.OUI.UpdateURL, "https://mirror.example.com/ieee"},
+		{"oui.overrides_file", cfg.OUI.OverridesFile, "/etc/whosthere/oui_overrides.yaml"},
+		{"history.enabled", cfg.History.Enabled, false},
+		{"history.retention", cfg.History.Retention, 336 * time.Hour},
 		{"splash.enabled", cfg.Splash.Enabled, false},
 		{"splash.delay", cfg.Splash.Delay, 750 * time.Millisecond},
 		{"theme.enabled", cfg.Theme.Enabled, false},
@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -67,7 +70,15 @@ func writeSettingLine(sb *strings.Builder, s *GlobalSetting, parts []string, ind
 	key := parts[len(parts)-1]
 	value := getDisplayValue(s, defaults)
 
-	if s.Doc.CommentedOut {
+	// a commented out setting that carries a value is written as-is, so that
+	// saving the config does not drop it
+	commentedOut := s.Doc.CommentedOut
+	if commentedOut && s.Get != nil && !isEmptyValue(s.Get(defaults)) {
+		commentedOut = false
+		value = formatValue(s.Get(defaults))
+	}
+
+	if commentedOut {
 		sb.WriteString(indent)
 		sb.WriteString("# ")
 		sb.WriteString(key)
@@ -112,11 +123,24 @@ func formatValue(v any) string {
 	case int:
 		return fmt.Sprintf("%d", val)
 	case []int:
+		return formatIntList(val)
+	case []string:
 		parts := make([]string, len(val))
-		for i, port := range val {
-			parts[i] = fmt.Sprintf("%d", port)
+		for i, v := range val {
+			parts[i] = strconv.Quote(v)
 		}
 		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]string:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = formatMapKey(k) + ": " + strconv.Quote(val[k])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
 	case time.Duration:
 		return formatDuration(val)
 	case fmt.Stringer:
@@ -126,6 +150,65 @@ func formatValue(v any) string {
 	}
 }
 
+// plainKeyRE matches map keys that YAML reads back as strings without quoting.
+var plainKeyRE = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// formatMapKey quotes keys that would otherwise be read back as numbers or
+// need escaping, e.g. "9999" or "8081/tcp".
+func formatMapKey(k string) string {
+	if plainKeyRE.MatchString(k) {
+		return k
+	}
+	return strconv.Quote(k)
+}
+
+// formatIntList formats a list of ports as a YAML flow sequence. Runs of
+// minPortRun or more consecutive ports are collapsed into quoted ranges;
+// shorter runs stay plain numbers for readability.
+func formatIntList(val []int) string {
+	const minPortRun = 4
+	var parts []string
+	for i := 0; i < len(val); {
+		j := i
+		for j+1 < len(val) && val[j+1] == val[j]+1 {
+			j++
+		}
+		if j-i+1 >= minPortRun {
+			parts = append(parts, fmt.Sprintf("\"%d-%d\"", val[i], val[j]))
+		} else {
+			for _, port := range val[i : j+1] {
+				parts = append(parts, fmt.Sprintf("%d", port))
+			}
+		}
+		i = j + 1
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// isEmptyValue reports whether a setting value is unset.
+func isEmptyValue(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case bool:
+		return !val
+	case int:
+		return val == 0
+	case time.Duration:
+		return val == 0
+	case []int:
+		return len(val) == 0
+	case []string:
+		return len(val) == 0
+	case map[string]string:
+		return len(val) == 0
+	default:
+		return false
+	}
+}
+
 func formatDuration(d time.Duration) string {
 	if d >= time.Hour && d%time.Hour == 0 {
 		return fmt.Sprintf("%dh", d/time.Hour)
@@ -29,6 +29,7 @@ func TestGenerateDefaultYAML(t *testing.T) {
 		"port_scanner:",
 		"timeout: 5s",
 		"tcp: [",
+		"udp: [53, 67, 123, 161, 514, 1900, 5353, 51820]",
 		"splash:",
 		"delay: 1s",
 		"theme:",
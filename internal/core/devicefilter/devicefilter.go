@@ -0,0 +1,146 @@
+// Package devicefilter matches devices against user supplied rules by IP
+// address, IP range, MAC address or manufacturer.
+package devicefilter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// rule matches a single device attribute.
+type rule func(d *discovery.Device) bool
+
+// Filter is a set of rules, a device matches when any rule matches.
+// The zero value matches nothing.
+type Filter struct {
+	rules []rule
+}
+
+// Parse builds a Filter from rules. Each rule is one of:
+//   - an IP address: "192.168.1.10"
+//   - a CIDR: "192.168.1.0/24"
+//   - an IP range: "192.168.1.100-192.168.1.150"
+//   - a MAC address: "aa:bb:cc:dd:ee:ff"
+//   - a MAC prefix (OUI): "aa:bb:cc"
+//   - anything else is matched case-insensitively against the manufacturer,
+//     e.g. "espressif"; prefix with "manufacturer:" to force this.
+func Parse(rules []string) (*Filter, error) {
+	f := &Filter{}
+	for _, raw := range rules {
+		r, err := parseRule(raw)
+		if err != nil {
+			return nil, err
+		}
+		f.rules = append(f.rules, r)
+	}
+	return f, nil
+}
+
+// Len returns the number of rules in the filter.
+func (f *Filter) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.rules)
+}
+
+// Match reports whether any rule matches d.
+func (f *Filter) Match(d *discovery.Device) bool {
+	if f == nil || d == nil {
+		return false
+	}
+	for _, r := range f.rules {
+		if r(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRule(raw string) (rule, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return nil, errors.New("device filter rule cannot be empty")
+	}
+	if name, ok := strings.CutPrefix(s, "manufacturer:"); ok {
+		return manufacturerRule(name)
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr = addr.Unmap()
+		return func(d *discovery.Device) bool { return deviceAddr(d) == addr }, nil
+	}
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		prefix = prefix.Masked()
+		return func(d *discovery.Device) bool { return prefix.Contains(deviceAddr(d)) }, nil
+	}
+	if from, to, ok := strings.Cut(s, "-"); ok {
+		start, err1 := netip.ParseAddr(strings.TrimSpace(from))
+		end, err2 := netip.ParseAddr(strings.TrimSpace(to))
+		if err1 == nil && err2 == nil {
+			start, end = start.Unmap(), end.Unmap()
+			if start.BitLen() != end.BitLen() || end.Less(start) {
+				return nil, fmt.Errorf("invalid IP range %q", s)
+			}
+			return func(d *discovery.Device) bool {
+				a := deviceAddr(d)
+				return a.IsValid() && a.BitLen() == start.BitLen() && !a.Less(start) && !end.Less(a)
+			}, nil
+		}
+	}
+	if mac, err := net.ParseMAC(s); err == nil {
+		want := mac.String()
+		return func(d *discovery.Device) bool { return normalizeMAC(d.MAC()) == want }, nil
+	}
+	if oui, ok := parseOUI(s); ok {
+		return func(d *discovery.Device) bool { return strings.HasPrefix(normalizeMAC(d.MAC()), oui) }, nil
+	}
+	return manufacturerRule(s)
+}
+
+func manufacturerRule(name string) (rule, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil, errors.New("manufacturer filter cannot be empty")
+	}
+	return func(d *discovery.Device) bool {
+		return strings.Contains(strings.ToLower(d.Manufacturer()), name)
+	}, nil
+}
+
+// deviceAddr returns the device IP as netip.Addr, or the zero Addr.
+func deviceAddr(d *discovery.Device) netip.Addr {
+	addr, ok := netip.AddrFromSlice(d.IP())
+	if !ok {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// normalizeMAC returns mac in lowercase colon notation, or "" when invalid.
+func normalizeMAC(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return ""
+	}
+	return hw.String()
+}
+
+// parseOUI parses a 3 byte MAC prefix like "aa:bb:cc" or "AA-BB-CC" into "aa:bb:cc".
+func parseOUI(s string) (string, bool) {
+	s = strings.ToLower(strings.ReplaceAll(s, "-", ":"))
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return "", false
+	}
+	for _, p := range parts {
+		if len(p) != 2 || strings.Trim(p, "0123456789abcdef") != "" {
+			return "", false
+		}
+	}
+	return s, true
+}
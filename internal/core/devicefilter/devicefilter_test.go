@@ -0,0 +1,61 @@
+package devicefilter
+
+import (
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDevice(ip, mac, manufacturer string) *discovery.Device {
+	d := discovery.NewDevice(net.ParseIP(ip))
+	d.SetMAC(mac)
+	d.SetManufacturer(manufacturer)
+	return d
+}
+
+func TestFilter_Match(t *testing.T) {
+	camera := newDevice("192.168.1.20", "AA:BB:CC:00:11:22", "Hikvision Digital Technology")
+	plug := newDevice("192.168.1.130", "24:0a:c4:12:34:56", "Espressif Inc.")
+	server := newDevice("10.0.0.5", "", "")
+
+	tests := []struct {
+		rule string
+		want []*discovery.Device
+	}{
+		{"192.168.1.20", []*discovery.Device{camera}},
+		{"192.168.1.0/24", []*discovery.Device{camera, plug}},
+		{"192.168.1.100-192.168.1.200", []*discovery.Device{plug}},
+		{"aa-bb-cc-00-11-22", []*discovery.Device{camera}},
+		{"24:0A:C4", []*discovery.Device{plug}},
+		{"espressif", []*discovery.Device{plug}},
+		{"manufacturer:hikvision", []*discovery.Device{camera}},
+		{"10.0.0.0/8", []*discovery.Device{server}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			f, err := Parse([]string{tt.rule})
+			require.NoError(t, err)
+			for _, d := range []*discovery.Device{camera, plug, server} {
+				assert.Equal(t, slices.Contains(tt.want, d), f.Match(d), "device %s", d.IP())
+			}
+		})
+	}
+}
+
+func TestFilter_Empty(t *testing.T) {
+	f, err := Parse(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, f.Len())
+	assert.False(t, f.Match(newDevice("192.168.1.1", "", "")))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, rule := range []string{"", "  ", "192.168.1.9-192.168.1.1", "10.0.0.1-::1", "manufacturer:"} {
+		_, err := Parse([]string{rule})
+		assert.Error(t, err, "rule %q", rule)
+	}
+}
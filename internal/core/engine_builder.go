@@ -2,28 +2,42 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
+	"os"
+	"path/filepath"
 
+	"github.com/goccy/go-yaml"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
+	"github.com/ramonvermeulen/whosthere/internal/core/autoscan"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/history"
+	"github.com/ramonvermeulen/whosthere/internal/core/knowndevices"
 	"github.com/ramonvermeulen/whosthere/internal/core/paths"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/classify"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/fingerprint"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery/oui"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/rules"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery/scanners/arp"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/scanners/local"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery/scanners/mdns"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery/scanners/ssdp"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery/sweeper"
 )
 
-func BuildEngine(cfg *config.Config, logger discovery.Logger) (*discovery.Engine, error) {
-	ctx := context.Background()
+// DefaultPortScanWorkers is the default number of ports probed concurrently per scan.
+const DefaultPortScanWorkers = 100
 
-	stateDir, err := paths.StateDir()
-	if err != nil {
-		logger.Log(ctx, slog.LevelWarn, "failed to resolve state dir for OUI cache; continuing with embedded OUI", "error", err)
-		stateDir = ""
-	}
+// BuildEngine creates the discovery engine configured in cfg. Additional options
+// are applied last, e.g. to attach rules.
+func BuildEngine(cfg *config.Config, logger discovery.Logger, extra ...discovery.Option) (*discovery.Engine, error) {
+	ctx := context.Background()
 
-	ouiDB, err := oui.New(ctx, oui.WithCacheDir(stateDir))
+	ouiDB, err := BuildOUIRegistry(cfg, logger)
 	if err != nil {
 		logger.Log(ctx, slog.LevelWarn, "failed to initialize OUI DB; continuing without OUI", "error", err)
 		ouiDB = nil
@@ -34,7 +48,12 @@ func BuildEngine(cfg *config.Config, logger discovery.Logger) (*discovery.Engine
 		return nil, err
 	}
 
-	var scanners []discovery.Scanner
+	// the local scanner only reads the host's own configuration, so it is always enabled
+	localScanner, err := local.New(iface, local.WithLogger(logger))
+	if err != nil {
+		return nil, err
+	}
+	scanners := []discovery.Scanner{localScanner}
 
 	if cfg.Scanners.SSDP.Enabled {
 		s, err := ssdp.New(iface, ssdp.WithLogger(logger))
@@ -64,6 +83,7 @@ func BuildEngine(cfg *config.Config, logger discovery.Logger) (*discovery.Engine
 		discovery.WithScanTimeout(cfg.ScanTimeout),
 		discovery.WithScanInterval(cfg.ScanInterval),
 		discovery.WithLogger(logger),
+		discovery.WithClassifier(classify.Classifier{}),
 	}
 
 	if ouiDB != nil {
@@ -81,5 +101,200 @@ func BuildEngine(cfg *config.Config, logger discovery.Logger) (*discovery.Engine
 		opts = append(opts, discovery.WithSweeper(s))
 	}
 
+	opts = append(opts, extra...)
 	return discovery.NewEngine(opts...)
 }
+
+// BuildOUIRegistry creates the OUI registry with the refresh policy configured in cfg,
+// caching downloaded registries in the state directory and applying the overrides
+// file, see OUIOverridesFile. Additional options are applied last, e.g. to disable
+// the automatic refresh.
+func BuildOUIRegistry(cfg *config.Config, logger discovery.Logger, extra ...oui.Option) (*oui.Registry, error) {
+	ctx := context.Background()
+
+	stateDir, err := paths.StateDir()
+	if err != nil {
+		logger.Log(ctx, slog.LevelWarn, "failed to resolve state dir for OUI cache; continuing with embedded OUI", "error", err)
+		stateDir = ""
+	}
+
+	opts := []oui.Option{
+		oui.WithCacheDir(stateDir),
+		oui.WithUpdateURL(cfg.OUI.UpdateURL),
+		oui.WithMaxAge(cfg.OUI.MaxAge),
+		oui.WithAutoRefresh(cfg.OUI.Refresh),
+	}
+	if path := OUIOverridesFile(cfg); path != "" {
+		opts = append(opts, oui.WithOverridesFile(path))
+	}
+	opts = append(opts, extra...)
+	return oui.New(ctx, opts...)
+}
+
+// defaultOUIOverridesFile is the name of the OUI overrides file looked up in the config directory.
+const defaultOUIOverridesFile = "oui_overrides.yaml"
+
+// OUIOverridesFile returns the OUI overrides file configured in cfg, or
+// oui_overrides.yaml in the config directory. The file does not need to exist.
+func OUIOverridesFile(cfg *config.Config) string {
+	if cfg.OUI.OverridesFile != "" {
+		return cfg.OUI.OverridesFile
+	}
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, defaultOUIOverridesFile)
+}
+
+// defaultRulesFile is the name of the rules file looked up in the config directory.
+const defaultRulesFile = "rules.yaml"
+
+// rulesFile is the layout of the rules file.
+type rulesFile struct {
+	Rules []rules.Rule `yaml:"rules"`
+}
+
+// BuildRules loads the rules file configured in cfg, or rules.yaml in the config
+// directory. It returns nil when no rules file is configured and the default one
+// does not exist.
+func BuildRules(cfg *config.Config) (*rules.Set, error) {
+	path := cfg.RulesFile
+	if path == "" {
+		dir, err := paths.ConfigDir()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(dir, defaultRulesFile)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rules file: %w", err)
+	}
+	var file rulesFile
+	if err := yaml.UnmarshalWithOptions(raw, &file, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("parse rules file %s: %w", path, err)
+	}
+	set, err := rules.New(file.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("rules file %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// defaultKnownDevicesFile is the name of the known devices file looked up in the config directory.
+const defaultKnownDevicesFile = "known_devices.yaml"
+
+// knownDevicesFile is the layout of the known devices file.
+type knownDevicesFile struct {
+	Devices []string `yaml:"devices"`
+}
+
+// BuildKnownDevices loads the known devices file configured in cfg, or
+// known_devices.yaml in the config directory, and combines it with the devices
+// annotated in store. When no known devices file is configured and the default
+// one does not exist, only the annotated devices are known.
+func BuildKnownDevices(cfg *config.Config, store *annotations.Store) (*knowndevices.List, error) {
+	path := cfg.KnownDevicesFile
+	if path == "" {
+		dir, err := paths.ConfigDir()
+		if err != nil {
+			return knowndevices.New(nil, store)
+		}
+		path = filepath.Join(dir, defaultKnownDevicesFile)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return knowndevices.New(nil, store)
+		}
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read known devices file: %w", err)
+	}
+	var file knownDevicesFile
+	if err := yaml.UnmarshalWithOptions(raw, &file, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("parse known devices file %s: %w", path, err)
+	}
+	list, err := knowndevices.New(file.Devices, store)
+	if err != nil {
+		return nil, fmt.Errorf("known devices file %s: %w", path, err)
+	}
+	return list, nil
+}
+
+// BuildHistory opens the device inventory in the state directory with the
+// retention and scan interval configured in cfg, reporting invalid entries of
+// the log to logger. It returns nil when the history is disabled.
+func BuildHistory(cfg *config.Config, logger discovery.Logger) (*history.Store, error) {
+	if !cfg.History.Enabled {
+		return nil, nil
+	}
+	store, err := history.OpenDefault(
+		history.WithRetention(cfg.History.Retention),
+		history.WithScanInterval(cfg.ScanInterval),
+		history.WithLogger(logger),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+	return store, nil
+}
+
+// BuildPortScanner creates the port scanner used for on-demand scans, bound to iface.
+// Results are annotated with IANA service names, honoring the configured overrides.
+// A service detector is attached when service detection or TLS inspection is enabled in cfg.
+// Additional options are applied last, e.g. to override the number of workers.
+func BuildPortScanner(cfg *config.Config, iface *discovery.InterfaceInfo, extra ...discovery.PortScannerOption) (*discovery.PortScanner, error) {
+	names, err := cfg.PortScanner.ServiceRegistry()
+	if err != nil {
+		return nil, err
+	}
+	opts := []discovery.PortScannerOption{
+		discovery.WithPortTimeout(cfg.PortScanner.Timeout),
+		discovery.WithServiceNamer(names),
+	}
+
+	sd, tlsCfg := cfg.PortScanner.ServiceDetection, cfg.PortScanner.TLS
+	if sd.Enabled || tlsCfg.Enabled {
+		det, err := fingerprint.New(
+			fingerprint.WithTimeout(sd.Timeout),
+			fingerprint.WithMaxBytes(sd.MaxBytes),
+			fingerprint.WithBannerGrabbing(sd.Enabled),
+			fingerprint.WithTLSInspection(tlsCfg.Enabled),
+			fingerprint.WithTLSPorts(tlsCfg.Ports...),
+		)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, discovery.WithServiceDetector(det))
+	}
+
+	opts = append(opts, extra...)
+	return discovery.NewPortScanner(DefaultPortScanWorkers, iface, opts...), nil
+}
+
+// BuildAutoScan creates the automatic port scan policy configured in cfg, scanning
+// with scanner. It returns nil when automatic port scanning is disabled.
+func BuildAutoScan(cfg *config.Config, scanner autoscan.Scanner, logger discovery.Logger) (*autoscan.Policy, error) {
+	auto := cfg.PortScanner.Auto
+	if !auto.Enabled {
+		return nil, nil
+	}
+	spec, err := cfg.PortScanner.ResolveProfile(auto.Profile)
+	if err != nil {
+		return nil, err
+	}
+	return autoscan.New(scanner,
+		autoscan.WithPorts(spec),
+		autoscan.WithCooldown(auto.Cooldown),
+		autoscan.WithMaxConcurrent(auto.MaxConcurrent),
+		autoscan.WithRatePerMinute(auto.RatePerMinute),
+		autoscan.WithInclude(auto.Include),
+		autoscan.WithExclude(auto.Exclude),
+		autoscan.WithLogger(logger),
+	)
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
+	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/knowndevices"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `
+rules:
+  - name: telnet
+    match: manufacturer ~ 'Espressif' and tcp has 23
+    tags: [risk]
+    alert: telnet open
+  - name: cameras
+    match: name ~ "^cam-"
+    label: Camera
+    category: camera
+`
+
+func TestBuildRules(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+
+	set, err := BuildRules(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, set, "no rules without a rules file")
+
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "whosthere")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rules.yaml"), []byte(testRules), 0o644))
+
+	set, err = BuildRules(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, set)
+	assert.Equal(t, 2, set.Len())
+}
+
+func TestBuildRules_Errors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown key":   "rules:\n  - name: r\n    match: name ~ x\n    lable: typo\n",
+		"invalid match": "rules:\n  - name: r\n    match: name ~\n    tags: [t]\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			cfg := config.DefaultConfig()
+			cfg.RulesFile = path
+			_, err := BuildRules(cfg)
+			assert.Error(t, err)
+		})
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.RulesFile = filepath.Join(dir, "missing.yaml")
+	_, err := BuildRules(cfg)
+	assert.Error(t, err, "a configured rules file has to exist")
+}
+
+func TestBuildKnownDevices(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+
+	store := annotations.New("")
+	_, err := store.Set("mac:aa:bb:cc:dd:ee:ff", annotations.Annotation{Tags: []string{knowndevices.TagTrusted}})
+	require.NoError(t, err)
+	trusted := discovery.NewDevice(net.ParseIP("10.0.0.30"))
+	trusted.SetMAC("aa:bb:cc:dd:ee:ff")
+
+	list, err := BuildKnownDevices(cfg, store)
+	require.NoError(t, err)
+	require.NotNil(t, list, "annotated devices are known without a known devices file")
+	assert.True(t, list.Known(trusted))
+	assert.False(t, list.Known(discovery.NewDevice(net.ParseIP("192.168.1.20"))))
+
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "whosthere")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	content := "devices:\n  - b8:27:eb\n  - 192.168.1.0/24\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "known_devices.yaml"), []byte(content), 0o644))
+
+	list, err = BuildKnownDevices(cfg, nil)
+	require.NoError(t, err)
+	require.NotNil(t, list)
+	assert.True(t, list.Known(discovery.NewDevice(net.ParseIP("192.168.1.20"))))
+	assert.False(t, list.Known(discovery.NewDevice(net.ParseIP("10.0.0.20"))))
+
+	cfg.KnownDevicesFile = filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(cfg.KnownDevicesFile, []byte("devices:\n  - 10.0.0.20-10.0.0.10\n"), 0o644))
+	_, err = BuildKnownDevices(cfg, nil)
+	assert.Error(t, err)
+}
+
+func TestBuildHistory(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+
+	store, err := BuildHistory(cfg, discovery.NoOpLogger{})
+	require.NoError(t, err)
+	require.NotNil(t, store)
+	require.NoError(t, store.Observe(discovery.NewDevice(net.ParseIP("192.168.1.20"))))
+	require.NoError(t, store.Flush())
+	assert.FileExists(t, filepath.Join(os.Getenv("XDG_STATE_HOME"), "whosthere", "history.jsonl"))
+
+	cfg.History.Enabled = false
+	store, err = BuildHistory(cfg, discovery.NoOpLogger{})
+	require.NoError(t, err)
+	assert.Nil(t, store)
+}
+
+func TestOUIOverridesFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+
+	assert.Equal(t, filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "whosthere", "oui_overrides.yaml"), OUIOverridesFile(cfg))
+
+	cfg.OUI.OverridesFile = "/etc/whosthere/oui_overrides.yaml"
+	assert.Equal(t, "/etc/whosthere/oui_overrides.yaml", OUIOverridesFile(cfg))
+}
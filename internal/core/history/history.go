@@ -0,0 +1,935 @@
+// Package history keeps an inventory of the devices seen across runs: when each
+// device was first and last seen, the sessions it was online, the changes of its
+// IP address, MAC address and name, and a summary of every scan.
+//
+// The inventory is stored as an append-only log of JSON lines, compacted into
+// one entry per device and scan after compactEvery appended entries, or when the
+// last compaction is older than compactAge. Devices not seen within the
+// retention, and older changes and scans, are dropped on compaction.
+//
+// Several processes, e.g. the TUI, the daemon and scans run from cron, may share
+// the log. Appends and compactions hold a lock file next to the log, and a
+// compaction reloads the log first, so entries appended by other processes are
+// kept.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/paths"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+const (
+	// DefaultRetention is how long devices, changes and scans are kept by default.
+	DefaultRetention = 30 * 24 * time.Hour
+
+	// defaultFile is the name of the history file in the state directory.
+	defaultFile = "history.jsonl"
+	// maxScans bounds the number of scan summaries kept, regardless of the retention.
+	maxScans = 10000
+	// compactEvery is the number of appended entries after which the log is compacted.
+	compactEvery = 1000
+	// compactAge is the age of the last compaction after which the log is
+	// compacted again, dropping the entries older than the retention.
+	compactAge = 24 * time.Hour
+	// flushEvery is the number of observed entries buffered before they are
+	// appended to the log, see Store.Flush.
+	flushEvery = 100
+	// gapScans is the number of scan intervals after which a device missing from
+	// the scans is offline, and scans further apart leave a gap in the monitoring.
+	gapScans = 3
+)
+
+// Fields of a device whose changes are recorded.
+const (
+	FieldIP   = "ip"
+	FieldMAC  = "mac"
+	FieldName = "name"
+)
+
+// Types of the entries in the log.
+const (
+	entryDevice   = "device"
+	entryChange   = "change"
+	entryRename   = "rename"
+	entryScan     = "scan"
+	entrySession  = "session"
+	entryCoverage = "coverage"
+	// entryCompacted marks the start of a compacted log.
+	entryCompacted = "compacted"
+)
+
+// Record is the inventory entry of a device, stored by its identity, see
+// discovery.Device.Identity.
+type Record struct {
+	ID                 string    `json:"id"`
+	IP                 string    `json:"ip,omitempty"`
+	MAC                string    `json:"mac,omitempty"`
+	Name               string    `json:"name,omitempty"`
+	Hostname           string    `json:"hostname,omitempty"`
+	Manufacturer       string    `json:"manufacturer,omitempty"`
+	Category           string    `json:"category,omitempty"`
+	CategoryConfidence float64   `json:"categoryConfidence,omitempty"`
+	Subnet             string    `json:"subnet,omitempty"`
+	FirstSeen          time.Time `json:"firstSeen"`
+	LastSeen           time.Time `json:"lastSeen"`
+	// LastPortScan is the time of the last port scan, so the cooldown of
+	// automatic port scans holds across restarts.
+	LastPortScan time.Time `json:"lastPortScan,omitzero"`
+	// Changes holds the observed changes of the IP address, MAC address and
+	// name, oldest first. They are stored as separate entries.
+	Changes []Change `json:"-"`
+	// Sessions holds the intervals the device was online, oldest first. They are
+	// stored as separate entries.
+	Sessions []Session `json:"-"`
+}
+
+// Session is an interval of scans that all saw a device, allowing it to be missed
+// by the scans in between for less than three scan intervals. A session seen by
+// a single scan starts and ends at the same time.
+type Session struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// overlap returns how long s overlaps the interval from start to end.
+func (s Session) overlap(start, end time.Time) time.Duration {
+	from, to := s.Start, s.End
+	if from.Before(start) {
+		from = start
+	}
+	if to.After(end) {
+		to = end
+	}
+	if !to.After(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// Change is an observed change of a field of a device.
+type Change struct {
+	Time  time.Time `json:"time"`
+	Field string    `json:"field"`
+	Old   string    `json:"old"`
+	New   string    `json:"new"`
+}
+
+// Scan summarizes a completed scan.
+type Scan struct {
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Devices  int           `json:"devices"`
+	// New is the number of devices seen for the first time.
+	New int `json:"new"`
+	// Seen holds the identities of the devices observed since the previous scan.
+	// It is only kept in the log until the next compaction, which stores the last
+	// seen time of every device instead.
+	Seen []string `json:"seen,omitempty"`
+}
+
+// entry is a line in the log.
+type entry struct {
+	Type    string   `json:"type"`
+	ID      string   `json:"id,omitempty"`
+	To      string   `json:"to,omitempty"`
+	Device  *Record  `json:"device,omitempty"`
+	Change  *Change  `json:"change,omitempty"`
+	Session *Session `json:"session,omitempty"`
+	Scan    *Scan    `json:"scan,omitempty"`
+	// Time is the time of a compaction.
+	Time time.Time `json:"time,omitzero"`
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithRetention sets how long devices, changes and scans are kept. Devices not
+// seen within the retention are dropped.
+func WithRetention(d time.Duration) Option {
+	return func(s *Store) {
+		if d > 0 {
+			s.retention = d
+		}
+	}
+}
+
+// WithLogger sets the logger used to report invalid entries of the log.
+func WithLogger(l discovery.Logger) Option {
+	return func(s *Store) {
+		if l != nil {
+			s.logger = l
+		}
+	}
+}
+
+// WithScanInterval sets the interval between scans. A device missing from the
+// scans for three intervals is offline, and scans further apart leave a gap in
+// the monitoring, e.g. while whosthere was not running.
+func WithScanInterval(d time.Duration) Option {
+	return func(s *Store) {
+		if d > 0 {
+			s.maxGap = gapScans * d
+		}
+	}
+}
+
+// Store holds the inventory and persists it to a log file. The zero path keeps
+// it in memory only.
+//
+// Thread-safe. A nil Store records nothing.
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	maxGap    time.Duration
+	devices   map[string]*Record
+	scans     []Scan
+	// coverage holds the intervals the network was monitored, oldest first.
+	coverage []Session
+	now      func() time.Time
+	logger   discovery.Logger
+
+	// seen holds the identities observed since the last scan summary, and added
+	// the number of them seen for the first time.
+	seen  map[string]struct{}
+	added int
+	// pending holds the observed entries not yet appended to the log.
+	pending []entry
+	// appended is the number of entries appended since the last compaction, at
+	// compacted, by this process or, when the log was opened, by any.
+	appended  int
+	compacted time.Time
+}
+
+// DefaultPath returns the path of the history file in the state directory.
+func DefaultPath() (string, error) {
+	dir, err := paths.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, defaultFile), nil
+}
+
+// New creates an empty store persisting to path.
+func New(path string, opts ...Option) *Store {
+	s := &Store{
+		path:      path,
+		retention: DefaultRetention,
+		maxGap:    gapScans * discovery.DefaultScanInterval,
+		devices:   make(map[string]*Record),
+		seen:      make(map[string]struct{}),
+		now:       time.Now,
+		logger:    discovery.NoOpLogger{},
+		// an empty log is compact
+		compacted: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Open creates a store persisting to path and loads the inventory saved to it
+// before. A missing file holds no devices. The log is compacted when it grew
+// large or was not compacted recently, and when it holds invalid entries, e.g.
+// a line cut short by a crash, which are dropped.
+func Open(path string, opts ...Option) (*Store, error) {
+	s := New(path, opts...)
+	if path == "" {
+		return s, nil
+	}
+	unlock, err := lock(path)
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	defer unlock()
+
+	valid, err := s.load()
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	now := s.now()
+	s.prune(now)
+	if !valid || s.compactDue(now) {
+		if err := s.rewrite(now); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// OpenDefault opens the store persisting to the history file in the state directory.
+func OpenDefault(opts ...Option) (*Store, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Open(path, opts...)
+}
+
+// load replaces the inventory with the one saved to the log, and reports
+// whether all entries were valid. Invalid entries, e.g. a line cut short by a
+// crash, are skipped and dropped on the next compaction. The caller holds the
+// lock.
+func (s *Store) load() (bool, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = f.Close() }()
+
+	s.devices = make(map[string]*Record)
+	s.scans, s.coverage = nil, nil
+	s.appended, s.compacted = 0, time.Time{}
+	valid := true
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var e entry
+			if jsonErr := json.Unmarshal(line, &e); jsonErr != nil {
+				s.logger.Log(context.Background(), slog.LevelWarn, "skipping invalid history entry",
+					"file", s.path, "line", n, "error", jsonErr)
+				valid = false
+			} else {
+				s.replay(e)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return valid, nil
+		}
+		if err != nil {
+			return valid, err
+		}
+	}
+}
+
+// replay applies an entry read from the log.
+func (s *Store) replay(e entry) {
+	if e.Type == entryCompacted {
+		s.compacted, s.appended = e.Time, 0
+		return
+	}
+	s.appended++
+	switch e.Type {
+	case entryDevice:
+		if e.Device == nil || e.Device.ID == "" {
+			return
+		}
+		rec := *e.Device
+		if old, ok := s.devices[rec.ID]; ok {
+			rec.Changes = old.Changes
+			rec.Sessions = old.Sessions
+			if !old.FirstSeen.IsZero() && old.FirstSeen.Before(rec.FirstSeen) {
+				rec.FirstSeen = old.FirstSeen
+			}
+			if old.LastSeen.After(rec.LastSeen) {
+				rec.LastSeen = old.LastSeen
+			}
+			if old.LastPortScan.After(rec.LastPortScan) {
+				rec.LastPortScan = old.LastPortScan
+			}
+		}
+		s.devices[rec.ID] = &rec
+	case entryChange:
+		if rec, ok := s.devices[e.ID]; ok && e.Change != nil {
+			rec.Changes = append(rec.Changes, *e.Change)
+		}
+	case entryRename:
+		if rec, ok := s.devices[e.ID]; ok && e.To != "" {
+			delete(s.devices, e.ID)
+			rec.ID = e.To
+			s.devices[e.To] = rec
+		}
+	case entrySession:
+		if rec, ok := s.devices[e.ID]; ok && e.Session != nil {
+			rec.Sessions = append(rec.Sessions, *e.Session)
+		}
+	case entryCoverage:
+		if e.Session != nil {
+			s.coverage = append(s.coverage, *e.Session)
+		}
+	case entryScan:
+		if e.Scan != nil {
+			s.applyScan(*e.Scan)
+		}
+	}
+}
+
+// applyScan updates the last seen time and the sessions of the devices seen by
+// scan, and the monitoring coverage.
+func (s *Store) applyScan(scan Scan) {
+	// the scan continues the monitoring, and the sessions, when the previous scan was recent enough
+	continuous := false
+	if n := len(s.coverage); n > 0 && scan.Time.Sub(s.coverage[n-1].End) <= s.maxGap {
+		continuous = true
+		if scan.Time.After(s.coverage[n-1].End) {
+			s.coverage[n-1].End = scan.Time
+		}
+	} else {
+		s.coverage = append(s.coverage, Session{Start: scan.Time, End: scan.Time})
+	}
+
+	for _, id := range scan.Seen {
+		rec, ok := s.devices[id]
+		if !ok {
+			continue
+		}
+		if scan.Time.After(rec.LastSeen) {
+			rec.LastSeen = scan.Time
+		}
+		n := len(rec.Sessions)
+		switch {
+		case n > 0 && !scan.Time.After(rec.Sessions[n-1].End):
+		case n > 0 && continuous && scan.Time.Sub(rec.Sessions[n-1].End) <= s.maxGap:
+			rec.Sessions[n-1].End = scan.Time
+		default:
+			rec.Sessions = append(rec.Sessions, Session{Start: scan.Time, End: scan.Time})
+		}
+	}
+
+	scan.Seen = nil
+	s.scans = append(s.scans, scan)
+}
+
+// Observe records d in the inventory: a device seen for the first time is
+// added, and changes of its IP address, MAC address and name are recorded.
+// The entries are appended to the log in batches, and the last seen time is
+// saved with the next scan summary, see RecordScan and Flush.
+func (s *Store) Observe(d *discovery.Device) error {
+	if s == nil || d == nil {
+		return nil
+	}
+	cur := recordOf(d)
+	if cur.ID == "" {
+		return nil
+	}
+	at := cur.LastSeen
+	if at.IsZero() {
+		at = time.Now()
+		cur.LastSeen = at
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []entry
+	rec, ok := s.devices[cur.ID]
+	if byIP := "ip:" + cur.IP; !ok && cur.IP != "" && byIP != cur.ID {
+		// the device was recorded before its MAC address or hostname was known
+		if rec, ok = s.devices[byIP]; ok {
+			delete(s.devices, byIP)
+			rec.ID = cur.ID
+			s.devices[cur.ID] = rec
+			entries = append(entries, entry{Type: entryRename, ID: byIP, To: cur.ID})
+		}
+	}
+
+	if !ok {
+		if cur.FirstSeen.IsZero() || cur.FirstSeen.After(at) {
+			cur.FirstSeen = at
+		}
+		rec = &cur
+		s.devices[cur.ID] = rec
+		s.added++
+		entries = append(entries, entry{Type: entryDevice, Device: rec.snapshot()})
+	} else {
+		for _, c := range rec.diff(cur, at) {
+			rec.Changes = append(rec.Changes, c)
+			entries = append(entries, entry{Type: entryChange, ID: rec.ID, Change: &c})
+		}
+		if rec.update(cur) {
+			entries = append(entries, entry{Type: entryDevice, Device: rec.snapshot()})
+		}
+		if at.After(rec.LastSeen) {
+			rec.LastSeen = at
+		}
+	}
+	s.seen[rec.ID] = struct{}{}
+
+	s.pending = append(s.pending, entries...)
+	if len(s.pending) < flushEvery {
+		return nil
+	}
+	return s.flush()
+}
+
+// Flush appends the observed entries not yet saved to the log, e.g. before
+// the process exits.
+func (s *Store) Flush() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// flush appends the pending entries to the log.
+func (s *Store) flush(extra ...entry) error {
+	entries := append(s.pending, extra...)
+	if err := s.append(entries...); err != nil {
+		return err
+	}
+	s.pending = nil
+	return nil
+}
+
+// RecordScan saves a summary of a completed scan, with the devices observed
+// since the previous one, and compacts the log when it grew large or was not
+// compacted recently.
+func (s *Store) RecordScan(stats *discovery.ScanStats) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan := Scan{Time: s.now(), New: s.added, Seen: slices.Sorted(maps.Keys(s.seen))}
+	if stats != nil {
+		scan.Duration = stats.Duration
+		scan.Devices = stats.Count
+	}
+	s.seen = make(map[string]struct{})
+	s.added = 0
+
+	if err := s.flush(entry{Type: entryScan, Scan: &scan}); err != nil {
+		return err
+	}
+	s.applyScan(scan)
+	if now := s.now(); len(s.scans) > maxScans || s.compactDue(now) {
+		return s.compact(now)
+	}
+	return nil
+}
+
+// compactDue reports whether the log grew large or was not compacted recently.
+func (s *Store) compactDue(now time.Time) bool {
+	return s.appended >= compactEvery || now.Sub(s.compacted) >= compactAge
+}
+
+// Records returns a copy of all records, ordered by identity.
+func (s *Store) Records() []Record {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Record, 0, len(s.devices))
+	for _, id := range slices.Sorted(maps.Keys(s.devices)) {
+		out = append(out, s.devices[id].clone())
+	}
+	return out
+}
+
+// IDs returns the identities of all recorded devices, sorted.
+func (s *Store) IDs() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Sorted(maps.Keys(s.devices))
+}
+
+// Record returns a copy of the record stored under the identity id.
+func (s *Store) Record(id string) (Record, bool) {
+	if s == nil {
+		return Record{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.devices[id]
+	if !ok {
+		return Record{}, false
+	}
+	return rec.clone(), true
+}
+
+// Scans returns a copy of the scan summaries, oldest first.
+func (s *Store) Scans() []Scan {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.scans)
+}
+
+// Devices returns the recorded devices with a known IP address, e.g. to
+// populate the device list before the first scan completed.
+func (s *Store) Devices() []*discovery.Device {
+	var out []*discovery.Device
+	for _, rec := range s.Records() {
+		if d := rec.Device(); d != nil {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Device builds a device from the record, or returns nil when its IP address is unknown.
+func (r Record) Device() *discovery.Device {
+	ip := net.ParseIP(r.IP)
+	if ip == nil {
+		return nil
+	}
+	d := discovery.NewDevice(ip)
+	d.SetMAC(r.MAC)
+	d.SetDisplayName(r.Name)
+	d.SetManufacturer(r.Manufacturer)
+	d.SetSubnet(r.Subnet)
+	if r.Category != "" {
+		d.SetCategory(r.Category, r.CategoryConfidence)
+	}
+	if r.Hostname != "" {
+		d.AddExtraData(discovery.HostnameKey, r.Hostname)
+	}
+	d.SetFirstSeen(r.FirstSeen)
+	d.SetLastSeen(r.LastSeen)
+	d.SetLastPortScan(r.LastPortScan)
+	return d
+}
+
+// recordOf returns the record of d, without changes.
+func recordOf(d *discovery.Device) Record {
+	rec := Record{
+		ID:           d.Identity(),
+		MAC:          d.MAC(),
+		Name:         d.DisplayName(),
+		Hostname:     d.ExtraData()[discovery.HostnameKey],
+		Manufacturer: d.Manufacturer(),
+		Category:     d.Category(),
+		Subnet:       d.Subnet(),
+		FirstSeen:    d.FirstSeen(),
+		LastSeen:     d.LastSeen(),
+		LastPortScan: d.LastPortScan(),
+	}
+	if ip := d.IP(); ip != nil {
+		rec.IP = ip.String()
+	}
+	if rec.Category != "" {
+		rec.CategoryConfidence = d.CategoryConfidence()
+	}
+	return rec
+}
+
+// diff returns the changes of the IP address, MAC address and name from r to
+// cur. Fields cur does not know are not changed.
+func (r *Record) diff(cur Record, at time.Time) []Change {
+	var out []Change
+	for _, f := range []struct{ field, old, new string }{
+		{FieldIP, r.IP, cur.IP},
+		{FieldMAC, r.MAC, cur.MAC},
+		{FieldName, r.Name, cur.Name},
+	} {
+		if f.old != "" && f.new != "" && f.old != f.new {
+			out = append(out, Change{Time: at, Field: f.field, Old: f.old, New: f.new})
+		}
+	}
+	return out
+}
+
+// update copies the fields cur knows into r and reports whether any of them changed.
+func (r *Record) update(cur Record) bool {
+	changed := false
+	set := func(dst *string, v string) {
+		if v != "" && *dst != v {
+			*dst = v
+			changed = true
+		}
+	}
+	set(&r.IP, cur.IP)
+	set(&r.MAC, cur.MAC)
+	set(&r.Name, cur.Name)
+	set(&r.Hostname, cur.Hostname)
+	set(&r.Manufacturer, cur.Manufacturer)
+	set(&r.Subnet, cur.Subnet)
+	if cur.Category != "" && (r.Category != cur.Category || r.CategoryConfidence != cur.CategoryConfidence) {
+		r.Category, r.CategoryConfidence = cur.Category, cur.CategoryConfidence
+		changed = true
+	}
+	if cur.LastPortScan.After(r.LastPortScan) {
+		r.LastPortScan = cur.LastPortScan
+		changed = true
+	}
+	return changed
+}
+
+// clone returns a deep copy of r.
+func (r *Record) clone() Record {
+	rec := *r
+	rec.Changes = slices.Clone(r.Changes)
+	rec.Sessions = slices.Clone(r.Sessions)
+	return rec
+}
+
+// snapshot returns a copy of r for a device entry.
+func (r *Record) snapshot() *Record {
+	rec := *r
+	rec.Changes = nil
+	rec.Sessions = nil
+	return &rec
+}
+
+// append writes entries to the end of the log.
+func (s *Store) append(entries ...entry) error {
+	if s.path == "" || len(entries) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	unlock, err := lock(s.path)
+	if err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	defer unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("save history: %w", err)
+		}
+	}
+	s.appended += len(entries)
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	return nil
+}
+
+// compact reloads the log, to include the entries appended by other processes,
+// drops the devices, changes and scans older than the retention and rewrites
+// the log with one entry per device, change and scan, replacing it atomically.
+// Pending entries are appended first.
+func (s *Store) compact(now time.Time) error {
+	if s.path == "" {
+		s.prune(now)
+		s.appended, s.compacted = 0, now
+		return nil
+	}
+	if err := s.flush(); err != nil {
+		return err
+	}
+	unlock, err := lock(s.path)
+	if err != nil {
+		return fmt.Errorf("compact history: %w", err)
+	}
+	defer unlock()
+	if _, err := s.load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("compact history: %w", err)
+	}
+	s.prune(now)
+	return s.rewrite(now)
+}
+
+// prune drops the devices, changes and scans older than the retention.
+func (s *Store) prune(now time.Time) {
+	cutoff := now.Add(-s.retention)
+	for id, rec := range s.devices {
+		if rec.LastSeen.Before(cutoff) {
+			delete(s.devices, id)
+			continue
+		}
+		rec.Changes = slices.DeleteFunc(rec.Changes, func(c Change) bool { return c.Time.Before(cutoff) })
+		rec.Sessions = slices.DeleteFunc(rec.Sessions, func(se Session) bool { return se.End.Before(cutoff) })
+	}
+	s.scans = slices.DeleteFunc(s.scans, func(sc Scan) bool { return sc.Time.Before(cutoff) })
+	s.coverage = slices.DeleteFunc(s.coverage, func(se Session) bool { return se.End.Before(cutoff) })
+	if n := len(s.scans) - maxScans; n > 0 {
+		s.scans = slices.Delete(s.scans, 0, n)
+	}
+}
+
+// rewrite replaces the log with one entry per device, change and scan,
+// atomically. The caller holds the lock.
+func (s *Store) rewrite(now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("compact history: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("compact history: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	var encErr error
+	write := func(e entry) {
+		if encErr == nil {
+			encErr = enc.Encode(e)
+		}
+	}
+	write(entry{Type: entryCompacted, Time: now})
+	for _, id := range slices.Sorted(maps.Keys(s.devices)) {
+		rec := s.devices[id]
+		write(entry{Type: entryDevice, Device: rec.snapshot()})
+		for _, c := range rec.Changes {
+			write(entry{Type: entryChange, ID: id, Change: &c})
+		}
+		for _, se := range rec.Sessions {
+			write(entry{Type: entrySession, ID: id, Session: &se})
+		}
+	}
+	for _, se := range s.coverage {
+		write(entry{Type: entryCoverage, Session: &se})
+	}
+	for _, sc := range s.scans {
+		write(entry{Type: entryScan, Scan: &sc})
+	}
+	if err := f.Close(); encErr == nil {
+		encErr = err
+	}
+	if encErr != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("compact history: %w", encErr)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("compact history: %w", err)
+	}
+	s.appended, s.compacted = 0, now
+	return nil
+}
+
+// Presence summarizes how long a device was online within a window ending now.
+type Presence struct {
+	Window time.Duration
+	// Monitored is how long the network was scanned within the window, and
+	// Online how long the device was seen by these scans.
+	Monitored time.Duration
+	Online    time.Duration
+	// Availability is the fraction of the monitored time the device was online.
+	Availability float64
+	// Drops is the number of times the device went offline while monitored.
+	Drops int
+	// Timeline holds the availability in equal buckets of the window, oldest
+	// first, or -1 for buckets that were not monitored.
+	Timeline []float64
+}
+
+// MarshalJSON encodes the durations of the presence in seconds.
+func (p Presence) MarshalJSON() ([]byte, error) {
+	type temp struct {
+		Window       float64   `json:"windowSeconds"`
+		Monitored    float64   `json:"monitoredSeconds"`
+		Online       float64   `json:"onlineSeconds"`
+		Availability float64   `json:"availability"`
+		Drops        int       `json:"drops"`
+		Timeline     []float64 `json:"timeline,omitempty"`
+	}
+	return json.Marshal(temp{
+		Window:       p.Window.Seconds(),
+		Monitored:    p.Monitored.Seconds(),
+		Online:       p.Online.Seconds(),
+		Availability: p.Availability,
+		Drops:        p.Drops,
+		Timeline:     p.Timeline,
+	})
+}
+
+// Presence summarizes the sessions of the device with identity id within the
+// window ending now, with a timeline of the given number of buckets.
+func (s *Store) Presence(id string, window time.Duration, buckets int) (Presence, bool) {
+	if s == nil {
+		return Presence{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.devices[id]
+	if !ok {
+		return Presence{}, false
+	}
+	return presence(rec.Sessions, s.coverage, s.now(), window, buckets), true
+}
+
+// presence computes the presence of a device with sessions within the window
+// ending at now, given the monitoring coverage.
+func presence(sessions, coverage []Session, now time.Time, window time.Duration, buckets int) Presence {
+	start := now.Add(-window)
+	p := Presence{Window: window}
+	p.Monitored, p.Online = covered(coverage, start, now), covered(sessions, start, now)
+	if p.Monitored > 0 {
+		p.Availability = min(float64(p.Online)/float64(p.Monitored), 1)
+	}
+
+	// a session ending before the monitoring did is a drop
+	for _, se := range sessions {
+		if se.End.Before(start) || !se.End.Before(now) {
+			continue
+		}
+		for _, c := range coverage {
+			if !se.End.Before(c.Start) && se.End.Before(c.End) {
+				p.Drops++
+				break
+			}
+		}
+	}
+
+	if buckets > 0 {
+		p.Timeline = make([]float64, buckets)
+		size := window / time.Duration(buckets)
+		for i := range p.Timeline {
+			from := start.Add(time.Duration(i) * size)
+			to := from.Add(size)
+			if i == buckets-1 {
+				to = now.Add(time.Nanosecond) // include a scan right now
+			}
+			monitored := covered(coverage, from, to)
+			switch {
+			case monitored > 0:
+				p.Timeline[i] = min(float64(covered(sessions, from, to))/float64(monitored), 1)
+			case anySeen(sessions, from, to):
+				// a single scan has no duration
+				p.Timeline[i] = 1
+			case anySeen(coverage, from, to):
+				p.Timeline[i] = 0
+			default:
+				p.Timeline[i] = -1
+			}
+		}
+	}
+	return p
+}
+
+// covered returns how long the intervals overlap the interval from start to end.
+func covered(intervals []Session, start, end time.Time) time.Duration {
+	var total time.Duration
+	for _, se := range intervals {
+		total += se.overlap(start, end)
+	}
+	return total
+}
+
+// anySeen reports whether any of the intervals lies within the interval from start to end.
+func anySeen(intervals []Session, start, end time.Time) bool {
+	for _, se := range intervals {
+		if !se.End.Before(start) && se.Start.Before(end) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,426 @@
+package history
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func newDevice(ip, mac, name string, seen time.Time) *discovery.Device {
+	d := discovery.NewDevice(net.ParseIP(ip))
+	d.SetMAC(mac)
+	d.SetDisplayName(name)
+	d.SetFirstSeen(seen)
+	d.SetLastSeen(seen)
+	return d
+}
+
+func TestStore_PersistsAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	first := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	printer := newDevice("192.168.1.20", "aa:bb:cc:dd:ee:ff", "printer.local", first)
+	printer.SetManufacturer("Brother")
+	if err := s.Observe(printer); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := s.RecordScan(&discovery.ScanStats{Count: 1, Duration: 3 * time.Second}); err != nil {
+		t.Fatalf("RecordScan error: %v", err)
+	}
+
+	// The printer moves to another IP address and is renamed.
+	later := first.Add(time.Hour)
+	if err := s.Observe(newDevice("192.168.1.21", "aa:bb:cc:dd:ee:ff", "office-printer.local", later)); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := s.RecordScan(&discovery.ScanStats{Count: 1}); err != nil {
+		t.Fatalf("RecordScan error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	records := reopened.Records()
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %+v", records)
+	}
+	rec := records[0]
+	if rec.ID != "mac:aa:bb:cc:dd:ee:ff" || rec.IP != "192.168.1.21" || rec.Name != "office-printer.local" || rec.Manufacturer != "Brother" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if !rec.FirstSeen.Equal(first) || !rec.LastSeen.After(later) {
+		t.Errorf("expected first seen %v and last seen after %v, got %v and %v", first, later, rec.FirstSeen, rec.LastSeen)
+	}
+	if len(rec.Changes) != 2 || rec.Changes[0].Field != FieldIP || rec.Changes[0].Old != "192.168.1.20" || rec.Changes[1].Field != FieldName {
+		t.Errorf("expected IP and name changes, got %+v", rec.Changes)
+	}
+
+	scans := reopened.Scans()
+	if len(scans) != 2 || scans[0].Devices != 1 || scans[0].New != 1 || scans[0].Duration != 3*time.Second || scans[1].New != 0 {
+		t.Errorf("unexpected scans %+v", scans)
+	}
+
+	devices := reopened.Devices()
+	if len(devices) != 1 || devices[0].IP().String() != "192.168.1.21" || devices[0].Identity() != rec.ID {
+		t.Errorf("expected the printer to be restored, got %v", devices)
+	}
+}
+
+func TestStore_PersistsLastPortScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	seen := time.Now().Add(-time.Hour).Truncate(time.Second)
+	d := newDevice("192.168.1.25", "aa:bb:cc:dd:ee:01", "", seen)
+	if err := s.Observe(d); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	// the device is port scanned and observed again by the next discovery scan
+	d.SetLastPortScan(seen.Add(time.Minute))
+	if err := s.Observe(d); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	devices := reopened.Devices()
+	if len(devices) != 1 || !devices[0].LastPortScan().Equal(seen.Add(time.Minute)) {
+		t.Errorf("expected the last port scan time to be restored, got %v", devices)
+	}
+}
+
+func TestStore_RenamesDevicesRecordedByIP(t *testing.T) {
+	s := New("")
+	seen := time.Now()
+	if err := s.Observe(newDevice("192.168.1.30", "", "", seen)); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := s.Observe(newDevice("192.168.1.30", "aa:bb:cc:00:11:22", "", seen)); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+
+	records := s.Records()
+	if len(records) != 1 || records[0].ID != "mac:aa:bb:cc:00:11:22" || records[0].MAC != "aa:bb:cc:00:11:22" {
+		t.Errorf("expected the record to move to the MAC address, got %+v", records)
+	}
+	if len(records[0].Changes) != 0 {
+		t.Errorf("a resolved MAC address is no change, got %+v", records[0].Changes)
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, WithRetention(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	now := time.Now()
+	if err := s.Observe(newDevice("192.168.1.40", "aa:bb:cc:00:00:01", "", now.Add(-48*time.Hour))); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := s.Observe(newDevice("192.168.1.41", "aa:bb:cc:00:00:02", "", now)); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	reopened, err := Open(path, WithRetention(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	records := reopened.Records()
+	if len(records) != 1 || records[0].IP != "192.168.1.41" {
+		t.Errorf("expected only the recent device to be kept, got %+v", records)
+	}
+}
+
+func TestOpen_CompactsAndDropsInvalidTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	seen := time.Now()
+	for _, name := range []string{"a.local", "b.local", "c.local"} {
+		if err := s.Observe(newDevice("192.168.1.50", "aa:bb:cc:00:00:03", name, seen)); err != nil {
+			t.Fatalf("Observe error: %v", err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	_, _ = f.WriteString(`{"type":"device","device":{"id":`)
+	_ = f.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if records := reopened.Records(); len(records) != 1 || records[0].Name != "c.local" || len(records[0].Changes) != 2 {
+		t.Errorf("unexpected records %+v", records)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	// the compaction marker, one device entry and two change entries
+	if lines := strings.Count(string(raw), "\n"); lines != 4 {
+		t.Errorf("expected the compacted log to hold 4 entries, got %d:\n%s", lines, raw)
+	}
+}
+
+func TestOpen_SkipsInvalidEntriesInTheMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	seen := time.Now()
+	if err := s.Observe(newDevice("192.168.1.50", "aa:bb:cc:00:00:03", "a.local", seen)); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	_, _ = f.WriteString(`{"type":"device","device":{"id":` + "\n")
+	_ = f.Close()
+	if err := s.Observe(newDevice("192.168.1.51", "aa:bb:cc:00:00:04", "b.local", seen)); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	var names []string
+	for _, rec := range reopened.Records() {
+		names = append(names, rec.Name)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"a.local", "b.local"}) {
+		t.Errorf("expected the entries around the invalid one to be kept, got %v", names)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if strings.Contains(string(raw), `{"id":`+"\n") {
+		t.Errorf("expected the invalid entry to be dropped on compaction:\n%s", raw)
+	}
+}
+
+func TestStore_BatchesAppendsAndCompactsRarely(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if err := s.Observe(newDevice("192.168.1.60", "aa:bb:cc:00:00:04", "", time.Now())); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected observations to be buffered until the scan is recorded, got %v", err)
+	}
+	if err := s.RecordScan(&discovery.ScanStats{Count: 1}); err != nil {
+		t.Fatalf("RecordScan error: %v", err)
+	}
+	// a log that was never compacted is compacted once
+	if _, err := Open(path); err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+
+	// A recently compacted log is not rewritten when opened, e.g. by every scan run.
+	if _, err := Open(path); err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("expected the log to be left as is, got:\n%s", after)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("expected the lock to be released, got %v", err)
+	}
+}
+
+func TestStore_CompactionKeepsAppendsOfOtherProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	b, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	seen := time.Now()
+	if err := a.Observe(newDevice("192.168.1.70", "aa:bb:cc:00:00:05", "", seen)); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := b.Observe(newDevice("192.168.1.71", "aa:bb:cc:00:00:06", "", seen)); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := b.RecordScan(&discovery.ScanStats{Count: 1}); err != nil {
+		t.Fatalf("RecordScan error: %v", err)
+	}
+	a.mu.Lock()
+	err = a.compact(a.now())
+	a.mu.Unlock()
+	if err != nil {
+		t.Fatalf("compact error: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if records := reopened.Records(); len(records) != 2 {
+		t.Errorf("expected the devices of both processes to be kept, got %+v", records)
+	}
+	if scans := reopened.Scans(); len(scans) != 1 {
+		t.Errorf("expected the scan of the other process to be kept, got %+v", scans)
+	}
+}
+
+func TestLock_RemovesStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if err := os.WriteFile(path+".lock", []byte("1"), 0o644); err != nil {
+		t.Fatalf("write lock: %v", err)
+	}
+	old := time.Now().Add(-2 * lockStale)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	unlock, err := lock(path)
+	if err != nil {
+		t.Fatalf("lock error: %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("expected the lock to be released, got %v", err)
+	}
+}
+
+func TestStore_NilIsNoOp(t *testing.T) {
+	var s *Store
+	if err := s.Observe(discovery.NewDevice(net.ParseIP("192.168.1.1"))); err != nil {
+		t.Errorf("Observe error: %v", err)
+	}
+	if err := s.RecordScan(&discovery.ScanStats{}); err != nil {
+		t.Errorf("RecordScan error: %v", err)
+	}
+	if len(s.Devices()) != 0 || len(s.Scans()) != 0 {
+		t.Errorf("expected a nil store to be empty")
+	}
+}
+
+func TestStore_Sessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, WithScanInterval(time.Minute))
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	start := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	now := start
+	s.now = func() time.Time { return now }
+
+	printer := newDevice("192.168.1.20", "aa:bb:cc:dd:ee:ff", "printer.local", start)
+	// Online for 10 minutes, missed by 2 scans, back for 5 minutes, then offline
+	// for 10 minutes, and online again after a gap in the monitoring.
+	for i := 0; i <= 30; i++ {
+		now = start.Add(time.Duration(i) * time.Minute)
+		if online := i <= 10 || (i >= 13 && i <= 18); online {
+			printer.SetLastSeen(now)
+			if err := s.Observe(printer); err != nil {
+				t.Fatalf("Observe error: %v", err)
+			}
+		}
+		if err := s.RecordScan(&discovery.ScanStats{}); err != nil {
+			t.Fatalf("RecordScan error: %v", err)
+		}
+	}
+	// whosthere was not running for 20 minutes
+	now = start.Add(50 * time.Minute)
+	printer.SetLastSeen(now)
+	if err := s.Observe(printer); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := s.RecordScan(&discovery.ScanStats{}); err != nil {
+		t.Fatalf("RecordScan error: %v", err)
+	}
+
+	want := []Session{
+		{Start: start, End: start.Add(18 * time.Minute)},
+		{Start: start.Add(50 * time.Minute), End: start.Add(50 * time.Minute)},
+	}
+	rec, _ := s.Record("mac:aa:bb:cc:dd:ee:ff")
+	if !slices.Equal(rec.Sessions, want) {
+		t.Errorf("expected sessions %v, got %v", want, rec.Sessions)
+	}
+
+	p, ok := s.Presence("mac:aa:bb:cc:dd:ee:ff", time.Hour, 6)
+	if !ok {
+		t.Fatal("expected presence")
+	}
+	if p.Monitored != 30*time.Minute || p.Online != 18*time.Minute || p.Drops != 1 {
+		t.Errorf("unexpected presence %+v", p)
+	}
+	if p.Availability != 0.6 {
+		t.Errorf("expected 60%% availability, got %v", p.Availability)
+	}
+	if wantTimeline := []float64{-1, 1, 0.8, 0, 0, 1}; !slices.Equal(p.Timeline, wantTimeline) {
+		t.Errorf("expected timeline %v, got %v", wantTimeline, p.Timeline)
+	}
+
+	// Sessions and coverage survive compaction.
+	reopened, err := Open(path, WithScanInterval(time.Minute))
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	reopened.now = s.now
+	rec, _ = reopened.Record("mac:aa:bb:cc:dd:ee:ff")
+	if !slices.EqualFunc(rec.Sessions, want, func(a, b Session) bool { return a.Start.Equal(b.Start) && a.End.Equal(b.End) }) {
+		t.Errorf("expected sessions %v after reopening, got %v", want, rec.Sessions)
+	}
+	if got, _ := reopened.Presence("mac:aa:bb:cc:dd:ee:ff", time.Hour, 0); got.Monitored != p.Monitored || got.Drops != 1 {
+		t.Errorf("expected the same presence after reopening, got %+v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// lockTimeout bounds how long to wait for another process holding the lock.
+	lockTimeout = 5 * time.Second
+	// lockStale is the age after which a lock file is considered left behind by
+	// a crashed process and removed. The lock is only held while writing the log.
+	lockStale = 30 * time.Second
+	// lockPoll is the interval at which a held lock is checked again.
+	lockPoll = 10 * time.Millisecond
+)
+
+// lock takes the lock of the log at path, shared with other processes, by
+// exclusively creating a lock file next to it. It returns a function that
+// releases the lock.
+func lock(path string) (func(), error) {
+	name := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, _ = f.WriteString(strconv.Itoa(os.Getpid()))
+			_ = f.Close()
+			return func() { _ = os.Remove(name) }, nil
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			// the directory of the log does not exist yet, so nobody else writes to it
+			return func() {}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("lock: %w", err)
+		}
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > lockStale {
+			_ = os.Remove(name)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("lock: %s is held by another process", name)
+		}
+		time.Sleep(lockPoll)
+	}
+}
@@ -0,0 +1,54 @@
+// Package knowndevices decides which devices are known on the network: devices
+// on the allowlist and devices trusted or named by hand, e.g. in the TUI.
+package knowndevices
+
+import (
+	"slices"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
+	"github.com/ramonvermeulen/whosthere/internal/core/devicefilter"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// TagTrusted is the annotation tag of devices trusted by hand.
+const TagTrusted = "trusted"
+
+var _ discovery.KnownDevices = (*List)(nil)
+
+// List holds the known devices. It implements discovery.KnownDevices.
+type List struct {
+	allow       *devicefilter.Filter
+	annotations *annotations.Store
+}
+
+// New creates a list of the devices matching any of the allowlist rules, see
+// devicefilter.Parse for the syntax, and the devices trusted or named in store.
+func New(allow []string, store *annotations.Store) (*List, error) {
+	f, err := devicefilter.Parse(allow)
+	if err != nil {
+		return nil, err
+	}
+	return &List{allow: f, annotations: store}, nil
+}
+
+// Known reports whether d is on the allowlist, or annotated with the trusted
+// tag or a custom name. Other annotations, e.g. a note asking who the device
+// belongs to, do not make a device known.
+func (l *List) Known(d *discovery.Device) bool {
+	if l.allow.Match(d) {
+		return true
+	}
+	a, _, ok := l.annotations.Lookup(d)
+	return ok && (a.Name != "" || slices.Contains(a.Tags, TagTrusted))
+}
+
+// Unknown returns the devices that are not known, in order.
+func (l *List) Unknown(devices []*discovery.Device) []*discovery.Device {
+	var out []*discovery.Device
+	for _, d := range devices {
+		if !l.Known(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package knowndevices
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestList_Known(t *testing.T) {
+	store := annotations.New("")
+	if _, err := store.Set("mac:00:11:32:aa:bb:cc", annotations.Annotation{Tags: []string{TagTrusted}}); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if _, err := store.Set("mac:00:11:32:aa:bb:ce", annotations.Annotation{Name: "printer"}); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if _, err := store.Set("mac:00:11:32:aa:bb:cf", annotations.Annotation{Notes: "who is this?", Tags: []string{"iot"}}); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	list, err := New([]string{"b8:27:eb", "aa:bb:cc:dd:ee:ff", "10.0.0.10-10.0.0.20"}, store)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	newDev := func(ip, mac string) *discovery.Device {
+		d := discovery.NewDevice(net.ParseIP(ip))
+		d.SetMAC(mac)
+		return d
+	}
+	tests := []struct {
+		name string
+		dev  *discovery.Device
+		want bool
+	}{
+		{"OUI prefix", newDev("192.168.1.2", "B8:27:EB:12:34:56"), true},
+		{"MAC", newDev("192.168.1.3", "aa:bb:cc:dd:ee:ff"), true},
+		{"IP range", newDev("10.0.0.15", ""), true},
+		{"trusted", newDev("192.168.1.4", "00:11:32:aa:bb:cc"), true},
+		{"named", newDev("192.168.1.6", "00:11:32:aa:bb:ce"), true},
+		{"notes only", newDev("192.168.1.7", "00:11:32:aa:bb:cf"), false},
+		{"unknown", newDev("192.168.1.5", "00:11:32:aa:bb:cd"), false},
+	}
+	var devices []*discovery.Device
+	for _, tt := range tests {
+		if got := list.Known(tt.dev); got != tt.want {
+			t.Errorf("%s: Known() = %v, want %v", tt.name, got, tt.want)
+		}
+		devices = append(devices, tt.dev)
+	}
+	if unknown := list.Unknown(devices); len(unknown) != 2 || unknown[0].IP().String() != "192.168.1.7" || unknown[1].IP().String() != "192.168.1.5" {
+		t.Errorf("expected only 192.168.1.7 and 192.168.1.5 to be unknown, got %v", unknown)
+	}
+}
+
+func TestNew_InvalidRule(t *testing.T) {
+	if _, err := New([]string{"10.0.0.20-10.0.0.10"}, nil); err == nil {
+		t.Errorf("expected error for an invalid IP range")
+	}
+}
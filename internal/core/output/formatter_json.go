@@ -25,3 +25,8 @@ func (f *JSONFormatter) Format(w io.Writer, results *discovery.ScanResults) erro
 	}
 	return encoder.Encode(results)
 }
+
+// FormatPorts writes the same document as Format, port results are part of every device.
+func (f *JSONFormatter) FormatPorts(w io.Writer, results *discovery.ScanResults) error {
+	return f.Format(w, results)
+}
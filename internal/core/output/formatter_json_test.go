@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/services"
 )
 
 func TestPrintDevices_JSON(t *testing.T) {
@@ -70,3 +71,114 @@ func TestPrintDevices_JSON_Pretty(t *testing.T) {
 		t.Error("expected to contain count with space")
 	}
 }
+
+func TestPrintDevices_JSON_PortResults(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	d.AddPortResult(discovery.PortResult{Port: 22, Protocol: "tcp", State: discovery.PortOpen, Latency: 1500 * time.Microsecond})
+
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{d},
+		Stats:   &discovery.ScanStats{Count: 1, Duration: time.Second},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDevices(&buf, results, FormatJSON); err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, `"ports":[{"port":22,"protocol":"tcp","serviceName":"ssh","state":"open","latency":"1.5ms"}]`) {
+		t.Errorf("expected port results in output, got %s", output)
+	}
+}
+
+func TestPrintDevices_JSON_PortService(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	d.AddPortResult(discovery.PortResult{
+		Port:     22,
+		Protocol: "tcp",
+		State:    discovery.PortOpen,
+		Latency:  time.Millisecond,
+		Service:  &discovery.ServiceInfo{Name: "ssh", Product: "OpenSSH", Version: "9.6p1", Banner: "SSH-2.0-OpenSSH_9.6p1"},
+	})
+
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{d},
+		Stats:   &discovery.ScanStats{Count: 1, Duration: time.Second},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDevices(&buf, results, FormatJSON); err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	want := `"service":{"name":"ssh","product":"OpenSSH","version":"9.6p1","banner":"SSH-2.0-OpenSSH_9.6p1"}`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("expected service info in output, got %s", buf.String())
+	}
+}
+
+func TestPrintDevices_JSON_Certificate(t *testing.T) {
+	notAfter := time.Date(2027, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	d.AddPortResult(discovery.PortResult{
+		Port:     443,
+		Protocol: "tcp",
+		State:    discovery.PortOpen,
+		Service: &discovery.ServiceInfo{
+			Name: "https",
+			Certificate: &discovery.CertificateInfo{
+				Subject:    "CN=nas.local",
+				Issuer:     "CN=nas.local",
+				SANs:       []string{"nas.local"},
+				NotBefore:  notAfter.AddDate(-1, 0, 0),
+				NotAfter:   notAfter,
+				KeyType:    "RSA-2048",
+				SelfSigned: true,
+				SHA256:     "ab12",
+			},
+		},
+	})
+
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{d},
+		Stats:   &discovery.ScanStats{Count: 1, Duration: time.Second},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDevices(&buf, results, FormatJSON); err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	want := `"certificate":{"subject":"CN=nas.local","issuer":"CN=nas.local","sans":["nas.local"],` +
+		`"notBefore":"2026-03-01T12:00:00Z","notAfter":"2027-03-01T12:00:00Z","keyType":"RSA-2048","selfSigned":true,"sha256":"ab12"}`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("expected certificate in output, got %s", buf.String())
+	}
+}
+
+func TestPrintDevices_JSON_ServiceNameOverrides(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	d.AddPortResult(discovery.PortResult{Port: 8081, Protocol: "tcp", State: discovery.PortOpen})
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{d},
+		Stats:   &discovery.ScanStats{Count: 1, Duration: time.Second},
+	}
+
+	names, err := services.New(services.WithOverrides(map[string]string{"8081/tcp": "grafana"}))
+	if err != nil {
+		t.Fatalf("services.New failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDevices(&buf, results, FormatJSON, WithServiceNames(names)); err != nil {
+		t.Fatalf("PrintDevices failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `"serviceName":"grafana"`) {
+		t.Errorf("expected overridden service name in output, got %s", buf.String())
+	}
+	if got := d.PortResults()["tcp"][0].ServiceName; got != "" {
+		t.Errorf("expected input device to be left untouched, got service name %q", got)
+	}
+}
@@ -3,7 +3,9 @@ package output
 import (
 	"fmt"
 	"io"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 )
@@ -35,7 +37,11 @@ func (f *TableFormatter) Format(w io.Writer, results *discovery.ScanResults) err
 		if mac == "" {
 			mac = "-"
 		}
-		if manufacturer == "" {
+		switch {
+		case manufacturer != "":
+		case discovery.IsRandomizedMAC(mac):
+			manufacturer = "(randomized MAC)"
+		default:
 			manufacturer = "-"
 		}
 
@@ -49,3 +55,64 @@ func (f *TableFormatter) Format(w io.Writer, results *discovery.ScanResults) err
 	_, err := fmt.Fprintf(w, "\nScan completed: %d device(s) found in %s\n", len(results.Devices), formatDuration(results.Stats.Duration))
 	return err
 }
+
+// FormatPorts prints one row per scanned port, grouped by device.
+func (f *TableFormatter) FormatPorts(w io.Writer, results *discovery.ScanResults) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "IP\tDISPLAY NAME\tPORT\tSERVICE\tSTATE\tDETECTED\tLATENCY")
+	_, _ = fmt.Fprintln(tw, "──\t────────────\t────\t───────\t─────\t────────\t───────")
+
+	var ports int
+	for _, d := range results.Devices {
+		ip := d.IP().String()
+		name := d.DisplayName()
+		if name == "" {
+			name = "-"
+		}
+
+		portResults := d.PortResults()
+		for _, proto := range []string{"tcp", "udp"} {
+			for _, r := range portResults[proto] {
+				ports++
+				service := r.ServiceName
+				if service == "" {
+					service = "-"
+				}
+				_, _ = fmt.Fprintf(tw, "%s\t%s\t%d/%s\t%s\t%s\t%s\t%s\n",
+					ip, name, r.Port, r.Protocol, service, r.State, formatService(r.Service), formatLatency(r.Latency))
+			}
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\nPort scan completed: %d port(s) reported on %d host(s) in %s\n", ports, len(results.Devices), formatDuration(results.Stats.Duration))
+	return err
+}
+
+// formatService renders the detected service as "name product version", or "-" when unknown.
+func formatService(info *discovery.ServiceInfo) string {
+	if info == nil {
+		return "-"
+	}
+	var parts []string
+	for _, p := range []string{info.Name, info.Product, info.Version} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatLatency(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return d.Round(100 * time.Microsecond).String()
+}
@@ -20,6 +20,11 @@ func TestPrintDevices(t *testing.T) {
 			return d
 		}(),
 		discovery.NewDevice(net.ParseIP("192.168.1.100")),
+		func() *discovery.Device {
+			d := discovery.NewDevice(net.ParseIP("192.168.1.101"))
+			d.SetMAC("da:a1:19:00:00:01")
+			return d
+		}(),
 	}
 
 	results := &discovery.ScanResults{
@@ -53,7 +58,10 @@ func TestPrintDevices(t *testing.T) {
 	if !strings.Contains(output, "Cisco") {
 		t.Error("expected output to contain manufacturer")
 	}
-	if !strings.Contains(output, "2 device(s) found") {
+	if !strings.Contains(output, "(randomized MAC)") {
+		t.Error("expected output to mark the randomized MAC address")
+	}
+	if !strings.Contains(output, "3 device(s) found") {
 		t.Error("expected output to contain device count")
 	}
 	if !strings.Contains(output, "1.5s") {
@@ -88,3 +96,33 @@ func TestPrintDevices_Empty(t *testing.T) {
 		t.Error("expected output to contain elapsed time")
 	}
 }
+
+func TestPrintPorts(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	d.SetDisplayName("nas")
+	d.SetPortResults([]discovery.PortResult{
+		{Port: 22, Protocol: "tcp", State: discovery.PortOpen, Latency: 1200 * time.Microsecond,
+			Service: &discovery.ServiceInfo{Name: "ssh", Product: "OpenSSH", Version: "9.6"}},
+		{Port: 53, Protocol: "udp", State: discovery.PortOpenFiltered},
+	})
+	results := &discovery.ScanResults{
+		Devices: []*discovery.Device{d, discovery.NewDevice(net.ParseIP("192.168.1.2"))},
+		Stats:   &discovery.ScanStats{Count: 2, Duration: 2 * time.Second},
+	}
+
+	o, err := NewOutput(FormatTable)
+	if err != nil {
+		t.Fatalf("NewOutput failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := o.PrintPorts(&buf, results); err != nil {
+		t.Fatalf("PrintPorts failed: %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{"PORT", "22/tcp", "open", "ssh OpenSSH 9.6", "domain", "1.2ms", "53/udp", "open|filtered", "2 port(s) reported on 2 host(s) in 2.0s"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/services"
 )
 
 // Format defines the output format type
@@ -32,11 +33,14 @@ type Output struct {
 	formatter Formatter
 	sortFunc  func(a, b *discovery.Device) bool
 	pretty    bool
+	names     discovery.ServiceNamer
 }
 
 // Formatter defines the interface for output formatters
 type Formatter interface {
 	Format(w io.Writer, results *discovery.ScanResults) error
+	// FormatPorts writes the port scan results of the devices.
+	FormatPorts(w io.Writer, results *discovery.ScanResults) error
 }
 
 // NewOutput creates a new output handler with the given options
@@ -44,6 +48,7 @@ func NewOutput(format Format, opts ...Option) (*Output, error) {
 	o := &Output{
 		sortFunc: DefaultSortFunc,
 		pretty:   DefaultPretty,
+		names:    services.Default(),
 	}
 
 	for _, opt := range opts {
@@ -70,7 +75,45 @@ func (o *Output) PrintDevices(w io.Writer, results *discovery.ScanResults) error
 		return o.sortFunc(results.Devices[i], results.Devices[j])
 	})
 
-	return o.formatter.Format(w, results)
+	return o.formatter.Format(w, o.withServiceNames(results))
+}
+
+// PrintPorts prints the port scan results of the devices to the writer
+func (o *Output) PrintPorts(w io.Writer, results *discovery.ScanResults) error {
+	sort.Slice(results.Devices, func(i, j int) bool {
+		return o.sortFunc(results.Devices[i], results.Devices[j])
+	})
+
+	return o.formatter.FormatPorts(w, o.withServiceNames(results))
+}
+
+// withServiceNames returns results with the service name filled in on every
+// port result that lacks one. Devices are copied, the input is left untouched.
+func (o *Output) withServiceNames(results *discovery.ScanResults) *discovery.ScanResults {
+	if o.names == nil {
+		return results
+	}
+	out := &discovery.ScanResults{Devices: make([]*discovery.Device, len(results.Devices)), Stats: results.Stats, CapturedAt: results.CapturedAt}
+	for i, d := range results.Devices {
+		portResults := d.PortResults()
+		if len(portResults) == 0 {
+			out.Devices[i] = d
+			continue
+		}
+		c := d.Copy()
+		var annotated []discovery.PortResult
+		for _, rs := range portResults {
+			for _, r := range rs {
+				if r.ServiceName == "" {
+					r.ServiceName = o.names.ServiceName(r.Port, r.Protocol)
+				}
+				annotated = append(annotated, r)
+			}
+		}
+		c.SetPortResults(annotated)
+		out.Devices[i] = c
+	}
+	return out
 }
 
 // PrintDevices is a convenience function to print devices with the given format and options
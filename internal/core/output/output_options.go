@@ -17,3 +17,12 @@ func WithSort(sortFunc func(a, b *discovery.Device) bool) Option {
 		return nil
 	}
 }
+
+// WithServiceNames sets the registry used to name ports in the output.
+// Defaults to the IANA registry without overrides.
+func WithServiceNames(names discovery.ServiceNamer) Option {
+	return func(o *Output) error {
+		o.names = names
+		return nil
+	}
+}
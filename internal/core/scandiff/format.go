@@ -0,0 +1,106 @@
+package scandiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteTable writes the report as a table with one row per changed device.
+func WriteTable(w io.Writer, r *Report) error {
+	if len(r.Changes) == 0 {
+		_, err := fmt.Fprintln(w, "No differences.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CHANGE\tSEVERITY\tIP\tMAC\tNAME\tDETAILS")
+	_, _ = fmt.Fprintln(tw, "──────\t────────\t──\t───\t────\t───────")
+	for _, c := range r.Changes {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.Kind, c.Severity, c.Device.IP, orDash(c.Device.MAC), orDash(c.Device.Name), orDash(details(c)))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%s\n", summary(r))
+	return err
+}
+
+// WriteMarkdown writes the report as a Markdown table, e.g. for pull requests or issues.
+func WriteMarkdown(w io.Writer, r *Report) error {
+	if _, err := fmt.Fprintf(w, "**%s**\n", summary(r)); err != nil {
+		return err
+	}
+	if len(r.Changes) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("\n| Change | Severity | IP | MAC | Name | Details |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, c := range r.Changes {
+		cells := []string{string(c.Kind), c.Severity.String(), c.Device.IP, c.Device.MAC, c.Device.Name, details(c)}
+		for i, cell := range cells {
+			cells[i] = strings.ReplaceAll(orDash(cell), "|", `\|`)
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as JSON.
+func WriteJSON(w io.Writer, r *Report, pretty bool) error {
+	encoder := json.NewEncoder(w)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(r)
+}
+
+// summary returns the number of changes of each kind, e.g. "1 added, 0 removed, 2 changed".
+func summary(r *Report) string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", r.Count(KindAdded), r.Count(KindRemoved), r.Count(KindChanged))
+}
+
+// details describes the changed fields, e.g. "ip: 10.0.0.2 → 10.0.0.3; ports: +tcp/22 -tcp/80".
+func details(c Change) string {
+	parts := make([]string, 0, len(c.Fields))
+	for _, f := range c.Fields {
+		if f.Field == FieldPorts {
+			parts = append(parts, "ports: "+portDelta(f.Old, f.New))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s → %s", f.Field, f.Old, f.New))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// portDelta lists the opened ports with a + and the closed ports with a -.
+func portDelta(from, to string) string {
+	before, after := strings.Split(from, ","), strings.Split(to, ",")
+	var parts []string
+	for _, p := range after {
+		if p != "" && !slices.Contains(before, p) {
+			parts = append(parts, "+"+p)
+		}
+	}
+	for _, p := range before {
+		if p != "" && !slices.Contains(after, p) {
+			parts = append(parts, "-"+p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
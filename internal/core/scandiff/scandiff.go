@@ -0,0 +1,366 @@
+// Package scandiff compares two scan results and reports the devices that were
+// added, removed or changed in between, rated by severity.
+package scandiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// Kind is the kind of difference of a device between two scans.
+type Kind string
+
+const (
+	KindAdded   Kind = "added"
+	KindRemoved Kind = "removed"
+	KindChanged Kind = "changed"
+)
+
+// Fields compared between the scans, also used as keys of Severities.
+const (
+	FieldIP           = "ip"
+	FieldMAC          = "mac"
+	FieldName         = "name"
+	FieldManufacturer = "manufacturer"
+	FieldPorts        = "ports"
+)
+
+// Severity rates how much attention a difference deserves.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota + 1
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return "none"
+}
+
+// MarshalJSON encodes the severity by name.
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// ParseSeverity parses a severity name: info, warning or critical.
+func ParseSeverity(name string) (Severity, error) {
+	for s, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (available: info, warning, critical)", name)
+}
+
+// Severities maps added and removed devices, and each changed field, to a severity.
+type Severities map[string]Severity
+
+// DefaultSeverities rates new devices, MAC address changes and port changes as
+// warnings and everything else as info.
+func DefaultSeverities() Severities {
+	return Severities{
+		string(KindAdded):   SeverityWarning,
+		string(KindRemoved): SeverityInfo,
+		FieldIP:             SeverityInfo,
+		FieldMAC:            SeverityWarning,
+		FieldName:           SeverityInfo,
+		FieldManufacturer:   SeverityInfo,
+		FieldPorts:          SeverityWarning,
+	}
+}
+
+// ParseSeverities returns the default severities overridden by rules of the
+// form key=severity, e.g. "added=critical" or "ports=info".
+func ParseSeverities(rules []string) (Severities, error) {
+	out := DefaultSeverities()
+	for _, rule := range rules {
+		key, name, ok := strings.Cut(rule, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok {
+			return nil, fmt.Errorf("invalid severity %q, expected key=severity", rule)
+		}
+		if _, known := out[key]; !known {
+			return nil, fmt.Errorf("unknown severity key %q (available: added, removed, ip, mac, name, manufacturer, ports)", key)
+		}
+		s, err := ParseSeverity(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		out[key] = s
+	}
+	return out, nil
+}
+
+// Device is the part of a scanned device that is compared.
+type Device struct {
+	IP           string `json:"ip"`
+	MAC          string `json:"mac,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	// Ports are the open ports as protocol/port, e.g. "tcp/22". They are only
+	// compared when both devices were port scanned.
+	Ports       []string `json:"ports,omitempty"`
+	PortScanned bool     `json:"-"`
+}
+
+// FromDevice takes the compared fields of d.
+func FromDevice(d *discovery.Device) Device {
+	out := Device{
+		MAC:          strings.ToLower(d.MAC()),
+		Name:         d.DisplayName(),
+		Manufacturer: d.Manufacturer(),
+	}
+	if ip := d.IP(); ip != nil {
+		out.IP = ip.String()
+	}
+	for proto, results := range d.PortResults() {
+		out.PortScanned = out.PortScanned || len(results) > 0
+		for _, r := range results {
+			if r.State == discovery.PortOpen {
+				out.Ports = append(out.Ports, fmt.Sprintf("%s/%d", proto, r.Port))
+			}
+		}
+	}
+	sortPorts(out.Ports)
+	return out
+}
+
+// FromResults takes the compared fields of all devices in results.
+func FromResults(results *discovery.ScanResults) []Device {
+	out := make([]Device, 0, len(results.Devices))
+	for _, d := range results.Devices {
+		out = append(out, FromDevice(d))
+	}
+	return out
+}
+
+// Load reads the devices of a scan result as written by "whosthere scan --json".
+func Load(r io.Reader) ([]Device, error) {
+	results, err := discovery.DecodeScanResults(r)
+	if err != nil {
+		return nil, err
+	}
+	return FromResults(results), nil
+}
+
+// LoadFile reads the devices of the scan result stored at path.
+func LoadFile(path string) ([]Device, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	devices, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return devices, nil
+}
+
+// FieldChange is a single changed field of a device.
+type FieldChange struct {
+	Field    string   `json:"field"`
+	Old      string   `json:"old"`
+	New      string   `json:"new"`
+	Severity Severity `json:"severity"`
+}
+
+// Change is a device that was added, removed or changed.
+type Change struct {
+	Kind     Kind     `json:"kind"`
+	Severity Severity `json:"severity"`
+	// Device is the device in the new scan, or in the old scan if it was removed.
+	Device Device        `json:"device"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// Report holds the differences between two scans.
+type Report struct {
+	Changes []Change `json:"changes"`
+}
+
+// Count returns the number of changes of kind.
+func (r *Report) Count(kind Kind) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// AtLeast returns the changes rated threshold or higher.
+func (r *Report) AtLeast(threshold Severity) []Change {
+	var out []Change
+	for _, c := range r.Changes {
+		if c.Severity >= threshold {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// MarshalJSON adds a summary with the number of changes of each kind.
+func (r *Report) MarshalJSON() ([]byte, error) {
+	type summary struct {
+		Added   int `json:"added"`
+		Removed int `json:"removed"`
+		Changed int `json:"changed"`
+	}
+	type temp struct {
+		Summary summary  `json:"summary"`
+		Changes []Change `json:"changes"`
+	}
+	t := temp{
+		Summary: summary{Added: r.Count(KindAdded), Removed: r.Count(KindRemoved), Changed: r.Count(KindChanged)},
+		Changes: r.Changes,
+	}
+	if t.Changes == nil {
+		t.Changes = []Change{}
+	}
+	return json.Marshal(t)
+}
+
+// Compare reports the differences between the devices of two scans. Devices are
+// matched by MAC and IP address, then by MAC address, then by IP address for
+// devices left unmatched, so a device that moved to another IP address shows up
+// as an IP change and an IP address taken over by another device as a MAC
+// change. Devices sharing a MAC address, e.g. a host on several subnets or a
+// router with several IP addresses, are matched by their IP address first.
+func Compare(from, to []Device, severities Severities) *Report {
+	if severities == nil {
+		severities = DefaultSeverities()
+	}
+
+	matched := make([]bool, len(from))
+	pair := make([]int, len(to))
+	byMACIP := make(map[[2]string][]int)
+	byMAC := make(map[string][]int)
+	byIP := make(map[string][]int)
+	for i, d := range from {
+		if d.MAC != "" {
+			byMACIP[[2]string{d.MAC, d.IP}] = append(byMACIP[[2]string{d.MAC, d.IP}], i)
+			byMAC[d.MAC] = append(byMAC[d.MAC], i)
+		}
+		byIP[d.IP] = append(byIP[d.IP], i)
+	}
+	// match pairs the unmatched devices of to with the first unmatched candidate
+	match := func(candidates func(Device) []int) {
+		for j, d := range to {
+			if pair[j] >= 0 {
+				continue
+			}
+			for _, i := range candidates(d) {
+				if !matched[i] {
+					pair[j], matched[i] = i, true
+					break
+				}
+			}
+		}
+	}
+	for j := range pair {
+		pair[j] = -1
+	}
+	match(func(d Device) []int {
+		if d.MAC == "" {
+			return nil
+		}
+		return byMACIP[[2]string{d.MAC, d.IP}]
+	})
+	match(func(d Device) []int {
+		if d.MAC == "" {
+			return nil
+		}
+		return byMAC[d.MAC]
+	})
+	match(func(d Device) []int { return byIP[d.IP] })
+
+	r := &Report{}
+	for j, d := range to {
+		if pair[j] < 0 {
+			r.Changes = append(r.Changes, Change{Kind: KindAdded, Severity: severities[string(KindAdded)], Device: d})
+			continue
+		}
+		if fields := compareDevice(from[pair[j]], d, severities); len(fields) > 0 {
+			c := Change{Kind: KindChanged, Device: d, Fields: fields}
+			for _, f := range fields {
+				c.Severity = max(c.Severity, f.Severity)
+			}
+			r.Changes = append(r.Changes, c)
+		}
+	}
+	for i, d := range from {
+		if !matched[i] {
+			r.Changes = append(r.Changes, Change{Kind: KindRemoved, Severity: severities[string(KindRemoved)], Device: d})
+		}
+	}
+
+	sort.SliceStable(r.Changes, func(a, b int) bool {
+		return discovery.CompareIPs(net.ParseIP(r.Changes[a].Device.IP), net.ParseIP(r.Changes[b].Device.IP))
+	})
+	return r
+}
+
+// compareDevice returns the fields that differ between the matched devices.
+// Fields missing on either side, e.g. a MAC address that was not resolved, are
+// not reported as changes.
+func compareDevice(from, to Device, severities Severities) []FieldChange {
+	var out []FieldChange
+	add := func(field, o, n string) {
+		if o != "" && n != "" && o != n {
+			out = append(out, FieldChange{Field: field, Old: o, New: n, Severity: severities[field]})
+		}
+	}
+	add(FieldIP, from.IP, to.IP)
+	add(FieldMAC, from.MAC, to.MAC)
+	add(FieldName, from.Name, to.Name)
+	add(FieldManufacturer, from.Manufacturer, to.Manufacturer)
+	if from.PortScanned && to.PortScanned && !slices.Equal(from.Ports, to.Ports) {
+		out = append(out, FieldChange{
+			Field:    FieldPorts,
+			Old:      strings.Join(from.Ports, ","),
+			New:      strings.Join(to.Ports, ","),
+			Severity: severities[FieldPorts],
+		})
+	}
+	return out
+}
+
+// sortPorts sorts protocol/port strings by protocol, then numerically by port.
+func sortPorts(ports []string) {
+	slices.SortFunc(ports, func(a, b string) int {
+		pa, na := splitPort(a)
+		pb, nb := splitPort(b)
+		if c := strings.Compare(pa, pb); c != 0 {
+			return c
+		}
+		return na - nb
+	})
+}
+
+func splitPort(s string) (string, int) {
+	proto, port, _ := strings.Cut(s, "/")
+	var n int
+	_, _ = fmt.Sscanf(port, "%d", &n)
+	return proto, n
+}
@@ -0,0 +1,191 @@
+package scandiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+func TestCompare(t *testing.T) {
+	from := []Device{
+		{IP: "192.168.1.10", MAC: "aa:bb:cc:00:00:01", Name: "printer.local", Manufacturer: "Brother"},
+		{IP: "192.168.1.11", MAC: "aa:bb:cc:00:00:02", Name: "nas.local", Ports: []string{"tcp/22", "tcp/80"}, PortScanned: true},
+		{IP: "192.168.1.12", MAC: "aa:bb:cc:00:00:03"},
+		{IP: "192.168.1.13", MAC: "aa:bb:cc:00:00:04", Name: "tv.local"},
+		{IP: "192.168.1.14", MAC: "aa:bb:cc:00:00:05", Ports: []string{"tcp/22"}, PortScanned: true},
+	}
+	to := []Device{
+		// moved to another IP address and renamed
+		{IP: "192.168.1.20", MAC: "aa:bb:cc:00:00:01", Name: "office-printer.local", Manufacturer: "Brother"},
+		{IP: "192.168.1.11", MAC: "aa:bb:cc:00:00:02", Name: "nas.local", Ports: []string{"tcp/22", "tcp/443"}, PortScanned: true},
+		// the IP address was taken over by another device
+		{IP: "192.168.1.12", MAC: "aa:bb:cc:00:00:99"},
+		// not port scanned and no name resolved, so unchanged
+		{IP: "192.168.1.14", MAC: "aa:bb:cc:00:00:05"},
+		{IP: "192.168.1.30", MAC: "aa:bb:cc:00:00:06"},
+	}
+
+	r := Compare(from, to, nil)
+
+	got := make([]string, 0, len(r.Changes))
+	for _, c := range r.Changes {
+		fields := make([]string, 0, len(c.Fields))
+		for _, f := range c.Fields {
+			fields = append(fields, f.Field)
+		}
+		got = append(got, string(c.Kind)+" "+c.Device.IP+" "+c.Severity.String()+" "+strings.Join(fields, ","))
+	}
+	want := []string{
+		"changed 192.168.1.11 warning ports",
+		"changed 192.168.1.12 warning mac",
+		"removed 192.168.1.13 info ",
+		"changed 192.168.1.20 info ip,name",
+		"added 192.168.1.30 warning ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if r.Count(KindChanged) != 3 || r.Count(KindAdded) != 1 || r.Count(KindRemoved) != 1 {
+		t.Errorf("unexpected counts in %+v", r.Changes)
+	}
+	if n := len(r.AtLeast(SeverityWarning)); n != 3 {
+		t.Errorf("expected 3 warnings, got %d", n)
+	}
+	if n := len(r.AtLeast(SeverityCritical)); n != 0 {
+		t.Errorf("expected no critical changes, got %d", n)
+	}
+}
+
+func TestCompare_SharedMAC(t *testing.T) {
+	// a router with an IP address on several subnets
+	from := []Device{
+		{IP: "192.168.1.1", MAC: "aa:bb:cc:00:00:01", Ports: []string{"tcp/80"}, PortScanned: true},
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"},
+		{IP: "172.16.0.1", MAC: "aa:bb:cc:00:00:01"},
+	}
+	to := []Device{
+		{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01"},
+		{IP: "192.168.1.1", MAC: "aa:bb:cc:00:00:01", Ports: []string{"tcp/80", "tcp/443"}, PortScanned: true},
+	}
+
+	r := Compare(from, to, nil)
+
+	got := make([]string, 0, len(r.Changes))
+	for _, c := range r.Changes {
+		fields := make([]string, 0, len(c.Fields))
+		for _, f := range c.Fields {
+			fields = append(fields, f.Field)
+		}
+		got = append(got, string(c.Kind)+" "+c.Device.IP+" "+strings.Join(fields, ","))
+	}
+	want := []string{
+		"removed 172.16.0.1 ",
+		"changed 192.168.1.1 ports",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCompare_Severities(t *testing.T) {
+	severities, err := ParseSeverities([]string{"added=critical", "Name = warning"})
+	if err != nil {
+		t.Fatalf("ParseSeverities error: %v", err)
+	}
+	r := Compare(
+		[]Device{{IP: "10.0.0.1", Name: "a"}},
+		[]Device{{IP: "10.0.0.1", Name: "b"}, {IP: "10.0.0.2"}},
+		severities,
+	)
+	if len(r.Changes) != 2 || r.Changes[0].Severity != SeverityWarning || r.Changes[1].Severity != SeverityCritical {
+		t.Errorf("unexpected changes %+v", r.Changes)
+	}
+
+	for _, invalid := range []string{"added", "unknown=info", "added=urgent"} {
+		if _, err := ParseSeverities([]string{invalid}); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	d.SetMAC("AA:BB:CC:00:00:01")
+	d.SetDisplayName("nas.local")
+	d.SetManufacturer("Synology")
+	d.SetFirstSeen(time.Now())
+	d.SetPortResults([]discovery.PortResult{
+		{Port: 443, Protocol: "tcp", State: discovery.PortOpen},
+		{Port: 22, Protocol: "tcp", State: discovery.PortOpen},
+		{Port: 80, Protocol: "tcp", State: discovery.PortClosed},
+		{Port: 161, Protocol: "udp", State: discovery.PortOpen},
+	})
+	raw, err := json.Marshal(&discovery.ScanResults{Devices: []*discovery.Device{d}, Stats: &discovery.ScanStats{Count: 1}})
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+
+	devices, err := Load(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	want := FromDevice(d)
+	if len(devices) != 1 || !equalDevice(devices[0], want) {
+		t.Fatalf("expected %+v, got %+v", want, devices)
+	}
+	if got := strings.Join(want.Ports, ","); got != "tcp/22,tcp/443,udp/161" || want.MAC != "aa:bb:cc:00:00:01" {
+		t.Errorf("unexpected device %+v", want)
+	}
+
+	if _, err := Load(strings.NewReader("not json")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func equalDevice(a, b Device) bool {
+	return a.IP == b.IP && a.MAC == b.MAC && a.Name == b.Name && a.Manufacturer == b.Manufacturer &&
+		strings.Join(a.Ports, ",") == strings.Join(b.Ports, ",") && a.PortScanned == b.PortScanned
+}
+
+func TestWrite(t *testing.T) {
+	r := Compare(
+		[]Device{{IP: "10.0.0.1", MAC: "aa:bb:cc:00:00:01", Ports: []string{"tcp/80"}, PortScanned: true}},
+		[]Device{{IP: "10.0.0.2", MAC: "aa:bb:cc:00:00:01", Name: "web|1", Ports: []string{"tcp/443"}, PortScanned: true}},
+		nil,
+	)
+
+	var table bytes.Buffer
+	if err := WriteTable(&table, r); err != nil {
+		t.Fatalf("WriteTable error: %v", err)
+	}
+	if !strings.Contains(table.String(), "ip: 10.0.0.1 → 10.0.0.2; ports: +tcp/443 -tcp/80") ||
+		!strings.Contains(table.String(), "0 added, 0 removed, 1 changed") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+
+	var md bytes.Buffer
+	if err := WriteMarkdown(&md, r); err != nil {
+		t.Fatalf("WriteMarkdown error: %v", err)
+	}
+	if !strings.Contains(md.String(), `| changed | warning | 10.0.0.2 | aa:bb:cc:00:00:01 | web\|1 |`) {
+		t.Errorf("unexpected markdown:\n%s", md.String())
+	}
+
+	var js bytes.Buffer
+	if err := WriteJSON(&js, r, false); err != nil {
+		t.Fatalf("WriteJSON error: %v", err)
+	}
+	if !strings.Contains(js.String(), `"summary":{"added":0,"removed":0,"changed":1}`) || !strings.Contains(js.String(), `"severity":"warning"`) {
+		t.Errorf("unexpected JSON: %s", js.String())
+	}
+
+	var empty bytes.Buffer
+	if err := WriteTable(&empty, Compare(nil, nil, nil)); err != nil || strings.TrimSpace(empty.String()) != "No differences." {
+		t.Errorf("unexpected output without differences: %q, %v", empty.String(), err)
+	}
+}
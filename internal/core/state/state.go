@@ -1,14 +1,32 @@
 package state
 
 import (
+	"errors"
+	"net"
 	"sort"
 	"sync"
+	"time"
 
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/history"
+	"github.com/ramonvermeulen/whosthere/internal/core/knowndevices"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/classify"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/rules"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/services"
 )
 
+// LabelKey is the extra data key holding the label applied to a device, by hand or by a rule.
+const LabelKey = rules.LabelKey
+
+// ErrDeviceNotFound is returned when annotating a device that is not known.
+var ErrDeviceNotFound = errors.New("device not found")
+
+// noticeTTL is how long a status bar notice stays visible.
+const noticeTTL = 5 * time.Second
+
 // ReadOnly provides read-only access to application state.
 // This interface is intended for "dumb" components that only need to read state.
 type ReadOnly interface {
@@ -21,8 +39,19 @@ type ReadOnly interface {
 	FilterPattern() string
 	IsDiscovering() bool
 	IsPortscanning() bool
+	PortScanProfile() string
+	PortScanMarked() bool
+	PortScanProgress() (done, total int)
+	IsMarked(ip string) bool
+	MarkedIPs() []string
+	Notice() string
+	ServiceName(port int, proto string) string
 	Config() config.Config
 	GetDevice(ip string) (*discovery.Device, bool)
+	Annotation(ip string) (annotations.Annotation, bool)
+	Presence(ip string, window time.Duration, buckets int) (history.Presence, bool)
+	Snapshot() (capturedAt time.Time, ok bool)
+	Restored(ip string) bool
 	SearchActive() bool
 	SearchText() string
 	SearchError() bool
@@ -35,24 +64,40 @@ type AppState struct {
 	mu sync.RWMutex
 
 	devices        map[string]*discovery.Device
+	restored       map[string]struct{} // IPs of devices restored from the history and not reported since
+	annotations    *annotations.Store
+	history        *history.Store
 	selectedIP     string
 	previousTheme  string
 	version        string
 	filterPattern  string
 	isDiscovering  bool
 	isPortscanning bool
+	portProfile    string
+	portScanMarked bool
+	scanDone       int
+	scanTotal      int
+	marked         map[string]struct{}
+	notice         string
+	noticeAt       time.Time
+	services       *services.Registry
 	cfg            *config.Config
 	searchError    bool
 	searchActive   bool
 	noColor        bool
+	snapshot       bool
+	capturedAt     time.Time
 }
 
 func NewAppState(cfg *config.Config, version string) *AppState {
 	s := &AppState{
-		devices: make(map[string]*discovery.Device),
-		version: version,
-		cfg:     cfg,
-		noColor: theme.IsNoColor() || (cfg != nil && cfg.Theme.NoColor),
+		devices:     make(map[string]*discovery.Device),
+		restored:    make(map[string]struct{}),
+		annotations: annotations.New(""),
+		marked:      make(map[string]struct{}),
+		version:     version,
+		cfg:         cfg,
+		noColor:     theme.IsNoColor() || (cfg != nil && cfg.Theme.NoColor),
 	}
 	theme.UpdateNoColor(s.noColor)
 
@@ -62,10 +107,26 @@ func NewAppState(cfg *config.Config, version string) *AppState {
 	}
 	s.previousTheme = themeName
 
+	s.portProfile = config.DefaultPortProfile
+	if cfg != nil && cfg.PortScanner.Profile != "" {
+		s.portProfile = cfg.PortScanner.Profile
+	}
+
+	s.services = services.Default()
+	if cfg != nil {
+		if reg, err := cfg.PortScanner.ServiceRegistry(); err == nil {
+			s.services = reg
+		}
+	}
+
 	return s
 }
 
-// UpsertDevice merges a device into the canonical device map.
+// UpsertDevice merges a device into the canonical device map and classifies
+// the result, which also takes port scans recorded on the canonical device into account.
+// A more confident category, e.g. one set by a rule, is kept. Annotations, which
+// include labels set by hand, take precedence over discovered data, e.g. the
+// annotated name over the display name.
 func (s *AppState) UpsertDevice(d *discovery.Device) {
 	if d.IP() == nil {
 		return
@@ -78,13 +139,44 @@ func (s *AppState) UpsertDevice(d *discovery.Device) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if existing, ok := s.devices[key]; ok {
+	delete(s.restored, key)
+	existing, ok := s.devices[key]
+	if ok {
 		existing.Merge(d)
-		s.devices[key] = existing
 	} else {
 		// Stores a copy to prevent race conditions between discovery engine and UI rendering
-		s.devices[key] = d.Copy()
+		existing = d.Copy()
+		s.devices[key] = existing
 	}
+	s.annotations.ApplyTo(existing)
+	if category, confidence := classify.Classify(existing); category != classify.Unknown && confidence >= existing.CategoryConfidence() {
+		existing.SetCategory(string(category), confidence)
+	}
+}
+
+// RestoreDevices populates the device map with devices from a previous run,
+// e.g. from the history, before the engine reported any. They count as
+// restored until the engine reports them again, see Restored.
+func (s *AppState) RestoreDevices(devices []*discovery.Device) {
+	for _, d := range devices {
+		s.UpsertDevice(d)
+		if ip := d.IP(); ip != nil {
+			s.mu.Lock()
+			if _, ok := s.devices[ip.String()]; ok {
+				s.restored[ip.String()] = struct{}{}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Restored reports whether the device at ip was restored from a previous run
+// and not seen in this one yet.
+func (s *AppState) Restored(ip string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.restored[ip]
+	return ok
 }
 
 // DevicesSnapshot returns a copy of all devices for rendering.
@@ -212,6 +304,283 @@ func (s *AppState) IsPortscanning() bool {
 	return s.isPortscanning
 }
 
+// SetPortScanProfile sets the port profile used for the next port scan.
+func (s *AppState) SetPortScanProfile(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.portProfile = name
+}
+
+// PortScanProfile returns the port profile used for the next port scan.
+func (s *AppState) PortScanProfile() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.portProfile
+}
+
+// SetPortScanMarked sets whether the next port scan targets the marked devices
+// instead of the selected one.
+func (s *AppState) SetPortScanMarked(marked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.portScanMarked = marked
+}
+
+// PortScanMarked reports whether the next port scan targets the marked devices.
+func (s *AppState) PortScanMarked() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.portScanMarked
+}
+
+// SetPortScanProgress sets the number of hosts done out of total for the running
+// bulk port scan. A total of 0 means no progress is reported.
+func (s *AppState) SetPortScanProgress(done, total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanDone, s.scanTotal = done, total
+}
+
+// PortScanProgress returns the progress of the running bulk port scan.
+func (s *AppState) PortScanProgress() (done, total int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scanDone, s.scanTotal
+}
+
+// ToggleMarked marks the device with ip, or unmarks it when already marked.
+func (s *AppState) ToggleMarked(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.marked[ip]; ok {
+		delete(s.marked, ip)
+		return
+	}
+	s.marked[ip] = struct{}{}
+}
+
+// SetMarked marks or unmarks all devices in ips.
+func (s *AppState) SetMarked(ips []string, marked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ip := range ips {
+		if marked {
+			s.marked[ip] = struct{}{}
+		} else {
+			delete(s.marked, ip)
+		}
+	}
+}
+
+// ClearMarked unmarks all devices.
+func (s *AppState) ClearMarked() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.marked)
+}
+
+// IsMarked reports whether the device with ip is marked.
+func (s *AppState) IsMarked(ip string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.marked[ip]
+	return ok
+}
+
+// MarkedIPs returns the IPs of the marked devices, sorted.
+func (s *AppState) MarkedIPs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sortedMarked()
+}
+
+// MarkedDevices returns the marked devices that are known, sorted by IP.
+func (s *AppState) MarkedDevices() []*discovery.Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*discovery.Device, 0, len(s.marked))
+	for _, ip := range s.sortedMarked() {
+		if d, ok := s.devices[ip]; ok {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (s *AppState) sortedMarked() []string {
+	ips := make([]net.IP, 0, len(s.marked))
+	for ip := range s.marked {
+		ips = append(ips, net.ParseIP(ip))
+	}
+	sort.Slice(ips, func(i, j int) bool { return discovery.CompareIPs(ips[i], ips[j]) })
+	out := make([]string, len(ips))
+	for i, ip := range ips {
+		out[i] = ip.String()
+	}
+	return out
+}
+
+// SetLabel saves label in the annotation of the device with ip, an empty label
+// removes it, and stores it in the extra data of the device right away. Like
+// other annotations, the label survives rediscovery and restarts, and follows the
+// device to a new IP address, or a new randomized MAC address when it announces
+// the same hostname, see discovery.Device.Identity. It returns
+// ErrDeviceNotFound for an unknown device.
+func (s *AppState) SetLabel(ip, label string) error {
+	a, _ := s.Annotation(ip)
+	a.Label = label
+	_, err := s.Annotate(ip, a)
+	return err
+}
+
+// SetAnnotationStore sets the store annotations are loaded from and saved to,
+// in memory by default.
+func (s *AppState) SetAnnotationStore(store *annotations.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.annotations = store
+}
+
+// SetHistory sets the device inventory presence is read from, none by default.
+func (s *AppState) SetHistory(store *history.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = store
+}
+
+// History returns the device inventory, or nil when the history is disabled.
+func (s *AppState) History() *history.Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.history
+}
+
+// Presence summarizes how long the device with ip was online within the window
+// ending now, see history.Store.Presence.
+func (s *AppState) Presence(ip string, window time.Duration, buckets int) (history.Presence, bool) {
+	s.mu.RLock()
+	d, ok := s.devices[ip]
+	store := s.history
+	s.mu.RUnlock()
+	if !ok {
+		return history.Presence{}, false
+	}
+	return store.Presence(d.Identity(), window, buckets)
+}
+
+// LoadSnapshot switches to read-only snapshot mode and adds the devices of saved
+// scan results. Results without a capture time are assumed to be captured when
+// their last device was seen.
+func (s *AppState) LoadSnapshot(results *discovery.ScanResults) {
+	capturedAt := results.CapturedAt
+	for _, d := range results.Devices {
+		if results.CapturedAt.IsZero() && d.LastSeen().After(capturedAt) {
+			capturedAt = d.LastSeen()
+		}
+		s.UpsertDevice(d)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot = true
+	s.capturedAt = capturedAt
+}
+
+// Snapshot reports whether saved scan results are shown instead of live
+// discovery, and when they were captured.
+func (s *AppState) Snapshot() (capturedAt time.Time, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.capturedAt, s.snapshot
+}
+
+// AnnotationStore returns the store annotations are loaded from and saved to.
+func (s *AppState) AnnotationStore() *annotations.Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.annotations
+}
+
+// Annotation returns the annotation of the device with ip, if any.
+func (s *AppState) Annotation(ip string) (annotations.Annotation, bool) {
+	s.mu.RLock()
+	d, ok := s.devices[ip]
+	store := s.annotations
+	s.mu.RUnlock()
+	if !ok {
+		return annotations.Annotation{}, false
+	}
+	a, _, ok := store.Lookup(d)
+	return a, ok
+}
+
+// Annotate saves the annotation of the device with ip under its identity, an
+// empty annotation removes it, and applies it to the device right away. It
+// returns the saved annotation, or ErrDeviceNotFound for an unknown device.
+func (s *AppState) Annotate(ip string, a annotations.Annotation) (annotations.Annotation, error) {
+	s.mu.RLock()
+	d, ok := s.devices[ip]
+	store := s.annotations
+	s.mu.RUnlock()
+	if !ok {
+		return annotations.Annotation{}, ErrDeviceNotFound
+	}
+
+	old, oldKey, found := store.Lookup(d)
+	key := d.Identity()
+	saved, err := store.Set(key, a)
+	if err != nil {
+		return annotations.Annotation{}, err
+	}
+	if found && oldKey != key {
+		// the device was annotated by a key other than its current identity, e.g. its IP
+		if _, err := store.Set(oldKey, annotations.Annotation{}); err != nil {
+			return annotations.Annotation{}, err
+		}
+	}
+	annotations.Remove(d, old)
+	annotations.Apply(d, saved)
+	return saved, nil
+}
+
+// Trust marks the device with ip as known by adding the trusted tag to its
+// annotation, and drops the unknown tag set by the engine.
+func (s *AppState) Trust(ip string) error {
+	a, _ := s.Annotation(ip)
+	a.Tags = append(a.Tags, knowndevices.TagTrusted)
+	if _, err := s.Annotate(ip, a); err != nil {
+		return err
+	}
+	if d, ok := s.GetDevice(ip); ok {
+		d.RemoveTag(discovery.TagUnknown)
+	}
+	return nil
+}
+
+// SetNotice shows a short-lived message in the status bar.
+func (s *AppState) SetNotice(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notice = msg
+	s.noticeAt = time.Now()
+}
+
+// Notice returns the status bar message, or "" once it expired.
+func (s *AppState) Notice() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if time.Since(s.noticeAt) > noticeTTL {
+		return ""
+	}
+	return s.notice
+}
+
+// ServiceName returns the service name of port on proto, honoring the configured
+// overrides, or "" when the port has no registered service.
+func (s *AppState) ServiceName(port int, proto string) string {
+	return s.services.Name(port, proto)
+}
+
 // Config returns the port scanner configuration.
 func (s *AppState) Config() config.Config {
 	s.mu.RLock()
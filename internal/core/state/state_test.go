@@ -1,10 +1,18 @@
 package state
 
 import (
+	"errors"
 	"net"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/history"
+	"github.com/ramonvermeulen/whosthere/internal/core/knowndevices"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 )
 
@@ -42,6 +50,44 @@ func TestUpsertDevice(t *testing.T) {
 	}
 }
 
+func TestRestoreDevices(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+	state.RestoreDevices([]*discovery.Device{
+		discovery.NewDevice(net.ParseIP("192.168.1.10")),
+		discovery.NewDevice(net.ParseIP("192.168.1.11")),
+	})
+	if len(state.DevicesSnapshot()) != 2 {
+		t.Fatalf("expected 2 restored devices, got %d", len(state.DevicesSnapshot()))
+	}
+	if !state.Restored("192.168.1.10") || !state.Restored("192.168.1.11") {
+		t.Errorf("expected restored devices to be marked as not seen yet")
+	}
+
+	// the engine reports one of them
+	state.UpsertDevice(discovery.NewDevice(net.ParseIP("192.168.1.10")))
+	if state.Restored("192.168.1.10") {
+		t.Errorf("expected a reported device to no longer count as restored")
+	}
+	if !state.Restored("192.168.1.11") {
+		t.Errorf("expected the device not reported yet to still count as restored")
+	}
+}
+
+func TestUpsertDevice_KeepsMoreConfidentCategory(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+
+	device := discovery.NewDevice(net.ParseIP("192.168.1.2"))
+	device.SetManufacturer("Brother Industries, Ltd.")
+	device.SetCategory("camera", 1)
+
+	state.UpsertDevice(device)
+
+	d, _ := state.GetDevice("192.168.1.2")
+	if d.Category() != "camera" {
+		t.Errorf("expected category set by a rule to be kept, got %q", d.Category())
+	}
+}
+
 func TestDevicesSnapshot(t *testing.T) {
 	state := NewAppState(config.DefaultConfig(), "1.0.0")
 
@@ -149,6 +195,125 @@ func TestIsPortscanning(t *testing.T) {
 	}
 }
 
+func TestServiceName(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.PortScanner.Services = map[string]string{"8081/tcp": "grafana"}
+	state := NewAppState(cfg, "1.0.0")
+
+	if got := state.ServiceName(22, "tcp"); got != "ssh" {
+		t.Errorf("expected ssh, got %q", got)
+	}
+	if got := state.ServiceName(8081, "tcp"); got != "grafana" {
+		t.Errorf("expected override grafana, got %q", got)
+	}
+}
+
+func TestMarked(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+	for _, ip := range []string{"192.168.1.10", "192.168.1.9", "192.168.1.2"} {
+		state.UpsertDevice(discovery.NewDevice(net.ParseIP(ip)))
+	}
+
+	state.ToggleMarked("192.168.1.10")
+	state.SetMarked([]string{"192.168.1.9", "192.168.1.2"}, true)
+	if got := strings.Join(state.MarkedIPs(), ","); got != "192.168.1.2,192.168.1.9,192.168.1.10" {
+		t.Errorf("expected marked IPs sorted numerically, got %s", got)
+	}
+	if len(state.MarkedDevices()) != 3 {
+		t.Errorf("expected 3 marked devices, got %d", len(state.MarkedDevices()))
+	}
+
+	state.ToggleMarked("192.168.1.10")
+	if state.IsMarked("192.168.1.10") {
+		t.Errorf("expected 192.168.1.10 to be unmarked after toggling twice")
+	}
+	state.SetMarked([]string{"192.168.1.9"}, false)
+	if got := strings.Join(state.MarkedIPs(), ","); got != "192.168.1.2" {
+		t.Errorf("expected only 192.168.1.2 marked, got %s", got)
+	}
+
+	state.ClearMarked()
+	if len(state.MarkedIPs()) != 0 {
+		t.Errorf("expected no marked devices after clearing")
+	}
+}
+
+func TestSetLabel(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+	state.UpsertDevice(discovery.NewDevice(net.ParseIP("192.168.1.1")))
+
+	if err := state.SetLabel("192.168.1.2", "unknown"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("expected labeling an unknown device to fail, got %v", err)
+	}
+	if err := state.SetLabel("192.168.1.1", "office"); err != nil {
+		t.Fatalf("SetLabel error: %v", err)
+	}
+
+	// Rediscovery must not drop the label.
+	rediscovered := discovery.NewDevice(net.ParseIP("192.168.1.1"))
+	rediscovered.SetExtraData(map[string]string{LabelKey: "other"})
+	state.UpsertDevice(rediscovered)
+
+	d, _ := state.GetDevice("192.168.1.1")
+	if got := d.ExtraData()[LabelKey]; got != "office" {
+		t.Errorf("expected label office, got %q", got)
+	}
+
+	if _, ok := state.Annotation("192.168.1.1"); !ok {
+		t.Errorf("expected the label to be saved in the annotations")
+	}
+
+	if err := state.SetLabel("192.168.1.1", ""); err != nil {
+		t.Fatalf("SetLabel error: %v", err)
+	}
+	if _, ok := d.ExtraData()[LabelKey]; ok {
+		t.Errorf("expected empty label to remove it")
+	}
+}
+
+func TestSetLabel_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	store, err := annotations.Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	first := NewAppState(config.DefaultConfig(), "1.0.0")
+	first.SetAnnotationStore(store)
+	d := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	d.SetMAC("aa:bb:cc:dd:ee:ff")
+	first.UpsertDevice(d)
+	if err := first.SetLabel("192.168.1.20", "printer"); err != nil {
+		t.Fatalf("SetLabel error: %v", err)
+	}
+
+	reopened, err := annotations.Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	second := NewAppState(config.DefaultConfig(), "1.0.0")
+	second.SetAnnotationStore(reopened)
+	second.UpsertDevice(d.Copy())
+	got, _ := second.GetDevice("192.168.1.20")
+	if label := got.ExtraData()[LabelKey]; label != "printer" {
+		t.Errorf("expected label printer after a restart, got %q", label)
+	}
+}
+
+func TestPortScanProgress(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+
+	state.SetPortScanMarked(true)
+	state.SetPortScanProgress(2, 5)
+	if done, total := state.PortScanProgress(); done != 2 || total != 5 || !state.PortScanMarked() {
+		t.Errorf("expected marked scan at 2/5, got %d/%d", done, total)
+	}
+
+	state.SetNotice("Copied 3 IP(s)")
+	if state.Notice() != "Copied 3 IP(s)" {
+		t.Errorf("expected notice, got %q", state.Notice())
+	}
+}
+
 func TestGetDevice(t *testing.T) {
 	state := NewAppState(config.DefaultConfig(), "1.0.0")
 
@@ -183,3 +348,155 @@ func TestSearch(t *testing.T) {
 		t.Errorf("expected search text search, got %s", state.SearchText())
 	}
 }
+
+func TestSetLabel_FollowsDeviceIdentity(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+	phone := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	phone.SetMAC("da:a1:19:00:00:01")
+	phone.AddExtraData(discovery.HostnameKey, "Ramons-iPhone")
+	state.UpsertDevice(phone)
+	state.SetLabel("192.168.1.20", "phone")
+
+	// The phone rotated its randomized MAC address and got another IP address.
+	rotated := discovery.NewDevice(net.ParseIP("192.168.1.31"))
+	rotated.SetMAC("ea:00:00:00:00:02")
+	rotated.AddExtraData(discovery.HostnameKey, "ramons-iphone")
+	state.UpsertDevice(rotated)
+
+	other := discovery.NewDevice(net.ParseIP("192.168.1.32"))
+	other.SetMAC("fa:00:00:00:00:03")
+	state.UpsertDevice(other)
+
+	d, _ := state.GetDevice("192.168.1.31")
+	if got := d.ExtraData()[LabelKey]; got != "phone" {
+		t.Errorf("expected label phone after MAC rotation, got %q", got)
+	}
+	d, _ = state.GetDevice("192.168.1.32")
+	if got := d.ExtraData()[LabelKey]; got != "" {
+		t.Errorf("expected no label on another device, got %q", got)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+	store, err := annotations.Open(filepath.Join(t.TempDir(), "annotations.json"))
+	if err != nil {
+		t.Fatalf("open annotations: %v", err)
+	}
+	state.SetAnnotationStore(store)
+
+	if _, err := state.Annotate("192.168.1.20", annotations.Annotation{Name: "NAS"}); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("expected ErrDeviceNotFound, got %v", err)
+	}
+
+	nas := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	nas.SetDisplayName("diskstation.local")
+	state.UpsertDevice(nas)
+	if _, err := state.Annotate("192.168.1.20", annotations.Annotation{Name: "NAS", Owner: "ramon", Tags: []string{"backup"}}); err != nil {
+		t.Fatalf("Annotate error: %v", err)
+	}
+
+	// The MAC address becomes known and the scanner reports its own name again.
+	rediscovered := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	rediscovered.SetMAC("00:11:32:aa:bb:cc")
+	rediscovered.SetDisplayName("diskstation.local")
+	state.UpsertDevice(rediscovered)
+
+	d, _ := state.GetDevice("192.168.1.20")
+	if d.DisplayName() != "NAS" || d.ExtraData()[annotations.OwnerKey] != "ramon" || !slices.Contains(d.Tags(), "backup") {
+		t.Errorf("expected annotation to take precedence, got %q %v %v", d.DisplayName(), d.ExtraData(), d.Tags())
+	}
+
+	// Annotating again moves the annotation from the IP to the MAC address.
+	if _, err := state.Annotate("192.168.1.20", annotations.Annotation{Name: "Backup NAS"}); err != nil {
+		t.Fatalf("Annotate error: %v", err)
+	}
+	all := store.All()
+	if _, ok := all["mac:00:11:32:aa:bb:cc"]; !ok || len(all) != 1 {
+		t.Errorf("expected the annotation to be stored by MAC only, got %v", all)
+	}
+	if a, ok := state.Annotation("192.168.1.20"); !ok || a.Name != "Backup NAS" {
+		t.Errorf("expected updated annotation, got %+v, %v", a, ok)
+	}
+	if d.DisplayName() != "Backup NAS" || d.ExtraData()[annotations.OwnerKey] != "" || slices.Contains(d.Tags(), "backup") {
+		t.Errorf("expected the previous annotation to be replaced, got %q %v %v", d.DisplayName(), d.ExtraData(), d.Tags())
+	}
+}
+
+func TestTrust(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+	if err := state.Trust("192.168.1.30"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("expected ErrDeviceNotFound, got %v", err)
+	}
+
+	d := discovery.NewDevice(net.ParseIP("192.168.1.30"))
+	d.SetMAC("aa:bb:cc:dd:ee:ff")
+	d.AddTag(discovery.TagUnknown)
+	state.UpsertDevice(d)
+	if _, err := state.Annotate("192.168.1.30", annotations.Annotation{Owner: "ramon"}); err != nil {
+		t.Fatalf("Annotate error: %v", err)
+	}
+
+	if err := state.Trust("192.168.1.30"); err != nil {
+		t.Fatalf("Trust error: %v", err)
+	}
+	a, ok := state.Annotation("192.168.1.30")
+	if !ok || a.Owner != "ramon" || !slices.Contains(a.Tags, knowndevices.TagTrusted) {
+		t.Errorf("expected the trusted tag to be added to the annotation, got %+v", a)
+	}
+	got, _ := state.GetDevice("192.168.1.30")
+	if slices.Contains(got.Tags(), discovery.TagUnknown) || !slices.Contains(got.Tags(), knowndevices.TagTrusted) {
+		t.Errorf("expected the device to be trusted, got tags %v", got.Tags())
+	}
+}
+
+func TestPresence(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+	if _, ok := state.Presence("192.168.1.20", time.Hour, 0); ok {
+		t.Errorf("expected no presence for an unknown device")
+	}
+
+	store := history.New("")
+	state.SetHistory(store)
+	d := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+	d.SetLastSeen(time.Now())
+	state.UpsertDevice(d)
+	if err := store.Observe(d); err != nil {
+		t.Fatalf("Observe error: %v", err)
+	}
+	if err := store.RecordScan(&discovery.ScanStats{Count: 1}); err != nil {
+		t.Fatalf("RecordScan error: %v", err)
+	}
+
+	p, ok := state.Presence("192.168.1.20", time.Hour, 4)
+	if !ok || len(p.Timeline) != 4 || p.Timeline[3] != 1 {
+		t.Errorf("expected the device to be online in the last bucket, got %+v, %v", p, ok)
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	state := NewAppState(config.DefaultConfig(), "1.0.0")
+	if _, ok := state.Snapshot(); ok {
+		t.Fatal("expected live mode by default")
+	}
+
+	seen := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	a := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	a.SetLastSeen(seen)
+	b := discovery.NewDevice(net.ParseIP("192.168.1.11"))
+	b.SetLastSeen(seen.Add(time.Minute))
+	state.LoadSnapshot(&discovery.ScanResults{Devices: []*discovery.Device{a, b}})
+
+	capturedAt, ok := state.Snapshot()
+	if !ok || !capturedAt.Equal(seen.Add(time.Minute)) {
+		t.Errorf("expected a snapshot captured when the last device was seen, got %v (%v)", capturedAt, ok)
+	}
+	if n := len(state.DevicesSnapshot()); n != 2 {
+		t.Errorf("expected 2 devices, got %d", n)
+	}
+
+	state.LoadSnapshot(&discovery.ScanResults{CapturedAt: seen.Add(time.Hour), Devices: []*discovery.Device{a}})
+	if capturedAt, _ := state.Snapshot(); !capturedAt.Equal(seen.Add(time.Hour)) {
+		t.Errorf("expected the capture time of the results, got %v", capturedAt)
+	}
+}
@@ -2,10 +2,13 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -13,13 +16,17 @@ import (
 	"github.com/dece2183/go-clipboard"
 	"github.com/gdamore/tcell/v2"
 	"github.com/ramonvermeulen/whosthere/internal/core"
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
+	"github.com/ramonvermeulen/whosthere/internal/core/autoscan"
 	"github.com/ramonvermeulen/whosthere/internal/core/config"
+	"github.com/ramonvermeulen/whosthere/internal/core/output"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/events"
 	"github.com/ramonvermeulen/whosthere/internal/ui/routes"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
 	"github.com/ramonvermeulen/whosthere/internal/ui/views"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/rules"
 	"github.com/rivo/tview"
 )
 
@@ -27,6 +34,14 @@ const (
 	refreshInterval = 1 * time.Second
 )
 
+// snapshotActions are the routes of actions that change devices or their
+// annotations, unavailable in read-only snapshot mode, by action name.
+var snapshotActions = map[string]string{
+	routes.RoutePortScan: "Port scanning",
+	routes.RouteLabel:    "Labeling",
+	routes.RouteAnnotate: "Annotating",
+}
+
 // App represents the main TUI application.
 type App struct {
 	*tview.Application
@@ -38,19 +53,86 @@ type App struct {
 	events        chan events.Event
 	emit          func(events.Event)
 	portScanner   *discovery.PortScanner
+	autoScan      *autoscan.Policy
+	rules         *rules.Set
 	isReady       bool
 	clipboard     *clipboard.Clipboard
 	logger        *slog.Logger
+	// ctx is canceled when the app stops, ending port scans still running.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func NewApp(cfg *config.Config, logger *slog.Logger, version string) (*App, error) {
-	app := tview.NewApplication()
 	appState := state.NewAppState(cfg, version)
+	store, err := annotations.OpenDefault()
+	if err != nil {
+		return nil, fmt.Errorf("open annotations: %w", err)
+	}
+	appState.SetAnnotationStore(store)
+	hist, err := core.BuildHistory(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	appState.SetHistory(hist)
+	// populate the device list from the previous runs until the first scan completed
+	appState.RestoreDevices(hist.Devices())
+
+	a := newApp(cfg, logger, appState)
 
+	ruleSet, err := core.BuildRules(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("build rules: %w", err)
+	}
+	a.rules = ruleSet
+	var engOpts []discovery.Option
+	if ruleSet != nil {
+		engOpts = append(engOpts, discovery.WithRules(ruleSet))
+	}
+	known, err := core.BuildKnownDevices(cfg, store)
+	if err != nil {
+		return nil, fmt.Errorf("build known devices: %w", err)
+	}
+	// devices seen by a previous run were reported then
+	engOpts = append(engOpts, discovery.WithKnownDevices(known), discovery.WithReportedDevices(hist.IDs()...))
+
+	engine, err := core.BuildEngine(a.cfg, a.logger, engOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("build engine: %w", err)
+	}
+	a.engine = engine
+	portScanner, err := core.BuildPortScanner(cfg, engine.Iface)
+	if err != nil {
+		return nil, fmt.Errorf("build port scanner: %w", err)
+	}
+	a.portScanner = portScanner
+	autoScan, err := core.BuildAutoScan(cfg, portScanner, a.logger)
+	if err != nil {
+		return nil, fmt.Errorf("build auto port scan: %w", err)
+	}
+	a.autoScan = autoScan
+
+	return a, nil
+}
+
+// NewSnapshotApp creates an app that browses saved scan results. It does not
+// discover devices, and port scans, labels, annotations and trusting devices
+// are unavailable. The annotations and history of this host are left out, so
+// the devices are shown as captured.
+func NewSnapshotApp(cfg *config.Config, logger *slog.Logger, version string, results *discovery.ScanResults) *App {
+	appState := state.NewAppState(cfg, version)
+	appState.LoadSnapshot(results)
+	return newApp(cfg, logger, appState)
+}
+
+// newApp sets up the application and its pages around appState.
+func newApp(cfg *config.Config, logger *slog.Logger, appState *state.AppState) *App {
 	if logger == nil {
 		logger = slog.Default()
 	}
 
+	app := tview.NewApplication()
+	ctx, cancel := context.WithCancel(context.Background())
 	a := &App{
 		Application: app,
 		state:       appState,
@@ -58,6 +140,8 @@ func NewApp(cfg *config.Config, logger *slog.Logger, version string) (*App, erro
 		events:      make(chan events.Event, 100),
 		clipboard:   clipboard.New(clipboard.ClipboardOptions{Primary: false}),
 		logger:      logger,
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 	a.setupSignalHandler()
 
@@ -69,19 +153,11 @@ func NewApp(cfg *config.Config, logger *slog.Logger, version string) (*App, erro
 	a.applyTheme(appState.CurrentTheme())
 	a.setupPages(cfg)
 
-	engine, err := core.BuildEngine(a.cfg, logger)
-	if err != nil {
-		return nil, fmt.Errorf("build engine: %w", err)
-	}
-	a.engine = engine
-	// todo(ramon) handle in BuildEngine -> WithPortScanner(...)
-	a.portScanner = discovery.NewPortScanner(100, engine.Iface)
-
 	app.SetRoot(a.pages, true)
 	app.SetInputCapture(a.handleGlobalKeys)
 	app.EnableMouse(true)
 
-	return a, nil
+	return a
 }
 
 func (a *App) setupSignalHandler() {
@@ -126,9 +202,17 @@ func (a *App) Run() error {
 	if a.engine != nil && a.cfg != nil {
 		a.engine.Start(context.Background())
 		go a.handleEngineEvents()
+		if a.autoScan != nil {
+			go a.autoScan.Run(a.ctx)
+		}
 	}
 
-	return a.Application.Run()
+	err := a.Application.Run()
+	a.cancel()
+	if flushErr := a.state.History().Flush(); flushErr != nil {
+		a.logger.Warn("failed to save history", "error", flushErr)
+	}
+	return err
 }
 
 func (a *App) setupPages(cfg *config.Config) {
@@ -137,12 +221,18 @@ func (a *App) setupPages(cfg *config.Config) {
 	splashPage := views.NewSplashView(a.emit)
 	themePickerModal := views.NewThemeModalView(a.emit)
 	portScanModal := views.NewPortScanModalView(a.emit)
+	bulkActionsModal := views.NewBulkActionsModalView(a.emit)
+	labelModal := views.NewLabelModalView(a.emit)
+	annotationModal := views.NewAnnotationModalView(a.emit)
 
 	a.pages.AddPage(routes.RouteDashboard, dashboardPage, true, false)
 	a.pages.AddPage(routes.RouteDetail, detailPage, true, false)
 	a.pages.AddPage(routes.RouteSplash, splashPage, true, false)
 	a.pages.AddPage(routes.RouteThemePicker, themePickerModal, true, false)
 	a.pages.AddPage(routes.RoutePortScan, portScanModal, true, false)
+	a.pages.AddPage(routes.RouteBulkActions, bulkActionsModal, true, false)
+	a.pages.AddPage(routes.RouteLabel, labelModal, true, false)
+	a.pages.AddPage(routes.RouteAnnotate, annotationModal, true, false)
 
 	initialPage := routes.RouteDashboard
 	if cfg != nil && cfg.Splash.Enabled {
@@ -160,6 +250,10 @@ func (a *App) handleGlobalKeys(event *tcell.EventKey) *tcell.EventKey {
 		a.emit(events.NavigateTo{Route: routes.RouteThemePicker, Overlay: true})
 		return nil
 	case tcell.KeyRune:
+		switch a.GetFocus().(type) {
+		case *tview.InputField, *tview.TextArea:
+			return event
+		}
 		if event.Rune() == 'q' || event.Rune() == 'Q' {
 			a.Stop()
 			return nil
@@ -195,10 +289,40 @@ func (a *App) handleEngineEvents() {
 			a.emit(events.DiscoveryStarted{})
 		case discovery.EventScanCompleted:
 			a.emit(events.DiscoveryStopped{})
+			if err := a.state.History().RecordScan(event.Stats); err != nil {
+				a.logger.Warn("failed to record scan in history", "error", err)
+			}
 		case discovery.EventDeviceDiscovered:
-			if event.Device != nil {
-				a.state.UpsertDevice(event.Device)
+			if event.Device == nil {
+				break
+			}
+			a.state.UpsertDevice(event.Device)
+			d, ok := a.state.GetDevice(event.Device.IP().String())
+			if !ok {
+				break
+			}
+			// rules are evaluated again on the canonical device, which also holds port scan results
+			if a.rules != nil {
+				for _, alert := range a.rules.Evaluate(d) {
+					a.showAlert(alert)
+				}
+			}
+			if a.autoScan != nil {
+				a.autoScan.Observe(d)
+			}
+			if err := a.state.History().Observe(d); err != nil {
+				a.logger.Warn("failed to record device in history", "ip", d.IP().String(), "error", err)
+			}
+		case discovery.EventUnknownDevice:
+			if event.Device == nil {
+				break
 			}
+			a.state.UpsertDevice(event.Device)
+			ip := event.Device.IP().String()
+			a.logger.Warn("unknown device joined", "ip", ip, "mac", event.Device.MAC())
+			a.state.SetNotice(fmt.Sprintf("Unknown device joined: %s %s", ip, event.Device.MAC()))
+		case discovery.EventAlert:
+			a.showAlert(*event.Alert)
 		case discovery.EventError:
 			a.emit(events.DiscoveryStopped{})
 			if event.Error != nil {
@@ -208,6 +332,13 @@ func (a *App) handleEngineEvents() {
 	}
 }
 
+// showAlert logs an alert raised by a rule and shows it in the status bar.
+func (a *App) showAlert(alert discovery.Alert) {
+	ip := alert.Device.IP().String()
+	a.logger.Warn("rule alert: "+alert.Message, "rule", alert.Rule, "ip", ip, "mac", alert.Device.MAC())
+	a.state.SetNotice(fmt.Sprintf("Alert [%s] %s: %s", alert.Rule, ip, alert.Message))
+}
+
 func (a *App) QueueUpdateDraw(f func()) {
 	if a.Application == nil {
 		return
@@ -277,6 +408,9 @@ func (a *App) handleEvents() {
 		case events.FilterChanged:
 			a.state.SetFilterPattern(event.Pattern)
 		case events.NavigateTo:
+			if action, ok := snapshotActions[event.Route]; ok && a.readOnly(action) {
+				break
+			}
 			if event.Overlay {
 				a.pages.SendToFront(event.Route)
 				a.pages.ShowPage(event.Route)
@@ -301,12 +435,56 @@ func (a *App) handleEvents() {
 			a.state.SetIsDiscovering(true)
 		case events.DiscoveryStopped:
 			a.state.SetIsDiscovering(false)
+		case events.PortScanRequested:
+			a.state.SetPortScanMarked(event.Marked)
+			a.emit(events.NavigateTo{Route: routes.RoutePortScan, Overlay: true})
 		case events.PortScanStarted:
 			a.state.SetIsPortscanning(true)
 			a.emit(events.HideView{})
-			go a.startPortscan()
+			if a.state.PortScanMarked() {
+				go a.startBulkPortscan()
+			} else {
+				go a.startPortscan()
+			}
 		case events.PortScanStopped:
 			a.state.SetIsPortscanning(false)
+			a.state.SetPortScanProgress(0, 0)
+		case events.MarkToggled:
+			a.state.ToggleMarked(event.IP)
+		case events.MarkSet:
+			a.state.SetMarked(event.IPs, event.Marked)
+		case events.MarksCleared:
+			a.state.ClearMarked()
+		case events.CopyMarked:
+			a.copyMarked(event.MAC)
+		case events.ExportMarked:
+			a.exportMarked()
+		case events.LabelApplied:
+			ips := a.state.MarkedIPs()
+			var failed int
+			for _, ip := range ips {
+				if err := a.state.SetLabel(ip, event.Label); err != nil && !errors.Is(err, state.ErrDeviceNotFound) {
+					a.logger.Error("failed to save label", "ip", ip, "error", err)
+					failed++
+				}
+			}
+			switch {
+			case failed > 0:
+				a.state.SetNotice(fmt.Sprintf("Failed to save the label of %d device(s)", failed))
+			case event.Label == "":
+				a.state.SetNotice(fmt.Sprintf("Removed label from %d device(s)", len(ips)))
+			default:
+				a.state.SetNotice(fmt.Sprintf("Labeled %d device(s) %q", len(ips), event.Label))
+			}
+		case events.AnnotationApplied:
+			a.annotate(event)
+		case events.DeviceTrusted:
+			if a.readOnly("Trusting devices") {
+				break
+			}
+			a.trust(event.IP)
+		case events.PortScanProfileChanged:
+			a.state.SetPortScanProfile(event.Name)
 		case events.SearchStarted:
 			a.state.SetSearchActive(true)
 		case events.SearchError:
@@ -348,6 +526,55 @@ func (a *App) handleEvents() {
 	}
 }
 
+// readOnly reports whether saved scan results are shown, and if so tells in the
+// status bar that action is unavailable.
+func (a *App) readOnly(action string) bool {
+	if _, ok := a.state.Snapshot(); !ok {
+		return false
+	}
+	a.state.SetNotice(action + " is not available in read-only snapshot mode")
+	return true
+}
+
+// annotate saves the annotation of a device and reports the outcome in the status bar.
+func (a *App) annotate(event events.AnnotationApplied) {
+	// the label is set separately, see events.LabelApplied
+	current, _ := a.state.Annotation(event.IP)
+	saved, err := a.state.Annotate(event.IP, annotations.Annotation{
+		Name:  event.Name,
+		Label: current.Label,
+		Notes: event.Notes,
+		Owner: event.Owner,
+		Tags:  event.Tags,
+	})
+	switch {
+	case err != nil:
+		a.logger.Error("failed to save annotation", "ip", event.IP, "error", err)
+		a.state.SetNotice(fmt.Sprintf("Failed to save %s: %v", event.IP, err))
+	case saved.IsZero():
+		a.state.SetNotice(fmt.Sprintf("Removed annotation from %s", event.IP))
+	default:
+		a.state.SetNotice(fmt.Sprintf("Saved annotation of %s", event.IP))
+	}
+}
+
+// trust marks the device with ip, or the selected device, as known and reports
+// the outcome in the status bar.
+func (a *App) trust(ip string) {
+	if ip == "" {
+		ip = a.state.SelectedIP()
+	}
+	if ip == "" {
+		return
+	}
+	if err := a.state.Trust(ip); err != nil {
+		a.logger.Error("failed to trust device", "ip", ip, "error", err)
+		a.state.SetNotice(fmt.Sprintf("Failed to trust %s: %v", ip, err))
+		return
+	}
+	a.state.SetNotice(fmt.Sprintf("Trusted %s", ip))
+}
+
 func (a *App) startPortscan() {
 	device, ok := a.state.Selected()
 	if !ok {
@@ -355,20 +582,189 @@ func (a *App) startPortscan() {
 		return
 	}
 	ip := device.IP().String()
-	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ScanTimeout)
+	if !a.autoScan.Begin(ip) {
+		a.state.SetNotice(fmt.Sprintf("%s is being port scanned already", ip))
+		a.emit(events.PortScanStopped{})
+		return
+	}
+	defer a.autoScan.End(ip)
+	ctx, cancel := context.WithTimeout(a.ctx, a.cfg.ScanTimeout)
 	defer cancel()
 
-	openPorts := make(map[string][]int)
-	device.SetOpenPorts(openPorts)
+	spec, err := a.cfg.PortScanner.ResolveProfile(a.state.PortScanProfile())
+	if err != nil {
+		a.logger.Error("port scan failed", "ip", ip, "error", err)
+		a.emit(events.PortScanStopped{})
+		return
+	}
+
+	tcpResults, err := a.portScanner.Scan(ctx, ip, spec.TCP)
+	if err != nil {
+		a.logger.Error("port scan failed", "ip", ip, "error", err)
+		a.emit(events.PortScanStopped{})
+		return
+	}
+	udpResults, err := a.portScanner.ScanUDP(ctx, ip, spec.UDP)
+	if err != nil {
+		a.logger.Error("udp port scan failed", "ip", ip, "error", err)
+		a.emit(events.PortScanStopped{})
+		return
+	}
+
+	device.SetPortResults(nil)
 	device.SetLastPortScan(time.Now())
 
-	var mu sync.Mutex
-	_ = a.portScanner.Stream(ctx, ip, a.cfg.PortScanner.TCP, a.cfg.PortScanner.Timeout, func(port int) {
-		mu.Lock()
-		defer mu.Unlock()
-		openPorts["tcp"] = append(openPorts["tcp"], port)
-	})
+	var wg sync.WaitGroup
+	for _, results := range []<-chan discovery.PortResult{tcpResults, udpResults} {
+		wg.Add(1)
+		go func(results <-chan discovery.PortResult) {
+			defer wg.Done()
+			for r := range results {
+				device.AddPortResult(r)
+			}
+		}(results)
+	}
+	wg.Wait()
 
-	device.SetOpenPorts(openPorts)
 	a.emit(events.PortScanStopped{})
 }
+
+// startBulkPortscan port scans all marked devices with a shared worker pool,
+// reporting the number of hosts done as port scan progress. The scan gets the
+// scan timeout for every device, and is canceled when the app stops. Devices
+// being port scanned already, e.g. automatically, are skipped.
+func (a *App) startBulkPortscan() {
+	defer a.emit(events.PortScanStopped{})
+
+	marked := a.state.MarkedDevices()
+	var devices []*discovery.Device
+	for _, d := range marked {
+		if a.autoScan.Begin(d.IP().String()) {
+			devices = append(devices, d)
+		}
+	}
+	if len(devices) == 0 {
+		if len(marked) > 0 {
+			a.state.SetNotice("The marked devices are being port scanned already")
+		}
+		return
+	}
+	defer func() {
+		for _, d := range devices {
+			a.autoScan.End(d.IP().String())
+		}
+	}()
+	spec, err := a.cfg.PortScanner.ResolveProfile(a.state.PortScanProfile())
+	if err != nil {
+		a.logger.Error("port scan failed", "error", err)
+		return
+	}
+
+	targets := make([]string, len(devices))
+	byTarget := make(map[string]*discovery.Device, len(devices))
+	for i, d := range devices {
+		targets[i] = d.IP().String()
+		byTarget[targets[i]] = d
+	}
+	a.state.SetPortScanProgress(0, len(devices))
+
+	ctx, cancel := context.WithTimeout(a.ctx, a.cfg.ScanTimeout*time.Duration(len(devices)))
+	defer cancel()
+	results, err := a.portScanner.ScanHosts(ctx, targets, spec.TCP, spec.UDP)
+	if err != nil {
+		a.logger.Error("port scan failed", "error", err)
+		return
+	}
+	// the results of a device replace its previous ones once all its ports are
+	// probed, so a canceled scan keeps the previous results of the devices not done
+	collected := make(map[string][]discovery.PortResult, len(devices))
+	done := 0
+	for r := range results {
+		collected[r.Target] = append(collected[r.Target], r.PortResult)
+		if len(collected[r.Target]) == spec.Len() {
+			done++
+			a.state.SetPortScanProgress(done, len(devices))
+			byTarget[r.Target].SetPortResults(collected[r.Target])
+			byTarget[r.Target].SetLastPortScan(time.Now())
+		}
+	}
+	if ctx.Err() != nil && done < len(devices) {
+		a.state.SetNotice(fmt.Sprintf("Port scan stopped after %d of %d device(s)", done, len(devices)))
+		return
+	}
+	a.state.SetNotice(fmt.Sprintf("Port scanned %d device(s)", len(devices)))
+}
+
+// copyMarked copies the IPs, or MACs, of the marked devices to the clipboard
+// as a newline separated list.
+func (a *App) copyMarked(mac bool) {
+	var lines []string
+	for _, d := range a.state.MarkedDevices() {
+		v := d.IP().String()
+		if mac {
+			v = d.MAC()
+		}
+		if v != "" {
+			lines = append(lines, v)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	if err := a.clipboard.CopyText(strings.Join(lines, "\n")); err != nil {
+		a.logger.Warn("failed to copy to clipboard", "error", err)
+		return
+	}
+	what := "IP"
+	if mac {
+		what = "MAC"
+	}
+	a.state.SetNotice(fmt.Sprintf("Copied %d %s(s)", len(lines), what))
+}
+
+// exportMarked writes the marked devices as JSON to a timestamped file in the
+// working directory. Devices of a snapshot keep its capture time.
+func (a *App) exportMarked() {
+	devices := a.state.MarkedDevices()
+	if len(devices) == 0 {
+		return
+	}
+	now := time.Now()
+	path, err := filepath.Abs(fmt.Sprintf("whosthere-export-%s.json", now.Format("20060102-150405")))
+	if err != nil {
+		a.logger.Error("failed to export devices", "error", err)
+		return
+	}
+	capturedAt := now
+	if snapshotAt, ok := a.state.Snapshot(); ok {
+		capturedAt = snapshotAt
+	}
+	if err := writeExport(path, devices, capturedAt, a.cfg); err != nil {
+		a.logger.Error("failed to export devices", "path", path, "error", err)
+		a.state.SetNotice("Export failed, see the log for details")
+		return
+	}
+	a.state.SetNotice(fmt.Sprintf("Exported %d device(s) to %s", len(devices), path))
+}
+
+func writeExport(path string, devices []*discovery.Device, capturedAt time.Time, cfg *config.Config) (err error) {
+	names, err := cfg.PortScanner.ServiceRegistry()
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	results := &discovery.ScanResults{
+		Devices:    devices,
+		Stats:      &discovery.ScanStats{Count: len(devices)},
+		CapturedAt: capturedAt,
+	}
+	return output.PrintDevices(f, results, output.FormatJSON, output.WithPretty(), output.WithServiceNames(names))
+}
@@ -3,38 +3,72 @@ package components
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/events"
+	"github.com/ramonvermeulen/whosthere/internal/ui/routes"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
 	"github.com/ramonvermeulen/whosthere/internal/ui/utils"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/classify"
 	"github.com/rivo/tview"
 )
 
 var _ UIComponent = &DeviceTable{}
 
+// certFilterRE matches the search syntax that lists devices with a TLS
+// certificate expiring within N days, e.g. "cert:30".
+var certFilterRE = regexp.MustCompile(`(?i)^cert:(\d+)$`)
+
+// categoryFilterRE matches the search syntax that lists devices of a category,
+// e.g. "cat:printer" or "category:tv".
+var categoryFilterRE = regexp.MustCompile(`(?i)^cat(?:egory)?:(.+)$`)
+
 // DeviceTable wraps a tview.Table for displaying discovered devices.
 type DeviceTable struct {
 	*tview.Table
 	devices     []*discovery.Device
 	filterRE    *regexp.Regexp
+	certFilter  bool
+	certWithin  time.Duration
+	catFilter   classify.Category
+	hasCategory bool
 	searching   bool
 	searchInput string
 
+	// marked mirrors the marked devices in state as of the last Render.
+	marked map[string]bool
+	// visual is set while selecting a range to mark, starting at visualAnchor.
+	visual       bool
+	visualAnchor string
+	// rowIPs holds the IP of every data row, in display order.
+	rowIPs []string
+	// unknown holds the IPs of the rows tagged unknown by the engine.
+	unknown map[string]bool
+	// restored holds the IPs of the devices restored from a previous run and not
+	// seen in this one yet, as of the last Render.
+	restored map[string]bool
+
 	emit func(events.Event)
 }
 
 func NewDeviceTable(emit func(events.Event)) *DeviceTable {
-	t := &DeviceTable{Table: tview.NewTable(), devices: []*discovery.Device{}, emit: emit}
+	t := &DeviceTable{Table: tview.NewTable(), devices: []*discovery.Device{}, marked: map[string]bool{}, emit: emit}
 	t.
 		SetBorder(true).
 		SetTitle(" Devices ")
 	t.SetFixed(1, 0)
 	t.SetSelectable(true, false)
+	t.SetSelectionChangedFunc(func(int, int) {
+		if t.visual {
+			t.styleRows()
+		}
+	})
 
 	theme.RegisterPrimitive(t.Table)
 
@@ -58,22 +92,71 @@ func (dt *DeviceTable) HandleInput(ev *tcell.EventKey) *tcell.EventKey {
 func (dt *DeviceTable) handleNormalKey(ev *tcell.EventKey) *tcell.EventKey {
 	switch {
 	case ev.Key() == tcell.KeyEsc:
-		if dt.filterRE != nil {
+		switch {
+		case dt.visual:
+			dt.visual = false
+			dt.styleRows()
+		case dt.hasFilter():
 			dt.applySearch("")
-			return nil
+		case len(dt.marked) > 0:
+			dt.emit(events.MarksCleared{})
+		default:
+			return ev
 		}
-		return ev
+		return nil
+	case ev.Rune() == ' ':
+		if ip := dt.SelectedIP(); ip != "" {
+			dt.emit(events.MarkToggled{IP: ip})
+			if row, _ := dt.GetSelection(); row < dt.GetRowCount()-1 {
+				dt.Select(row+1, 0)
+			}
+		}
+		return nil
+	case ev.Rune() == 'V':
+		if dt.visual {
+			dt.emit(events.MarkSet{IPs: dt.visualRange(), Marked: true})
+			dt.visual = false
+		} else if ip := dt.SelectedIP(); ip != "" {
+			dt.visual = true
+			dt.visualAnchor = ip
+		}
+		dt.styleRows()
+		return nil
+	case ev.Rune() == '*':
+		if len(dt.rowIPs) > 0 {
+			dt.emit(events.MarkSet{IPs: dt.rowIPs, Marked: !dt.allMarked(dt.rowIPs)})
+		}
+		return nil
+	case ev.Rune() == 'a':
+		dt.emit(events.NavigateTo{Route: routes.RouteBulkActions, Overlay: true})
+		return nil
+	case ev.Rune() == 'p':
+		if len(dt.marked) > 0 {
+			dt.emit(events.PortScanRequested{Marked: true})
+		}
+		return nil
 	case ev.Rune() == '/':
 		dt.searching = true
 		dt.searchInput = ""
 		dt.emit(events.SearchStarted{})
 		return nil
+	case ev.Rune() == 't':
+		if ip := dt.SelectedIP(); ip != "" {
+			dt.emit(events.DeviceTrusted{IP: ip})
+		}
+		return nil
 	case ev.Rune() == 'g':
 		dt.SelectFirst()
 		return nil
 	case ev.Rune() == 'G':
 		dt.SelectLast()
 		return nil
+	case ev.Rune() == 'y' && len(dt.marked) > 0:
+		dt.emit(events.CopyMarked{})
+		return nil
+	case ev.Rune() == 'Y' && len(dt.marked) > 0:
+		dt.emit(events.CopyMarked{MAC: true})
+		return nil
 	case ev.Rune() == 'y':
 		ip := dt.SelectedIP()
 		if ip != "" {
@@ -125,10 +208,35 @@ func (dt *DeviceTable) handleSearchKey(ev *tcell.EventKey) *tcell.EventKey {
 }
 
 // SetFilter compiles and applies a regex filter across visible columns (case-insensitive).
+// The special pattern "cert:N" instead keeps devices with a TLS certificate
+// that expires within N days, including expired ones, and "cat:NAME" keeps
+// devices of the named category.
 func (dt *DeviceTable) SetFilter(pattern string) error {
 	pattern = strings.TrimSpace(pattern)
+	dt.filterRE = nil
+	dt.certFilter = false
+	dt.hasCategory = false
 	if pattern == "" {
-		dt.filterRE = nil
+		dt.refresh()
+		return nil
+	}
+	if m := certFilterRE.FindStringSubmatch(pattern); m != nil {
+		days, err := strconv.Atoi(m[1])
+		if err != nil {
+			return err
+		}
+		dt.certFilter = true
+		dt.certWithin = time.Duration(days) * 24 * time.Hour
+		dt.refresh()
+		return nil
+	}
+	if m := categoryFilterRE.FindStringSubmatch(pattern); m != nil {
+		c, ok := classify.Parse(m[1])
+		if !ok {
+			return fmt.Errorf("unknown category %q", m[1])
+		}
+		dt.hasCategory = true
+		dt.catFilter = c
 		dt.refresh()
 		return nil
 	}
@@ -141,6 +249,11 @@ func (dt *DeviceTable) SetFilter(pattern string) error {
 	return nil
 }
 
+// hasFilter reports whether a regex, certificate or category filter is active.
+func (dt *DeviceTable) hasFilter() bool {
+	return dt.filterRE != nil || dt.certFilter || dt.hasCategory
+}
+
 // applySearch applies an incremental search pattern, keeping the previous filter on errors.
 func (dt *DeviceTable) applySearch(pattern string) {
 	pattern = strings.TrimSpace(pattern)
@@ -162,20 +275,26 @@ func (dt *DeviceTable) applySearch(pattern string) {
 // Render updates the table with the latest devices from state.
 func (dt *DeviceTable) Render(st state.ReadOnly) {
 	dt.devices = st.DevicesSnapshot()
+	dt.restored = make(map[string]bool)
+	for _, d := range dt.devices {
+		if ip := d.IP().String(); st.Restored(ip) {
+			dt.restored[ip] = true
+		}
+	}
+	dt.marked = make(map[string]bool)
+	for _, ip := range st.MarkedIPs() {
+		dt.marked[ip] = true
+	}
 	_ = dt.SetFilter(st.FilterPattern())
 }
 
 // SelectedIP returns the IP for the currently selected row, if any.
 func (dt *DeviceTable) SelectedIP() string {
 	row, _ := dt.GetSelection()
-	if row <= 0 {
-		return ""
-	}
-	cell := dt.GetCell(row, 0)
-	if cell == nil {
+	if row <= 0 || row > len(dt.rowIPs) {
 		return ""
 	}
-	return cell.Text
+	return dt.rowIPs[row-1]
 }
 
 // SelectedMAC returns the MAC for the currently selected row, if any.
@@ -203,8 +322,66 @@ func (dt *DeviceTable) SelectLast() {
 	}
 }
 
+// visualRange returns the IPs of the rows between the visual anchor and the
+// selected row, inclusive.
+func (dt *DeviceTable) visualRange() []string {
+	row, _ := dt.GetSelection()
+	anchor := slices.Index(dt.rowIPs, dt.visualAnchor) + 1
+	if row <= 0 || anchor <= 0 {
+		return nil
+	}
+	from, to := min(anchor, row), max(anchor, row)
+	return slices.Clone(dt.rowIPs[from-1 : to])
+}
+
+// allMarked reports whether every IP in ips is marked.
+func (dt *DeviceTable) allMarked(ips []string) bool {
+	for _, ip := range ips {
+		if !dt.marked[ip] {
+			return false
+		}
+	}
+	return true
+}
+
+// styleRows highlights unknown and marked rows, and the pending range while in
+// visual mode. Rows of devices not seen in this run yet are dimmed.
+func (dt *DeviceTable) styleRows() {
+	var inRange map[string]bool
+	if dt.visual {
+		inRange = make(map[string]bool)
+		for _, ip := range dt.visualRange() {
+			inRange[ip] = true
+		}
+	}
+	for i, ip := range dt.rowIPs {
+		color := tview.Styles.PrimaryTextColor
+		attrs := tcell.AttrNone
+		if dt.restored[ip] {
+			attrs |= tcell.AttrDim
+		}
+		if dt.unknown[ip] {
+			color = tcell.ColorRed
+			attrs |= tcell.AttrBold
+		}
+		if dt.marked[ip] {
+			color = tview.Styles.TertiaryTextColor
+			attrs |= tcell.AttrBold
+		}
+		if inRange[ip] {
+			attrs |= tcell.AttrUnderline
+		}
+		for col := 0; col < dt.GetColumnCount(); col++ {
+			if cell := dt.GetCell(i+1, col); cell != nil {
+				cell.SetTextColor(color).SetAttributes(attrs)
+			}
+		}
+	}
+}
+
 type tableRow struct {
-	ip, hostname, mac, manufacturer, lastSeen string
+	ip, hostname, mac, manufacturer, category, lastSeen, label, tags, roles string
+	unknown                                                                 bool
 }
 
 func (dt *DeviceTable) buildRows() []tableRow {
@@ -214,10 +391,18 @@ func (dt *DeviceTable) buildRows() []tableRow {
 			ip:           d.IP().String(),
 			hostname:     d.DisplayName(),
 			mac:          d.MAC(),
-			manufacturer: d.Manufacturer(),
+			manufacturer: utils.Manufacturer(d),
+			category:     formatCategory(classify.Category(d.Category())),
 			lastSeen:     utils.FmtDuration(time.Since(d.LastSeen())),
+			label:        d.ExtraData()[state.LabelKey],
+			tags:         strings.Join(d.Tags(), " "),
+			roles:        formatRoles(d.Roles()),
+			unknown:      slices.Contains(d.Tags(), discovery.TagUnknown),
+		}
+		if dt.restored[row.ip] {
+			row.lastSeen += " (prev. run)"
 		}
-		if dt.filterRE != nil && !dt.rowMatches(&row) {
+		if dt.hasFilter() && !dt.rowMatches(&row, d) {
 			continue
 		}
 		rows = append(rows, row)
@@ -230,7 +415,13 @@ func (dt *DeviceTable) refresh() {
 	dt.Clear()
 	const maxColWidth = 30
 
-	headers := []string{"IP", "Display Name", "MAC", "Manufacturer", "Last Seen"}
+	rows := dt.buildRows()
+	hasLabels := slices.ContainsFunc(rows, func(r tableRow) bool { return r.label != "" })
+
+	headers := []string{"IP", "Display Name", "MAC", "Manufacturer", "Category", "Last Seen"}
+	if hasLabels {
+		headers = append(headers, "Label")
+	}
 
 	for i, h := range headers {
 		text := utils.Truncate(h, maxColWidth)
@@ -240,34 +431,61 @@ func (dt *DeviceTable) refresh() {
 			SetExpansion(1))
 	}
 
-	rows := dt.buildRows()
+	dt.unknown = make(map[string]bool)
+	for _, row := range rows {
+		if row.unknown {
+			dt.unknown[row.ip] = true
+		}
+	}
 
 	title := fmt.Sprintf(" Devices (%v) ", len(rows))
-	if dt.filterRE != nil {
+	if len(dt.unknown) > 0 {
+		title += fmt.Sprintf(" [%s]<%d unknown>[-] ", utils.ColorToHexTag(tcell.ColorRed), len(dt.unknown))
+	}
+	if len(dt.marked) > 0 {
+		title += fmt.Sprintf(" [%s]<%d marked>[-] ", utils.ColorToHexTag(tview.Styles.TertiaryTextColor), len(dt.marked))
+	}
+	if dt.visual {
+		title += fmt.Sprintf(" [%s]<visual>[-] ", utils.ColorToHexTag(tview.Styles.TertiaryTextColor))
+	}
+	switch {
+	case dt.certFilter:
+		title += fmt.Sprintf(" [%s]<cert:%d>[-] ", utils.ColorToHexTag(tview.Styles.SecondaryTextColor), int(dt.certWithin.Hours()/24))
+	case dt.hasCategory:
+		title += fmt.Sprintf(" [%s]<cat:%s>[-] ", utils.ColorToHexTag(tview.Styles.SecondaryTextColor), classify.Label(dt.catFilter))
+	case dt.filterRE != nil:
 		title += fmt.Sprintf(" [%s]<%s>[-] ", utils.ColorToHexTag(tview.Styles.SecondaryTextColor), dt.filterRE.String())
 	}
 	dt.SetTitle(title)
 
+	dt.rowIPs = make([]string, len(rows))
 	for rowIndex, rowData := range rows {
 		r := rowIndex + 1
+		dt.rowIPs[rowIndex] = rowData.ip
 
-		ipText := utils.Truncate(rowData.ip, maxColWidth)
+		ipText := utils.Truncate(strings.TrimSpace(rowData.ip+" "+rowData.roles), maxColWidth)
 		hostText := utils.Truncate(rowData.hostname, maxColWidth)
 		macText := utils.Truncate(rowData.mac, maxColWidth)
 		manuText := utils.Truncate(rowData.manufacturer, maxColWidth)
+		catText := utils.Truncate(rowData.category, maxColWidth)
 		seenText := utils.Truncate(rowData.lastSeen, maxColWidth)
 
-		dt.SetCell(r, 0, tview.NewTableCell(ipText).SetExpansion(1))
+		dt.SetCell(r, 0, tview.NewTableCell(tview.Escape(ipText)).SetExpansion(1))
 		dt.SetCell(r, 1, tview.NewTableCell(hostText).SetExpansion(1))
 		dt.SetCell(r, 2, tview.NewTableCell(macText).SetExpansion(1))
 		dt.SetCell(r, 3, tview.NewTableCell(manuText).SetExpansion(1))
-		dt.SetCell(r, 4, tview.NewTableCell(seenText).SetExpansion(1))
+		dt.SetCell(r, 4, tview.NewTableCell(catText).SetExpansion(1))
+		dt.SetCell(r, 5, tview.NewTableCell(seenText).SetExpansion(1))
+		if hasLabels {
+			dt.SetCell(r, 6, tview.NewTableCell(utils.Truncate(rowData.label, maxColWidth)).SetExpansion(1))
+		}
 	}
+	dt.styleRows()
 	// Restore selection if possible, otherwise select first.
 	if dt.GetRowCount() > 1 {
 		selectedRow := -1
 		for i, row := range rows {
-			if utils.Truncate(row.ip, maxColWidth) == selectedIP {
+			if row.ip == selectedIP {
 				selectedRow = i + 1 // +1 for header
 				break
 			}
@@ -280,7 +498,13 @@ func (dt *DeviceTable) refresh() {
 	}
 }
 
-func (dt *DeviceTable) rowMatches(r *tableRow) bool {
+func (dt *DeviceTable) rowMatches(r *tableRow, d *discovery.Device) bool {
+	if dt.certFilter {
+		return len(d.ExpiringCertificates(dt.certWithin, time.Now())) > 0
+	}
+	if dt.hasCategory {
+		return classify.Category(d.Category()) == dt.catFilter
+	}
 	if dt.filterRE == nil {
 		return true
 	}
@@ -288,5 +512,37 @@ func (dt *DeviceTable) rowMatches(r *tableRow) bool {
 		dt.filterRE.MatchString(r.hostname) ||
 		dt.filterRE.MatchString(r.mac) ||
 		dt.filterRE.MatchString(r.manufacturer) ||
-		dt.filterRE.MatchString(r.lastSeen)
+		dt.filterRE.MatchString(r.category) ||
+		dt.filterRE.MatchString(r.lastSeen) ||
+		dt.filterRE.MatchString(r.label) ||
+		dt.filterRE.MatchString(r.tags) ||
+		dt.filterRE.MatchString(r.roles)
+}
+
+// roleBadges are the short names of roles shown next to the IP address.
+var roleBadges = map[string]string{
+	discovery.RoleSelf:    "self",
+	discovery.RoleGateway: "gw",
+	discovery.RoleDNS:     "dns",
+}
+
+// formatRoles renders roles as badges, e.g. "[gw][dns]".
+func formatRoles(roles []string) string {
+	var b strings.Builder
+	for _, role := range roles {
+		badge, ok := roleBadges[role]
+		if !ok {
+			badge = role
+		}
+		b.WriteString("[" + badge + "]")
+	}
+	return b.String()
+}
+
+// formatCategory renders a category with its glyph, or "" when it is unknown.
+func formatCategory(c classify.Category) string {
+	if c == classify.Unknown {
+		return ""
+	}
+	return classify.Glyph(c) + " " + classify.Label(c)
 }
@@ -9,7 +9,8 @@ import (
 var _ UIComponent = &Header{}
 
 // Header is a simple reusable header bar for pages.
-// It renders the app title and, optionally, a version string.
+// It renders the app title and, optionally, a version string. When saved scan
+// results are shown, it says so along with their capture time.
 type Header struct {
 	*tview.TextView
 }
@@ -32,5 +33,12 @@ func (h *Header) Render(s state.ReadOnly) {
 	if version := s.Version(); version != "" {
 		text = baseTitle + " - v" + version
 	}
+	if capturedAt, ok := s.Snapshot(); ok {
+		captured := "unknown time"
+		if !capturedAt.IsZero() {
+			captured = capturedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		text += " - READ-ONLY SNAPSHOT captured " + captured
+	}
 	h.SetText(text)
 }
@@ -8,28 +8,35 @@ import (
 
 var _ UIComponent = &StatusBar{}
 
-// StatusBar combines a Spinner with a right-aligned help text into a single flex row.
+// StatusBar combines a Spinner and an optional message with a right-aligned
+// help text into a single flex row.
 type StatusBar struct {
 	*tview.Flex
 	spinner *Spinner
+	message *tview.TextView
 	help    *tview.TextView
 }
 
 func NewStatusBar() *StatusBar {
 	sp := NewSpinner()
+	message := tview.NewTextView().
+		SetTextAlign(tview.AlignLeft)
 	help := tview.NewTextView().
 		SetTextAlign(tview.AlignRight)
 	row := tview.NewFlex().
 		SetDirection(tview.FlexColumn).
 		AddItem(sp, 0, 1, false).
+		AddItem(message, 0, 0, false).
 		AddItem(help, 0, 2, false)
 
+	theme.RegisterPrimitive(message)
 	theme.RegisterPrimitive(help)
 	theme.RegisterPrimitive(row)
 
 	return &StatusBar{
 		Flex:    row,
 		spinner: sp,
+		message: message,
 		help:    help,
 	}
 }
@@ -43,6 +50,19 @@ func (s *StatusBar) SetHelp(text string) {
 	s.help.SetText(text)
 }
 
+// SetMessage shows text next to the spinner, an empty text hides the message.
+func (s *StatusBar) SetMessage(text string) {
+	if s == nil || s.message == nil || s.message.GetText(false) == text {
+		return
+	}
+	s.message.SetText(text)
+	width := 0
+	if text != "" {
+		width = tview.TaggedStringWidth(text) + 2
+	}
+	s.ResizeItem(s.message, width, 0)
+}
+
 // Render implements UIComponent.
 func (s *StatusBar) Render(_ state.ReadOnly) {
 	// StatusBar is updated via SetHelp, no state update needed.
@@ -47,6 +47,11 @@ type PortScanStarted struct{}
 // PortScanStopped is emitted when port scan stops.
 type PortScanStopped struct{}
 
+// PortScanProfileChanged is emitted when a different port profile is picked for the next scan.
+type PortScanProfileChanged struct {
+	Name string
+}
+
 // SearchStarted is emitted when search mode starts.
 type SearchStarted struct{}
 
@@ -67,3 +72,53 @@ type CopyIP struct {
 type CopyMac struct {
 	MAC string
 }
+
+// PortScanRequested is emitted to open the port scan modal, for the marked
+// devices when Marked is set, otherwise for the selected device.
+type PortScanRequested struct {
+	Marked bool
+}
+
+// MarkToggled is emitted to mark or unmark a single device.
+type MarkToggled struct {
+	IP string
+}
+
+// MarkSet is emitted to mark or unmark several devices at once.
+type MarkSet struct {
+	IPs    []string
+	Marked bool
+}
+
+// MarksCleared is emitted to unmark all devices.
+type MarksCleared struct{}
+
+// CopyMarked is emitted to copy the IPs, or the MACs when MAC is set, of the
+// marked devices to the clipboard as a newline separated list.
+type CopyMarked struct {
+	MAC bool
+}
+
+// ExportMarked is emitted to export the marked devices to a JSON file.
+type ExportMarked struct{}
+
+// LabelApplied is emitted to label the marked devices, an empty label removes it.
+type LabelApplied struct {
+	Label string
+}
+
+// AnnotationApplied is emitted to save the name, owner, tags and notes of the
+// device with IP, empty fields remove them.
+type AnnotationApplied struct {
+	IP    string
+	Name  string
+	Owner string
+	Tags  []string
+	Notes string
+}
+
+// DeviceTrusted is emitted to mark the device with IP as known. An empty IP
+// refers to the selected device.
+type DeviceTrusted struct {
+	IP string
+}
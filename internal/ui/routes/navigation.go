@@ -6,4 +6,7 @@ const (
 	RouteDetail      = "detail"
 	RouteThemePicker = "theme-picker"
 	RoutePortScan    = "port-scan"
+	RouteBulkActions = "bulk-actions"
+	RouteLabel       = "label"
+	RouteAnnotate    = "annotate"
 )
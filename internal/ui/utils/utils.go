@@ -2,12 +2,19 @@ package utils
 
 import (
 	"fmt"
+	"math"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 )
 
+// RandomizedMAC is shown instead of an empty manufacturer for devices using a
+// randomized MAC address.
+const RandomizedMAC = "(randomized MAC)"
+
 // ColorToHexTag converts a tcell.Color to a tview dynamic color hex tag.
 func ColorToHexTag(c tcell.Color) string {
 	r, g, b := c.RGB()
@@ -44,6 +51,24 @@ func Truncate(s string, maxLen int) string {
 	return s[:maxLen-1] + "…"
 }
 
+// sparkBlocks are the blocks of a sparkline, from empty to full.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders values between 0 and 1 as blocks of increasing height.
+// Negative values, e.g. missing data, are rendered as a dot.
+func Sparkline(values []float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		if v < 0 {
+			b.WriteRune('·')
+			continue
+		}
+		i := int(math.Round(min(v, 1) * float64(len(sparkBlocks)-1)))
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
 // SanitizeString returns the string if it contains only printable characters.
 // Otherwise, it returns a hex representation.
 func SanitizeString(s string) string {
@@ -55,3 +80,12 @@ func SanitizeString(s string) string {
 	}
 	return s
 }
+
+// Manufacturer returns the manufacturer of d, or RandomizedMAC when it is unknown
+// because d uses a randomized MAC address.
+func Manufacturer(d *discovery.Device) string {
+	if m := d.Manufacturer(); m != "" || !discovery.IsRandomizedMAC(d.MAC()) {
+		return m
+	}
+	return RandomizedMAC
+}
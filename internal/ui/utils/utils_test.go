@@ -1,10 +1,12 @@
 package utils
 
 import (
+	"net"
 	"testing"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 )
 
 func TestColorToHexTag(t *testing.T) {
@@ -47,6 +49,12 @@ func TestFmtDuration(t *testing.T) {
 	}
 }
 
+func TestSparkline(t *testing.T) {
+	if got, want := Sparkline([]float64{0, 0.5, 1, -1, 2}), "▁▅█·█"; got != want {
+		t.Errorf("Sparkline() = %s, expected %s", got, want)
+	}
+}
+
 func TestTruncate(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -65,3 +73,22 @@ func TestTruncate(t *testing.T) {
 		}
 	}
 }
+
+func TestManufacturer(t *testing.T) {
+	tests := []struct {
+		mac, manufacturer, expected string
+	}{
+		{"b8:27:eb:12:34:56", "Raspberry Pi", "Raspberry Pi"},
+		{"b8:27:eb:12:34:56", "", ""},
+		{"da:a1:19:00:00:01", "", RandomizedMAC},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		d := discovery.NewDevice(net.ParseIP("192.168.1.20"))
+		d.SetMAC(test.mac)
+		d.SetManufacturer(test.manufacturer)
+		if result := Manufacturer(d); result != test.expected {
+			t.Errorf("Manufacturer(%s, %q) = %q, expected %q", test.mac, test.manufacturer, result, test.expected)
+		}
+	}
+}
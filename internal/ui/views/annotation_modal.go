@@ -0,0 +1,106 @@
+package views
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/internal/core/state"
+	"github.com/ramonvermeulen/whosthere/internal/ui/events"
+	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
+	"github.com/rivo/tview"
+)
+
+var _ View = &AnnotationModalView{}
+
+const (
+	// annotationModalWidth is the width of the annotation form, including its border.
+	annotationModalWidth = 60
+	// annotationModalHeight is the height of the annotation form, including its border.
+	annotationModalHeight = 17
+)
+
+// AnnotationModalView is a modal overlay page to edit the name, owner, tags and
+// notes of the selected device.
+type AnnotationModalView struct {
+	*tview.Flex
+	form  *tview.Form
+	name  *tview.InputField
+	owner *tview.InputField
+	tags  *tview.InputField
+	notes *tview.TextArea
+	// prefilledFor is the IP of the device the form was last prefilled for.
+	prefilledFor string
+
+	emit func(events.Event)
+}
+
+func NewAnnotationModalView(emit func(events.Event)) *AnnotationModalView {
+	fieldWidth := annotationModalWidth - 14
+	name := tview.NewInputField().SetLabel("Name").SetFieldWidth(fieldWidth)
+	owner := tview.NewInputField().SetLabel("Owner").SetFieldWidth(fieldWidth)
+	tags := tview.NewInputField().SetLabel("Tags").SetFieldWidth(fieldWidth).
+		SetPlaceholder("comma separated")
+	notes := tview.NewTextArea().SetLabel("Notes").SetSize(4, fieldWidth)
+
+	form := tview.NewForm().
+		AddFormItem(name).
+		AddFormItem(owner).
+		AddFormItem(tags).
+		AddFormItem(notes)
+	form.SetBorder(true).SetTitle(" Edit device ")
+
+	p := &AnnotationModalView{form: form, name: name, owner: owner, tags: tags, notes: notes, emit: emit}
+
+	hide := func() {
+		// prefill again when reopened, dropping unsaved edits
+		p.prefilledFor = ""
+		emit(events.HideView{})
+	}
+	form.AddButton("Save", func() {
+		emit(events.AnnotationApplied{
+			IP:    p.prefilledFor,
+			Name:  name.GetText(),
+			Owner: owner.GetText(),
+			Tags:  strings.Split(tags.GetText(), ","),
+			Notes: notes.GetText(),
+		})
+		hide()
+	}).
+		AddButton("Cancel", hide)
+	form.SetCancelFunc(hide)
+
+	p.Flex = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+			AddItem(nil, 0, 1, false).
+			AddItem(form, annotationModalWidth, 0, true).
+			AddItem(nil, 0, 1, false), annotationModalHeight, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	theme.RegisterPrimitive(form)
+	theme.RegisterPrimitive(name)
+	theme.RegisterPrimitive(owner)
+	theme.RegisterPrimitive(tags)
+	theme.RegisterPrimitive(notes)
+
+	return p
+}
+
+func (p *AnnotationModalView) FocusTarget() tview.Primitive { return p.form }
+
+// Render prefills the form with the annotation of the selected device whenever
+// another device is selected.
+func (p *AnnotationModalView) Render(s state.ReadOnly) {
+	ip := s.SelectedIP()
+	p.form.SetTitle(fmt.Sprintf(" Edit %s ", ip))
+	if ip == p.prefilledFor {
+		return
+	}
+	p.prefilledFor = ip
+
+	a, _ := s.Annotation(ip)
+	p.name.SetText(a.Name)
+	p.owner.SetText(a.Owner)
+	p.tags.SetText(strings.Join(a.Tags, ", "))
+	p.notes.SetText(a.Notes, false)
+}
@@ -0,0 +1,84 @@
+package views
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/ramonvermeulen/whosthere/internal/core/state"
+	"github.com/ramonvermeulen/whosthere/internal/ui/events"
+	"github.com/ramonvermeulen/whosthere/internal/ui/routes"
+	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
+	"github.com/rivo/tview"
+)
+
+var _ View = &BulkActionsModalView{}
+
+// BulkActionsModalView is a modal overlay page with the actions that apply to
+// all marked devices.
+type BulkActionsModalView struct {
+	*tview.Modal
+	emit func(events.Event)
+
+	// marked is the number of marked devices at the last Render.
+	marked int
+}
+
+func NewBulkActionsModalView(emit func(events.Event)) *BulkActionsModalView {
+	p := &BulkActionsModalView{emit: emit}
+
+	modal := tview.NewModal().
+		SetText("").
+		AddButtons([]string{"Port Scan", "Copy IPs", "Copy MACs", "Export", "Label", "Cancel"}).
+		SetDoneFunc(func(_ int, buttonLabel string) {
+			if p.marked == 0 {
+				emit(events.HideView{})
+				return
+			}
+			switch buttonLabel {
+			case "Port Scan":
+				emit(events.HideView{})
+				emit(events.PortScanRequested{Marked: true})
+			case "Copy IPs":
+				emit(events.CopyMarked{})
+				emit(events.HideView{})
+			case "Copy MACs":
+				emit(events.CopyMarked{MAC: true})
+				emit(events.HideView{})
+			case "Export":
+				emit(events.ExportMarked{})
+				emit(events.HideView{})
+			case "Label":
+				emit(events.HideView{})
+				emit(events.NavigateTo{Route: routes.RouteLabel, Overlay: true})
+			default:
+				emit(events.HideView{})
+			}
+		})
+	p.Modal = modal
+
+	modal.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			emit(events.HideView{})
+			return nil
+		}
+		return event
+	})
+
+	theme.RegisterPrimitive(p.Modal)
+
+	return p
+}
+
+func (p *BulkActionsModalView) FocusTarget() tview.Primitive { return p.Modal }
+
+func (p *BulkActionsModalView) Render(s state.ReadOnly) {
+	ips := s.MarkedIPs()
+	p.marked = len(ips)
+	if len(ips) == 0 {
+		p.SetText("No devices marked.\n\nUse Space, V or * in the device table to mark devices.").SetTitle(" Bulk Actions ")
+		return
+	}
+	text := fmt.Sprintf("Apply an action to %d marked device(s):\n\n%s", len(ips), truncateList(strings.Join(ips, ", ")))
+	p.SetText(text).SetTitle(" Bulk Actions ")
+}
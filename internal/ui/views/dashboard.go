@@ -38,6 +38,9 @@ func NewDashboardView(emit func(events.Event), queue func(f func())) *DashboardV
 		"j/k: up/down" + components.Divider +
 			"Enter: details" + components.Divider +
 			"y: copy" + components.Divider +
+			"Space/V/*: mark" + components.Divider +
+			"a: actions" + components.Divider +
+			"t: trust" + components.Divider +
 			"Ctrl+T: theme" + components.Divider +
 			"q: quit",
 	)
@@ -77,12 +80,18 @@ func (d *DashboardView) Render(s state.ReadOnly) {
 	d.header.Render(s)
 	d.filterBar.Render(s)
 	d.statusBar.Render(s)
+	d.statusBar.SetMessage(s.Notice())
 
 	d.updateFooter(s.SearchActive())
 
-	if s.IsDiscovering() {
+	switch {
+	case s.IsPortscanning():
+		d.statusBar.Spinner().SetSuffix(portScanSuffix(s))
 		d.statusBar.Spinner().Start(d.queue)
-	} else {
+	case s.IsDiscovering():
+		d.statusBar.Spinner().SetSuffix(" Discovering Devices...")
+		d.statusBar.Spinner().Start(d.queue)
+	default:
 		d.statusBar.Spinner().Stop(d.queue)
 	}
 }
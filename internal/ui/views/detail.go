@@ -6,17 +6,24 @@ import (
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/ramonvermeulen/whosthere/internal/core/annotations"
+	"github.com/ramonvermeulen/whosthere/internal/core/history"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/components"
 	"github.com/ramonvermeulen/whosthere/internal/ui/events"
 	"github.com/ramonvermeulen/whosthere/internal/ui/routes"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
 	"github.com/ramonvermeulen/whosthere/internal/ui/utils"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/classify"
 	"github.com/rivo/tview"
 )
 
 var _ View = &DetailView{}
 
+// certExpiryWarning highlights certificates that expire within this period.
+const certExpiryWarning = 30 * 24 * time.Hour
+
 // DetailView shows detailed information about the currently selected device.
 type DetailView struct {
 	*tview.Flex
@@ -37,7 +44,7 @@ func NewDetailView(emit func(events.Event), queue func(f func())) *DetailView {
 		SetTitle(" Details ")
 
 	statusBar := components.NewStatusBar()
-	statusBar.SetHelp("q: Quit" + components.Divider + "Esc: Back" + components.Divider + "y/Y: Copy IP/MAC" + components.Divider + "p: Port Scan")
+	statusBar.SetHelp("q: Quit" + components.Divider + "Esc: Back" + components.Divider + "y/Y: Copy IP/MAC" + components.Divider + "p: Port Scan" + components.Divider + "e: Edit" + components.Divider + "t: Trust")
 
 	main.AddItem(header, 1, 0, false)
 	main.AddItem(info, 0, 1, true)
@@ -70,7 +77,13 @@ func handleInput(p *DetailView) func(ev *tcell.EventKey) *tcell.EventKey {
 			p.emit(events.NavigateTo{Route: routes.RouteDashboard, Overlay: true})
 			return nil
 		case ev.Rune() == 'p':
-			p.emit(events.NavigateTo{Route: routes.RoutePortScan, Overlay: true})
+			p.emit(events.PortScanRequested{})
+			return nil
+		case ev.Rune() == 'e':
+			p.emit(events.NavigateTo{Route: routes.RouteAnnotate, Overlay: true})
+			return nil
+		case ev.Rune() == 't':
+			p.emit(events.DeviceTrusted{})
 			return nil
 		case ev.Rune() == 'y':
 			p.emit(events.CopyIP{})
@@ -84,6 +97,22 @@ func handleInput(p *DetailView) func(ev *tcell.EventKey) *tcell.EventKey {
 	}
 }
 
+// presenceBuckets is the number of half hours in the presence timeline of the last 24 hours.
+const presenceBuckets = 48
+
+// formatPresence formats the availability of a device and how often it went offline.
+func formatPresence(p history.Presence) string {
+	out := fmt.Sprintf("%.1f%% online", p.Availability*100)
+	switch p.Drops {
+	case 0:
+	case 1:
+		out += ", dropped off once"
+	default:
+		out += fmt.Sprintf(", dropped off %d times", p.Drops)
+	}
+	return out
+}
+
 func (d *DetailView) FocusTarget() tview.Primitive { return d.info }
 
 // Render reloads the text view from the currently selected device, if any.
@@ -142,13 +171,48 @@ func (d *DetailView) Render(s state.ReadOnly) {
 	}
 
 	writeLine("IP", device.IP().String())
+	if subnet := device.Subnet(); subnet != "" {
+		writeLine("Subnet", subnet)
+	}
 	writeLine("Display Name", device.DisplayName())
 	writeLine("MAC", device.MAC())
-	writeLine("Manufacturer", device.Manufacturer())
+	writeLine("Manufacturer", utils.Manufacturer(device))
+	if c := classify.Category(device.Category()); c != classify.Unknown {
+		writeLine("Category", fmt.Sprintf("%s %s (%.0f%%)", classify.Glyph(c), classify.Label(c), device.CategoryConfidence()*100))
+	}
+	if label := device.ExtraData()[state.LabelKey]; label != "" {
+		writeLine("Label", utils.SanitizeString(label))
+	}
+	if owner := device.ExtraData()[annotations.OwnerKey]; owner != "" {
+		writeLine("Owner", utils.SanitizeString(owner))
+	}
+	if notes := device.ExtraData()[annotations.NotesKey]; notes != "" {
+		writeLine("Notes", utils.SanitizeString(notes))
+	}
+	if roles := device.Roles(); len(roles) > 0 {
+		writeLine("Roles", formatRoles(roles))
+	}
+	if tags := device.Tags(); len(tags) > 0 {
+		writeLine("Tags", utils.SanitizeString(strings.Join(tags, ", ")))
+	}
 	writeLine("First Seen", formatTime(device.FirstSeen()))
-	writeLine("Last Seen", formatTime(device.LastSeen()))
+	lastSeen := formatTime(device.LastSeen())
+	if s.Restored(device.IP().String()) {
+		lastSeen += " (previous run, not seen yet)"
+	}
+	writeLine("Last Seen", lastSeen)
 	_, _ = fmt.Fprintln(d.info)
 
+	if day, ok := s.Presence(device.IP().String(), 24*time.Hour, presenceBuckets); ok && day.Monitored > 0 {
+		writeSection("Presence")
+		writeLine("  Last 24h", formatPresence(day))
+		if week, ok := s.Presence(device.IP().String(), 7*24*time.Hour, 0); ok {
+			writeLine("  Last 7d", formatPresence(week))
+		}
+		writeLine("  Timeline", utils.Sparkline(day.Timeline))
+		_, _ = fmt.Fprintln(d.info)
+	}
+
 	writeSection("Sources")
 	if len(device.Sources()) == 0 {
 		_, _ = fmt.Fprintln(d.info, "  (none)")
@@ -159,16 +223,39 @@ func (d *DetailView) Render(s state.ReadOnly) {
 	}
 
 	_, _ = fmt.Fprintln(d.info)
-	writeSection("Open Ports")
-	if len(device.OpenPorts()) == 0 {
-		_, _ = fmt.Fprintln(d.info, "  (no ports scanned yet)")
-	} else {
-		for _, key := range utils.SortedKeys(device.OpenPorts()) {
-			ports := device.OpenPorts()[key]
+	writeSection("Ports")
+	portResults := device.PortResults()
+	openPorts := device.OpenPorts()
+	switch {
+	case len(portResults) > 0:
+		for _, key := range utils.SortedKeys(portResults) {
+			results := portResults[key]
+			if len(results) == 0 {
+				continue
+			}
+			writeProto(key)
+			for _, r := range results {
+				name := r.ServiceName
+				if name == "" {
+					name = s.ServiceName(r.Port, key)
+				}
+				_, _ = fmt.Fprintf(d.info, "    %-6d %-16s %-13s %-8s %s\n", r.Port, name, r.State, fmtLatency(r.Latency), fmtService(r.Service))
+				if r.Service != nil && r.Service.Title != "" {
+					_, _ = fmt.Fprintf(d.info, "    %-6s %s\n", "", utils.SanitizeString(r.Service.Title))
+				}
+			}
+			_, _ = fmt.Fprintln(d.info)
+		}
+		if !device.LastPortScan().IsZero() {
+			writeLastScan(device.LastPortScan().Format("2006-01-02 15:04:05"))
+		}
+	case len(openPorts) > 0:
+		for _, key := range utils.SortedKeys(openPorts) {
+			ports := openPorts[key]
 			if len(ports) > 0 {
 				writeProto(key)
 				for _, port := range ports {
-					_, _ = fmt.Fprintf(d.info, "    %d\n", port)
+					_, _ = fmt.Fprintf(d.info, "    %-6d %s\n", port, s.ServiceName(port, key))
 				}
 				_, _ = fmt.Fprintln(d.info)
 			}
@@ -176,6 +263,34 @@ func (d *DetailView) Render(s state.ReadOnly) {
 		if !device.LastPortScan().IsZero() {
 			writeLastScan(device.LastPortScan().Format("2006-01-02 15:04:05"))
 		}
+	default:
+		_, _ = fmt.Fprintln(d.info, "  (no ports scanned yet)")
+	}
+
+	if certs := certificateResults(portResults); len(certs) > 0 {
+		_, _ = fmt.Fprintln(d.info)
+		writeSection("Certificates")
+		now := time.Now()
+		for _, r := range certs {
+			c := r.Service.Certificate
+			writeProto(fmt.Sprintf("%d/%s", r.Port, r.Protocol))
+			issuer := c.Issuer
+			if c.SelfSigned {
+				issuer += " (self-signed)"
+			}
+			validity := fmt.Sprintf("%s - %s (%s)", c.NotBefore.Format("2006-01-02"), c.NotAfter.Format("2006-01-02"), fmtExpiry(c, now))
+			if !noColor && c.ExpiresWithin(certExpiryWarning, now) {
+				validity = "[" + utils.ColorToHexTag(tcell.ColorRed) + "]" + validity + "[-]"
+			}
+			_, _ = fmt.Fprintf(d.info, "    Subject:  %s\n", utils.SanitizeString(c.Subject))
+			_, _ = fmt.Fprintf(d.info, "    Issuer:   %s\n", utils.SanitizeString(issuer))
+			if len(c.SANs) > 0 {
+				_, _ = fmt.Fprintf(d.info, "    SANs:     %s\n", utils.SanitizeString(strings.Join(c.SANs, ", ")))
+			}
+			_, _ = fmt.Fprintf(d.info, "    Valid:    %s\n", validity)
+			_, _ = fmt.Fprintf(d.info, "    Key:      %s %s\n", c.KeyType, c.TLSVersion)
+			_, _ = fmt.Fprintf(d.info, "    SHA-256:  %s\n", c.SHA256)
+		}
 	}
 
 	_, _ = fmt.Fprintln(d.info)
@@ -193,7 +308,7 @@ func (d *DetailView) Render(s state.ReadOnly) {
 
 	switch {
 	case s.IsPortscanning():
-		d.statusBar.Spinner().SetSuffix(" Port scanning...")
+		d.statusBar.Spinner().SetSuffix(portScanSuffix(s))
 		d.statusBar.Spinner().Start(d.queue)
 	case s.IsDiscovering():
 		d.statusBar.Spinner().SetSuffix(" Discovering Devices...")
@@ -202,3 +317,81 @@ func (d *DetailView) Render(s state.ReadOnly) {
 		d.statusBar.Spinner().Stop(d.queue)
 	}
 }
+
+// fmtLatency renders a port probe latency with a precision that fits its magnitude.
+func fmtLatency(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "-"
+	case d < time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	case d < time.Second:
+		return d.Round(100 * time.Microsecond).String()
+	default:
+		return d.Round(10 * time.Millisecond).String()
+	}
+}
+
+// fmtService renders the detected service as "name product version", or the
+// raw banner when nothing more specific is known.
+func fmtService(info *discovery.ServiceInfo) string {
+	if info == nil {
+		return ""
+	}
+	var parts []string
+	for _, p := range []string{info.Name, info.Product, info.Version} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 && info.Banner != "" {
+		parts = append(parts, info.Banner)
+	}
+	return utils.SanitizeString(strings.Join(parts, " "))
+}
+
+// certificateResults returns the port results that carry a TLS certificate.
+func certificateResults(results map[string][]discovery.PortResult) []discovery.PortResult {
+	var out []discovery.PortResult
+	for _, key := range utils.SortedKeys(results) {
+		for _, r := range results[key] {
+			if r.Service != nil && r.Service.Certificate != nil {
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
+
+// fmtExpiry renders the remaining validity of a certificate in days.
+func fmtExpiry(c *discovery.CertificateInfo, now time.Time) string {
+	days := int(c.NotAfter.Sub(now).Hours() / 24)
+	switch {
+	case c.Expired(now):
+		return fmt.Sprintf("expired %d days ago", -days)
+	case days == 0:
+		return "expires today"
+	default:
+		return fmt.Sprintf("expires in %d days", days)
+	}
+}
+
+// roleNames are the human-readable names of network roles.
+var roleNames = map[string]string{
+	discovery.RoleSelf:    "This host",
+	discovery.RoleGateway: "Default gateway",
+	discovery.RoleDNS:     "DNS server",
+}
+
+// formatRoles renders roles by their human-readable names, e.g. "Default gateway, DNS server".
+func formatRoles(roles []string) string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if name, ok := roleNames[role]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, role)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,94 @@
+package views
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/ramonvermeulen/whosthere/internal/core/state"
+	"github.com/ramonvermeulen/whosthere/internal/ui/events"
+	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
+	"github.com/rivo/tview"
+)
+
+var _ View = &LabelModalView{}
+
+// labelModalWidth is the width of the label form, including its border.
+const labelModalWidth = 50
+
+// LabelModalView is a modal overlay page to label the marked devices.
+type LabelModalView struct {
+	*tview.Flex
+	form  *tview.Form
+	input *tview.InputField
+	// prefilledFor identifies the marked devices the input was last prefilled for.
+	prefilledFor string
+
+	emit func(events.Event)
+}
+
+func NewLabelModalView(emit func(events.Event)) *LabelModalView {
+	input := tview.NewInputField().
+		SetLabel("Label ").
+		SetFieldWidth(labelModalWidth - 12)
+
+	form := tview.NewForm().
+		AddFormItem(input)
+	form.SetBorder(true).SetTitle(" Label ")
+
+	p := &LabelModalView{form: form, input: input, emit: emit}
+
+	apply := func() {
+		emit(events.LabelApplied{Label: strings.TrimSpace(input.GetText())})
+		emit(events.HideView{})
+	}
+	form.AddButton("Apply", apply).
+		AddButton("Cancel", func() { emit(events.HideView{}) })
+	form.SetCancelFunc(func() { emit(events.HideView{}) })
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			apply()
+		}
+	})
+
+	p.Flex = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+			AddItem(nil, 0, 1, false).
+			AddItem(form, labelModalWidth, 0, true).
+			AddItem(nil, 0, 1, false), 7, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	theme.RegisterPrimitive(form)
+	theme.RegisterPrimitive(input)
+
+	return p
+}
+
+func (p *LabelModalView) FocusTarget() tview.Primitive { return p.form }
+
+// Render prefills the input with the label shared by all marked devices, if
+// any, whenever the set of marked devices changed.
+func (p *LabelModalView) Render(s state.ReadOnly) {
+	ips := s.MarkedIPs()
+	p.form.SetTitle(fmt.Sprintf(" Label %d device(s) ", len(ips)))
+	key := strings.Join(ips, ",")
+	if key == p.prefilledFor {
+		return
+	}
+	p.prefilledFor = key
+
+	labels := make(map[string]struct{})
+	for _, ip := range ips {
+		if d, ok := s.GetDevice(ip); ok {
+			labels[d.ExtraData()[state.LabelKey]] = struct{}{}
+		}
+	}
+	label := ""
+	if len(labels) == 1 {
+		for l := range labels {
+			label = l
+		}
+	}
+	p.input.SetText(label)
+}
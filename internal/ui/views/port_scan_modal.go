@@ -2,38 +2,48 @@ package views
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/ramonvermeulen/whosthere/internal/core/state"
 	"github.com/ramonvermeulen/whosthere/internal/ui/events"
 	"github.com/ramonvermeulen/whosthere/internal/ui/theme"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/ports"
 	"github.com/rivo/tview"
 )
 
 var _ View = &PortScanModalView{}
 
-// PortScanModalView is a modal overlay page for port scanning the selected device.
+// PortScanModalView is a modal overlay page for port scanning the selected
+// device, or all marked devices.
 type PortScanModalView struct {
 	*tview.Modal
 	emit func(events.Event)
+
+	// profile and profiles reflect the state at the last Render and drive
+	// the "Next Profile" button.
+	profile  string
+	profiles []string
 }
 
 func NewPortScanModalView(emit func(events.Event)) *PortScanModalView {
+	p := &PortScanModalView{emit: emit}
+
 	modal := tview.NewModal().
 		SetText("").
-		AddButtons([]string{"Start Scan", "Cancel"}).
+		AddButtons([]string{"Start Scan", "Next Profile", "Cancel"}).
 		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-			if buttonIndex == 0 {
+			switch buttonIndex {
+			case 0:
 				emit(events.PortScanStarted{})
-			} else {
+			case 1:
+				emit(events.PortScanProfileChanged{Name: p.nextProfile()})
+			default:
 				emit(events.HideView{})
 			}
 		})
-
-	p := &PortScanModalView{
-		Modal: modal,
-		emit:  emit,
-	}
+	p.Modal = modal
 
 	modal.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyEsc {
@@ -51,17 +61,93 @@ func NewPortScanModalView(emit func(events.Event)) *PortScanModalView {
 func (p *PortScanModalView) FocusTarget() tview.Primitive { return p.Modal }
 
 func (p *PortScanModalView) Render(s state.ReadOnly) {
-	device, ok := s.Selected()
-	if !ok {
-		p.SetText("No device selected.")
-		return
+	title := ""
+	if s.PortScanMarked() {
+		n := len(s.MarkedIPs())
+		if n == 0 {
+			p.SetText("No devices marked.")
+			return
+		}
+		title = fmt.Sprintf(" %d marked device(s) ", n)
+	} else {
+		device, ok := s.Selected()
+		if !ok {
+			p.SetText("No device selected.")
+			return
+		}
+		title = fmt.Sprintf(" IP: %s ", device.IP())
 	}
 	cfg := s.Config()
-	tcpPorts := cfg.PortScanner.TCP
+	p.profile = s.PortScanProfile()
+	p.profiles = cfg.PortScanner.ProfileNames()
+
+	text := fmt.Sprintf("Profile: %s\n\n", p.profile)
+	spec, err := cfg.PortScanner.ResolveProfile(p.profile)
+	if err != nil {
+		text += fmt.Sprintf("%v\n", err)
+	} else {
+		text += "The following ports will be scanned:\n\n"
+		text += fmt.Sprintf("TCP (%d): %s\n", len(spec.TCP), truncateList(describePorts(s, spec.TCP, "tcp")))
+		if len(spec.UDP) > 0 {
+			text += fmt.Sprintf("UDP (%d): %s\n", len(spec.UDP), truncateList(describePorts(s, spec.UDP, "udp")))
+		}
+	}
+	text += "\nOnly scan hosts that you have permission to scan!"
 
-	text := "The following ports will be scanned:\n\n"
-	text += fmt.Sprintf("TCP: %v\n\n", tcpPorts)
-	text += "Only scan hosts that you have permission to scan!"
+	p.Modal.SetText(text).SetTitle(title)
+}
 
-	p.Modal.SetText(text).SetTitle(fmt.Sprintf(" IP: %s ", device.IP()))
+// nextProfile returns the profile following the current one, wrapping around.
+func (p *PortScanModalView) nextProfile() string {
+	if len(p.profiles) == 0 {
+		return p.profile
+	}
+	for i, name := range p.profiles {
+		if name == p.profile {
+			return p.profiles[(i+1)%len(p.profiles)]
+		}
+	}
+	return p.profiles[0]
+}
+
+// describePorts renders a port list compactly, naming single ports after
+// their service, e.g. "22 ssh, 80 http, 8000-8100".
+func describePorts(s state.ReadOnly, list []int, proto string) string {
+	parts := strings.Split(ports.FormatList(list), ",")
+	for i, part := range parts {
+		if strings.Contains(part, "-") {
+			continue
+		}
+		port, err := strconv.Atoi(part)
+		if err != nil {
+			continue
+		}
+		if name := s.ServiceName(port, proto); name != "" {
+			parts[i] = part + " " + name
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// portScanSuffix is the spinner text while port scanning, with the number of
+// hosts done during a bulk scan.
+func portScanSuffix(s state.ReadOnly) string {
+	if done, total := s.PortScanProgress(); total > 0 {
+		return fmt.Sprintf(" Port scanning %d/%d hosts...", done, total)
+	}
+	return " Port scanning..."
+}
+
+// maxPortListLen caps the port list shown in the modal so large profiles stay readable.
+const maxPortListLen = 200
+
+func truncateList(s string) string {
+	if len(s) <= maxPortListLen {
+		return s
+	}
+	cut := strings.LastIndexByte(s[:maxPortListLen], ',')
+	if cut < 0 {
+		cut = maxPortListLen
+	}
+	return s[:cut] + ", ..."
 }
@@ -0,0 +1,138 @@
+// Command zz_services trims the IANA Service Name and Transport Protocol Port
+// Number Registry to the service names embedded in pkg/discovery/services.
+//
+//	go run ./internal/zz_services service-names-port-numbers.csv pkg/discovery/services/service-names.csv
+//
+// Only named TCP and UDP ports are kept, with the first name listed for each
+// port. Port ranges are expanded.
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type service struct {
+	name  string
+	port  int
+	proto string
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: zz_services <iana.csv> <service-names.csv>")
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2]); err != nil {
+		fmt.Fprintln(os.Stderr, "zz_services:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out string) error {
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	services, err := read(f)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return errors.New("no services found")
+	}
+
+	tmp := out + ".tmp"
+	w, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"Service Name", "Port Number", "Transport Protocol"})
+	for _, s := range services {
+		_ = cw.Write([]string{s.name, strconv.Itoa(s.port), s.proto})
+	}
+	cw.Flush()
+	if err := errors.Join(cw.Error(), w.Close()); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, out)
+}
+
+// read parses the registry and returns its named TCP and UDP ports, ordered by
+// port and protocol.
+func read(r io.Reader) ([]service, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if len(header) < 3 || header[0] != "Service Name" {
+		return nil, errors.New("unexpected header, expected the IANA service names CSV")
+	}
+
+	seen := make(map[string]bool)
+	var services []service
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 3 {
+			continue
+		}
+		name, proto := strings.TrimSpace(rec[0]), strings.ToLower(strings.TrimSpace(rec[2]))
+		if name == "" || (proto != "tcp" && proto != "udp") {
+			continue
+		}
+		first, last, ok := portRange(rec[1])
+		if !ok {
+			continue
+		}
+		for port := first; port <= last; port++ {
+			// the registry lists the primary name first, later rows are aliases
+			k := strconv.Itoa(port) + "/" + proto
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			services = append(services, service{name: name, port: port, proto: proto})
+		}
+	}
+	slices.SortFunc(services, func(a, b service) int {
+		return cmp.Or(cmp.Compare(a.port, b.port), cmp.Compare(a.proto, b.proto))
+	})
+	return services, nil
+}
+
+// portRange parses a port ("22") or port range ("6000-6063").
+func portRange(s string) (first, last int, ok bool) {
+	lo, hi, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	first, err := strconv.Atoi(lo)
+	if err != nil || first < 1 || first > 65535 {
+		return 0, 0, false
+	}
+	if !isRange {
+		return first, first, true
+	}
+	last, err = strconv.Atoi(hi)
+	if err != nil || last < first || last > 65535 {
+		return 0, 0, false
+	}
+	return first, last, true
+}
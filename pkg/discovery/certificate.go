@@ -0,0 +1,29 @@
+package discovery
+
+import "time"
+
+// CertificateInfo summarizes the leaf certificate presented by a TLS service.
+// It is recorded without verifying the chain, so it also covers the self-signed
+// certificates typically found on printers, NAS boxes and management interfaces.
+type CertificateInfo struct {
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	SANs       []string  `json:"sans,omitempty"`
+	NotBefore  time.Time `json:"notBefore"`
+	NotAfter   time.Time `json:"notAfter"`
+	KeyType    string    `json:"keyType"` // e.g. "RSA-2048", "ECDSA-P-256", "Ed25519"
+	SelfSigned bool      `json:"selfSigned"`
+	SHA256     string    `json:"sha256"` // hex encoded fingerprint of the DER certificate
+	TLSVersion string    `json:"tlsVersion,omitempty"`
+}
+
+// Expired reports whether the certificate is no longer valid at now.
+func (c *CertificateInfo) Expired(now time.Time) bool {
+	return now.After(c.NotAfter)
+}
+
+// ExpiresWithin reports whether the certificate expires within d of now.
+// Already expired certificates are included.
+func (c *CertificateInfo) ExpiresWithin(d time.Duration, now time.Time) bool {
+	return c.NotAfter.Sub(now) <= d
+}
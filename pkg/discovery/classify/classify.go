@@ -0,0 +1,209 @@
+// Package classify assigns discovered devices to categories such as router,
+// printer or camera. It combines weak signals from mDNS service types, SSDP
+// device types, the OUI manufacturer, hostname patterns and open ports into a
+// category and a confidence between 0 and 1.
+//
+// Example:
+//
+//	category, confidence := classify.Classify(device)
+//	fmt.Println(classify.Glyph(category), classify.Label(category), confidence)
+package classify
+
+import (
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/scanners/mdns"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/scanners/ssdp"
+)
+
+// Category is the kind of a device.
+type Category string
+
+const (
+	Unknown  Category = ""
+	Router   Category = "router"
+	Printer  Category = "printer"
+	Media    Category = "media"
+	Speaker  Category = "speaker"
+	Phone    Category = "phone"
+	Computer Category = "computer"
+	NAS      Category = "nas"
+	Camera   Category = "camera"
+	IoT      Category = "iot"
+	Console  Category = "console"
+)
+
+// MinConfidence is the confidence below which a device is left unclassified.
+const MinConfidence = 0.3
+
+var _ discovery.Classifier = Classifier{}
+
+// categories lists all categories, ties between scores are broken in this order.
+var categories = []Category{Router, Printer, Camera, NAS, Speaker, Media, Console, Phone, IoT, Computer}
+
+var labels = map[Category]string{
+	Unknown:  "Unknown",
+	Router:   "Router",
+	Printer:  "Printer",
+	Media:    "TV/Media",
+	Speaker:  "Speaker",
+	Phone:    "Phone",
+	Computer: "Computer",
+	NAS:      "NAS",
+	Camera:   "Camera",
+	IoT:      "IoT",
+	Console:  "Game Console",
+}
+
+var glyphs = map[Category]string{
+	Unknown:  "❔",
+	Router:   "📡",
+	Printer:  "📠",
+	Media:    "📺",
+	Speaker:  "🔊",
+	Phone:    "📱",
+	Computer: "💻",
+	NAS:      "💾",
+	Camera:   "📷",
+	IoT:      "💡",
+	Console:  "🎮",
+}
+
+// aliases maps alternative names accepted by Parse to their category.
+var aliases = map[string]Category{
+	"tv":             Media,
+	"tv/media":       Media,
+	"smart-home":     IoT,
+	"smarthome":      IoT,
+	"iot/smart-home": IoT,
+	"game-console":   Console,
+	"gameconsole":    Console,
+	"game console":   Console,
+	"storage":        NAS,
+	"gateway":        Router,
+}
+
+// Categories returns all categories, excluding Unknown.
+func Categories() []Category {
+	return append([]Category(nil), categories...)
+}
+
+// Label returns the human-readable name of c, e.g. "TV/Media".
+func Label(c Category) string {
+	if l, ok := labels[c]; ok {
+		return l
+	}
+	return string(c)
+}
+
+// Glyph returns the icon shown next to c in the TUI.
+func Glyph(c Category) string {
+	if g, ok := glyphs[c]; ok {
+		return g
+	}
+	return glyphs[Unknown]
+}
+
+// Parse resolves a category from its name, label or alias, case-insensitively.
+func Parse(s string) (Category, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, c := range categories {
+		if s == string(c) || s == strings.ToLower(labels[c]) {
+			return c, true
+		}
+	}
+	c, ok := aliases[s]
+	return c, ok
+}
+
+// Classifier implements discovery.Classifier with the built-in signals.
+type Classifier struct{}
+
+// Classify implements discovery.Classifier.
+func (Classifier) Classify(d *discovery.Device) (string, float64) {
+	c, confidence := Classify(d)
+	return string(c), confidence
+}
+
+// Classify returns the most likely category of d and the confidence in it.
+// Signals come from mDNS service types, SSDP device types, the manufacturer, the
+// hostname, network roles and open ports. Every matching signal adds evidence for a category; independent signals are
+// combined as 1 - Π(1 - weight), so agreeing signals raise the confidence
+// without ever reaching 1. Devices scoring below MinConfidence are Unknown.
+func Classify(d *discovery.Device) (Category, float64) {
+	if d == nil {
+		return Unknown, 0
+	}
+	miss := make(map[Category]float64, len(categories))
+	add := func(c Category, weight float64) {
+		if _, ok := miss[c]; !ok {
+			miss[c] = 1
+		}
+		miss[c] *= 1 - weight
+	}
+
+	extra := d.ExtraData()
+	if svc := extra[mdns.ServiceTypeKey]; svc != "" {
+		for _, s := range mdnsSignals {
+			if s.match(svc) {
+				add(s.category, s.weight)
+			}
+		}
+	}
+	for _, v := range []string{extra[ssdp.DeviceTypeKey], extra["server"]} {
+		if v == "" {
+			continue
+		}
+		for _, s := range ssdpSignals {
+			if s.match(v) {
+				add(s.category, s.weight)
+			}
+		}
+	}
+	if m := d.Manufacturer(); m != "" {
+		for _, s := range manufacturerSignals {
+			if s.match(m) {
+				add(s.category, s.weight)
+			}
+		}
+	}
+	if name := d.DisplayName(); name != "" {
+		for _, s := range hostnameSignals {
+			if s.re.MatchString(name) {
+				add(s.category, s.weight)
+			}
+		}
+	}
+	for _, role := range d.Roles() {
+		for _, s := range roleSignals {
+			if s.role == role {
+				add(s.category, s.weight)
+			}
+		}
+	}
+	open := d.OpenPorts()
+	for _, s := range portSignals {
+		for _, p := range open[s.proto] {
+			if p == s.port {
+				add(s.category, s.weight)
+				break
+			}
+		}
+	}
+
+	best, bestConf := Unknown, 0.0
+	for _, c := range categories {
+		m, ok := miss[c]
+		if !ok {
+			continue
+		}
+		if conf := 1 - m; conf > bestConf {
+			best, bestConf = c, conf
+		}
+	}
+	if bestConf < MinConfidence {
+		return Unknown, 0
+	}
+	return best, bestConf
+}
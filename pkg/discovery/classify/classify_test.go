@@ -0,0 +1,107 @@
+package classify
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/scanners/mdns"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/scanners/ssdp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDevice(name, manufacturer string, extra map[string]string, openTCP ...int) *discovery.Device {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.10"))
+	d.SetDisplayName(name)
+	d.SetManufacturer(manufacturer)
+	d.SetExtraData(extra)
+	for _, p := range openTCP {
+		d.AddPortResult(discovery.PortResult{Port: p, Protocol: "tcp", State: discovery.PortOpen})
+	}
+	return d
+}
+
+func withRole(d *discovery.Device, role string) *discovery.Device {
+	d.AddRole(role)
+	return d
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		device *discovery.Device
+		want   Category
+	}{
+		{"mdns printer", newDevice("Office._ipp._tcp.local.", "", map[string]string{mdns.ServiceTypeKey: "_ipp._tcp"}), Printer},
+		{"ssdp gateway", newDevice("", "", map[string]string{ssdp.DeviceTypeKey: "urn:schemas-upnp-org:device:InternetGatewayDevice:1"}), Router},
+		{"sonos", newDevice("", "Sonos, Inc.", nil), Speaker},
+		{"camera by manufacturer", newDevice("", "Hangzhou Hikvision Digital Technology Co.,Ltd.", nil), Camera},
+		{"phone by hostname", newDevice("Pixel-7", "", nil), Phone},
+		{"iot by hostname", newDevice("shelly-plug-a1b2c3", "", nil), IoT},
+		{"nas by name", newDevice("diskstation", "", nil), NAS},
+		{"computer by rdp port", newDevice("", "", nil, 3389), Computer},
+		{"printer by raw port", newDevice("", "", nil, 9100), Printer},
+		{"console", newDevice("", "Nintendo Co.,Ltd", nil), Console},
+		{"gateway role", withRole(newDevice("", "Some Vendor", nil), discovery.RoleGateway), Router},
+		{"unknown", newDevice("host-42", "Some Vendor", nil), Unknown},
+		{"weak signal only", newDevice("", "", nil, 22), Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conf := Classify(tt.device)
+			assert.Equal(t, tt.want, got)
+			if tt.want == Unknown {
+				assert.Zero(t, conf)
+			} else {
+				assert.GreaterOrEqual(t, conf, MinConfidence)
+				assert.Less(t, conf, 1.0)
+			}
+		})
+	}
+}
+
+func TestClassify_SignalsCombine(t *testing.T) {
+	_, single := Classify(newDevice("", "", nil, 9100))
+	_, combined := Classify(newDevice("", "Brother Industries, Ltd.", map[string]string{mdns.ServiceTypeKey: "_ipp._tcp"}, 9100))
+	assert.Greater(t, combined, single)
+}
+
+func TestClassify_StrongestCategoryWins(t *testing.T) {
+	// An Espressif module advertising an ESPHome service on a host named like a camera.
+	d := newDevice("cam-frontdoor", "Espressif Inc.", map[string]string{mdns.ServiceTypeKey: "_esphomelib._tcp"})
+	got, _ := Classify(d)
+	assert.Equal(t, IoT, got)
+}
+
+func TestClassifier_ImplementsDiscoveryClassifier(t *testing.T) {
+	var c discovery.Classifier = Classifier{}
+	category, conf := c.Classify(newDevice("", "Synology Incorporated", nil))
+	assert.Equal(t, string(NAS), category)
+	assert.Greater(t, conf, 0.0)
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Category{
+		"printer":      Printer,
+		"TV":           Media,
+		"tv/media":     Media,
+		"Game Console": Console,
+		"smart-home":   IoT,
+		" NAS ":        NAS,
+	} {
+		got, ok := Parse(in)
+		require.True(t, ok, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, ok := Parse("toaster")
+	assert.False(t, ok)
+}
+
+func TestLabelAndGlyph(t *testing.T) {
+	for _, c := range Categories() {
+		assert.NotEmpty(t, Label(c))
+		assert.NotEqual(t, Glyph(Unknown), Glyph(c), c)
+	}
+	assert.Equal(t, "Unknown", Label(Unknown))
+}
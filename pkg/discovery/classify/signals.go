@@ -0,0 +1,208 @@
+package classify
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// substringSignal adds weight to category when a value contains the substring,
+// case-insensitively.
+type substringSignal struct {
+	substr   string
+	category Category
+	weight   float64
+}
+
+func (s substringSignal) match(v string) bool {
+	return strings.Contains(strings.ToLower(v), s.substr)
+}
+
+// hostnameSignal adds weight to category when the display name matches re.
+type hostnameSignal struct {
+	re       *regexp.Regexp
+	category Category
+	weight   float64
+}
+
+// roleSignal adds weight to category when the device has role.
+type roleSignal struct {
+	role     string
+	category Category
+	weight   float64
+}
+
+// portSignal adds weight to category when port is open.
+type portSignal struct {
+	proto    string
+	port     int
+	category Category
+	weight   float64
+}
+
+// mdnsSignals match DNS-SD service types, e.g. "_ipp._tcp".
+var mdnsSignals = []substringSignal{
+	{"_ipp.", Printer, 0.9},
+	{"_ipps.", Printer, 0.9},
+	{"_printer.", Printer, 0.9},
+	{"_pdl-datastream.", Printer, 0.9},
+	{"_scanner.", Printer, 0.6},
+	{"_uscan.", Printer, 0.6},
+	{"_googlecast.", Media, 0.7},
+	{"_airplay.", Media, 0.6},
+	{"_androidtvremote", Media, 0.9},
+	{"_roku", Media, 0.9},
+	{"_raop.", Speaker, 0.5},
+	{"_spotify-connect.", Speaker, 0.5},
+	{"_sonos.", Speaker, 0.9},
+	{"_smb.", NAS, 0.4},
+	{"_afpovertcp.", NAS, 0.4},
+	{"_adisk.", NAS, 0.6},
+	{"_nfs.", NAS, 0.5},
+	{"_hap.", IoT, 0.8},
+	{"_hue.", IoT, 0.9},
+	{"_matter", IoT, 0.8},
+	{"_esphomelib.", IoT, 0.9},
+	{"_shelly.", IoT, 0.9},
+	{"_companion-link.", Phone, 0.4},
+	{"_apple-mobdev2.", Phone, 0.8},
+	{"_workstation.", Computer, 0.7},
+	{"_ssh.", Computer, 0.4},
+	{"_sftp-ssh.", Computer, 0.4},
+	{"_rfb.", Computer, 0.5},
+	{"_axis-video.", Camera, 0.9},
+	{"_rtsp.", Camera, 0.5},
+	{"_onvif", Camera, 0.8},
+	{"_xbox", Console, 0.9},
+}
+
+// ssdpSignals match UPnP device types and SERVER headers.
+var ssdpSignals = []substringSignal{
+	{":internetgatewaydevice:", Router, 0.9},
+	{":wandevice:", Router, 0.8},
+	{":wanconnectiondevice:", Router, 0.8},
+	{":mediarenderer:", Media, 0.6},
+	{"dial-multiscreen-org", Media, 0.8},
+	{":mediaserver:", NAS, 0.5},
+	{":zoneplayer:", Speaker, 0.9},
+	{":printer:", Printer, 0.9},
+	{":digitalsecuritycamera:", Camera, 0.9},
+	{"roku", Media, 0.8},
+	{"xbox", Console, 0.8},
+	{"playstation", Console, 0.8},
+	{"synology", NAS, 0.8},
+	{"qnap", NAS, 0.8},
+}
+
+// manufacturerSignals match OUI organization names.
+var manufacturerSignals = []substringSignal{
+	{"mikrotik", Router, 0.7},
+	{"ubiquiti", Router, 0.5},
+	{"netgear", Router, 0.5},
+	{"tp-link", Router, 0.4},
+	{"zyxel", Router, 0.5},
+	{"avm gmbh", Router, 0.7},
+	{"arris", Router, 0.6},
+	{"technicolor", Router, 0.6},
+	{"sagemcom", Router, 0.6},
+	{"juniper", Router, 0.5},
+	{"cisco", Router, 0.4},
+	{"brother", Printer, 0.8},
+	{"seiko epson", Printer, 0.8},
+	{"canon", Printer, 0.6},
+	{"lexmark", Printer, 0.8},
+	{"xerox", Printer, 0.8},
+	{"kyocera", Printer, 0.8},
+	{"ricoh", Printer, 0.7},
+	{"hewlett packard", Printer, 0.3},
+	{"roku", Media, 0.8},
+	{"vizio", Media, 0.8},
+	{"hisense", Media, 0.7},
+	{"tcl", Media, 0.5},
+	{"lg electronics", Media, 0.4},
+	{"panasonic", Media, 0.3},
+	{"sonos", Speaker, 0.9},
+	{"bose", Speaker, 0.8},
+	{"harman", Speaker, 0.5},
+	{"oneplus", Phone, 0.7},
+	{"motorola mobility", Phone, 0.7},
+	{"guangdong oppo", Phone, 0.7},
+	{"vivo mobile", Phone, 0.7},
+	{"xiaomi", Phone, 0.3},
+	{"huawei", Phone, 0.3},
+	{"intel corporate", Computer, 0.5},
+	{"dell", Computer, 0.5},
+	{"lenovo", Computer, 0.4},
+	{"micro-star", Computer, 0.5},
+	{"giga-byte", Computer, 0.5},
+	{"asrock", Computer, 0.6},
+	{"synology", NAS, 0.9},
+	{"qnap", NAS, 0.9},
+	{"western digital", NAS, 0.5},
+	{"buffalo", NAS, 0.4},
+	{"hikvision", Camera, 0.9},
+	{"dahua", Camera, 0.9},
+	{"axis communications", Camera, 0.9},
+	{"reolink", Camera, 0.9},
+	{"amcrest", Camera, 0.8},
+	{"espressif", IoT, 0.7},
+	{"tuya", IoT, 0.8},
+	{"allterco", IoT, 0.9},
+	{"shelly", IoT, 0.9},
+	{"signify", IoT, 0.9},
+	{"philips lighting", IoT, 0.9},
+	{"nest labs", IoT, 0.6},
+	{"ecobee", IoT, 0.9},
+	{"irobot", IoT, 0.8},
+	{"belkin", IoT, 0.4},
+	{"silicon laboratories", IoT, 0.5},
+	{"nintendo", Console, 0.9},
+	{"sony interactive", Console, 0.9},
+}
+
+// hostnameSignals match mDNS, SSDP or DNS names.
+var hostnameSignals = []hostnameSignal{
+	{regexp.MustCompile(`(?i)\b(router|gateway|fritz\.?box|openwrt|pfsense|opnsense|edgerouter|mikrotik|dd-wrt)\b|^(gw|rtr)\b`), Router, 0.7},
+	{regexp.MustCompile(`(?i)printer|laserjet|officejet|deskjet|envy|\bmfc-|^(brn|npi)[0-9a-f]{6}`), Printer, 0.7},
+	{regexp.MustCompile(`(?i)\b(tv|bravia|roku|chromecast|apple-?tv|fire-?tv|shield|webos|tizen)\b`), Media, 0.7},
+	{regexp.MustCompile(`(?i)sonos|homepod|\becho\b|speaker|nest-?(mini|audio)|google-?home`), Speaker, 0.7},
+	{regexp.MustCompile(`(?i)iphone|ipad|android|galaxy|pixel|\bphone\b`), Phone, 0.7},
+	{regexp.MustCompile(`(?i)macbook|imac|mac-?mini|mac-?pro|laptop|desktop|thinkpad|workstation|\bpc\b`), Computer, 0.6},
+	{regexp.MustCompile(`(?i)\bnas\b|diskstation|synology|qnap|truenas|freenas|unraid`), NAS, 0.8},
+	{regexp.MustCompile(`(?i)\bcam\b|^cam-|camera|ipcam|doorbell|\b(nvr|dvr)\b`), Camera, 0.7},
+	{regexp.MustCompile(`(?i)esp[-_]?[0-9a-f]{4,}|shelly|tasmota|esphome|tuya|\bhue\b|thermostat|\bplug\b|sensor|\bbulb\b`), IoT, 0.6},
+	{regexp.MustCompile(`(?i)xbox|playstation|\bps[345]\b|nintendo`), Console, 0.7},
+}
+
+// roleSignals match network roles, e.g. the default gateway.
+var roleSignals = []roleSignal{
+	{discovery.RoleGateway, Router, 0.9},
+	{discovery.RoleSelf, Computer, 0.5},
+}
+
+// portSignals match open ports recorded by port scans.
+var portSignals = []portSignal{
+	{"tcp", 9100, Printer, 0.8},
+	{"tcp", 515, Printer, 0.6},
+	{"tcp", 631, Printer, 0.4},
+	{"tcp", 554, Camera, 0.5},
+	{"tcp", 37777, Camera, 0.8},
+	{"tcp", 5000, NAS, 0.3},
+	{"tcp", 5001, NAS, 0.4},
+	{"tcp", 2049, NAS, 0.4},
+	{"tcp", 548, NAS, 0.3},
+	{"tcp", 3389, Computer, 0.7},
+	{"tcp", 5900, Computer, 0.3},
+	{"tcp", 22, Computer, 0.2},
+	{"tcp", 8008, Media, 0.5},
+	{"tcp", 8009, Media, 0.6},
+	{"tcp", 8060, Media, 0.8},
+	{"tcp", 1400, Speaker, 0.8},
+	{"tcp", 62078, Phone, 0.9},
+	{"tcp", 6668, IoT, 0.7},
+	{"tcp", 53, Router, 0.4},
+	{"udp", 53, Router, 0.4},
+	{"udp", 67, Router, 0.5},
+	{"udp", 3074, Console, 0.6},
+}
@@ -2,11 +2,23 @@ package discovery
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
 
+// Roles a device can have on the local network, see Device.Roles.
+const (
+	RoleSelf    = "self"    // the host running the discovery
+	RoleGateway = "gateway" // a default gateway of the host
+	RoleDNS     = "dns"     // a DNS server configured on the host
+)
+
 // Device represents a discovered network device with information aggregated
 // from multiple discovery protocols (ARP, mDNS, SSDP, etc.).
 //
@@ -18,26 +30,39 @@ import (
 //   - mac: Hardware address in colon-separated format (e.g., "aa:bb:cc:dd:ee:ff")
 //   - displayName: Human-readable name from mDNS, SSDP, or other protocols
 //   - manufacturer: Vendor name derived from the MAC address OUI prefix
+//   - subnet: Local subnet of the device in CIDR notation (e.g., "192.168.1.0/24")
+//   - category: Kind of device (e.g. "printer") and the confidence in it, set by a Classifier
+//   - tags: Free-form tags, e.g. set by rules
+//   - roles: Roles on the local network, e.g. RoleGateway
 //   - sources: Set of scanner names that contributed data (e.g., {"arp-cache", "mdns"})
 //   - firstSeen: When this device was first discovered
 //   - lastSeen: Most recent discovery time
 //   - extraData: Protocol-specific metadata (e.g., SSDP device type, mDNS TXT records)
-//   - openPorts: Results from port scans, organized by protocol (not serialized to JSON)
-//   - lastPortScan: Timestamp of the most recent port scan (not serialized to JSON)
+//   - openPorts: Open port numbers from port scans, organized by protocol
+//   - portResults: Per-port scan results (state, latency), organized by protocol
+//   - lastPortScan: Timestamp of the most recent port scan
 //
 // Devices are uniquely identified by their IP address. When the same IP is seen
 // by multiple scanners, their data is merged using the Merge method.
+//
+// All fields are serialized to JSON, and UnmarshalJSON restores a device from it.
 type Device struct {
 	mu           sync.RWMutex
 	ip           net.IP
 	mac          string
 	displayName  string
 	manufacturer string
+	subnet       string
+	category     string
+	categoryConf float64
+	tags         []string
+	roles        []string
 	sources      map[string]struct{}
 	firstSeen    time.Time
 	lastSeen     time.Time
 	extraData    map[string]string
 	openPorts    map[string][]int
+	portResults  map[string][]PortResult
 	lastPortScan time.Time
 }
 
@@ -53,12 +78,13 @@ type Device struct {
 func NewDevice(ip net.IP) *Device {
 	now := time.Now()
 	return &Device{
-		ip:        ip,
-		sources:   make(map[string]struct{}),
-		firstSeen: now,
-		lastSeen:  now,
-		extraData: make(map[string]string),
-		openPorts: make(map[string][]int),
+		ip:          ip,
+		sources:     make(map[string]struct{}),
+		firstSeen:   now,
+		lastSeen:    now,
+		extraData:   make(map[string]string),
+		openPorts:   make(map[string][]int),
+		portResults: make(map[string][]PortResult),
 	}
 }
 
@@ -67,11 +93,16 @@ func NewDevice(ip net.IP) *Device {
 //   - ip: copied if missing
 //   - mac: copied if missing
 //   - displayName: copied if missing
-//   - manufacturer: copied if missing
+//   - manufacturer: other wins when set, as it holds the latest OUI lookup
+//   - subnet: copied if missing
+//   - category: the one with the highest confidence, other wins ties
+//   - tags, roles: union of both
 //   - sources: union of all sources
 //   - extraData: merged, new keys added
 //   - firstSeen: earliest time
 //   - lastSeen: latest time
+//   - openPorts: union per protocol
+//   - portResults: merged per protocol, new ports added
 //
 // Thread-safe: both devices are locked during the operation.
 //
@@ -111,9 +142,22 @@ func (d *Device) Merge(other *Device) {
 	if d.displayName == "" && other.displayName != "" {
 		d.displayName = other.displayName
 	}
-	if d.manufacturer == "" && other.manufacturer != "" {
+	if other.manufacturer != "" {
+		// the latest lookup wins, e.g. after the OUI overrides changed
 		d.manufacturer = other.manufacturer
 	}
+	if d.subnet == "" && other.subnet != "" {
+		d.subnet = other.subnet
+	}
+	if other.category != "" && other.categoryConf >= d.categoryConf {
+		d.category, d.categoryConf = other.category, other.categoryConf
+	}
+	for _, tag := range other.tags {
+		d.tags = insertSorted(d.tags, tag)
+	}
+	for _, role := range other.roles {
+		d.roles = insertSorted(d.roles, role)
+	}
 	if d.sources == nil {
 		d.sources = make(map[string]struct{})
 	}
@@ -154,6 +198,16 @@ func (d *Device) Merge(other *Device) {
 			}
 		}
 	}
+	if d.portResults == nil {
+		d.portResults = make(map[string][]PortResult)
+	}
+	for protocol, results := range other.portResults {
+		for _, r := range results {
+			if indexOfPort(d.portResults[protocol], r.Port) < 0 {
+				d.portResults[protocol] = insertPortResult(d.portResults[protocol], r)
+			}
+		}
+	}
 	if other.lastPortScan.After(d.lastPortScan) {
 		d.lastPortScan = other.lastPortScan
 	}
@@ -190,6 +244,48 @@ func (d *Device) Manufacturer() string {
 	return d.manufacturer
 }
 
+// Subnet returns the local subnet of the device in CIDR notation, or "" when unknown.
+func (d *Device) Subnet() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.subnet
+}
+
+// Category returns the device's category, or "" when it is unknown.
+func (d *Device) Category() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.category
+}
+
+// CategoryConfidence returns the confidence in the device's category, between 0 and 1.
+func (d *Device) CategoryConfidence() float64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.categoryConf
+}
+
+// Tags returns a copy of the device's tags, sorted.
+func (d *Device) Tags() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]string(nil), d.tags...)
+}
+
+// Roles returns a copy of the device's roles, sorted.
+func (d *Device) Roles() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]string(nil), d.roles...)
+}
+
+// HasRole reports whether the device has role.
+func (d *Device) HasRole(role string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return slices.Contains(d.roles, role)
+}
+
 // Sources returns a copy of the device's sources map.
 func (d *Device) Sources() map[string]struct{} {
 	d.mu.RLock()
@@ -237,6 +333,37 @@ func (d *Device) OpenPorts() map[string][]int {
 	return m
 }
 
+// PortResults returns a deep copy of the per-port scan results, organized by protocol
+// and sorted by port number.
+func (d *Device) PortResults() map[string][]PortResult {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	m := make(map[string][]PortResult, len(d.portResults))
+	for k, v := range d.portResults {
+		m[k] = append([]PortResult(nil), v...)
+	}
+	return m
+}
+
+// ExpiringCertificates returns the port results whose TLS certificate expires
+// within d of now, including already expired ones, sorted by protocol and port.
+func (d *Device) ExpiringCertificates(within time.Duration, now time.Time) []PortResult {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var out []PortResult
+	for _, proto := range sortedKeys(d.portResults) {
+		for _, r := range d.portResults[proto] {
+			if r.Service == nil || r.Service.Certificate == nil {
+				continue
+			}
+			if r.Service.Certificate.ExpiresWithin(within, now) {
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
+
 // LastPortScan returns the last port scan time.
 func (d *Device) LastPortScan() time.Time {
 	d.mu.RLock()
@@ -276,6 +403,21 @@ func (d *Device) SetManufacturer(manufacturer string) {
 	d.manufacturer = manufacturer
 }
 
+// SetSubnet sets the local subnet of the device in CIDR notation.
+func (d *Device) SetSubnet(subnet string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subnet = subnet
+}
+
+// SetCategory sets the device's category and the confidence in it, between 0 and 1.
+func (d *Device) SetCategory(category string, confidence float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.category = category
+	d.categoryConf = confidence
+}
+
 // SetSources sets the device's sources map.
 func (d *Device) SetSources(sources map[string]struct{}) {
 	d.mu.Lock()
@@ -320,6 +462,58 @@ func (d *Device) SetOpenPorts(ports map[string][]int) {
 	}
 }
 
+// SetPortResults replaces all port scan results and rebuilds the open ports map from them.
+// Passing an empty slice clears previous results, e.g. before starting a new scan.
+func (d *Device) SetPortResults(results []PortResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.portResults = make(map[string][]PortResult)
+	d.openPorts = make(map[string][]int)
+	for _, r := range results {
+		d.addPortResultLocked(r)
+	}
+}
+
+// AddPortResult records the result for a single port, replacing any previous
+// result for the same protocol and port. Open ports are also reflected in OpenPorts.
+func (d *Device) AddPortResult(r PortResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.portResults == nil {
+		d.portResults = make(map[string][]PortResult)
+	}
+	if d.openPorts == nil {
+		d.openPorts = make(map[string][]int)
+	}
+	d.addPortResultLocked(r)
+}
+
+func (d *Device) addPortResultLocked(r PortResult) {
+	proto := r.Protocol
+	if i := indexOfPort(d.portResults[proto], r.Port); i >= 0 {
+		d.portResults[proto][i] = r
+	} else {
+		d.portResults[proto] = insertPortResult(d.portResults[proto], r)
+	}
+
+	ports := d.openPorts[proto]
+	idx := -1
+	for i, p := range ports {
+		if p == r.Port {
+			idx = i
+			break
+		}
+	}
+	switch {
+	case r.State == PortOpen && idx < 0:
+		ports = append(ports, r.Port)
+		sort.Ints(ports)
+		d.openPorts[proto] = ports
+	case r.State != PortOpen && idx >= 0:
+		d.openPorts[proto] = append(ports[:idx], ports[idx+1:]...)
+	}
+}
+
 // SetLastPortScan sets the last port scan time.
 func (d *Device) SetLastPortScan(t time.Time) {
 	d.mu.Lock()
@@ -337,6 +531,29 @@ func (d *Device) AddSource(name string) {
 	d.sources[name] = struct{}{}
 }
 
+// AddTag adds a tag to the device, if not present yet.
+func (d *Device) AddTag(tag string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tags = insertSorted(d.tags, tag)
+}
+
+// RemoveTag removes a tag from the device, if present.
+func (d *Device) RemoveTag(tag string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i, ok := slices.BinarySearch(d.tags, tag); ok {
+		d.tags = slices.Delete(d.tags, i, i+1)
+	}
+}
+
+// AddRole adds a role to the device, if not present yet.
+func (d *Device) AddRole(role string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.roles = insertSorted(d.roles, role)
+}
+
 // AddExtraData adds a key-value pair to extra data.
 func (d *Device) AddExtraData(key, value string) {
 	d.mu.Lock()
@@ -357,11 +574,17 @@ func (d *Device) Copy() *Device {
 		mac:          d.mac,
 		displayName:  d.displayName,
 		manufacturer: d.manufacturer,
+		subnet:       d.subnet,
+		category:     d.category,
+		categoryConf: d.categoryConf,
+		tags:         append([]string(nil), d.tags...),
+		roles:        append([]string(nil), d.roles...),
 		sources:      make(map[string]struct{}),
 		firstSeen:    d.firstSeen,
 		lastSeen:     d.lastSeen,
 		extraData:    make(map[string]string),
 		openPorts:    make(map[string][]int),
+		portResults:  make(map[string][]PortResult),
 		lastPortScan: d.lastPortScan,
 	}
 
@@ -374,49 +597,227 @@ func (d *Device) Copy() *Device {
 	for k, v := range d.openPorts {
 		newD.openPorts[k] = append([]int(nil), v...)
 	}
+	for k, v := range d.portResults {
+		newD.portResults[k] = append([]PortResult(nil), v...)
+	}
 
 	return newD
 }
 
+// deviceJSON is the JSON representation of a Device.
+type deviceJSON struct {
+	IP           string            `json:"ip"`
+	MAC          string            `json:"mac"`
+	DisplayName  string            `json:"displayName"`
+	Manufacturer string            `json:"manufacturer"`
+	Subnet       string            `json:"subnet,omitempty"`
+	Category     string            `json:"category,omitempty"`
+	Confidence   float64           `json:"categoryConfidence,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+	Roles        []string          `json:"roles,omitempty"`
+	Sources      []string          `json:"sources"`
+	FirstSeen    time.Time         `json:"firstSeen"`
+	LastSeen     time.Time         `json:"lastSeen"`
+	ExtraData    map[string]string `json:"extraData"`
+	OpenPorts    map[string][]int  `json:"openPorts,omitempty"`
+	Ports        []PortResult      `json:"ports,omitempty"`
+	LastPortScan time.Time         `json:"lastPortScan,omitzero"`
+}
+
 // MarshalJSON customizes the JSON encoding of the Device struct.
 // It ensures thread-safe access to the fields.
 func (d *Device) MarshalJSON() ([]byte, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	type temp struct {
-		IP           string            `json:"ip"`
-		MAC          string            `json:"mac"`
-		DisplayName  string            `json:"displayName"`
-		Manufacturer string            `json:"manufacturer"`
-		Sources      []string          `json:"sources"`
-		FirstSeen    time.Time         `json:"firstSeen"`
-		LastSeen     time.Time         `json:"lastSeen"`
-		ExtraData    map[string]string `json:"extraData"`
-	}
-
 	ipStr := ""
 	if d.ip != nil {
 		ipStr = d.ip.String()
 	}
 
-	t := temp{
+	t := deviceJSON{
 		IP:           ipStr,
 		MAC:          d.mac,
 		DisplayName:  d.displayName,
 		Manufacturer: d.manufacturer,
+		Subnet:       d.subnet,
+		Category:     d.category,
+		Confidence:   math.Round(d.categoryConf*100) / 100,
+		Tags:         d.tags,
+		Roles:        d.roles,
 		Sources:      make([]string, 0, len(d.sources)),
 		FirstSeen:    d.firstSeen,
 		LastSeen:     d.lastSeen,
 		ExtraData:    make(map[string]string, len(d.extraData)),
+		LastPortScan: d.lastPortScan,
 	}
 
-	for source := range d.sources {
-		t.Sources = append(t.Sources, source)
-	}
+	t.Sources = append(t.Sources, sortedKeys(d.sources)...)
 	for k, v := range d.extraData {
 		t.ExtraData[k] = v
 	}
+	for proto, ports := range d.openPorts {
+		if len(ports) > 0 {
+			if t.OpenPorts == nil {
+				t.OpenPorts = make(map[string][]int)
+			}
+			t.OpenPorts[proto] = ports
+		}
+	}
+	for _, proto := range sortedKeys(d.portResults) {
+		t.Ports = append(t.Ports, d.portResults[proto]...)
+	}
 
 	return json.Marshal(t)
 }
+
+// UnmarshalJSON restores a device encoded by MarshalJSON. Open ports are
+// rebuilt from the port results and extended with the encoded open ports.
+func (d *Device) UnmarshalJSON(data []byte) error {
+	var t deviceJSON
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	var ip net.IP
+	if t.IP != "" {
+		if ip = net.ParseIP(t.IP); ip == nil {
+			return fmt.Errorf("invalid device IP address %q", t.IP)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.ip = ip
+	d.mac = t.MAC
+	d.displayName = t.DisplayName
+	d.manufacturer = t.Manufacturer
+	d.subnet = t.Subnet
+	d.category = t.Category
+	d.categoryConf = t.Confidence
+	d.tags = nil
+	for _, tag := range t.Tags {
+		d.tags = insertSorted(d.tags, tag)
+	}
+	d.roles = nil
+	for _, role := range t.Roles {
+		d.roles = insertSorted(d.roles, role)
+	}
+	d.sources = make(map[string]struct{}, len(t.Sources))
+	for _, source := range t.Sources {
+		d.sources[source] = struct{}{}
+	}
+	d.firstSeen = t.FirstSeen
+	d.lastSeen = t.LastSeen
+	d.extraData = make(map[string]string, len(t.ExtraData))
+	for k, v := range t.ExtraData {
+		d.extraData[k] = v
+	}
+	d.portResults = make(map[string][]PortResult)
+	d.openPorts = make(map[string][]int)
+	for _, r := range t.Ports {
+		d.addPortResultLocked(r)
+	}
+	for proto, ports := range t.OpenPorts {
+		for _, p := range ports {
+			if !slices.Contains(d.openPorts[proto], p) {
+				d.openPorts[proto] = append(d.openPorts[proto], p)
+			}
+		}
+		sort.Ints(d.openPorts[proto])
+	}
+	d.lastPortScan = t.LastPortScan
+	return nil
+}
+
+// portResultJSON is the JSON representation of a PortResult.
+type portResultJSON struct {
+	Port        int          `json:"port"`
+	Protocol    string       `json:"protocol"`
+	ServiceName string       `json:"serviceName,omitempty"`
+	State       PortState    `json:"state"`
+	Latency     string       `json:"latency"`
+	Error       string       `json:"error,omitempty"`
+	Service     *ServiceInfo `json:"service,omitempty"`
+}
+
+// MarshalJSON encodes a PortResult with its latency as a duration string and
+// its error as a plain message.
+func (r PortResult) MarshalJSON() ([]byte, error) {
+	t := portResultJSON{
+		Port:        r.Port,
+		Protocol:    r.Protocol,
+		ServiceName: r.ServiceName,
+		State:       r.State,
+		Latency:     r.Latency.Round(time.Microsecond).String(),
+		Service:     r.Service,
+	}
+	if r.Err != nil {
+		t.Error = r.Err.Error()
+	}
+	return json.Marshal(t)
+}
+
+// UnmarshalJSON restores a PortResult encoded by MarshalJSON. The error only
+// keeps its message.
+func (r *PortResult) UnmarshalJSON(data []byte) error {
+	var t portResultJSON
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	*r = PortResult{
+		Port:        t.Port,
+		Protocol:    t.Protocol,
+		ServiceName: t.ServiceName,
+		State:       t.State,
+		Service:     t.Service,
+	}
+	if t.Latency != "" {
+		latency, err := time.ParseDuration(t.Latency)
+		if err != nil {
+			return fmt.Errorf("invalid port latency %q: %w", t.Latency, err)
+		}
+		r.Latency = latency
+	}
+	if t.Error != "" {
+		r.Err = errors.New(t.Error)
+	}
+	return nil
+}
+
+// insertSorted adds s to the sorted list, unless present or empty.
+func insertSorted(list []string, s string) []string {
+	i := sort.SearchStrings(list, s)
+	if s == "" || (i < len(list) && list[i] == s) {
+		return list
+	}
+	return slices.Insert(list, i, s)
+}
+
+// indexOfPort returns the index of port in results, or -1 if absent.
+func indexOfPort(results []PortResult, port int) int {
+	for i := range results {
+		if results[i].Port == port {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertPortResult inserts r keeping results sorted by port number.
+func insertPortResult(results []PortResult, r PortResult) []PortResult {
+	i := sort.Search(len(results), func(i int) bool { return results[i].Port >= r.Port })
+	results = append(results, PortResult{})
+	copy(results[i+1:], results[i:])
+	results[i] = r
+	return results
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,7 +1,10 @@
 package discovery
 
 import (
+	"encoding/json"
+	"errors"
 	"net"
+	"strings"
 	"testing"
 	"time"
 )
@@ -32,6 +35,12 @@ func TestDeviceMerge(t *testing.T) {
 	if base.Manufacturer() != "" {
 		t.Fatalf("Manufacturer merge failed, got %s", base.Manufacturer())
 	}
+
+	other.SetManufacturer("Acme")
+	base.Merge(other)
+	if base.Manufacturer() != "Acme" {
+		t.Fatalf("expected latest manufacturer, got %s", base.Manufacturer())
+	}
 	sources := base.Sources()
 	if _, ok := sources["a"]; !ok {
 		t.Fatalf("source a missing")
@@ -55,3 +64,224 @@ func TestDeviceMergeNilOther(t *testing.T) {
 	d := NewDevice(net.IP{})
 	d.Merge(nil)
 }
+
+func TestDeviceAddPortResult(t *testing.T) {
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+	d.AddPortResult(PortResult{Port: 443, Protocol: "tcp", State: PortOpen})
+	d.AddPortResult(PortResult{Port: 22, Protocol: "tcp", State: PortOpen})
+	d.AddPortResult(PortResult{Port: 23, Protocol: "tcp", State: PortClosed})
+
+	if got := d.OpenPorts()["tcp"]; len(got) != 2 || got[0] != 22 || got[1] != 443 {
+		t.Fatalf("expected open tcp ports [22 443], got %v", got)
+	}
+	results := d.PortResults()["tcp"]
+	if len(results) != 3 || results[0].Port != 22 || results[1].Port != 23 || results[2].Port != 443 {
+		t.Fatalf("expected results sorted by port, got %+v", results)
+	}
+
+	// a later result for the same port replaces the earlier one
+	d.AddPortResult(PortResult{Port: 443, Protocol: "tcp", State: PortFiltered})
+	if got := d.OpenPorts()["tcp"]; len(got) != 1 || got[0] != 22 {
+		t.Fatalf("expected 443 removed from open ports, got %v", got)
+	}
+	if got := d.PortResults()["tcp"]; len(got) != 3 || got[2].State != PortFiltered {
+		t.Fatalf("expected 443 replaced with filtered, got %+v", got)
+	}
+
+	d.SetPortResults(nil)
+	if len(d.PortResults()) != 0 || len(d.OpenPorts()) != 0 {
+		t.Fatalf("expected results cleared")
+	}
+}
+
+func TestDeviceExpiringCertificates(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	withCert := func(port int, notAfter time.Time) PortResult {
+		return PortResult{
+			Port: port, Protocol: "tcp", State: PortOpen,
+			Service: &ServiceInfo{Certificate: &CertificateInfo{NotAfter: notAfter}},
+		}
+	}
+
+	d := NewDevice(net.ParseIP("10.0.0.1"))
+	d.AddPortResult(withCert(8443, now.Add(-24*time.Hour)))   // expired
+	d.AddPortResult(withCert(443, now.Add(10*24*time.Hour)))  // expiring soon
+	d.AddPortResult(withCert(993, now.Add(400*24*time.Hour))) // valid
+	d.AddPortResult(PortResult{Port: 22, Protocol: "tcp", State: PortOpen})
+
+	got := d.ExpiringCertificates(30*24*time.Hour, now)
+	if len(got) != 2 || got[0].Port != 443 || got[1].Port != 8443 {
+		t.Fatalf("expected ports 443 and 8443, got %+v", got)
+	}
+	if !got[1].Service.Certificate.Expired(now) || got[0].Service.Certificate.Expired(now) {
+		t.Fatalf("unexpected expiry state")
+	}
+	if len(d.ExpiringCertificates(0, now)) != 1 {
+		t.Fatalf("expected only the expired certificate with a zero window")
+	}
+}
+
+func TestDeviceCategory(t *testing.T) {
+	base := NewDevice(net.ParseIP("10.0.0.1"))
+	base.SetCategory("computer", 0.4)
+
+	weaker := NewDevice(net.ParseIP("10.0.0.1"))
+	weaker.SetCategory("phone", 0.3)
+	base.Merge(weaker)
+	if base.Category() != "computer" {
+		t.Fatalf("expected weaker category to be ignored, got %q", base.Category())
+	}
+
+	stronger := NewDevice(net.ParseIP("10.0.0.1"))
+	stronger.SetCategory("nas", 0.876)
+	base.Merge(stronger)
+	if base.Category() != "nas" || base.CategoryConfidence() != 0.876 {
+		t.Fatalf("expected stronger category to win, got %q (%v)", base.Category(), base.CategoryConfidence())
+	}
+
+	if c := base.Copy(); c.Category() != "nas" {
+		t.Fatalf("expected copy to keep category, got %q", c.Category())
+	}
+
+	b, err := base.MarshalJSON()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(b), `"category":"nas","categoryConfidence":0.88`) {
+		t.Fatalf("expected category in JSON, got %s", b)
+	}
+}
+
+func TestDeviceTags(t *testing.T) {
+	base := NewDevice(net.ParseIP("10.0.0.1"))
+	base.AddTag("risk")
+	base.AddTag("risk")
+	base.AddTag("")
+
+	other := NewDevice(net.ParseIP("10.0.0.1"))
+	other.AddTag("camera")
+	base.Merge(other)
+
+	if got := strings.Join(base.Tags(), ","); got != "camera,risk" {
+		t.Fatalf("expected sorted, unique tags, got %q", got)
+	}
+	if got := strings.Join(base.Copy().Tags(), ","); got != "camera,risk" {
+		t.Fatalf("expected copy to keep tags, got %q", got)
+	}
+	copied := base.Copy()
+	copied.RemoveTag("camera")
+	copied.RemoveTag("missing")
+	if got := strings.Join(copied.Tags(), ","); got != "risk" {
+		t.Fatalf("expected tag to be removed, got %q", got)
+	}
+
+	b, err := base.MarshalJSON()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(b), `"tags":["camera","risk"]`) {
+		t.Fatalf("expected tags in JSON, got %s", b)
+	}
+}
+
+func TestDeviceRoles(t *testing.T) {
+	base := NewDevice(net.ParseIP("192.168.1.1"))
+	base.AddRole(RoleGateway)
+
+	other := NewDevice(net.ParseIP("192.168.1.1"))
+	other.AddRole(RoleDNS)
+	other.AddRole(RoleGateway)
+	base.Merge(other)
+
+	if got := strings.Join(base.Roles(), ","); got != "dns,gateway" {
+		t.Fatalf("expected merged roles, got %q", got)
+	}
+	if !base.Copy().HasRole(RoleDNS) || base.HasRole(RoleSelf) {
+		t.Fatalf("unexpected roles on copy: %v", base.Copy().Roles())
+	}
+
+	b, err := base.MarshalJSON()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(b), `"roles":["dns","gateway"]`) {
+		t.Fatalf("expected roles in JSON, got %s", b)
+	}
+}
+
+func TestDeviceSubnet(t *testing.T) {
+	base := NewDevice(net.ParseIP("10.0.0.1"))
+	other := NewDevice(net.ParseIP("10.0.0.1"))
+	other.SetSubnet("10.0.0.0/16")
+	base.Merge(other)
+
+	if base.Subnet() != "10.0.0.0/16" || base.Copy().Subnet() != "10.0.0.0/16" {
+		t.Fatalf("expected merged subnet, got %q", base.Subnet())
+	}
+
+	b, err := base.MarshalJSON()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(b), `"subnet":"10.0.0.0/16"`) {
+		t.Fatalf("expected subnet in JSON, got %s", b)
+	}
+}
+
+func TestDeviceJSONRoundTrip(t *testing.T) {
+	seen := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := NewDevice(net.ParseIP("192.168.1.10"))
+	d.SetMAC("aa:bb:cc:dd:ee:ff")
+	d.SetDisplayName("nas.local")
+	d.SetManufacturer("Synology")
+	d.SetSubnet("192.168.1.0/24")
+	d.SetCategory("nas", 0.9)
+	d.AddTag("storage")
+	d.AddRole(RoleDNS)
+	d.AddSource("arp")
+	d.AddSource("mdns")
+	d.AddExtraData("model", "DS920+")
+	d.SetFirstSeen(seen)
+	d.SetLastSeen(seen.Add(time.Minute))
+	d.SetLastPortScan(seen.Add(2 * time.Minute))
+	d.SetPortResults([]PortResult{
+		{Port: 22, Protocol: "tcp", ServiceName: "ssh", State: PortOpen, Latency: 2 * time.Millisecond, Service: &ServiceInfo{Name: "ssh", Product: "OpenSSH"}},
+		{Port: 23, Protocol: "tcp", State: PortClosed, Err: errors.New("connection refused")},
+	})
+
+	b, err := d.MarshalJSON()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, want := range []string{`"openPorts":{"tcp":[22]}`, `"lastPortScan":"2026-03-01T12:02:00Z"`, `"sources":["arp","mdns"]`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %s in JSON, got %s", want, b)
+		}
+	}
+
+	var got Device
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	again, err := got.MarshalJSON()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(again) != string(b) {
+		t.Errorf("expected the same JSON after a round trip:\n%s\n%s", b, again)
+	}
+	results := got.PortResults()["tcp"]
+	if len(results) != 2 || results[0].Latency != 2*time.Millisecond || results[1].Err == nil || results[1].Err.Error() != "connection refused" {
+		t.Errorf("unexpected port results %+v", results)
+	}
+	if !got.HasRole(RoleDNS) || !got.LastPortScan().Equal(seen.Add(2*time.Minute)) {
+		t.Errorf("unexpected device %s", again)
+	}
+
+	if err := json.Unmarshal([]byte(`{"ip":"not an ip"}`), &got); err == nil {
+		t.Error("expected an error for an invalid IP address")
+	}
+	if b, _ := NewDevice(net.ParseIP("10.0.0.1")).MarshalJSON(); strings.Contains(string(b), "lastPortScan") || strings.Contains(string(b), "openPorts") {
+		t.Errorf("expected no port fields without a port scan, got %s", b)
+	}
+}
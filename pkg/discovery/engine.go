@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"sync"
 	"time"
 
@@ -53,28 +55,124 @@ type Sweeper interface {
 	Start(ctx context.Context)
 }
 
+// Classifier assigns a category (e.g. "printer") to a device, with a confidence
+// between 0 and 1. It returns "" when the device cannot be classified.
+// See the classify package for the built-in classifier.
+type Classifier interface {
+	Classify(d *Device) (category string, confidence float64)
+}
+
+// RuleEvaluator applies user-defined rules to a device, e.g. to label, categorize
+// or tag it, and returns the alerts raised by the matching rules.
+// See the rules package for the built-in implementation.
+type RuleEvaluator interface {
+	Evaluate(d *Device) []Alert
+}
+
+// TagUnknown tags devices that are not known, see KnownDevices.
+const TagUnknown = "unknown"
+
+// KnownDevices reports whether a device is known, e.g. listed in an allowlist.
+// Devices that are not known are tagged with TagUnknown at the end of every scan,
+// and reported once as EventUnknownDevice.
+type KnownDevices interface {
+	Known(d *Device) bool
+}
+
 // ScanStats contains statistics about a completed scan.
 type ScanStats struct {
 	Count    int
 	Duration time.Duration
 }
 
+type scanStatsJSON struct {
+	Count    int    `json:"count"`
+	Duration string `json:"duration"`
+}
+
 // MarshalJSON customizes the JSON encoding of the ScanStats struct.
 func (s *ScanStats) MarshalJSON() ([]byte, error) {
-	type temp struct {
-		Count    int    `json:"count"`
-		Duration string `json:"duration"`
-	}
-	return json.Marshal(temp{
+	return json.Marshal(scanStatsJSON{
 		Count:    s.Count,
 		Duration: fmt.Sprintf("%.1fs", s.Duration.Seconds()),
 	})
 }
 
+// UnmarshalJSON restores ScanStats encoded by MarshalJSON.
+func (s *ScanStats) UnmarshalJSON(data []byte) error {
+	var t scanStatsJSON
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	*s = ScanStats{Count: t.Count}
+	if t.Duration != "" {
+		d, err := time.ParseDuration(t.Duration)
+		if err != nil {
+			return fmt.Errorf("invalid scan duration %q: %w", t.Duration, err)
+		}
+		s.Duration = d
+	}
+	return nil
+}
+
+// ScanResultsSchemaVersion is the version of the JSON document written for
+// ScanResults. It is raised on changes that break existing readers.
+const ScanResultsSchemaVersion = 1
+
 // ScanResults contains the results of a completed scan, including devices and stats.
 type ScanResults struct {
 	Devices []*Device  `json:"devices"`
 	Stats   *ScanStats `json:"stats"`
+	// CapturedAt is when the scan completed, zero when unknown.
+	CapturedAt time.Time `json:"capturedAt,omitzero"`
+}
+
+type scanResultsJSON struct {
+	SchemaVersion int        `json:"schemaVersion"`
+	CapturedAt    time.Time  `json:"capturedAt,omitzero"`
+	Devices       []*Device  `json:"devices"`
+	Stats         *ScanStats `json:"stats"`
+}
+
+// MarshalJSON encodes the results along with ScanResultsSchemaVersion.
+func (r ScanResults) MarshalJSON() ([]byte, error) {
+	return json.Marshal(scanResultsJSON{
+		SchemaVersion: ScanResultsSchemaVersion,
+		CapturedAt:    r.CapturedAt,
+		Devices:       r.Devices,
+		Stats:         r.Stats,
+	})
+}
+
+// UnmarshalJSON restores results encoded by MarshalJSON. Documents without a
+// schema version, written before it was introduced, are accepted; documents
+// of a newer schema version are not.
+func (r *ScanResults) UnmarshalJSON(data []byte) error {
+	var t scanResultsJSON
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	if t.SchemaVersion > ScanResultsSchemaVersion {
+		return fmt.Errorf("unsupported scan results schema version %d, this version of whosthere reads up to %d",
+			t.SchemaVersion, ScanResultsSchemaVersion)
+	}
+	*r = ScanResults{Devices: t.Devices, Stats: t.Stats, CapturedAt: t.CapturedAt}
+	return nil
+}
+
+// DecodeScanResults reads scan results as written by encoding ScanResults to JSON,
+// e.g. by "whosthere scan --json".
+func DecodeScanResults(rd io.Reader) (*ScanResults, error) {
+	var r ScanResults
+	if err := json.NewDecoder(rd).Decode(&r); err != nil {
+		return nil, fmt.Errorf("decode scan results: %w", err)
+	}
+	for _, d := range r.Devices {
+		if d == nil {
+			return nil, errors.New("decode scan results: null device")
+		}
+	}
+	return &r, nil
 }
 
 // Engine coordinates multiple scanners and merges device results.
@@ -95,6 +193,9 @@ type Engine struct {
 	scanInterval  time.Duration
 	scanTimeout   time.Duration
 	ouiRegistry   *oui.Registry
+	classifier    Classifier
+	rules         RuleEvaluator
+	known         KnownDevices
 	logger        Logger
 	maxDevices    int
 
@@ -102,6 +203,9 @@ type Engine struct {
 	cancel  context.CancelFunc
 	wg      sync.WaitGroup
 	running bool
+
+	reportedMu sync.Mutex
+	reported   map[string]struct{} // identities of the unknown devices reported before
 }
 
 // NewEngine creates a new discovery engine with the provided options.
@@ -359,6 +463,7 @@ func (e *Engine) performScan(ctx context.Context) (*ScanResults, error) {
 	}
 
 	deviceSlice := mapToSlicePtr(devices)
+	e.reportUnknown(deviceSlice)
 	stats := &ScanStats{
 		Count:    len(deviceSlice),
 		Duration: time.Since(start),
@@ -366,8 +471,9 @@ func (e *Engine) performScan(ctx context.Context) (*ScanResults, error) {
 	e.emit(NewScanCompletedEvent(stats))
 
 	results := &ScanResults{
-		Devices: deviceSlice,
-		Stats:   stats,
+		Devices:    deviceSlice,
+		Stats:      stats,
+		CapturedAt: time.Now(),
 	}
 	return results, nil
 }
@@ -389,17 +495,25 @@ func (e *Engine) processDevice(d *Device, devices map[string]*Device) {
 
 	if existing, found := devices[key]; found {
 		existing.Merge(d)
+		e.fillSubnet(existing)
 		e.fillManufacturer(existing)
+		e.classify(existing)
 		d = existing
 	} else {
 		if d.FirstSeen().IsZero() {
 			d.SetFirstSeen(time.Now())
 		}
+		e.fillSubnet(d)
 		e.fillManufacturer(d)
+		e.classify(d)
 		devices[key] = d
 	}
 
+	alerts := e.evaluateRules(d)
 	e.emit(NewDeviceEvent(d))
+	for _, a := range alerts {
+		e.emit(NewAlertEvent(a))
+	}
 }
 
 // emit sends an event non-blocking
@@ -416,12 +530,82 @@ func (e *Engine) emit(event Event) {
 }
 
 // fillManufacturer fills the Manufacturer field using OUI lookup if empty.
+// Locally administered MAC addresses only resolve when they are in a company ID
+// (CID) block; the others are randomized and tagged with TagRandomizedMAC instead.
 func (e *Engine) fillManufacturer(d *Device) {
-	if d == nil || e.ouiRegistry == nil || d.Manufacturer() != "" || d.MAC() == "" {
+	if d == nil || d.Manufacturer() != "" || d.MAC() == "" {
+		return
+	}
+	if e.ouiRegistry != nil {
+		if org, ok := e.ouiRegistry.Lookup(d.MAC()); ok {
+			d.SetManufacturer(org)
+			return
+		}
+	}
+	if IsRandomizedMAC(d.MAC()) {
+		d.AddTag(TagRandomizedMAC)
+	}
+}
+
+// fillSubnet records the local subnet of the interface the device is on, if empty.
+func (e *Engine) fillSubnet(d *Device) {
+	if d == nil || d.Subnet() != "" {
+		return
+	}
+	if subnet := e.Iface.SubnetOf(d.IP()); subnet != nil {
+		d.SetSubnet((&net.IPNet{IP: subnet.IP.Mask(subnet.Mask), Mask: subnet.Mask}).String())
+	}
+}
+
+// classify sets the device category using the classifier, if any.
+func (e *Engine) classify(d *Device) {
+	if d == nil || e.classifier == nil {
 		return
 	}
-	if org, ok := e.ouiRegistry.Lookup(d.MAC()); ok {
-		d.SetManufacturer(org)
+	d.SetCategory(e.classifier.Classify(d))
+}
+
+// evaluateRules applies the rules, if any, after classification so rules can
+// override the category.
+func (e *Engine) evaluateRules(d *Device) []Alert {
+	if d == nil || e.rules == nil {
+		return nil
+	}
+	return e.rules.Evaluate(d)
+}
+
+// reportUnknown tags the devices that are not known with TagUnknown, and emits an
+// EventUnknownDevice the first time each of them is seen. This runs at the end of
+// a scan, once the scanners had a chance to resolve the MAC address of every device.
+func (e *Engine) reportUnknown(devices []*Device) {
+	if e.known == nil {
+		return
+	}
+	e.reportedMu.Lock()
+	defer e.reportedMu.Unlock()
+	if e.reported == nil {
+		e.reported = make(map[string]struct{})
+	}
+
+	for _, d := range devices {
+		if e.known.Known(d) {
+			continue
+		}
+		d.AddTag(TagUnknown)
+
+		id := d.Identity()
+		if _, ok := e.reported[id]; ok {
+			continue
+		}
+		e.reported[id] = struct{}{}
+		// a device reported before its MAC address was resolved is not reported again
+		if byIP := "ip:" + d.IP().String(); byIP != id {
+			if _, ok := e.reported[byIP]; ok {
+				delete(e.reported, byIP)
+				continue
+			}
+		}
+		e.emit(NewUnknownDeviceEvent(d))
 	}
 }
 
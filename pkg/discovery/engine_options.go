@@ -107,6 +107,60 @@ func WithScanners(scanners ...Scanner) Option {
 	}
 }
 
+// WithClassifier sets the classifier that assigns a category to every discovered
+// device, after its manufacturer has been resolved.
+func WithClassifier(c Classifier) Option {
+	return func(e *Engine) error {
+		if c == nil {
+			return errors.New("classifier cannot be nil")
+		}
+		e.classifier = c
+		return nil
+	}
+}
+
+// WithRules sets the rules evaluated on every device update, after the device has
+// been classified. Alerts raised by the rules are emitted as EventAlert events,
+// right after the EventDeviceDiscovered event of the device.
+func WithRules(r RuleEvaluator) Option {
+	return func(e *Engine) error {
+		if r == nil {
+			return errors.New("rules cannot be nil")
+		}
+		e.rules = r
+		return nil
+	}
+}
+
+// WithKnownDevices sets the devices that are known. Devices that are not known
+// are tagged with TagUnknown at the end of every scan, and reported once as an
+// EventUnknownDevice event.
+func WithKnownDevices(k KnownDevices) Option {
+	return func(e *Engine) error {
+		if k == nil {
+			return errors.New("known devices cannot be nil")
+		}
+		e.known = k
+		return nil
+	}
+}
+
+// WithReportedDevices sets the identities, see Device.Identity, of the devices
+// reported before, e.g. seen by a previous run, so unknown devices among them are
+// not reported again as an EventUnknownDevice event. They are still tagged with
+// TagUnknown.
+func WithReportedDevices(ids ...string) Option {
+	return func(e *Engine) error {
+		if e.reported == nil {
+			e.reported = make(map[string]struct{}, len(ids))
+		}
+		for _, id := range ids {
+			e.reported[id] = struct{}{}
+		}
+		return nil
+	}
+}
+
 // WithOUIRegistry enables manufacturer name lookups based on MAC address OUI prefixes.
 // The registry maps the first 3 bytes of MAC addresses to vendor names.
 // When set, the engine automatically populates the Manufacturer field of discovered devices.
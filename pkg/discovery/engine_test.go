@@ -1,8 +1,12 @@
 package discovery_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"net"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -141,3 +145,200 @@ func TestEngine_StartStop_ClosesEvents(t *testing.T) {
 		}
 	}
 }
+
+type tagRules struct{}
+
+func (tagRules) Evaluate(d *discovery.Device) []discovery.Alert {
+	d.AddTag("seen")
+	return []discovery.Alert{{Rule: "seen", Message: "device seen", Device: d}}
+}
+
+func TestEngine_Scan_EvaluatesRules(t *testing.T) {
+	iface := testkit.MustInterfaceInfo(t)
+
+	s := &testkit.FakeScanner{NameStr: "s", Devices: []*discovery.Device{discovery.NewDevice(testkit.MustIP(t, "10.0.0.2"))}}
+
+	e, err := discovery.NewEngine(
+		discovery.WithInterface(iface),
+		discovery.WithScanners(s),
+		discovery.WithScanTimeout(250*time.Millisecond),
+		discovery.WithRules(tagRules{}),
+	)
+	require.NoError(t, err)
+
+	res, err := e.Scan(context.Background())
+	require.NoError(t, err)
+	require.Len(t, res.Devices, 1)
+	require.Equal(t, []string{"seen"}, res.Devices[0].Tags())
+
+	var types []discovery.EventType
+	for len(e.Events) > 0 {
+		ev := <-e.Events
+		types = append(types, ev.Type)
+		if ev.Type == discovery.EventAlert {
+			require.Equal(t, "seen", ev.Alert.Rule)
+			require.Equal(t, "10.0.0.2", ev.Alert.Device.IP().String())
+		}
+	}
+	require.Equal(t, []discovery.EventType{
+		discovery.EventScanStarted,
+		discovery.EventDeviceDiscovered,
+		discovery.EventAlert,
+		discovery.EventScanCompleted,
+	}, types)
+}
+
+func TestEngine_Scan_RecordsSubnet(t *testing.T) {
+	iface := testkit.MustInterfaceInfo(t)
+	s := &testkit.FakeScanner{Devices: []*discovery.Device{
+		discovery.NewDevice(testkit.MustIP(t, "192.168.0.20")),
+		discovery.NewDevice(testkit.MustIP(t, "10.0.0.2")),
+	}}
+
+	e, err := discovery.NewEngine(
+		discovery.WithInterface(iface),
+		discovery.WithScanners(s),
+		discovery.WithScanTimeout(200*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	results, err := e.Scan(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results.Devices, 2)
+
+	subnets := map[string]string{}
+	for _, d := range results.Devices {
+		subnets[d.IP().String()] = d.Subnet()
+	}
+	require.Equal(t, map[string]string{"192.168.0.20": "192.168.0.0/24", "10.0.0.2": ""}, subnets)
+}
+
+func TestEngine_Scan_TagsRandomizedMAC(t *testing.T) {
+	iface := testkit.MustInterfaceInfo(t)
+	private := discovery.NewDevice(testkit.MustIP(t, "192.168.0.20"))
+	private.SetMAC("da:a1:19:00:00:01")
+	global := discovery.NewDevice(testkit.MustIP(t, "192.168.0.21"))
+	global.SetMAC("b8:27:eb:12:34:56")
+	s := &testkit.FakeScanner{Devices: []*discovery.Device{private, global}}
+
+	e, err := discovery.NewEngine(
+		discovery.WithInterface(iface),
+		discovery.WithScanners(s),
+		discovery.WithScanTimeout(200*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	results, err := e.Scan(context.Background())
+	require.NoError(t, err)
+
+	tags := map[string][]string{}
+	for _, d := range results.Devices {
+		tags[d.IP().String()] = d.Tags()
+	}
+	require.Equal(t, map[string][]string{
+		"192.168.0.20": {discovery.TagRandomizedMAC},
+		"192.168.0.21": nil,
+	}, tags)
+}
+
+type knownMACs map[string]bool
+
+func (k knownMACs) Known(d *discovery.Device) bool { return k[d.MAC()] }
+
+func TestEngine_Scan_ReportsUnknownDevicesOnce(t *testing.T) {
+	iface := testkit.MustInterfaceInfo(t)
+	known := discovery.NewDevice(testkit.MustIP(t, "192.168.0.20"))
+	known.SetMAC("b8:27:eb:12:34:56")
+	// the MAC address of the unknown device is resolved by a later observation
+	unknown := discovery.NewDevice(testkit.MustIP(t, "192.168.0.21"))
+	unknownMAC := discovery.NewDevice(testkit.MustIP(t, "192.168.0.21"))
+	unknownMAC.SetMAC("00:11:32:aa:bb:cc")
+	s := &testkit.FakeScanner{Devices: []*discovery.Device{known, unknown, unknownMAC}}
+
+	e, err := discovery.NewEngine(
+		discovery.WithInterface(iface),
+		discovery.WithScanners(s),
+		discovery.WithScanTimeout(200*time.Millisecond),
+		discovery.WithKnownDevices(knownMACs{"b8:27:eb:12:34:56": true}),
+	)
+	require.NoError(t, err)
+
+	var reported []string
+	for range 2 {
+		results, err := e.Scan(context.Background())
+		require.NoError(t, err)
+		for _, d := range results.Devices {
+			require.Equal(t, d.IP().String() == "192.168.0.21", slices.Contains(d.Tags(), discovery.TagUnknown), d.IP().String())
+		}
+		for len(e.Events) > 0 {
+			if ev := <-e.Events; ev.Type == discovery.EventUnknownDevice {
+				reported = append(reported, ev.Device.MAC())
+			}
+		}
+	}
+	require.Equal(t, []string{"00:11:32:aa:bb:cc"}, reported)
+}
+
+func TestEngine_Scan_SkipsDevicesReportedBefore(t *testing.T) {
+	iface := testkit.MustInterfaceInfo(t)
+	seen := discovery.NewDevice(testkit.MustIP(t, "192.168.0.21"))
+	seen.SetMAC("00:11:32:aa:bb:cc")
+	fresh := discovery.NewDevice(testkit.MustIP(t, "192.168.0.22"))
+	fresh.SetMAC("00:11:32:dd:ee:ff")
+	s := &testkit.FakeScanner{Devices: []*discovery.Device{seen, fresh}}
+
+	e, err := discovery.NewEngine(
+		discovery.WithInterface(iface),
+		discovery.WithScanners(s),
+		discovery.WithScanTimeout(200*time.Millisecond),
+		discovery.WithKnownDevices(knownMACs{}),
+		// seen by a previous run
+		discovery.WithReportedDevices(seen.Identity()),
+	)
+	require.NoError(t, err)
+
+	results, err := e.Scan(context.Background())
+	require.NoError(t, err)
+	for _, d := range results.Devices {
+		require.Contains(t, d.Tags(), discovery.TagUnknown, d.IP().String())
+	}
+	var reported []string
+	for len(e.Events) > 0 {
+		if ev := <-e.Events; ev.Type == discovery.EventUnknownDevice {
+			reported = append(reported, ev.Device.MAC())
+		}
+	}
+	require.Equal(t, []string{"00:11:32:dd:ee:ff"}, reported)
+}
+
+func TestDecodeScanResults(t *testing.T) {
+	d := discovery.NewDevice(testkit.MustIP(t, "10.0.0.1"))
+	d.SetMAC("aa:bb:cc:dd:ee:ff")
+	d.AddPortResult(discovery.PortResult{Port: 443, Protocol: "tcp", State: discovery.PortOpen})
+	raw, err := json.Marshal(&discovery.ScanResults{
+		Devices:    []*discovery.Device{d},
+		Stats:      &discovery.ScanStats{Count: 1, Duration: 1500 * time.Millisecond},
+		CapturedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(raw), `"schemaVersion":1`)
+
+	results, err := discovery.DecodeScanResults(bytes.NewReader(raw))
+	require.NoError(t, err)
+	require.Len(t, results.Devices, 1)
+	require.Equal(t, "aa:bb:cc:dd:ee:ff", results.Devices[0].MAC())
+	require.Equal(t, map[string][]int{"tcp": {443}}, results.Devices[0].OpenPorts())
+	require.Equal(t, &discovery.ScanStats{Count: 1, Duration: 1500 * time.Millisecond}, results.Stats)
+	require.True(t, results.CapturedAt.Equal(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)))
+
+	// documents written before the schema version was introduced
+	results, err = discovery.DecodeScanResults(strings.NewReader(`{"devices":[{"ip":"10.0.0.2"}],"stats":{"count":1,"duration":"0.5s"}}`))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.2", results.Devices[0].IP().String())
+	require.True(t, results.CapturedAt.IsZero())
+
+	_, err = discovery.DecodeScanResults(strings.NewReader(`{"schemaVersion":2,"devices":[]}`))
+	require.ErrorContains(t, err, "unsupported scan results schema version 2")
+	_, err = discovery.DecodeScanResults(strings.NewReader(`{"devices":[null]}`))
+	require.Error(t, err)
+}
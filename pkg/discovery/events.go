@@ -5,9 +5,10 @@ package discovery
 // indicating what happened. Based on the Type, exactly one of Device,
 // Error, or Stats will be non-nil:
 //
-//   - EventDeviceDiscovered: Device is non-nil
+//   - EventDeviceDiscovered, EventUnknownDevice: Device is non-nil
 //   - EventScanCompleted: Stats is non-nil
 //   - EventError: Error is non-nil
+//   - EventAlert: Alert is non-nil
 //   - EventScanStarted, EventEngineStarted, EventEngineStopped:
 //     all fields are nil
 //
@@ -23,9 +24,17 @@ package discovery
 //	}
 type Event struct {
 	Type   EventType
-	Device *Device    // non-nil when Type == EventDeviceDiscovered
+	Device *Device    // non-nil when Type == EventDeviceDiscovered or EventUnknownDevice
 	Error  error      // non-nil when Type == EventError
 	Stats  *ScanStats // non-nil when Type == EventScanCompleted
+	Alert  *Alert     // non-nil when Type == EventAlert
+}
+
+// Alert is raised when a device matches a rule with an alert action, see RuleEvaluator.
+type Alert struct {
+	Rule    string
+	Message string
+	Device  *Device
 }
 
 // EventType indicates what kind of event this is.
@@ -38,6 +47,8 @@ const (
 	EventError
 	EventEngineStarted
 	EventEngineStopped
+	EventAlert
+	EventUnknownDevice
 )
 
 // NewDeviceEvent creates a device discovery event.
@@ -56,6 +67,22 @@ func NewScanCompletedEvent(stats *ScanStats) Event {
 	}
 }
 
+// NewUnknownDeviceEvent creates an event for a device that is not known, see KnownDevices.
+func NewUnknownDeviceEvent(device *Device) Event {
+	return Event{
+		Type:   EventUnknownDevice,
+		Device: device,
+	}
+}
+
+// NewAlertEvent creates a rule alert event.
+func NewAlertEvent(alert Alert) Event {
+	return Event{
+		Type:  EventAlert,
+		Alert: &alert,
+	}
+}
+
 // NewErrorEvent creates an error event.
 func NewErrorEvent(err error) Event {
 	return Event{
@@ -0,0 +1,343 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+const (
+	// DefaultTimeout bounds the total time spent identifying a single port.
+	DefaultTimeout = 3 * time.Second
+	// DefaultMaxBytes bounds the number of bytes read from a single connection.
+	DefaultMaxBytes = 4096
+
+	// bannerWait is how long to wait for a server that speaks first
+	// (SSH, FTP, SMTP, ...) before falling back to an HTTP probe.
+	bannerWait = 1500 * time.Millisecond
+	// maxFieldLen truncates banners and titles reported to the caller.
+	maxFieldLen = 120
+)
+
+// telnet IAC (interpret as command) byte, see RFC 854.
+const telnetIAC = 0xff
+
+var (
+	titleRe     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	serverTagRe = regexp.MustCompile(`^([A-Za-z][\w.\-]*)(?:/([\w.\-]+))?`)
+	// plaintextRejectedRe matches the errors web servers return for plain HTTP
+	// sent to a TLS port, e.g. nginx "The plain HTTP request was sent to HTTPS port"
+	// or Go "Client sent an HTTP request to an HTTPS server".
+	plaintextRejectedRe = regexp.MustCompile(`(?i)(HTTP request (was sent )?to (an )?HTTPS|plain HTTP to an SSL-enabled)`)
+)
+
+var _ discovery.ServiceDetector = (*Detector)(nil)
+
+// Detector identifies services on open TCP ports. It first waits briefly for
+// a passive banner, which covers protocols where the server speaks first, and
+// otherwise sends a minimal HTTP request to capture the Server header and page
+// title. The result is matched against an embedded signature table to derive
+// product and version.
+//
+// Services on well-known TLS ports, or that reject plaintext, are inspected
+// with an unverified TLS handshake to record the presented certificate.
+//
+// Every connection is bounded by the configured timeout and byte limit, so a
+// slow or chatty service cannot stall a scan.
+type Detector struct {
+	timeout        time.Duration
+	maxBytes       int
+	dialer         discovery.Dialer
+	bannerGrabbing bool
+	tlsInspection  bool
+	tlsPorts       map[int]bool
+}
+
+// New creates a Detector with the specified options.
+//
+// Example:
+//
+//	import "github.com/ramonvermeulen/whosthere/pkg/discovery/fingerprint"
+//
+//	det, err := fingerprint.New(fingerprint.WithTimeout(2 * time.Second))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	scanner := discovery.NewPortScanner(20, iface, discovery.WithServiceDetector(det))
+func New(opts ...Option) (*Detector, error) {
+	d := &Detector{
+		timeout:        DefaultTimeout,
+		maxBytes:       DefaultMaxBytes,
+		dialer:         &net.Dialer{},
+		bannerGrabbing: true,
+		tlsInspection:  true,
+		tlsPorts:       portSet(DefaultTLSPorts),
+	}
+	for _, opt := range opts {
+		if err := opt(d); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// errNoService is returned when nothing recognizable was received.
+var errNoService = errors.New("no recognizable service")
+
+// dialFunc opens a new connection to the port being identified.
+type dialFunc func(ctx context.Context) (net.Conn, error)
+
+// Detect connects to target:port and tries to identify the service.
+// Well-known TLS ports, and ports that turn out not to speak plaintext, are
+// inspected with a TLS handshake and get their certificate recorded.
+// It returns an error when the port could not be reached or nothing
+// recognizable was received.
+func (d *Detector) Detect(ctx context.Context, target string, port int) (*discovery.ServiceInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(target, fmt.Sprint(port))
+	if d.tlsInspection && d.tlsPorts[port] {
+		return d.detectTLS(ctx, target, addr)
+	}
+	if !d.bannerGrabbing {
+		return nil, errNoService
+	}
+
+	info, tlsHint, err := d.detect(ctx, target, d.dialPlain(addr), true)
+	if d.tlsInspection && (tlsHint || errors.Is(err, errNoService)) {
+		if tlsInfo, tlsErr := d.detectTLS(ctx, target, addr); tlsErr == nil {
+			return tlsInfo, nil
+		}
+	}
+	return info, err
+}
+
+// detect identifies the service reachable through dial. With passive set it
+// first waits for a banner before sending anything. Servers that speak first
+// still put their banner at the start of the reply to the HTTP probe, so
+// skipping the wait only costs politeness, not accuracy.
+// The returned tlsHint reports whether the service complained about receiving plaintext.
+func (d *Detector) detect(ctx context.Context, target string, dial dialFunc, passive bool) (info *discovery.ServiceInfo, tlsHint bool, err error) {
+	if passive {
+		banner, err := d.readBanner(ctx, dial)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(banner) > 0 {
+			return identifyBanner(banner), false, nil
+		}
+	}
+
+	head, err := d.exchange(ctx, dial, httpRequest("HEAD", target))
+	if err != nil {
+		return nil, false, err
+	}
+	if !bytes.HasPrefix(head, []byte("HTTP/")) {
+		if info := identifyBanner(head); info.Name != "" {
+			return info, false, nil
+		}
+		return nil, false, errNoService
+	}
+	info = identifyHTTP(head)
+	if body, err := d.exchange(ctx, dial, httpRequest("GET", target)); err == nil {
+		info.Title = htmlTitle(body)
+		tlsHint = plaintextRejected(body)
+	}
+	return info, tlsHint || plaintextRejected(head), nil
+}
+
+func (d *Detector) dialPlain(addr string) dialFunc {
+	return func(ctx context.Context) (net.Conn, error) {
+		return d.dialer.DialContext(ctx, "tcp", addr)
+	}
+}
+
+// readBanner connects and returns whatever the server sends on its own
+// within bannerWait. An empty banner is not an error.
+func (d *Detector) readBanner(ctx context.Context, dial dialFunc) ([]byte, error) {
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	deadline := time.Now().Add(bannerWait)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	_ = conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	buf := make([]byte, d.maxBytes)
+	n, err := conn.Read(buf)
+	if n > 0 {
+		return buf[:n], nil
+	}
+	var ne net.Error
+	if err == nil || errors.Is(err, io.EOF) || (errors.As(err, &ne) && ne.Timeout() && ctx.Err() == nil) {
+		return nil, nil
+	}
+	return nil, err
+}
+
+// exchange writes req on a new connection and reads the response until EOF,
+// the byte limit or ctx's deadline, whichever comes first.
+func (d *Detector) exchange(ctx context.Context, dial dialFunc, req []byte) ([]byte, error) {
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+	resp, err := io.ReadAll(io.LimitReader(conn, int64(d.maxBytes)))
+	if len(resp) > 0 {
+		// a truncated response is still useful
+		return resp, nil
+	}
+	return nil, err
+}
+
+func httpRequest(method, host string) []byte {
+	return []byte(method + " / HTTP/1.0\r\n" +
+		"Host: " + host + "\r\n" +
+		"User-Agent: whosthere\r\n" +
+		"Accept: */*\r\n" +
+		"Connection: close\r\n\r\n")
+}
+
+// identifyBanner matches a passive banner against the signature table.
+func identifyBanner(raw []byte) *discovery.ServiceInfo {
+	if raw[0] == telnetIAC {
+		info := &discovery.ServiceInfo{Name: "telnet"}
+		info.Banner = firstLine(stripTelnet(raw))
+		return info
+	}
+
+	info := &discovery.ServiceInfo{Banner: firstLine(raw)}
+	if sig, version, ok := match(sourceBanner, string(raw)); ok {
+		info.Name = sig.service
+		info.Product = sig.product
+		info.Version = version
+	}
+	return info
+}
+
+// identifyHTTP extracts the Server header from a raw HTTP response and
+// matches it against the signature table.
+func identifyHTTP(raw []byte) *discovery.ServiceInfo {
+	info := &discovery.ServiceInfo{Name: "http", Banner: firstLine(raw)}
+	server := httpHeader(raw, "Server")
+	if server == "" {
+		return info
+	}
+	if sig, version, ok := match(sourceHTTP, server); ok {
+		info.Product = sig.product
+		info.Version = version
+		return info
+	}
+	// unknown server: report its product token as-is, e.g. "FooServer/1.2"
+	if m := serverTagRe.FindStringSubmatch(server); m != nil {
+		info.Product = m[1]
+		info.Version = m[2]
+	}
+	return info
+}
+
+// plaintextRejected reports whether an HTTP response says the port expects TLS.
+func plaintextRejected(raw []byte) bool {
+	return plaintextRejectedRe.Match(raw)
+}
+
+// httpHeader returns the value of the named header in a raw HTTP response.
+func httpHeader(raw []byte, name string) string {
+	head, _, _ := bytes.Cut(raw, []byte("\r\n\r\n"))
+	lines := strings.Split(string(head), "\n")
+	for _, line := range lines[1:] {
+		k, v, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(k), name) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// htmlTitle returns the sanitized <title> of an HTML document, if any.
+func htmlTitle(raw []byte) string {
+	m := titleRe.FindSubmatch(raw)
+	if m == nil {
+		return ""
+	}
+	return sanitize(strings.Join(strings.Fields(string(m[1])), " "))
+}
+
+// stripTelnet removes telnet option negotiation (IAC sequences) from raw.
+func stripTelnet(raw []byte) []byte {
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != telnetIAC {
+			out = append(out, raw[i])
+			continue
+		}
+		if i+1 >= len(raw) {
+			break
+		}
+		switch raw[i+1] {
+		case 251, 252, 253, 254: // WILL, WONT, DO, DONT take an option byte
+			i += 2
+		case 250: // SB ... IAC SE
+			end := bytes.Index(raw[i:], []byte{telnetIAC, 240})
+			if end < 0 {
+				return out
+			}
+			i += end + 1
+		default:
+			i++
+		}
+	}
+	return out
+}
+
+// firstLine returns the first non-empty line of raw, sanitized.
+func firstLine(raw []byte) string {
+	for _, line := range strings.Split(string(raw), "\n") {
+		if s := sanitize(line); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// sanitize drops non-printable characters and truncates s to maxFieldLen runes.
+func sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == unicode.ReplacementChar || !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > maxFieldLen {
+		s = string(r[:maxFieldLen])
+	}
+	return s
+}
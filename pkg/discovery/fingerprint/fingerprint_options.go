@@ -0,0 +1,85 @@
+package fingerprint
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// Option configures a Detector during construction.
+type Option func(*Detector) error
+
+// WithTimeout sets the maximum time spent identifying a single port,
+// covering the banner read and any HTTP requests.
+// Must be positive.
+//
+// Default: 3 seconds (DefaultTimeout)
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Detector) error {
+		if timeout <= 0 {
+			return errors.New("service detection timeout must be positive")
+		}
+		d.timeout = timeout
+		return nil
+	}
+}
+
+// WithMaxBytes sets the maximum number of bytes read per connection.
+// Must be positive.
+//
+// Default: 4096 (DefaultMaxBytes)
+func WithMaxBytes(n int) Option {
+	return func(d *Detector) error {
+		if n <= 0 {
+			return errors.New("service detection max bytes must be positive")
+		}
+		d.maxBytes = n
+		return nil
+	}
+}
+
+// WithDialer overrides the Dialer used to open connections, e.g. to bind
+// probes to a specific interface.
+func WithDialer(dialer discovery.Dialer) Option {
+	return func(d *Detector) error {
+		if dialer == nil {
+			return errors.New("dialer cannot be nil")
+		}
+		d.dialer = dialer
+		return nil
+	}
+}
+
+// WithBannerGrabbing enables or disables banner grabbing and HTTP probing.
+// With banner grabbing disabled only TLS certificate inspection is performed,
+// and only on the configured TLS ports.
+//
+// Default: enabled
+func WithBannerGrabbing(enabled bool) Option {
+	return func(d *Detector) error {
+		d.bannerGrabbing = enabled
+		return nil
+	}
+}
+
+// WithTLSInspection enables or disables TLS certificate inspection.
+//
+// Default: enabled
+func WithTLSInspection(enabled bool) Option {
+	return func(d *Detector) error {
+		d.tlsInspection = enabled
+		return nil
+	}
+}
+
+// WithTLSPorts sets the ports that are inspected with a TLS handshake right away.
+// Other ports are only inspected when they turn out not to speak plaintext.
+//
+// Default: 443, 8443, 993, 995, 636 (DefaultTLSPorts)
+func WithTLSPorts(ports ...int) Option {
+	return func(d *Detector) error {
+		d.tlsPorts = portSet(ports)
+		return nil
+	}
+}
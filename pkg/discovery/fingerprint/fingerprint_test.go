@@ -0,0 +1,194 @@
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedSignaturesParse(t *testing.T) {
+	sigs, err := parseSignatures(embeddedSignatures)
+	require.NoError(t, err)
+	require.NotEmpty(t, sigs)
+}
+
+func TestParseSignatures_Invalid(t *testing.T) {
+	_, err := parseSignatures([]byte("ssh\tbanner\tOpenSSH"))
+	require.Error(t, err)
+
+	_, err = parseSignatures([]byte("ssh\tftp\tOpenSSH\t^SSH"))
+	require.Error(t, err)
+
+	_, err = parseSignatures([]byte("ssh\tbanner\tOpenSSH\t(["))
+	require.Error(t, err)
+}
+
+func TestIdentifyBanner(t *testing.T) {
+	tests := []struct {
+		banner  string
+		name    string
+		product string
+		version string
+	}{
+		{"SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n", "ssh", "OpenSSH", "9.6p1"},
+		{"SSH-2.0-dropbear_2022.83\r\n", "ssh", "Dropbear", "2022.83"},
+		{"SSH-2.0-Go\r\n", "ssh", "", ""},
+		{"220 (vsFTPd 3.0.5)\r\n", "ftp", "vsftpd", "3.0.5"},
+		{"220 ProFTPD 1.3.8 Server (Debian) [::ffff:10.0.0.2]\r\n", "ftp", "ProFTPD", "1.3.8"},
+		{"220 mail.example.com ESMTP Postfix (Ubuntu)\r\n", "smtp", "Postfix", ""},
+		{"220 mx.example.com ESMTP Exim 4.96 Mon, 01 Jan 2024\r\n", "smtp", "Exim", "4.96"},
+		{"* OK [CAPABILITY IMAP4rev1] Dovecot ready.\r\n", "imap", "Dovecot", ""},
+		{"RFB 003.008\n", "vnc", "", "003.008"},
+		{"\x4a\x00\x00\x00\x0a8.0.36\x00", "mysql", "MySQL", "8.0.36"},
+		{"hello world\r\n", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.banner, func(t *testing.T) {
+			info := identifyBanner([]byte(tt.banner))
+			require.Equal(t, tt.name, info.Name)
+			require.Equal(t, tt.product, info.Product)
+			require.Equal(t, tt.version, info.Version)
+			require.NotContains(t, info.Banner, "\r")
+		})
+	}
+}
+
+func TestIdentifyBanner_Telnet(t *testing.T) {
+	raw := []byte{telnetIAC, 253, 24, telnetIAC, 251, 1, 'l', 'o', 'g', 'i', 'n', ':', ' '}
+	info := identifyBanner(raw)
+	require.Equal(t, "telnet", info.Name)
+	require.Equal(t, "login:", info.Banner)
+}
+
+func TestIdentifyHTTP(t *testing.T) {
+	tests := []struct {
+		server  string
+		product string
+		version string
+	}{
+		{"nginx/1.24.0", "nginx", "1.24.0"},
+		{"nginx", "nginx", ""},
+		{"Apache/2.4.57 (Debian)", "Apache httpd", "2.4.57"},
+		{"Microsoft-IIS/10.0", "Microsoft IIS", "10.0"},
+		{"CUPS/2.4 IPP/2.1", "CUPS", "2.4"},
+		{"FooServer/1.2", "FooServer", "1.2"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			raw := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n"
+			if tt.server != "" {
+				raw += "Server: " + tt.server + "\r\n"
+			}
+			raw += "\r\n"
+			info := identifyHTTP([]byte(raw))
+			require.Equal(t, "http", info.Name)
+			require.Equal(t, tt.product, info.Product)
+			require.Equal(t, tt.version, info.Version)
+			require.Equal(t, "HTTP/1.1 200 OK", info.Banner)
+		})
+	}
+}
+
+func TestHTMLTitle(t *testing.T) {
+	require.Equal(t, "Router Login", htmlTitle([]byte("<html><head><TITLE lang=en>\n  Router\n  Login\n</TITLE>")))
+	require.Equal(t, "", htmlTitle([]byte("<html><body>no title</body></html>")))
+}
+
+func TestDetect_PassiveBanner(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = fmt.Fprint(conn, "SSH-2.0-OpenSSH_9.6p1\r\n")
+			_ = conn.Close()
+		}
+	}()
+
+	d, err := New(WithTimeout(2 * time.Second))
+	require.NoError(t, err)
+
+	host, port := splitAddr(t, ln.Addr())
+	info, err := d.Detect(context.Background(), host, port)
+	require.NoError(t, err)
+	require.Equal(t, "ssh", info.Name)
+	require.Equal(t, "OpenSSH", info.Product)
+	require.Equal(t, "9.6p1", info.Version)
+	require.Equal(t, "SSH-2.0-OpenSSH_9.6p1", info.Banner)
+}
+
+func TestDetect_HTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "lighttpd/1.4.73")
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, "<html><head><title>NAS Admin</title></head></html>")
+	}))
+	defer srv.Close()
+
+	d, err := New(WithTimeout(5 * time.Second))
+	require.NoError(t, err)
+
+	host, port := splitAddr(t, srv.Listener.Addr())
+	info, err := d.Detect(context.Background(), host, port)
+	require.NoError(t, err)
+	require.Equal(t, "http", info.Name)
+	require.Equal(t, "lighttpd", info.Product)
+	require.Equal(t, "1.4.73", info.Version)
+	require.Equal(t, "NAS Admin", info.Title)
+}
+
+func TestDetect_MaxBytes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for i := 0; i < 100; i++ {
+			if _, err := fmt.Fprint(conn, "220 ESMTP ready and a lot of padding\r\n"); err != nil {
+				return
+			}
+		}
+	}()
+
+	d, err := New(WithMaxBytes(16))
+	require.NoError(t, err)
+
+	host, port := splitAddr(t, ln.Addr())
+	info, err := d.Detect(context.Background(), host, port)
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(info.Banner), 16)
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	_, err := New(WithTimeout(0))
+	require.Error(t, err)
+	_, err = New(WithMaxBytes(-1))
+	require.Error(t, err)
+	_, err = New(WithDialer(nil))
+	require.Error(t, err)
+}
+
+func splitAddr(t *testing.T, addr net.Addr) (string, int) {
+	t.Helper()
+	host, p, err := net.SplitHostPort(addr.String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(p)
+	require.NoError(t, err)
+	return host, port
+}
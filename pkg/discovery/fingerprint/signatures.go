@@ -0,0 +1,88 @@
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//go:embed signatures.txt
+var embeddedSignatures []byte
+
+// Signature sources, see signatures.txt.
+const (
+	sourceBanner = "banner"
+	sourceHTTP   = "http"
+)
+
+// signature maps a banner or HTTP Server header pattern to a service and product.
+type signature struct {
+	service string
+	source  string
+	product string
+	re      *regexp.Regexp
+}
+
+// signatures is the parsed embedded signature table.
+var signatures = mustParseSignatures(embeddedSignatures)
+
+func mustParseSignatures(data []byte) []signature {
+	sigs, err := parseSignatures(data)
+	if err != nil {
+		panic(err)
+	}
+	return sigs
+}
+
+// parseSignatures parses the tab separated signature table.
+func parseSignatures(data []byte) ([]signature, error) {
+	var sigs []signature
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool { return r == '\t' })
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("signatures.txt:%d: expected 4 tab separated fields, got %d", line, len(fields))
+		}
+		if fields[1] != sourceBanner && fields[1] != sourceHTTP {
+			return nil, fmt.Errorf("signatures.txt:%d: unknown source %q", line, fields[1])
+		}
+		re, err := regexp.Compile(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("signatures.txt:%d: %w", line, err)
+		}
+		product := fields[2]
+		if product == "-" {
+			product = ""
+		}
+		sigs = append(sigs, signature{service: fields[0], source: fields[1], product: product, re: re})
+	}
+	return sigs, sc.Err()
+}
+
+// match returns the first signature for source matching s, with the version
+// taken from the first capture group.
+func match(source, s string) (sig signature, version string, ok bool) {
+	for _, sig := range signatures {
+		if sig.source != source {
+			continue
+		}
+		m := sig.re.FindStringSubmatch(s)
+		if m == nil {
+			continue
+		}
+		if len(m) > 1 {
+			version = m[1]
+		}
+		return sig, version, true
+	}
+	return signature{}, "", false
+}
@@ -0,0 +1,166 @@
+package fingerprint
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// DefaultTLSPorts are the ports inspected with a TLS handshake right away:
+// HTTPS, alternative HTTPS, IMAPS, POP3S and LDAPS.
+var DefaultTLSPorts = []int{443, 8443, 993, 995, 636}
+
+// tlsServiceNames maps plaintext service names to their TLS wrapped variant.
+var tlsServiceNames = map[string]string{
+	"http": "https",
+	"imap": "imaps",
+	"pop3": "pop3s",
+	"smtp": "smtps",
+	"ftp":  "ftps",
+}
+
+// detectTLS performs an unverified TLS handshake to record the certificate and,
+// when banner grabbing is enabled, identifies the service behind it.
+func (d *Detector) detectTLS(ctx context.Context, target, addr string) (*discovery.ServiceInfo, error) {
+	var cert *discovery.CertificateInfo
+	dial := d.dialTLS(addr, target, &cert)
+
+	if !d.bannerGrabbing {
+		conn, err := dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		_ = conn.Close()
+		return &discovery.ServiceInfo{Name: "tls", Certificate: cert}, nil
+	}
+
+	// no passive wait: most TLS services are HTTPS, and the others still
+	// answer the HTTP probe with their banner
+	info, _, err := d.detect(ctx, target, dial, false)
+	if cert == nil {
+		// the handshake never succeeded
+		return nil, err
+	}
+	if info == nil {
+		info = &discovery.ServiceInfo{Name: "tls"}
+	} else if name, ok := tlsServiceNames[info.Name]; ok {
+		info.Name = name
+	}
+	info.Certificate = cert
+	return info, nil
+}
+
+// dialTLS returns a dialFunc that performs an unverified TLS handshake and
+// records the peer certificate in *cert on the first successful handshake.
+func (d *Detector) dialTLS(addr, target string, cert **discovery.CertificateInfo) dialFunc {
+	cfg := &tls.Config{
+		// inspection only, nothing sensitive is sent: accept anything so that
+		// self-signed and expired certificates can be reported as well
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		CipherSuites:       allCipherSuites(),
+	}
+	if net.ParseIP(target) == nil {
+		cfg.ServerName = target
+	}
+
+	return func(ctx context.Context) (net.Conn, error) {
+		raw, err := d.dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		conn := tls.Client(raw, cfg)
+		if err := conn.HandshakeContext(ctx); err != nil {
+			_ = raw.Close()
+			return nil, err
+		}
+		if *cert == nil {
+			state := conn.ConnectionState()
+			if len(state.PeerCertificates) == 0 {
+				_ = conn.Close()
+				return nil, errors.New("no peer certificate presented")
+			}
+			*cert = certificateInfo(state.PeerCertificates[0], state.Version)
+		}
+		return conn, nil
+	}
+}
+
+// certificateInfo summarizes a leaf certificate.
+func certificateInfo(c *x509.Certificate, version uint16) *discovery.CertificateInfo {
+	sum := sha256.Sum256(c.Raw)
+	info := &discovery.CertificateInfo{
+		Subject:    c.Subject.String(),
+		Issuer:     c.Issuer.String(),
+		NotBefore:  c.NotBefore,
+		NotAfter:   c.NotAfter,
+		KeyType:    keyType(c),
+		SelfSigned: selfSigned(c),
+		SHA256:     hex.EncodeToString(sum[:]),
+		TLSVersion: tls.VersionName(version),
+	}
+	info.SANs = append(info.SANs, c.DNSNames...)
+	for _, ip := range c.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	info.SANs = append(info.SANs, c.EmailAddresses...)
+	for _, u := range c.URIs {
+		info.SANs = append(info.SANs, u.String())
+	}
+	return info
+}
+
+// keyType describes the certificate's public key, e.g. "RSA-2048" or "ECDSA-P-256".
+func keyType(c *x509.Certificate) string {
+	switch k := c.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return c.PublicKeyAlgorithm.String()
+	}
+}
+
+// selfSigned reports whether c is issued by itself and carries a valid
+// signature from its own key. CheckSignatureFrom is not used because it
+// rejects parents that are not CAs, which device certificates rarely are.
+func selfSigned(c *x509.Certificate) bool {
+	if c.Subject.String() != c.Issuer.String() {
+		return false
+	}
+	return c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature) == nil
+}
+
+// allCipherSuites includes the insecure suites so that handshakes with old
+// embedded devices succeed; the connection is only used for inspection.
+func allCipherSuites() []uint16 {
+	var ids []uint16
+	for _, s := range tls.CipherSuites() {
+		ids = append(ids, s.ID)
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func portSet(ports []int) map[int]bool {
+	m := make(map[int]bool, len(ports))
+	for _, p := range ports {
+		m[p] = true
+	}
+	return m
+}
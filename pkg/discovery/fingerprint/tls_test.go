@@ -0,0 +1,113 @@
+package fingerprint
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect_TLSDetectedOnPlainPort(t *testing.T) {
+	srv := newTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3")
+		_, _ = fmt.Fprint(w, "<title>Printer</title>")
+	}))
+	defer srv.Close()
+
+	d, err := New(WithTimeout(5 * time.Second))
+	require.NoError(t, err)
+
+	host, port := splitAddr(t, srv.Listener.Addr())
+	info, err := d.Detect(context.Background(), host, port)
+	require.NoError(t, err)
+	require.Equal(t, "https", info.Name)
+	require.Equal(t, "nginx", info.Product)
+	require.Equal(t, "Printer", info.Title)
+
+	cert := info.Certificate
+	require.NotNil(t, cert)
+	sum := sha256.Sum256(srv.Certificate().Raw)
+	require.Equal(t, hex.EncodeToString(sum[:]), cert.SHA256)
+	require.Contains(t, cert.SANs, "127.0.0.1")
+	require.NotEmpty(t, cert.KeyType)
+	require.NotEmpty(t, cert.TLSVersion)
+}
+
+func TestDetect_TLSPortWithoutBannerGrabbing(t *testing.T) {
+	srv := newTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	host, port := splitAddr(t, srv.Listener.Addr())
+	d, err := New(WithBannerGrabbing(false), WithTLSPorts(port))
+	require.NoError(t, err)
+
+	info, err := d.Detect(context.Background(), host, port)
+	require.NoError(t, err)
+	require.Equal(t, "tls", info.Name)
+	require.NotNil(t, info.Certificate)
+}
+
+func TestDetect_NothingEnabled(t *testing.T) {
+	srv := newTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	host, port := splitAddr(t, srv.Listener.Addr())
+	d, err := New(WithBannerGrabbing(false))
+	require.NoError(t, err)
+
+	_, err = d.Detect(context.Background(), host, port)
+	require.Error(t, err)
+}
+
+func TestCertificateInfo(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	notAfter := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second).UTC()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nas.local"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     []string{"nas.local"},
+		IPAddresses:  []net.IP{net.ParseIP("192.168.1.20")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	info := certificateInfo(cert, 0x0304)
+	require.Equal(t, "CN=nas.local", info.Subject)
+	require.Equal(t, "CN=nas.local", info.Issuer)
+	require.Equal(t, []string{"nas.local", "192.168.1.20"}, info.SANs)
+	require.Equal(t, "ECDSA-P-256", info.KeyType)
+	require.True(t, info.SelfSigned)
+	require.Equal(t, "TLS 1.3", info.TLSVersion)
+	require.Equal(t, notAfter, info.NotAfter)
+	require.Len(t, info.SHA256, 64)
+}
+
+// newTLSServer starts a TLS test server that does not log handshake errors
+// caused by the plaintext probes.
+func newTLSServer(h http.Handler) *httptest.Server {
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	return srv
+}
@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"net"
+	"strings"
+)
+
+const (
+	// TagRandomizedMAC tags devices using a randomized MAC address, see IsRandomizedMAC.
+	TagRandomizedMAC = "randomized_mac"
+
+	// HostnameKey is the extra data key holding the hostname a device announces
+	// itself with, e.g. its mDNS host name without the ".local" suffix.
+	HostnameKey = "hostname"
+)
+
+// IsRandomizedMAC reports whether mac is a locally administered unicast address.
+// Phones and laptops use such randomized ("private") addresses per network, so
+// they have no manufacturer in the OUI registry and change between networks.
+func IsRandomizedMAC(mac string) bool {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) == 0 {
+		return false
+	}
+	return hw[0]&0x02 != 0 && hw[0]&0x01 == 0
+}
+
+// Identity returns a key identifying the device across scans, in order of preference:
+//   - "mac:<mac>" for a globally unique MAC address
+//   - "host:<hostname>" for a device with a randomized MAC address announcing an
+//     mDNS hostname, so it is recognized after rotating its MAC address
+//   - "mac:<mac>" for any other MAC address
+//   - "ip:<ip>" for a device without a MAC address
+//
+// Returns "" for a device without MAC and IP address.
+func (d *Device) Identity() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	mac := strings.ToLower(d.mac)
+	if mac != "" && !IsRandomizedMAC(mac) {
+		return "mac:" + mac
+	}
+	if host := strings.ToLower(d.extraData[HostnameKey]); host != "" {
+		return "host:" + host
+	}
+	if mac != "" {
+		return "mac:" + mac
+	}
+	if d.ip != nil {
+		return "ip:" + d.ip.String()
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsRandomizedMAC(t *testing.T) {
+	tests := map[string]bool{
+		"da:a1:19:00:00:01": true,  // locally administered
+		"02:00:00:00:00:01": true,  // locally administered
+		"DA-A1-19-00-00-01": true,  // other notation
+		"b8:27:eb:12:34:56": false, // globally unique (Raspberry Pi)
+		"03:00:00:00:00:01": false, // locally administered multicast
+		"01:00:5e:00:00:fb": false, // multicast
+		"":                  false,
+		"not-a-mac":         false,
+	}
+	for mac, want := range tests {
+		if got := IsRandomizedMAC(mac); got != want {
+			t.Errorf("IsRandomizedMAC(%q) = %v, want %v", mac, got, want)
+		}
+	}
+}
+
+func TestDeviceIdentity(t *testing.T) {
+	newDev := func(mac, host string) *Device {
+		d := NewDevice(net.ParseIP("192.168.1.20"))
+		d.SetMAC(mac)
+		if host != "" {
+			d.AddExtraData(HostnameKey, host)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name string
+		dev  *Device
+		want string
+	}{
+		{"global MAC", newDev("B8:27:EB:12:34:56", "pi"), "mac:b8:27:eb:12:34:56"},
+		{"randomized MAC with hostname", newDev("da:a1:19:00:00:01", "Ramons-iPhone"), "host:ramons-iphone"},
+		{"randomized MAC", newDev("da:a1:19:00:00:01", ""), "mac:da:a1:19:00:00:01"},
+		{"no MAC", newDev("", ""), "ip:192.168.1.20"},
+		{"nothing", NewDevice(nil), ""},
+	}
+	for _, tt := range tests {
+		if got := tt.dev.Identity(); got != tt.want {
+			t.Errorf("%s: Identity() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	rotated := newDev("ea:00:00:00:00:02", "ramons-iphone")
+	if rotated.Identity() != newDev("da:a1:19:00:00:01", "Ramons-iPhone").Identity() {
+		t.Errorf("expected the same identity after a MAC rotation")
+	}
+}
@@ -1,17 +1,24 @@
 package discovery
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"strings"
 )
 
 // InterfaceInfo contains network interface information required for device discovery.
 // Scanners need both the interface itself and its IPv4 configuration to operate.
 // Use NewInterfaceInfo() to create instances with proper validation.
+//
+// An interface can carry several IPv4 addresses, e.g. a secondary address on
+// another subnet. IPv4Addr and IPv4Net describe the first one, IPv4Nets all of
+// them; use Subnets to iterate them.
 type InterfaceInfo struct {
 	Interface *net.Interface // The network interface device
 	IPv4Addr  *net.IP        // Host's IPv4 address on this interface
 	IPv4Net   *net.IPNet     // The subnet CIDR (e.g., 192.168.1.0/24)
+	IPv4Nets  []*net.IPNet   // All IPv4 networks, with the host's address on each as IP
 }
 
 // NewInterfaceInfo creates an InterfaceInfo from a network interface name.
@@ -50,11 +57,15 @@ func NewInterfaceInfo(interfaceName string) (*InterfaceInfo, error) {
 	}
 
 	for _, addr := range addresses {
-		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil {
+			continue
+		}
+		if info.IPv4Addr == nil {
 			info.IPv4Addr = &ipnet.IP
 			info.IPv4Net = ipnet
-			break
 		}
+		info.IPv4Nets = append(info.IPv4Nets, ipnet)
 	}
 
 	if info.IPv4Addr == nil {
@@ -64,6 +75,36 @@ func NewInterfaceInfo(interfaceName string) (*InterfaceInfo, error) {
 	return info, nil
 }
 
+// Subnets returns the IPv4 networks of the interface, each with the host's
+// address on that network as IP. When IPv4Nets is not set, e.g. for an
+// InterfaceInfo built by hand, the network of IPv4Addr and IPv4Net is returned.
+func (i *InterfaceInfo) Subnets() []*net.IPNet {
+	if i == nil {
+		return nil
+	}
+	if len(i.IPv4Nets) > 0 {
+		return i.IPv4Nets
+	}
+	if i.IPv4Addr == nil || i.IPv4Net == nil {
+		return nil
+	}
+	return []*net.IPNet{{IP: *i.IPv4Addr, Mask: i.IPv4Net.Mask}}
+}
+
+// SubnetOf returns the network of the interface containing ip, or nil if ip
+// is not on a local subnet.
+func (i *InterfaceInfo) SubnetOf(ip net.IP) *net.IPNet {
+	if ip == nil {
+		return nil
+	}
+	for _, subnet := range i.Subnets() {
+		if subnet.Contains(ip) {
+			return subnet
+		}
+	}
+	return nil
+}
+
 // getNetworkInterface returns the network interface by name.
 // If interfaceName is empty, it attempts to return the OS default network interface.
 func getNetworkInterface(interfaceName string) (*net.Interface, error) {
@@ -83,37 +124,156 @@ func getNetworkInterface(interfaceName string) (*net.Interface, error) {
 	return iface, nil
 }
 
-// getDefaultInterface attempts to return the OS default network interface
-// todo(ramon) find more reliable way to get default interface across platforms
-func getDefaultInterface() (*net.Interface, error) {
-	// try to get the default interface by sending UDP packet
-	if name, err := getInterfaceNameByUDP(); err == nil {
-		return name, nil
-	}
+// virtualInterfacePrefixes are name prefixes of virtual interfaces, e.g. container
+// bridges, that are skipped when falling back to the first interface that is up.
+var virtualInterfacePrefixes = []string{"docker", "veth", "br-", "virbr"}
 
-	// if that fails, return the first non-loopback interface that is up
-	// this is often the default interface, but in special cases it might not be
-	// todo: find better solution in the future, maybe by parsing routing table?
+// InterfaceCandidate is a network interface considered as default interface,
+// see InterfaceCandidates.
+type InterfaceCandidate struct {
+	Interface net.Interface
+	IPv4Net   *net.IPNet // First IPv4 network on the interface, nil if none
+	Chosen    bool       // Whether this is the default interface
+	Reason    string     // Why the interface was chosen or skipped
+}
+
+// InterfaceCandidates lists the network interfaces of the host and marks the one
+// used when no interface is configured. The default interface is the one of the
+// preferred IPv4 default route in the routing table. When the routing table can't
+// be read, it is the interface used to reach a public IP (no packets are sent),
+// and as a last resort the first interface that is up, not a loopback and not
+// virtual (docker*, veth*, br-*, virbr*).
+func InterfaceCandidates() ([]InterfaceCandidate, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
 		return nil, err
 	}
 
+	candidates := make([]InterfaceCandidate, 0, len(interfaces))
 	for _, iface := range interfaces {
-		if iface.Flags&net.FlagLoopback == 0 && iface.Flags&net.FlagUp != 0 {
-			return &iface, nil
+		c := InterfaceCandidate{Interface: iface}
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+					c.IPv4Net = ipnet
+					break
+				}
+			}
+		}
+		candidates = append(candidates, c)
+	}
+
+	routes, routesErr := DefaultRoutes()
+	chooseInterface(candidates, routes, routesErr, getInterfaceNameByUDP)
+	return candidates, nil
+}
+
+// chooseInterface marks the default interface among candidates and gives every
+// candidate a reason, see InterfaceCandidates. udp is only called when no
+// default route is usable.
+func chooseInterface(candidates []InterfaceCandidate, routes []Route, routesErr error, udp func() (string, error)) {
+	usable := make([]bool, len(candidates))
+	for i := range candidates {
+		c := &candidates[i]
+		switch {
+		case c.Interface.Flags&net.FlagLoopback != 0:
+			c.Reason = "loopback interface"
+		case c.Interface.Flags&net.FlagUp == 0:
+			c.Reason = "interface is down"
+		case c.IPv4Net == nil:
+			c.Reason = "no IPv4 address"
+		default:
+			usable[i] = true
+		}
+	}
+	choose := func(i int, reason string) {
+		candidates[i].Chosen = true
+		candidates[i].Reason = reason
+		for j := range candidates {
+			if j != i && usable[j] && candidates[j].Reason == "" {
+				candidates[j].Reason = "not the default interface"
+			}
+		}
+	}
+	index := func(name string) int {
+		for i := range candidates {
+			if candidates[i].Interface.Name == name && usable[i] {
+				return i
+			}
 		}
+		return -1
 	}
 
-	return nil, fmt.Errorf("no network interface found")
+	for n, r := range routes {
+		if i := index(r.Interface); i >= 0 {
+			reason := fmt.Sprintf("default route (metric %d)", r.Metric)
+			if r.Gateway != nil {
+				reason = fmt.Sprintf("default route via %s (metric %d)", r.Gateway, r.Metric)
+			}
+			choose(i, reason)
+			for _, other := range routes[n+1:] {
+				if j := index(other.Interface); j >= 0 && j != i {
+					candidates[j].Reason = fmt.Sprintf("default route with a higher metric (%d)", other.Metric)
+				}
+			}
+			return
+		}
+	}
+
+	if routesErr != nil || len(routes) == 0 {
+		if name, err := udp(); err == nil {
+			if i := index(name); i >= 0 {
+				choose(i, "used to reach the internet, no usable default route")
+				return
+			}
+		}
+	}
+
+	for i := range candidates {
+		if !usable[i] {
+			continue
+		}
+		if isVirtualInterface(candidates[i].Interface.Name) {
+			candidates[i].Reason = "virtual interface"
+			continue
+		}
+		choose(i, "first interface that is up, no usable default route")
+		return
+	}
+}
+
+// isVirtualInterface reports whether name looks like a virtual interface, e.g. a docker bridge.
+func isVirtualInterface(name string) bool {
+	for _, prefix := range virtualInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// getDefaultInterface returns the OS default network interface, see InterfaceCandidates.
+func getDefaultInterface() (*net.Interface, error) {
+	candidates, err := InterfaceCandidates()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range candidates {
+		if c.Chosen {
+			iface := c.Interface
+			return &iface, nil
+		}
+	}
+	return nil, errors.New("no network interface found")
 }
 
 // getInterfaceNameByUDP tries to determine the default network interface
 // by creating a UDP connection to a public IP and checking the local address used.
-func getInterfaceNameByUDP() (*net.Interface, error) {
+// No packets are sent, but it fails on hosts without a route to the internet.
+func getInterfaceNameByUDP() (string, error) {
 	conn, err := net.Dial("udp", "8.8.8.8:53")
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer func(conn net.Conn) {
 		_ = conn.Close()
@@ -123,7 +283,7 @@ func getInterfaceNameByUDP() (*net.Interface, error) {
 
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	for _, iface := range interfaces {
@@ -142,12 +302,12 @@ func getInterfaceNameByUDP() (*net.Interface, error) {
 			}
 
 			if ip != nil && ip.Equal(localAddr.IP) {
-				return &iface, nil
+				return iface.Name, nil
 			}
 		}
 	}
 
-	return nil, fmt.Errorf("interface not found for IP %s", localAddr.IP)
+	return "", fmt.Errorf("interface not found for IP %s", localAddr.IP)
 }
 
 // CompareIPs compares two IP addresses for sorting purposes.
@@ -1,6 +1,8 @@
 package discovery
 
 import (
+	"errors"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,3 +15,112 @@ func TestNewInterfaceInfo_InvalidInterface(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no such network interface")
 }
+
+func testCandidates() []InterfaceCandidate {
+	up := net.FlagUp | net.FlagBroadcast
+	ipNet := func(cidr string) *net.IPNet {
+		ip, n, _ := net.ParseCIDR(cidr)
+		n.IP = ip
+		return n
+	}
+	return []InterfaceCandidate{
+		{Interface: net.Interface{Name: "lo", Flags: net.FlagUp | net.FlagLoopback}, IPv4Net: ipNet("127.0.0.1/8")},
+		{Interface: net.Interface{Name: "docker0", Flags: up}, IPv4Net: ipNet("172.17.0.1/16")},
+		{Interface: net.Interface{Name: "eth0", Flags: up}, IPv4Net: ipNet("192.168.1.42/24")},
+		{Interface: net.Interface{Name: "wlan0", Flags: up}, IPv4Net: ipNet("192.168.2.42/24")},
+		{Interface: net.Interface{Name: "eth1", Flags: 0}, IPv4Net: ipNet("10.0.0.2/24")},
+		{Interface: net.Interface{Name: "wg0", Flags: up}},
+	}
+}
+
+func chosen(candidates []InterfaceCandidate) string {
+	for _, c := range candidates {
+		if c.Chosen {
+			return c.Interface.Name
+		}
+	}
+	return ""
+}
+
+func TestChooseInterface_DefaultRoute(t *testing.T) {
+	candidates := testCandidates()
+	udp := func() (string, error) { t.Fatal("udp must not be used with a default route"); return "", nil }
+	routes := []Route{
+		{Interface: "eth1", Gateway: net.ParseIP("10.0.0.1"), Metric: 50},
+		{Interface: "wlan0", Gateway: net.ParseIP("192.168.2.1"), Metric: 100},
+		{Interface: "eth0", Gateway: net.ParseIP("192.168.1.1"), Metric: 600},
+	}
+
+	chooseInterface(candidates, routes, nil, udp)
+
+	assert.Equal(t, "wlan0", chosen(candidates), "down interfaces are skipped")
+	reasons := map[string]string{}
+	for _, c := range candidates {
+		reasons[c.Interface.Name] = c.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"lo":      "loopback interface",
+		"docker0": "not the default interface",
+		"eth0":    "default route with a higher metric (600)",
+		"wlan0":   "default route via 192.168.2.1 (metric 100)",
+		"eth1":    "interface is down",
+		"wg0":     "no IPv4 address",
+	}, reasons)
+}
+
+func TestChooseInterface_UDPWithoutRoutingTable(t *testing.T) {
+	candidates := testCandidates()
+	chooseInterface(candidates, nil, ErrRoutesUnsupported, func() (string, error) { return "eth0", nil })
+	assert.Equal(t, "eth0", chosen(candidates))
+}
+
+func TestChooseInterface_FallbackSkipsVirtualInterfaces(t *testing.T) {
+	candidates := testCandidates()
+	chooseInterface(candidates, nil, ErrRoutesUnsupported, func() (string, error) { return "", errors.New("network is unreachable") })
+
+	assert.Equal(t, "eth0", chosen(candidates))
+	assert.Equal(t, "virtual interface", candidates[1].Reason)
+}
+
+func TestIsVirtualInterface(t *testing.T) {
+	for name, want := range map[string]bool{
+		"docker0":         true,
+		"veth1a2b3c":      true,
+		"br-0123456789ab": true,
+		"virbr0":          true,
+		"eth0":            false,
+		"enp3s0":          false,
+		"br0":             false,
+	} {
+		assert.Equal(t, want, isVirtualInterface(name), name)
+	}
+}
+
+func testMultiSubnetInterface() *InterfaceInfo {
+	ip := net.ParseIP("192.168.1.10").To4()
+	primary := &net.IPNet{IP: ip, Mask: net.CIDRMask(24, 32)}
+	secondary := &net.IPNet{IP: net.ParseIP("10.0.0.1").To4(), Mask: net.CIDRMask(16, 32)}
+	return &InterfaceInfo{IPv4Addr: &ip, IPv4Net: primary, IPv4Nets: []*net.IPNet{primary, secondary}}
+}
+
+func TestInterfaceInfo_SubnetOf(t *testing.T) {
+	info := testMultiSubnetInterface()
+
+	assert.Len(t, info.Subnets(), 2)
+	assert.Equal(t, "192.168.1.10/24", info.SubnetOf(net.ParseIP("192.168.1.20")).String())
+	assert.Equal(t, "10.0.0.1/16", info.SubnetOf(net.ParseIP("10.0.200.1")).String())
+	assert.Nil(t, info.SubnetOf(net.ParseIP("172.16.0.1")))
+	assert.Nil(t, info.SubnetOf(nil))
+}
+
+func TestInterfaceInfo_Subnets_WithoutIPv4Nets(t *testing.T) {
+	ip, ipNet, err := net.ParseCIDR("192.168.1.10/24")
+	assert.NoError(t, err)
+	info := &InterfaceInfo{IPv4Addr: &ip, IPv4Net: ipNet}
+
+	subnets := info.Subnets()
+	assert.Len(t, subnets, 1)
+	assert.Equal(t, "192.168.1.10/24", subnets[0].String(), "the host address is kept")
+	assert.Nil(t, (*InterfaceInfo)(nil).Subnets())
+	assert.Empty(t, (&InterfaceInfo{}).Subnets())
+}
@@ -5,147 +5,271 @@ import (
 	"context"
 	_ "embed"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
 // see https://pkg.go.dev/embed
-// this will embed the oui.csv file while compiling the binary
+// this will embed the IEEE registry CSV files while compiling the binary
 //
 //go:embed oui.csv
 var embeddedOUIDB []byte
 
+//go:embed mam.csv
+var embeddedMAMDB []byte
+
+//go:embed oui36.csv
+var embeddedOUI36DB []byte
+
+//go:embed cid.csv
+var embeddedCIDDB []byte
+
 const (
-	updateURL       = "https://standards-oui.ieee.org/oui/oui.csv"
-	maxAge          = 30 * 24 * time.Hour
+	// DefaultUpdateURL is the base URL the registries are downloaded from.
+	DefaultUpdateURL = "https://standards-oui.ieee.org"
+	// DefaultMaxAge is the age after which New refreshes the registries.
+	DefaultMaxAge = 30 * 24 * time.Hour
+
 	clientTimeout   = 30 * time.Second
 	userAgentHeader = "whosthere/1.0 (+https://github.com/ramonvermeulen/whosthere)"
 	acceptHeader    = "text/csv,application/vnd.ms-excel;q=0.9,*/*;q=0.8"
 )
 
+// source is an IEEE registry published as CSV file.
+type source struct {
+	file     string // file name in the cache directory
+	path     string // path below the update URL
+	embedded []byte
+}
+
+// sources are the IEEE registries combined by a Registry. MA-L assignments and
+// CIDs are 24-bit prefixes, MA-M 28-bit and MA-S (formerly IAB) 36-bit ones; the
+// MA-L blocks holding MA-M and MA-S assignments belong to "IEEE Registration
+// Authority", which is why Lookup prefers the longest matching prefix.
+var sources = []source{
+	{file: "oui.csv", path: "oui/oui.csv", embedded: embeddedOUIDB},
+	{file: "mam.csv", path: "oui28/mam.csv", embedded: embeddedMAMDB},
+	{file: "oui36.csv", path: "oui36/oui36.csv", embedded: embeddedOUI36DB},
+	{file: "cid.csv", path: "cid/cid.csv", embedded: embeddedCIDDB},
+}
+
+// prefixLengths are the assignment sizes in hex digits, longest first.
+var prefixLengths = []int{9, 7, 6}
+
 // Registry provides MAC address OUI (Organizationally Unique Identifier) lookups
-// to resolve manufacturer names from MAC addresses. The first 3 bytes of a MAC
-// address identify the manufacturer.
+// to resolve manufacturer names from MAC addresses. Manufacturers are identified
+// by a 24-bit (MA-L, CID), 28-bit (MA-M) or 36-bit (MA-S) prefix of the MAC address.
 //
-// The registry embeds IEEE OUI data and can optionally cache updates to disk.
-// It automatically refreshes data older than 30 days from the IEEE website.
+// The registry embeds IEEE registry data and can optionally cache updates to disk.
+// It automatically refreshes data older than 30 days from the IEEE website,
+// see WithMaxAge, WithUpdateURL and WithAutoRefresh.
 //
 // Thread-safe for concurrent lookups.
 type Registry struct {
-	mu        sync.RWMutex
-	prefixMap map[string]string
-	loadedAt  time.Time
-	path      string
-	logger    *slog.Logger
+	mu          sync.RWMutex
+	prefixes    map[string]map[string]string // organizations by prefix, per source file
+	loadedAt    time.Time                    // when the oldest source was loaded
+	dir         string
+	updateURL   string
+	maxAge      time.Duration
+	autoRefresh bool
+	logger      *slog.Logger
+
+	overrides          map[string]string // organizations by prefix of any length, guarded by mu
+	overridesPath      string
+	overridesMu        sync.Mutex // guards reloading the overrides file
+	overridesCheckedAt time.Time
+	overridesModTime   time.Time
+}
+
+// Match describes which registry entry answered a lookup.
+type Match struct {
+	Organization string
+	Prefix       string // matching prefix as upper case hex digits, e.g. "AC84C6"
+	Source       string // the overrides file, or the IEEE registry file, e.g. "mam.csv"
+	Override     bool   // whether the overrides file answered
+}
+
+// SourceInfo describes one of the IEEE registries loaded in a Registry.
+type SourceInfo struct {
+	File    string // file name in the cache directory, e.g. "mam.csv"
+	URL     string // where the registry is refreshed from
+	Entries int    // number of assignments
 }
 
 // Option configures a Registry during construction.
 type Option func(*Registry)
 
 // WithCacheDir sets the directory for caching OUI data.
-// The registry saves downloaded IEEE data to oui.csv in this directory,
-// reducing network requests on subsequent runs. If empty, no caching occurs.
+// The registry saves downloaded IEEE data to oui.csv, mam.csv, oui36.csv and
+// cid.csv in this directory, reducing network requests on subsequent runs.
+// If empty, no caching occurs.
 func WithCacheDir(dir string) Option {
 	return func(r *Registry) {
-		if dir == "" {
+		r.dir = dir
+	}
+}
+
+// WithUpdateURL sets the base URL the registries are refreshed from, e.g. an
+// internal mirror of the IEEE website. The registries are downloaded from the
+// same paths as on the IEEE website (oui/oui.csv, oui28/mam.csv, oui36/oui36.csv
+// and cid/cid.csv). If empty, DefaultUpdateURL is used.
+func WithUpdateURL(url string) Option {
+	return func(r *Registry) {
+		if url == "" {
 			return
 		}
-		r.path = filepath.Join(dir, "oui.csv")
+		r.updateURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithMaxAge sets the age after which New refreshes the registries in the
+// background. Non-positive values keep DefaultMaxAge.
+func WithMaxAge(d time.Duration) Option {
+	return func(r *Registry) {
+		if d > 0 {
+			r.maxAge = d
+		}
+	}
+}
+
+// WithAutoRefresh enables or disables the background refresh of stale data in
+// New, e.g. to never reach out to the network. Refresh can still be called
+// explicitly. Enabled by default.
+func WithAutoRefresh(enabled bool) Option {
+	return func(r *Registry) {
+		r.autoRefresh = enabled
 	}
 }
 
 // New creates an OUI registry with embedded IEEE data.
 // If WithCacheDir is used and cached data exists, it's loaded instead.
-// Automatically triggers a background refresh if data is older than 30 days.
+// Automatically triggers a background refresh if data is older than the max age,
+// unless disabled with WithAutoRefresh.
 //
 // The registry is immediately usable even if the background refresh fails.
 func New(ctx context.Context, opts ...Option) (*Registry, error) {
 	reg := &Registry{
-		prefixMap: make(map[string]string),
-		logger:    slog.Default(),
+		prefixes:    make(map[string]map[string]string, len(sources)),
+		updateURL:   DefaultUpdateURL,
+		maxAge:      DefaultMaxAge,
+		autoRefresh: true,
+		logger:      slog.Default(),
 	}
 	for _, opt := range opts {
 		opt(reg)
 	}
 
-	data := embeddedOUIDB
-	if reg.path != "" {
-		b, err := os.ReadFile(reg.path)
-		switch err {
-		case nil:
-			data = b
-			if info, statErr := os.Stat(reg.path); statErr == nil {
-				reg.loadedAt = info.ModTime()
-			}
-			reg.logger.Debug("OUI: loaded CSV from cache", "path", reg.path, "bytes", len(data))
-		default:
-			reg.logger.Debug("OUI: cache not available, using embedded CSV", "path", reg.path, "err", err)
-			if mkErr := os.MkdirAll(filepath.Dir(reg.path), 0o755); mkErr == nil {
-				if writeErr := os.WriteFile(reg.path, data, 0o644); writeErr != nil {
-					reg.logger.Debug("OUI: failed to write embedded oui.csv to cache", "err", writeErr)
-				}
-			}
-			reg.loadedAt = time.Now()
+	entryCount := 0
+	for i, src := range sources {
+		data, loadedAt := reg.load(src)
+		m, err := parseCSVBytes(data)
+		if err != nil {
+			reg.logger.Error("OUI: failed to parse CSV", "file", src.file, "err", err)
+			return nil, fmt.Errorf("%s: %w", src.file, err)
 		}
-	} else {
-		reg.loadedAt = time.Now()
-	}
-
-	if err := reg.loadFromBytes(data); err != nil {
-		reg.logger.Error("OUI: failed to parse CSV", "err", err)
-		return nil, err
+		if len(m) == 0 {
+			// e.g. a registry that was not embedded yet, refresh it
+			loadedAt = time.Time{}
+		}
+		if i == 0 || loadedAt.Before(reg.loadedAt) {
+			reg.loadedAt = loadedAt
+		}
+		reg.prefixes[src.file] = m
+		entryCount += len(m)
 	}
+	reg.logger.Debug("OUI: registry initialized", "entries", entryCount, "dir", reg.dir, "loaded_at", reg.loadedAt)
+	reg.reloadOverrides()
 
-	reg.mu.RLock()
-	entryCount := len(reg.prefixMap)
-	loadedAt := reg.loadedAt
-	path := reg.path
-	reg.mu.RUnlock()
-	reg.logger.Debug("OUI: registry initialized", "entries", entryCount, "path", path, "loaded_at", loadedAt)
-
-	age := time.Since(loadedAt)
-	// todo(ramon): make this configurable, e.g. the time period as well as whether to trigger a background refresh at all
-	if reg.path != "" && age > maxAge {
-		reg.logger.Info("OUI: data older than maxAge, triggering one-time refresh", "age", age, "max_age", maxAge)
+	age := time.Since(reg.loadedAt)
+	switch {
+	case !reg.autoRefresh:
+		reg.logger.Debug("OUI: automatic refresh disabled", "age", age)
+	case reg.dir != "" && age > reg.maxAge:
+		reg.logger.Info("OUI: data older than maxAge, triggering one-time refresh", "age", age, "max_age", reg.maxAge)
 		go func() {
 			if err := reg.Refresh(ctx); err != nil {
 				reg.logger.Debug("OUI: initial one-time refresh failed", "err", err)
 			}
 		}()
-	} else {
-		reg.logger.Debug("OUI: data is fresh enough, skipping initial refresh", "age", age, "max_age", maxAge)
+	default:
+		reg.logger.Debug("OUI: data is fresh enough, skipping initial refresh", "age", age, "max_age", reg.maxAge)
 	}
 
 	return reg, nil
 }
 
-// Refresh downloads the latest OUI data from the IEEE website and updates the registry.
+// load returns the cached data of src, or the embedded data when it is not cached,
+// and when it was loaded.
+func (reg *Registry) load(src source) ([]byte, time.Time) {
+	if reg.dir == "" {
+		return src.embedded, time.Now()
+	}
+	path := filepath.Join(reg.dir, src.file)
+	b, err := os.ReadFile(path)
+	if err == nil {
+		loadedAt := time.Now()
+		if info, statErr := os.Stat(path); statErr == nil {
+			loadedAt = info.ModTime()
+		}
+		reg.logger.Debug("OUI: loaded CSV from cache", "path", path, "bytes", len(b))
+		return b, loadedAt
+	}
+	reg.logger.Debug("OUI: cache not available, using embedded CSV", "path", path, "err", err)
+	if mkErr := os.MkdirAll(reg.dir, 0o755); mkErr == nil {
+		if writeErr := os.WriteFile(path, src.embedded, 0o644); writeErr != nil {
+			reg.logger.Debug("OUI: failed to write embedded CSV to cache", "path", path, "err", writeErr)
+		}
+	}
+	return src.embedded, time.Now()
+}
+
+// Refresh downloads the latest registry data from the IEEE website and updates the registry.
 // If a cache directory was configured, the downloaded data is saved to disk.
 //
-// Called automatically in the background when data is older than 30 days.
+// Called automatically in the background when data is older than the max age.
 // You can also call it manually to force an update.
 //
-// Returns an error if the download fails or the data is malformed.
-// The registry continues using existing data if the refresh fails.
+// Returns an error if a download fails or the data is malformed. The registry
+// keeps using the existing data of the registries that failed to refresh.
 func (reg *Registry) Refresh(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, clientTimeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, updateURL, http.NoBody)
+	client := &http.Client{Timeout: clientTimeout}
+	var errs []error
+	for _, src := range sources {
+		if err := reg.refreshSource(ctx, client, src); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src.file, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	reg.mu.Lock()
+	reg.loadedAt = time.Now()
+	reg.mu.Unlock()
+	return nil
+}
+
+func (reg *Registry) refreshSource(ctx context.Context, client *http.Client, src source) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reg.url(src), http.NoBody)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("User-Agent", userAgentHeader)
 	req.Header.Set("Accept", acceptHeader)
 
-	client := &http.Client{Timeout: clientTimeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -167,15 +291,14 @@ func (reg *Registry) Refresh(ctx context.Context) error {
 	}
 
 	reg.mu.Lock()
-	reg.prefixMap = m
-	reg.loadedAt = time.Now()
-	path := reg.path
+	reg.prefixes[src.file] = m
+	dir := reg.dir
 	reg.mu.Unlock()
 
-	if path != "" {
-		if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
-			if err := os.WriteFile(path, data, 0o644); err != nil {
-				reg.logger.Debug("OUI: failed to persist refreshed oui.csv", "err", err)
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err == nil {
+			if err := os.WriteFile(filepath.Join(dir, src.file), data, 0o644); err != nil {
+				reg.logger.Debug("OUI: failed to persist refreshed CSV", "file", src.file, "err", err)
 			}
 		}
 	}
@@ -183,20 +306,24 @@ func (reg *Registry) Refresh(ctx context.Context) error {
 	return nil
 }
 
-func (reg *Registry) loadFromBytes(b []byte) error {
-	m, err := parseCSVBytes(b)
-	if err != nil {
-		return err
-	}
-	reg.mu.Lock()
-	defer reg.mu.Unlock()
-	reg.prefixMap = m
-	if reg.loadedAt.IsZero() {
-		reg.loadedAt = time.Now()
+// url returns the URL src is refreshed from.
+func (reg *Registry) url(src source) string {
+	return reg.updateURL + "/" + src.path
+}
+
+// Sources describes the loaded registries, in lookup order.
+func (reg *Registry) Sources() []SourceInfo {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	out := make([]SourceInfo, 0, len(sources))
+	for _, src := range sources {
+		out = append(out, SourceInfo{File: src.file, URL: reg.url(src), Entries: len(reg.prefixes[src.file])})
 	}
-	return nil
+	return out
 }
 
+// parseCSVBytes parses an IEEE registry CSV file into organizations by
+// assignment, which is a prefix of 6, 7 or 9 hex digits.
 func parseCSVBytes(b []byte) (map[string]string, error) {
 	r := csv.NewReader(bufio.NewReader(strings.NewReader(string(b))))
 	r.FieldsPerRecord = -1
@@ -206,7 +333,7 @@ func parseCSVBytes(b []byte) (map[string]string, error) {
 		return nil, fmt.Errorf("reading header: %w", err)
 	}
 	if len(header) < 3 {
-		return nil, fmt.Errorf("unexpected header format in OUI CSV")
+		return nil, errors.New("unexpected header format in OUI CSV")
 	}
 
 	const (
@@ -233,8 +360,8 @@ func parseCSVBytes(b []byte) (map[string]string, error) {
 			continue
 		}
 
-		prefix := normalizeMACPrefix(macField)
-		if prefix == "" {
+		prefix := normalizeMAC(macField)
+		if !slices.Contains(prefixLengths, len(prefix)) {
 			continue
 		}
 		if _, exists := m[prefix]; !exists {
@@ -245,35 +372,82 @@ func parseCSVBytes(b []byte) (map[string]string, error) {
 	return m, nil
 }
 
-func normalizeMACPrefix(s string) string {
-	s = strings.ToUpper(s)
+// normalizeMAC returns the upper case hex digits of a MAC address or prefix, or
+// "" when s holds anything else or is shorter than 24 bits.
+func normalizeMAC(s string) string {
+	s = hexDigits(s)
+	if len(s) < 6 {
+		return ""
+	}
+	return s
+}
+
+// normalizePrefix returns the upper case hex digits of a MAC prefix of any length,
+// up to a full MAC address, or "" when s holds anything else.
+func normalizePrefix(s string) string {
+	s = hexDigits(s)
+	if len(s) > 12 {
+		return ""
+	}
+	return s
+}
+
+// hexDigits returns s in upper case without separators, or "" when s holds
+// anything else than hex digits and separators.
+func hexDigits(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
 	s = strings.ReplaceAll(s, "-", "")
 	s = strings.ReplaceAll(s, ":", "")
 	s = strings.ReplaceAll(s, ".", "")
-	if len(s) < 6 {
-		return ""
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') {
+			return ""
+		}
 	}
-	return s[:6]
+	return s
 }
 
 // Lookup returns the manufacturer name for a MAC address.
 // Accepts various MAC formats: "AA:BB:CC:DD:EE:FF", "AA-BB-CC-DD-EE-FF", "AABBCCDDEEFF".
-// Case-insensitive. Only the first 3 bytes (OUI prefix) are used.
+// Case-insensitive. The longest matching override is used, see WithOverridesFile,
+// and otherwise the longest matching MA-S (36-bit), MA-M (28-bit) or MA-L and
+// CID (24-bit) prefix.
 //
 // Returns the manufacturer name and true if found, empty string and false otherwise.
 func (reg *Registry) Lookup(mac string) (string, bool) {
-	prefix := normalizeMACPrefix(mac)
-	if prefix == "" {
+	m, ok := reg.Resolve(mac)
+	return m.Organization, ok
+}
+
+// Resolve is like Lookup, but also reports which prefix and source answered.
+func (reg *Registry) Resolve(mac string) (Match, bool) {
+	hex := normalizeMAC(mac)
+	if hex == "" {
 		reg.logger.Debug("OUI: lookup skipped, empty/invalid MAC", "mac", mac)
-		return "", false
+		return Match{}, false
 	}
+	reg.reloadOverrides()
+
 	reg.mu.RLock()
 	defer reg.mu.RUnlock()
-	org, ok := reg.prefixMap[prefix]
-	if !ok {
-		reg.logger.Debug("OUI: no entry for prefix", "mac", mac, "prefix", prefix)
-		return "", false
+	for n := len(hex); n > 0 && len(reg.overrides) > 0; n-- {
+		if org, ok := reg.overrides[hex[:n]]; ok {
+			reg.logger.Debug("OUI: override hit", "mac", mac, "prefix", hex[:n], "org", org)
+			return Match{Organization: org, Prefix: hex[:n], Source: reg.overridesPath, Override: true}, true
+		}
+	}
+	for _, n := range prefixLengths {
+		if n > len(hex) {
+			continue
+		}
+		prefix := hex[:n]
+		for _, src := range sources {
+			if org, ok := reg.prefixes[src.file][prefix]; ok {
+				reg.logger.Debug("OUI: lookup hit", "mac", mac, "prefix", prefix, "org", org)
+				return Match{Organization: org, Prefix: prefix, Source: src.file}, true
+			}
+		}
 	}
-	reg.logger.Debug("OUI: lookup hit", "mac", mac, "prefix", prefix, "org", org)
-	return org, true
+	reg.logger.Debug("OUI: no entry for prefix", "mac", mac, "prefix", hex[:6])
+	return Match{}, false
 }
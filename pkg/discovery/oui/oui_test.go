@@ -1,7 +1,15 @@
 package oui
 
 import (
+	"context"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 )
 
@@ -29,7 +37,7 @@ func TestLookup(t *testing.T) {
 	if err != nil {
 		t.Fatalf("parseCSVBytes error: %v", err)
 	}
-	reg := &Registry{prefixMap: m, logger: slog.Default()}
+	reg := &Registry{prefixes: map[string]map[string]string{"oui.csv": m}, logger: slog.Default()}
 
 	tests := []struct {
 		name    string
@@ -52,3 +60,157 @@ func TestLookup(t *testing.T) {
 		})
 	}
 }
+
+const csvHeader = "Registry,Assignment,Organization Name,Organization Address\n"
+
+func TestLookup_LongestPrefix(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"oui.csv":   csvHeader + "MA-L,70B3D5,IEEE Registration Authority,Piscataway\nMA-L,286FB9,Test Org,Somewhere\n",
+		"mam.csv":   csvHeader + "MA-M,70B3D5F,MA-M Org,Somewhere\n",
+		"oui36.csv": csvHeader + "IAB,70B3D5F12,MA-S Org,Somewhere\nMA-S,ZZZZZZZZZ,Invalid,Somewhere\n",
+		"cid.csv":   csvHeader + "CID,0A1B2C,CID Org,Somewhere\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg, err := New(ctx, WithCacheDir(dir))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	tests := []struct {
+		mac     string
+		wantOrg string
+		wantOK  bool
+	}{
+		{"70:B3:D5:F1:23:45", "MA-S Org", true},
+		{"70:b3:d5:f4:56:78", "MA-M Org", true},
+		{"70:B3:D5:01:23:45", "IEEE Registration Authority", true},
+		{"28-6F-B9-00-11-22", "Test Org", true},
+		{"0a:1b:2c:00:00:01", "CID Org", true},
+		{"70B3D5", "IEEE Registration Authority", true},
+		{"zz:zz:zz:zz:zz:zz", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := reg.Lookup(tt.mac)
+		if ok != tt.wantOK || got != tt.wantOrg {
+			t.Errorf("Lookup(%q) = %q, %v; want %q, %v", tt.mac, got, ok, tt.wantOrg, tt.wantOK)
+		}
+	}
+}
+
+func TestParseCSVBytes_SkipsInvalidAssignments(t *testing.T) {
+	m, err := parseCSVBytes([]byte(csvHeader + "MA-M,70B3D5F,Org,Somewhere\nMA-X,70B3D5F1,Too long for MA-M,Somewhere\n"))
+	if err != nil {
+		t.Fatalf("parseCSVBytes error: %v", err)
+	}
+	if len(m) != 1 || m["70B3D5F"] != "Org" {
+		t.Fatalf("unexpected entries: %v", m)
+	}
+}
+
+func TestRefresh_FromUpdateURL(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/mirror/oui/oui.csv":
+			_, _ = w.Write([]byte(csvHeader + "MA-L,286FB9,Mirror Org,Somewhere\n"))
+		case "/mirror/oui28/mam.csv":
+			_, _ = w.Write([]byte(csvHeader + "MA-M,70B3D5F,MA-M Org,Somewhere\n"))
+		case "/mirror/oui36/oui36.csv", "/mirror/cid/cid.csv":
+			_, _ = w.Write([]byte(csvHeader))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	reg, err := New(context.Background(), WithCacheDir(dir), WithUpdateURL(srv.URL+"/mirror/"), WithAutoRefresh(false))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("expected no requests with automatic refresh disabled, got %d", n)
+	}
+
+	if err := reg.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh error: %v", err)
+	}
+	if org, _ := reg.Lookup("28:6f:b9:00:11:22"); org != "Mirror Org" {
+		t.Errorf("expected refreshed MA-L entry, got %q", org)
+	}
+	if org, _ := reg.Lookup("70:b3:d5:f4:56:78"); org != "MA-M Org" {
+		t.Errorf("expected refreshed MA-M entry, got %q", org)
+	}
+
+	info := reg.Sources()
+	if len(info) != 4 || info[1].File != "mam.csv" || info[1].Entries != 1 || info[1].URL != srv.URL+"/mirror/oui28/mam.csv" {
+		t.Errorf("unexpected sources: %+v", info)
+	}
+	cached, err := os.ReadFile(filepath.Join(dir, "mam.csv"))
+	if err != nil || len(cached) == 0 {
+		t.Errorf("expected refreshed mam.csv to be cached, err=%v", err)
+	}
+}
+
+func TestRefresh_KeepsDataOnFailure(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	reg, err := New(context.Background(), WithUpdateURL(srv.URL))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	before := reg.Sources()[0].Entries
+	if err := reg.Refresh(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if after := reg.Sources()[0].Entries; after != before || after == 0 {
+		t.Errorf("expected embedded entries to be kept, got %d before and %d after", before, after)
+	}
+}
+
+// TestEmbedded_ResolvesMAMPrefix guards against shipping registries without
+// assignments: devices of MA-M vendors must resolve offline, without a cache
+// directory or a refresh, to their vendor rather than the IEEE block holding
+// their assignment. Run "make update-oui" when it fails.
+func TestEmbedded_ResolvesMAMPrefix(t *testing.T) {
+	for _, src := range sources {
+		m, err := parseCSVBytes(src.embedded)
+		if err != nil {
+			t.Fatalf("embedded %s: %v", src.file, err)
+		}
+		if len(m) == 0 {
+			t.Errorf("embedded %s holds no assignments", src.file)
+		}
+	}
+
+	mam, _ := parseCSVBytes(embeddedMAMDB)
+	if len(mam) == 0 {
+		t.FailNow()
+	}
+	prefixes := make([]string, 0, len(mam))
+	for p := range mam {
+		prefixes = append(prefixes, p)
+	}
+	slices.Sort(prefixes)
+	prefix := prefixes[0]
+
+	reg, err := New(context.Background(), WithAutoRefresh(false))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	mac := prefix + strings.Repeat("0", 12-len(prefix))
+	m, ok := reg.Resolve(mac)
+	if !ok || m.Source != "mam.csv" || m.Organization != mam[prefix] || m.Organization == "IEEE Registration Authority" {
+		t.Errorf("Resolve(%s) = %+v, %v; want %q from mam.csv", mac, m, ok, mam[prefix])
+	}
+}
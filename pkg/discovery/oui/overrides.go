@@ -0,0 +1,108 @@
+package oui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// overrideCheckInterval limits how often Lookup checks the overrides file for changes.
+const overrideCheckInterval = time.Second
+
+// overridesFile is the layout of the overrides file.
+type overridesFile struct {
+	Overrides map[string]string `yaml:"overrides"`
+}
+
+// WithOverridesFile sets a YAML file mapping MAC prefixes of any length, or full
+// MAC addresses, to organizations:
+//
+//	overrides:
+//	  "AC:84:C6": Acme
+//	  "AC:84:C6:12:34:56": Acme Doorbell
+//
+// Overrides are consulted before the IEEE registries, preferring the longest
+// matching prefix, e.g. to name white-label devices after their brand instead of
+// the manufacturer. The file is optional and reloaded when it changes.
+func WithOverridesFile(path string) Option {
+	return func(r *Registry) {
+		r.overridesPath = path
+	}
+}
+
+// reloadOverrides loads the overrides file when it changed since the last check.
+// The current overrides are kept when the file is invalid, and dropped when it is removed.
+func (reg *Registry) reloadOverrides() {
+	if reg.overridesPath == "" {
+		return
+	}
+
+	reg.overridesMu.Lock()
+	defer reg.overridesMu.Unlock()
+	if time.Since(reg.overridesCheckedAt) < overrideCheckInterval {
+		return
+	}
+	reg.overridesCheckedAt = time.Now()
+
+	info, err := os.Stat(reg.overridesPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !reg.overridesModTime.IsZero() {
+			reg.logger.Info("OUI: overrides file removed", "file", reg.overridesPath)
+		}
+		reg.overridesModTime = time.Time{}
+		reg.setOverrides(nil)
+		return
+	}
+	if err != nil {
+		reg.logger.Warn("OUI: failed to stat overrides file", "file", reg.overridesPath, "err", err)
+		return
+	}
+	if info.ModTime().Equal(reg.overridesModTime) {
+		return
+	}
+	reg.overridesModTime = info.ModTime()
+
+	m, err := loadOverrides(reg.overridesPath)
+	if err != nil {
+		reg.logger.Warn("OUI: failed to load overrides file; keeping previous overrides", "file", reg.overridesPath, "err", err)
+		return
+	}
+	reg.setOverrides(m)
+	reg.logger.Debug("OUI: overrides loaded", "file", reg.overridesPath, "entries", len(m))
+}
+
+func (reg *Registry) setOverrides(m map[string]string) {
+	reg.mu.Lock()
+	reg.overrides = m
+	reg.mu.Unlock()
+}
+
+// loadOverrides reads the overrides file at path into organizations by upper case
+// hex prefix.
+func loadOverrides(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file overridesFile
+	if err := yaml.UnmarshalWithOptions(raw, &file, yaml.Strict()); err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]string, len(file.Overrides))
+	for prefix, org := range file.Overrides {
+		hex := normalizePrefix(prefix)
+		if hex == "" {
+			return nil, fmt.Errorf("invalid MAC prefix %q", prefix)
+		}
+		if org == "" {
+			return nil, fmt.Errorf("empty organization for MAC prefix %q", prefix)
+		}
+		m[hex] = org
+	}
+	return m, nil
+}
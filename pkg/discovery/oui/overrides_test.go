@@ -0,0 +1,101 @@
+package oui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolve_OverridesBeforeIEEE(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oui_overrides.yaml")
+	writeOverrides(t, path, `overrides:
+  "28:6F:B9": Acme
+  "28-6f-b9-12-34-56": Acme Doorbell
+`)
+
+	reg, err := New(context.Background(), WithAutoRefresh(false), WithOverridesFile(path))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	reg.prefixes["oui.csv"] = map[string]string{"286FB9": "Shenzhen ODM", "70B3D5": "IEEE Registration Authority"}
+
+	tests := []struct {
+		mac  string
+		want Match
+	}{
+		{"28:6f:b9:12:34:56", Match{Organization: "Acme Doorbell", Prefix: "286FB9123456", Source: path, Override: true}},
+		{"28:6F:B9:00:00:01", Match{Organization: "Acme", Prefix: "286FB9", Source: path, Override: true}},
+		{"70:B3:D5:00:00:01", Match{Organization: "IEEE Registration Authority", Prefix: "70B3D5", Source: "oui.csv"}},
+	}
+	for _, tt := range tests {
+		got, ok := reg.Resolve(tt.mac)
+		if !ok || got != tt.want {
+			t.Errorf("Resolve(%q) = %+v, %v; want %+v", tt.mac, got, ok, tt.want)
+		}
+	}
+}
+
+func TestResolve_ReloadsOverridesOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oui_overrides.yaml")
+	reg, err := New(context.Background(), WithAutoRefresh(false), WithOverridesFile(path))
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	reload := func() { reg.overridesCheckedAt = time.Time{} }
+
+	if _, ok := reg.Lookup("0a:1b:2c:00:00:01"); ok {
+		t.Fatalf("expected no match without overrides file")
+	}
+
+	writeOverrides(t, path, "overrides:\n  0A1B2C: Acme\n")
+	reload()
+	if org, _ := reg.Lookup("0a:1b:2c:00:00:01"); org != "Acme" {
+		t.Errorf("expected override after creating the file, got %q", org)
+	}
+
+	writeOverrides(t, path, "overrides:\n  0A1B2C: [invalid\n")
+	setModTime(t, path, time.Now().Add(time.Minute))
+	reload()
+	if org, _ := reg.Lookup("0a:1b:2c:00:00:01"); org != "Acme" {
+		t.Errorf("expected previous override to be kept for an invalid file, got %q", org)
+	}
+
+	writeOverrides(t, path, "overrides:\n  0A1B2C: Acme Corp\n")
+	setModTime(t, path, time.Now().Add(2*time.Minute))
+	reload()
+	if org, _ := reg.Lookup("0a:1b:2c:00:00:01"); org != "Acme Corp" {
+		t.Errorf("expected changed override, got %q", org)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	reload()
+	if _, ok := reg.Lookup("0a:1b:2c:00:00:01"); ok {
+		t.Errorf("expected no match after removing the file")
+	}
+}
+
+func TestLoadOverrides_InvalidPrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oui_overrides.yaml")
+	writeOverrides(t, path, "overrides:\n  \"AC:84:C6:12:34:56:78\": Too long\n")
+	if _, err := loadOverrides(path); err == nil {
+		t.Errorf("expected error for a prefix longer than a MAC address")
+	}
+}
+
+func writeOverrides(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write overrides: %v", err)
+	}
+}
+
+func setModTime(t *testing.T, path string, mod time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
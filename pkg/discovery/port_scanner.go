@@ -2,22 +2,142 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"net"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
+// DefaultPortScanTimeout is the per-port connect timeout used when none is configured.
+const DefaultPortScanTimeout = 5 * time.Second
+
+// PortState describes the outcome of probing a single port.
+type PortState string
+
+const (
+	// PortOpen means the port accepted a connection.
+	PortOpen PortState = "open"
+	// PortClosed means the host actively refused the connection (TCP RST).
+	PortClosed PortState = "closed"
+	// PortFiltered means no answer was received before the timeout,
+	// typically because a firewall silently drops the traffic.
+	PortFiltered PortState = "filtered"
+	// PortOpenFiltered is specific to UDP: the probe got neither a reply nor an
+	// ICMP port unreachable, so the port is either open or silently filtered.
+	PortOpenFiltered PortState = "open|filtered"
+)
+
+// PortResult is the result of probing a single port on a target.
+type PortResult struct {
+	Port     int
+	Protocol string
+	// ServiceName is the registered name of the port (e.g. "ssh"), set when
+	// the scanner has a ServiceNamer configured. It says nothing about what
+	// actually listens on the port, see Service for that.
+	ServiceName string
+	State       PortState
+	// Latency is the time it took to connect (open) or to be refused (closed).
+	// For filtered ports it is the time spent waiting before giving up.
+	Latency time.Duration
+	// Err holds the dial error for closed and filtered ports, nil for open ports.
+	Err error
+	// Service is set for open ports when the scanner has a ServiceDetector
+	// configured and the service could be identified.
+	Service *ServiceInfo
+}
+
+// ServiceInfo describes the service identified on an open port.
+type ServiceInfo struct {
+	Name    string `json:"name,omitempty"`    // protocol level name, e.g. "ssh", "http"
+	Product string `json:"product,omitempty"` // implementation, e.g. "OpenSSH", "nginx"
+	Version string `json:"version,omitempty"` // product version when advertised
+	Title   string `json:"title,omitempty"`   // HTML page title for web services
+	Banner  string `json:"banner,omitempty"`  // first line of the raw banner, sanitized
+	// Certificate is set when the service speaks TLS.
+	Certificate *CertificateInfo `json:"certificate,omitempty"`
+}
+
+// ServiceDetector identifies the service listening on an open TCP port,
+// e.g. by reading its banner. Implementations must honor ctx and bound the
+// amount of data they read. See the fingerprint package for the built-in one.
+type ServiceDetector interface {
+	Detect(ctx context.Context, target string, port int) (*ServiceInfo, error)
+}
+
+// ServiceNamer maps a port to its registered service name, e.g. 22/tcp to "ssh".
+// It returns "" for unknown ports. See the services package for the IANA registry.
+type ServiceNamer interface {
+	ServiceName(port int, proto string) string
+}
+
 // Dialer abstracts network connection creation for testability.
 type Dialer interface {
 	DialContext(ctx context.Context, network, address string) (net.Conn, error)
 }
 
-// PortScanner performs TCP port scanning on network devices.
+// PortScanner performs TCP and UDP port scanning on network devices.
 type PortScanner struct {
-	workers int
-	dialer  Dialer
-	iface   *InterfaceInfo
+	workers  int
+	timeout  time.Duration
+	dialer   Dialer
+	iface    *InterfaceInfo
+	detector ServiceDetector
+	namer    ServiceNamer
+}
+
+// PortScannerOption configures a PortScanner during construction.
+type PortScannerOption func(*PortScanner)
+
+// WithPortTimeout sets the connect timeout applied to each probed port.
+// Non-positive values are ignored.
+//
+// Default: 5 seconds (DefaultPortScanTimeout)
+func WithPortTimeout(timeout time.Duration) PortScannerOption {
+	return func(ps *PortScanner) {
+		if timeout > 0 {
+			ps.timeout = timeout
+		}
+	}
+}
+
+// WithDialer overrides the Dialer used to open connections.
+// Mainly useful for testing or for routing probes through a custom transport.
+func WithDialer(d Dialer) PortScannerOption {
+	return func(ps *PortScanner) {
+		if d != nil {
+			ps.dialer = d
+		}
+	}
+}
+
+// WithPortWorkers overrides the number of ports probed concurrently.
+// Non-positive values are ignored.
+func WithPortWorkers(n int) PortScannerOption {
+	return func(ps *PortScanner) {
+		if n > 0 {
+			ps.workers = n
+		}
+	}
+}
+
+// WithServiceDetector enables service detection on open TCP ports.
+// Detection runs right after a successful connect, so results for open ports
+// arrive later by at most the detector's own timeout.
+func WithServiceDetector(d ServiceDetector) PortScannerOption {
+	return func(ps *PortScanner) {
+		ps.detector = d
+	}
+}
+
+// WithServiceNamer annotates every PortResult with the registered service name of its port.
+func WithServiceNamer(n ServiceNamer) PortScannerOption {
+	return func(ps *PortScanner) {
+		ps.namer = n
+	}
 }
 
 // NewPortScanner creates a PortScanner with the specified number of concurrent workers.
@@ -27,13 +147,21 @@ type PortScanner struct {
 // Example:
 //
 //	iface, _ := discovery.NewInterfaceInfo("en0")
-//	scanner := discovery.NewPortScanner(20, iface)
-func NewPortScanner(workers int, iface *InterfaceInfo) *PortScanner {
-	return &PortScanner{
+//	scanner := discovery.NewPortScanner(20, iface, discovery.WithPortTimeout(time.Second))
+func NewPortScanner(workers int, iface *InterfaceInfo, opts ...PortScannerOption) *PortScanner {
+	if workers <= 0 {
+		workers = 1
+	}
+	ps := &PortScanner{
 		workers: workers,
+		timeout: DefaultPortScanTimeout,
 		dialer:  &netDialer{iface: iface},
 		iface:   iface,
 	}
+	for _, opt := range opts {
+		opt(ps)
+	}
+	return ps
 }
 
 // netDialer implements Dialer using net.Dialer.
@@ -43,80 +171,314 @@ type netDialer struct {
 
 func (d *netDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
 	var dialer net.Dialer
-	dialer.LocalAddr = &net.TCPAddr{IP: *d.iface.IPv4Addr}
+	if local := d.localIP(address); local != nil {
+		if strings.HasPrefix(network, "udp") {
+			dialer.LocalAddr = &net.UDPAddr{IP: local}
+		} else {
+			dialer.LocalAddr = &net.TCPAddr{IP: local}
+		}
+	}
 	return dialer.DialContext(ctx, network, address)
 }
 
+// localIP returns the address of the interface to dial address from: the
+// host's address on the subnet of the target, or the primary address of the
+// interface for targets outside its subnets.
+func (d *netDialer) localIP(address string) net.IP {
+	if d.iface == nil || d.iface.IPv4Addr == nil {
+		return nil
+	}
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		if subnet := d.iface.SubnetOf(net.ParseIP(host)); subnet != nil {
+			return subnet.IP
+		}
+	}
+	return *d.iface.IPv4Addr
+}
+
+// Scan probes the given TCP ports on target and streams one PortResult per port
+// on the returned channel. Scanning happens concurrently using the configured
+// number of workers, so results arrive in completion order, not port order.
+//
+// The channel is closed once every port has been probed or ctx is done.
+// Ports that were not probed before cancellation produce no result.
+//
+// Example:
+//
+//	results, err := scanner.Scan(ctx, "192.168.1.10", []int{22, 80, 443})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for r := range results {
+//	    fmt.Printf("%d/%s %s (%s)\n", r.Port, r.Protocol, r.State, r.Latency)
+//	}
+func (ps *PortScanner) Scan(ctx context.Context, target string, ports []int) (<-chan PortResult, error) {
+	if strings.TrimSpace(target) == "" {
+		return nil, errors.New("port scan target cannot be empty")
+	}
+	return ps.scan(ctx, target, ports, ps.timeout, ps.probeTCP), nil
+}
+
+// ScanUDP probes the given UDP ports on target and streams one PortResult per port.
+// Each probe sends a protocol-specific payload (DNS query, NTP client request,
+// SNMP get, SSDP M-SEARCH, ...) so that services which only answer well-formed
+// requests reply. Ports without a dedicated probe receive an empty datagram.
+//
+// Results are classified as:
+//   - PortOpen: the target replied
+//   - PortClosed: an ICMP port unreachable was received
+//   - PortOpenFiltered: no reply before the timeout
+//   - PortFiltered: any other ICMP error (e.g. administratively prohibited)
+//
+// Like Scan, the channel is closed once all ports are probed or ctx is done.
+func (ps *PortScanner) ScanUDP(ctx context.Context, target string, ports []int) (<-chan PortResult, error) {
+	if strings.TrimSpace(target) == "" {
+		return nil, errors.New("port scan target cannot be empty")
+	}
+	return ps.scan(ctx, target, ports, ps.timeout, ps.probeUDP), nil
+}
+
 // Stream scans TCP ports on the target IP address and calls the callback for each open port.
 // Scanning happens concurrently using the configured number of workers.
-// The callback is invoked from multiple goroutines - ensure it's thread-safe.
+// The callback is invoked from a single goroutine.
+//
+// Deprecated: use Scan, which also reports closed and filtered ports together with latency.
 func (ps *PortScanner) Stream(ctx context.Context, ip string, ports []int, timeout time.Duration, callback func(int)) error {
-	// todo(ramon): consider using a channel for results instead of a callback, just like the Engine does for devices.
-	// This would allow more flexible handling of results and better integration with the rest of the system.
-	if len(ports) == 0 {
-		return nil
+	for r := range ps.scan(ctx, ip, ports, timeout, ps.probeTCP) {
+		if r.State == PortOpen {
+			callback(r.Port)
+		}
 	}
+	return ctx.Err()
+}
 
-	var wg sync.WaitGroup
-	portChan := make(chan int, ps.workers)
-	errChan := make(chan error, 1)
+// HostPortResult is a PortResult together with the target it was probed on,
+// as produced by ScanHosts.
+type HostPortResult struct {
+	Target string
+	PortResult
+}
 
-	for i := 0; i < ps.workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			ps.streamWorker(ctx, ip, portChan, callback, timeout)
-		}()
+// ScanHosts probes the given TCP and UDP ports on every target and streams one
+// HostPortResult per (target, port). All targets share the scanner's worker
+// pool, so the number of workers is a global concurrency budget rather than a
+// per-host one. Results arrive in completion order.
+//
+// The channel is closed once every port on every target has been probed or ctx is done.
+//
+// Example:
+//
+//	results, err := scanner.ScanHosts(ctx, []string{"192.168.1.1", "192.168.1.2"}, []int{22, 443}, nil)
+//	for r := range results {
+//	    fmt.Printf("%s %d/%s %s\n", r.Target, r.Port, r.Protocol, r.State)
+//	}
+func (ps *PortScanner) ScanHosts(ctx context.Context, targets []string, tcp, udp []int) (<-chan HostPortResult, error) {
+	for _, t := range targets {
+		if strings.TrimSpace(t) == "" {
+			return nil, errors.New("port scan target cannot be empty")
+		}
 	}
+	jobs := func(yield func(portJob) bool) {
+		for _, t := range targets {
+			for _, port := range tcp {
+				if !yield(portJob{target: t, port: port, probe: ps.probeTCP}) {
+					return
+				}
+			}
+			for _, port := range udp {
+				if !yield(portJob{target: t, port: port, probe: ps.probeUDP}) {
+					return
+				}
+			}
+		}
+	}
+	out := make(chan HostPortResult, ps.workers)
+	ps.run(ctx, jobs, ps.timeout, func(j portJob, r PortResult) bool {
+		select {
+		case out <- HostPortResult{Target: j.target, PortResult: r}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(out) })
+	return out, nil
+}
 
-	go func() {
-		defer close(portChan)
+// probeFunc probes a single port and classifies the outcome.
+type probeFunc func(ctx context.Context, target string, port int, timeout time.Duration) PortResult
+
+// portJob is a single port to probe on a target.
+type portJob struct {
+	target string
+	port   int
+	probe  probeFunc
+}
+
+// scan fans ports out to the worker pool and returns the result channel.
+func (ps *PortScanner) scan(ctx context.Context, target string, ports []int, timeout time.Duration, probe probeFunc) <-chan PortResult {
+	out := make(chan PortResult, ps.workers)
+	jobs := func(yield func(portJob) bool) {
 		for _, port := range ports {
+			if !yield(portJob{target: target, port: port, probe: probe}) {
+				return
+			}
+		}
+	}
+	ps.run(ctx, jobs, timeout, func(_ portJob, r PortResult) bool {
+		select {
+		case out <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(out) })
+	return out
+}
+
+// run feeds jobs to the worker pool and hands every result to emit, which
+// reports whether the worker should continue. done is called once all workers exited.
+func (ps *PortScanner) run(ctx context.Context, jobs iter.Seq[portJob], timeout time.Duration, emit func(portJob, PortResult) bool, done func()) {
+	jobChan := make(chan portJob)
+	go func() {
+		defer close(jobChan)
+		for j := range jobs {
 			select {
-			case portChan <- port:
+			case jobChan <- j:
 			case <-ctx.Done():
-				errChan <- ctx.Err()
 				return
 			}
 		}
 	}()
 
+	var wg sync.WaitGroup
+	for i := 0; i < ps.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scanWorker(ctx, jobChan, timeout, ps.namer, emit)
+		}()
+	}
+
 	go func() {
 		wg.Wait()
-		close(errChan)
+		done()
 	}()
-
-	if err := <-errChan; err != nil {
-		return err
-	}
-	return ctx.Err()
 }
 
-// streamWorker performs the actual port scanning for streaming.
-func (ps *PortScanner) streamWorker(ctx context.Context, ip string, ports <-chan int, callback func(int), timeout time.Duration) {
-	for {
-		select {
-		case <-ctx.Done():
+// scanWorker probes jobs from the input channel until it is drained or ctx is done.
+func scanWorker(ctx context.Context, jobs <-chan portJob, timeout time.Duration, namer ServiceNamer, emit func(portJob, PortResult) bool) {
+	for j := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		r := j.probe(ctx, j.target, j.port, timeout)
+		// a probe cut short by the parent context says nothing about the port
+		if ctx.Err() != nil {
+			return
+		}
+		if namer != nil {
+			r.ServiceName = namer.ServiceName(r.Port, r.Protocol)
+		}
+		if !emit(j, r) {
 			return
-		case port, ok := <-ports:
-			if !ok {
-				return
-			}
-			if ps.isPortOpen(ctx, ip, port, timeout) {
-				callback(port)
-			}
 		}
 	}
 }
 
-// isPortOpen checks if a TCP port is open using context-aware dialing.
-func (ps *PortScanner) isPortOpen(ctx context.Context, ip string, port int, timeout time.Duration) bool {
+// probeTCP attempts a TCP connect and classifies the outcome.
+func (ps *PortScanner) probeTCP(ctx context.Context, target string, port int, timeout time.Duration) PortResult {
 	dialCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	conn, err := ps.dialer.DialContext(dialCtx, "tcp", fmt.Sprintf("%s:%d", ip, port))
+
+	start := time.Now()
+	conn, err := ps.dialer.DialContext(dialCtx, "tcp", net.JoinHostPort(target, fmt.Sprint(port)))
+	latency := time.Since(start)
+
+	r := PortResult{Port: port, Protocol: "tcp", Latency: latency}
 	if err != nil {
-		return false
+		r.State = classifyDialError(err)
+		r.Err = err
+		return r
+	}
+	_ = conn.Close()
+	r.State = PortOpen
+	if ps.detector != nil {
+		if info, err := ps.detector.Detect(ctx, target, port); err == nil {
+			r.Service = info
+		}
+	}
+	return r
+}
+
+// probeUDP sends the protocol probe for port and waits for a reply or an ICMP error.
+// On a connected UDP socket the kernel surfaces ICMP port unreachable as
+// ECONNREFUSED on the next read, which is what distinguishes closed from open|filtered.
+func (ps *PortScanner) probeUDP(ctx context.Context, target string, port int, timeout time.Duration) PortResult {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r := PortResult{Port: port, Protocol: "udp"}
+	start := time.Now()
+
+	conn, err := ps.dialer.DialContext(probeCtx, "udp", net.JoinHostPort(target, fmt.Sprint(port)))
+	if err != nil {
+		r.State = classifyUDPError(err)
+		r.Err = err
+		r.Latency = time.Since(start)
+		return r
+	}
+	defer func() { _ = conn.Close() }()
+
+	if dl, ok := probeCtx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	// unblock the read when the parent context is canceled before the deadline
+	stop := context.AfterFunc(probeCtx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.Write(udpProbePayload(port)); err != nil {
+		r.State = classifyUDPError(err)
+		r.Err = err
+		r.Latency = time.Since(start)
+		return r
+	}
+
+	buf := make([]byte, 1500)
+	_, err = conn.Read(buf)
+	r.Latency = time.Since(start)
+	if err != nil {
+		r.State = classifyUDPError(err)
+		if r.State != PortOpenFiltered {
+			r.Err = err
+		}
+		return r
+	}
+	r.State = PortOpen
+	return r
+}
+
+// classifyUDPError maps a failed UDP probe to closed (ICMP port unreachable),
+// open|filtered (no answer) or filtered (any other error).
+func classifyUDPError(err error) PortState {
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return PortOpenFiltered
+	}
+	// Windows reports ICMP port unreachable on UDP sockets as WSAECONNRESET.
+	if errors.Is(err, syscall.ECONNRESET) || classifyDialError(err) == PortClosed {
+		return PortClosed
+	}
+	return PortFiltered
+}
+
+// classifyDialError maps a failed TCP connect to closed (refused) or filtered (anything else).
+func classifyDialError(err error) PortState {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return PortClosed
+	}
+	// Windows reports WSAECONNREFUSED which does not match syscall.ECONNREFUSED.
+	if strings.Contains(strings.ToLower(err.Error()), "refused") {
+		return PortClosed
 	}
-	err = conn.Close()
-	return err == nil
+	return PortFiltered
 }
@@ -2,8 +2,11 @@ package discovery
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"os"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -23,15 +26,22 @@ func (m *mockConn) SetReadDeadline(t time.Time) error  { return nil }
 func (m *mockConn) SetWriteDeadline(t time.Time) error { return nil }
 
 // mockDialer is a mock implementation of Dialer for testing.
+// Addresses in openPorts connect, addresses in filteredPorts block until the
+// dial context expires, everything else is refused.
 type mockDialer struct {
-	openPorts map[string]bool
+	openPorts     map[string]bool
+	filteredPorts map[string]bool
 }
 
 func (m *mockDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
 	if m.openPorts[address] {
 		return &mockConn{}, nil // simulate open
 	}
-	return nil, net.ErrClosed // simulate closed
+	if m.filteredPorts[address] {
+		<-ctx.Done() // simulate dropped SYN
+		return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+	}
+	return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)} // simulate RST
 }
 
 func TestPortScanner_Stream(t *testing.T) {
@@ -75,3 +85,139 @@ func TestPortScanner_Stream_EmptyPorts(t *testing.T) {
 	require.NoError(t, err)
 	require.Empty(t, openPorts)
 }
+
+func TestPortScanner_Scan(t *testing.T) {
+	mock := &mockDialer{
+		openPorts:     map[string]bool{"127.0.0.1:80": true},
+		filteredPorts: map[string]bool{"127.0.0.1:8080": true},
+	}
+	ps := NewPortScanner(4, nil, WithDialer(mock), WithPortTimeout(50*time.Millisecond))
+
+	results, err := ps.Scan(context.Background(), "127.0.0.1", []int{22, 80, 8080})
+	require.NoError(t, err)
+
+	got := make(map[int]PortResult)
+	for r := range results {
+		got[r.Port] = r
+	}
+
+	require.Len(t, got, 3)
+	require.Equal(t, PortOpen, got[80].State)
+	require.NoError(t, got[80].Err)
+	require.Equal(t, PortClosed, got[22].State)
+	require.Error(t, got[22].Err)
+	require.Equal(t, PortFiltered, got[8080].State)
+	require.GreaterOrEqual(t, got[8080].Latency, 50*time.Millisecond)
+	for _, r := range got {
+		require.Equal(t, "tcp", r.Protocol)
+	}
+}
+
+type mockDetector struct {
+	mu    sync.Mutex
+	ports []int
+}
+
+func (m *mockDetector) Detect(ctx context.Context, target string, port int) (*ServiceInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ports = append(m.ports, port)
+	return &ServiceInfo{Name: "http", Product: "nginx"}, nil
+}
+
+func TestPortScanner_Scan_ServiceDetector(t *testing.T) {
+	mock := &mockDialer{openPorts: map[string]bool{"127.0.0.1:80": true}}
+	det := &mockDetector{}
+	ps := NewPortScanner(2, nil, WithDialer(mock), WithServiceDetector(det))
+
+	results, err := ps.Scan(context.Background(), "127.0.0.1", []int{22, 80})
+	require.NoError(t, err)
+
+	got := make(map[int]PortResult)
+	for r := range results {
+		got[r.Port] = r
+	}
+
+	require.Equal(t, []int{80}, det.ports, "only open ports are fingerprinted")
+	require.Equal(t, &ServiceInfo{Name: "http", Product: "nginx"}, got[80].Service)
+	require.Nil(t, got[22].Service)
+}
+
+func TestPortScanner_Scan_EmptyTarget(t *testing.T) {
+	ps := NewPortScanner(1, nil)
+	_, err := ps.Scan(context.Background(), "", []int{80})
+	require.Error(t, err)
+}
+
+func TestPortScanner_Scan_Canceled(t *testing.T) {
+	mock := &mockDialer{filteredPorts: map[string]bool{"127.0.0.1:1": true, "127.0.0.1:2": true}}
+	ps := NewPortScanner(2, nil, WithDialer(mock), WithPortTimeout(time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := ps.Scan(ctx, "127.0.0.1", []int{1, 2, 3, 4})
+	require.NoError(t, err)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for range results {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("result channel not closed after cancellation")
+	}
+}
+
+func TestPortScanner_ScanHosts(t *testing.T) {
+	mock := &mockDialer{openPorts: map[string]bool{"10.0.0.1:22": true, "10.0.0.2:443": true}}
+	ps := NewPortScanner(3, nil, WithDialer(mock), WithPortTimeout(100*time.Millisecond))
+
+	results, err := ps.ScanHosts(context.Background(), []string{"10.0.0.1", "10.0.0.2"}, []int{22, 443}, nil)
+	require.NoError(t, err)
+
+	got := map[string]PortState{}
+	for r := range results {
+		got[net.JoinHostPort(r.Target, fmt.Sprint(r.Port))] = r.State
+	}
+	require.Equal(t, map[string]PortState{
+		"10.0.0.1:22":  PortOpen,
+		"10.0.0.1:443": PortClosed,
+		"10.0.0.2:22":  PortClosed,
+		"10.0.0.2:443": PortOpen,
+	}, got)
+}
+
+func TestPortScanner_ScanHosts_EmptyTarget(t *testing.T) {
+	ps := NewPortScanner(1, nil)
+	_, err := ps.ScanHosts(context.Background(), []string{"10.0.0.1", " "}, []int{80}, nil)
+	require.Error(t, err)
+}
+
+type mapNamer map[int]string
+
+func (m mapNamer) ServiceName(port int, _ string) string { return m[port] }
+
+func TestPortScanner_Scan_ServiceNamer(t *testing.T) {
+	mock := &mockDialer{openPorts: map[string]bool{"127.0.0.1:22": true}}
+	ps := NewPortScanner(2, nil, WithDialer(mock), WithServiceNamer(mapNamer{22: "ssh", 80: "http"}))
+
+	results, err := ps.Scan(context.Background(), "127.0.0.1", []int{22, 80, 9999})
+	require.NoError(t, err)
+	got := map[int]string{}
+	for r := range results {
+		got[r.Port] = r.ServiceName
+	}
+	require.Equal(t, map[int]string{22: "ssh", 80: "http", 9999: ""}, got)
+}
+
+func TestNetDialer_LocalIP(t *testing.T) {
+	d := &netDialer{iface: testMultiSubnetInterface()}
+
+	require.Equal(t, "10.0.0.1", d.localIP("10.0.3.4:22").String())
+	require.Equal(t, "192.168.1.10", d.localIP("192.168.1.20:22").String())
+	require.Equal(t, "192.168.1.10", d.localIP("8.8.8.8:53").String())
+	require.Nil(t, (&netDialer{}).localIP("10.0.3.4:22"))
+}
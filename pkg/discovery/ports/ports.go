@@ -0,0 +1,168 @@
+// Package ports parses nmap-like port specifications and provides named port
+// profiles for the port scanner.
+//
+// A port list is a comma separated set of ports and ranges, e.g.
+// "22,80,443,8000-8100". Open ended ranges default to the lowest or highest
+// port ("-1024", "60000-"), and a lone "-" means every port.
+//
+// A spec may additionally select the protocol with a "T:" or "U:" prefix,
+// which applies until the next prefix: "T:22,80,U:53,161". Ports without a
+// prefix are TCP.
+package ports
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MinPort is the lowest valid port number.
+	MinPort = 1
+	// MaxPort is the highest valid port number.
+	MaxPort = 65535
+)
+
+// Spec is a set of TCP and UDP ports to scan.
+type Spec struct {
+	TCP []int
+	UDP []int
+}
+
+// Len returns the total number of ports in the spec.
+func (s Spec) Len() int {
+	return len(s.TCP) + len(s.UDP)
+}
+
+// String formats the spec in the syntax accepted by Parse, e.g. "T:22,80,U:53".
+// The "T:" prefix is omitted when the spec has no UDP ports.
+func (s Spec) String() string {
+	switch {
+	case len(s.UDP) == 0:
+		return FormatList(s.TCP)
+	case len(s.TCP) == 0:
+		return "U:" + FormatList(s.UDP)
+	default:
+		return "T:" + FormatList(s.TCP) + ",U:" + FormatList(s.UDP)
+	}
+}
+
+// Parse parses a spec with optional "T:" and "U:" protocol prefixes.
+// The resulting port lists are sorted and free of duplicates.
+func Parse(spec string) (Spec, error) {
+	var s Spec
+	dst := &s.TCP
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case len(part) >= 2 && strings.EqualFold(part[:2], "T:"):
+			dst = &s.TCP
+			part = strings.TrimSpace(part[2:])
+		case len(part) >= 2 && strings.EqualFold(part[:2], "U:"):
+			dst = &s.UDP
+			part = strings.TrimSpace(part[2:])
+		}
+		if part == "" {
+			continue
+		}
+		lo, hi, err := parseRange(part)
+		if err != nil {
+			return Spec{}, err
+		}
+		for p := lo; p <= hi; p++ {
+			*dst = append(*dst, p)
+		}
+	}
+	s.TCP = normalize(s.TCP)
+	s.UDP = normalize(s.UDP)
+	return s, nil
+}
+
+// ParseList parses a comma separated list of ports and ranges without protocol
+// prefixes. The result is sorted and free of duplicates.
+func ParseList(list string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, err := parseRange(part)
+		if err != nil {
+			return nil, err
+		}
+		for p := lo; p <= hi; p++ {
+			out = append(out, p)
+		}
+	}
+	return normalize(out), nil
+}
+
+// FormatList formats ports as a compact list, collapsing consecutive ports
+// into ranges: []int{22, 80, 81, 82} becomes "22,80-82".
+func FormatList(ports []int) string {
+	sorted := normalize(slices.Clone(ports))
+	var sb strings.Builder
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.Itoa(sorted[i]))
+		if j > i {
+			sb.WriteByte('-')
+			sb.WriteString(strconv.Itoa(sorted[j]))
+		}
+		i = j + 1
+	}
+	return sb.String()
+}
+
+// parseRange parses "80", "1-1024", "-1024", "60000-" or "-".
+func parseRange(s string) (lo, hi int, err error) {
+	loStr, hiStr, isRange := strings.Cut(s, "-")
+	if !isRange {
+		p, err := parsePort(s)
+		return p, p, err
+	}
+
+	lo, hi = MinPort, MaxPort
+	if loStr = strings.TrimSpace(loStr); loStr != "" {
+		if lo, err = parsePort(loStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	if hiStr = strings.TrimSpace(hiStr); hiStr != "" {
+		if hi, err = parsePort(hiStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("invalid port range %q: start is greater than end", s)
+	}
+	return lo, hi, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	if p < MinPort || p > MaxPort {
+		return 0, fmt.Errorf("port %d out of range %d-%d", p, MinPort, MaxPort)
+	}
+	return p, nil
+}
+
+// normalize sorts ports and removes duplicates in place.
+func normalize(ports []int) []int {
+	if len(ports) == 0 {
+		return nil
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports)
+}
@@ -0,0 +1,124 @@
+package ports
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"22", []int{22}},
+		{"443, 22,80", []int{22, 80, 443}},
+		{"8000-8003,3389", []int{3389, 8000, 8001, 8002, 8003}},
+		{"22,22,20-23", []int{20, 21, 22, 23}},
+		{"-3", []int{1, 2, 3}},
+		{"65534-", []int{65534, 65535}},
+		{"", nil},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseList(tt.in)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	all, err := ParseList("-")
+	require.NoError(t, err)
+	require.Len(t, all, MaxPort)
+}
+
+func TestParseList_Invalid(t *testing.T) {
+	for _, in := range []string{"0", "65536", "http", "10-5", "1-2-3", "T:22"} {
+		t.Run(in, func(t *testing.T) {
+			_, err := ParseList(in)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse("T:22,80-81,U:53,161,t:443")
+	require.NoError(t, err)
+	require.Equal(t, []int{22, 80, 81, 443}, s.TCP)
+	require.Equal(t, []int{53, 161}, s.UDP)
+	require.Equal(t, 6, s.Len())
+
+	s, err = Parse("1-3")
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, s.TCP)
+	require.Nil(t, s.UDP)
+
+	_, err = Parse("U:99999")
+	require.Error(t, err)
+}
+
+func TestFormatList(t *testing.T) {
+	require.Equal(t, "22,80-82,443", FormatList([]int{443, 80, 81, 22, 82, 80}))
+	require.Equal(t, "", FormatList(nil))
+}
+
+func TestSpecString_RoundTrip(t *testing.T) {
+	for _, in := range []string{"22,80-82", "U:53,161", "T:22,U:53"} {
+		s, err := Parse(in)
+		require.NoError(t, err)
+		require.Equal(t, in, s.String())
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	for _, name := range Profiles() {
+		t.Run(name, func(t *testing.T) {
+			s, ok := Profile(name)
+			require.True(t, ok)
+			require.NotZero(t, s.Len())
+		})
+	}
+
+	_, ok := Profile("nope")
+	require.False(t, ok)
+
+	web, ok := Profile(" WEB ")
+	require.True(t, ok)
+	require.Contains(t, web.TCP, 443)
+}
+
+func TestTop(t *testing.T) {
+	require.Len(t, topTCP, 1000)
+
+	top100, _ := Profile("top100")
+	require.Len(t, top100.TCP, 100)
+	require.Contains(t, top100.TCP, 80)
+	require.True(t, isSorted(top100.TCP))
+
+	top1000, _ := Profile("top1000")
+	require.Len(t, top1000.TCP, 1000)
+	for _, p := range top100.TCP {
+		require.Contains(t, top1000.TCP, p)
+	}
+
+	require.Equal(t, []int{80}, Top(1))
+	require.Empty(t, Top(-1))
+	require.Len(t, Top(5000), 1000)
+}
+
+func TestValidateProfileName(t *testing.T) {
+	require.NoError(t, ValidateProfileName("nas"))
+	require.Error(t, ValidateProfileName(""))
+	require.Error(t, ValidateProfileName("my profile"))
+	require.Error(t, ValidateProfileName("a=b"))
+}
+
+func isSorted(ports []int) bool {
+	for i := 1; i < len(ports); i++ {
+		if ports[i] <= ports[i-1] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package ports
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:embed top_tcp.txt
+var embeddedTopTCP []byte
+
+// topTCP holds the embedded TCP ports ordered by how often they are found open.
+var topTCP = mustParseFrequencyTable(embeddedTopTCP)
+
+// builtinProfiles are the named profiles available out of the box, besides the
+// top-N profiles derived from the frequency table.
+var builtinProfiles = map[string]string{
+	"web":       "80,81,443,591,593,3000,4443,5000,5001,8000,8008,8080,8081,8088,8443,8888,9000,9090,9443",
+	"iot":       "T:23,80,81,443,554,1883,2323,4840,5000,7547,8080,8081,8443,8883,9000,49152,U:1900,5353,5683",
+	"windows":   "T:53,88,135,139,389,445,464,593,636,3268,3269,3389,5985,5986,9389,U:53,88,123,137,138,389",
+	"databases": "1433,1521,2483,2484,3306,5432,5984,6379,7000,7001,8086,9042,9200,9300,11211,26257,27017,27018,28015,50000",
+}
+
+// topProfiles maps the top-N profile names to N.
+var topProfiles = map[string]int{
+	"top100":  100,
+	"top1000": 1000,
+}
+
+// Profile returns the built-in profile with the given name.
+func Profile(name string) (Spec, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if n, ok := topProfiles[name]; ok {
+		return Spec{TCP: Top(n)}, true
+	}
+	spec, ok := builtinProfiles[name]
+	if !ok {
+		return Spec{}, false
+	}
+	s, err := Parse(spec)
+	if err != nil {
+		// built-in specs are covered by tests
+		panic(fmt.Sprintf("ports: invalid built-in profile %q: %v", name, err))
+	}
+	return s, true
+}
+
+// Profiles returns the names of all built-in profiles, sorted.
+func Profiles() []string {
+	names := make([]string, 0, len(builtinProfiles)+len(topProfiles))
+	for name := range builtinProfiles {
+		names = append(names, name)
+	}
+	for name := range topProfiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ValidateProfileName reports whether name can be used for a user-defined profile.
+func ValidateProfileName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("profile name cannot be empty")
+	}
+	if strings.ContainsAny(name, " ,:;=") {
+		return fmt.Errorf("profile name %q cannot contain spaces or any of ,:;=", name)
+	}
+	return nil
+}
+
+// Top returns the n most frequently open TCP ports, sorted by port number.
+// n is capped at the size of the embedded frequency table.
+func Top(n int) []int {
+	n = min(max(n, 0), len(topTCP))
+	out := slices.Clone(topTCP[:n])
+	slices.Sort(out)
+	return out
+}
+
+func mustParseFrequencyTable(data []byte) []int {
+	var out []int
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := strconv.Atoi(line)
+		if err != nil {
+			panic(fmt.Sprintf("ports: invalid entry %q in top_tcp.txt", line))
+		}
+		out = append(out, p)
+	}
+	return out
+}
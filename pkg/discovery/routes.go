@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrRoutesUnsupported is returned by DefaultRoutes on platforms where reading
+// the routing table is not implemented.
+var ErrRoutesUnsupported = errors.New("reading the routing table is not supported on this platform")
+
+// Route is an IPv4 default route from the kernel routing table.
+type Route struct {
+	Interface string // Name of the interface the route goes out of
+	Gateway   net.IP // Next hop, nil for routes without a gateway
+	Metric    int    // Lower metrics are preferred
+}
+
+// DefaultRoutes returns the IPv4 default routes of the host, preferred route first.
+// It reads /proc/net/route on Linux and returns ErrRoutesUnsupported elsewhere.
+func DefaultRoutes() ([]Route, error) {
+	return defaultRoutes()
+}
+
+// rtfUp and rtfGateway are the route flags from linux/route.h.
+const (
+	rtfUp      = 0x1
+	rtfGateway = 0x2
+)
+
+// parseProcNetRoute parses the default routes from the /proc/net/route format:
+//
+//	Iface  Destination  Gateway   Flags  RefCnt  Use  Metric  Mask      MTU  Window  IRTT
+//	eth0   00000000     0101A8C0  0003   0       0    100     00000000  0    0       0
+//
+// Addresses are hex encoded in host byte order.
+func parseProcNetRoute(r io.Reader) ([]Route, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return nil, errors.New("empty routing table")
+	}
+
+	var routes []Route
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+
+		route := Route{Interface: fields[0], Metric: metric}
+		if flags&rtfGateway != 0 {
+			gw, err := strconv.ParseUint(fields[2], 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gateway %q: %w", fields[2], err)
+			}
+			route.Gateway = make(net.IP, net.IPv4len)
+			binary.NativeEndian.PutUint32(route.Gateway, uint32(gw))
+		}
+		routes = append(routes, route)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Metric < routes[j].Metric })
+	return routes, nil
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package discovery
+
+import (
+	"fmt"
+	"os"
+)
+
+// procNetRoute is the kernel IPv4 routing table,
+// see https://man7.org/linux/man-pages/man5/proc_pid_net.5.html.
+const procNetRoute = "/proc/net/route"
+
+func defaultRoutes() ([]Route, error) {
+	f, err := os.Open(procNetRoute)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", procNetRoute, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return parseProcNetRoute(f)
+}
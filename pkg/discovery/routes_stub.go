@@ -0,0 +1,8 @@
+//go:build !linux
+
+package discovery
+
+// defaultRoutes is not implemented on non-Linux platforms yet.
+func defaultRoutes() ([]Route, error) {
+	return nil, ErrRoutesUnsupported
+}
@@ -0,0 +1,34 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcNetRoute(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlan0	00000000	FE01A8C0	0003	0	0	600	00000000	0	0	0
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+docker0	000011AC	00000000	0001	0	0	0	0000FFFF	0	0	0
+tun0	00000000	00000000	0001	0	0	700	00000000	0	0	0
+down0	00000000	0101A8C0	0002	0	0	0	00000000	0	0	0
+`
+	routes, err := parseProcNetRoute(strings.NewReader(table))
+	require.NoError(t, err)
+	require.Len(t, routes, 3)
+
+	assert.Equal(t, "eth0", routes[0].Interface)
+	assert.Equal(t, "192.168.1.1", routes[0].Gateway.String())
+	assert.Equal(t, 100, routes[0].Metric)
+	assert.Equal(t, "wlan0", routes[1].Interface)
+	assert.Equal(t, "192.168.1.254", routes[1].Gateway.String())
+	assert.Equal(t, "tun0", routes[2].Interface)
+	assert.Nil(t, routes[2].Gateway, "route without a gateway")
+
+	_, err = parseProcNetRoute(strings.NewReader(""))
+	assert.Error(t, err)
+}
@@ -0,0 +1,420 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// predicate reports whether a device matches a compiled expression.
+type predicate func(d *discovery.Device) bool
+
+// compile parses a match expression, see the package documentation for the syntax.
+func compile(expr string) (predicate, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	pred, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at offset %d", t, t.pos)
+	}
+	return pred, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// keyword reports whether t is the (case-insensitive) keyword kw.
+func (t token) keyword(kw string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, kw)
+}
+
+// operators are matched longest first.
+var operators = []string{"==", "!=", "!~", "&&", "||", "~", "!"}
+
+const punctuation = "()[],'\"=!~&|"
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokLBracket, "[", i})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokRBracket, "]", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, token{tokString, s[i+1 : i+1+end], i})
+			i += end + 2
+		case strings.IndexByte(punctuation, c) >= 0:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(s) && !unicode.IsSpace(rune(s[i])) && strings.IndexByte(punctuation, s[i]) < 0 {
+				i++
+			}
+			tokens = append(tokens, token{tokWord, s[start:i], start})
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.keyword("or") || (t.kind == tokOp && t.text == "||"); t = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(d *discovery.Device) bool { return l(d) || right(d) }
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (predicate, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.keyword("and") || (t.kind == tokOp && t.text == "&&"); t = p.peek() {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(d *discovery.Device) bool { return l(d) && right(d) }
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (predicate, error) {
+	if t := p.peek(); t.keyword("not") || (t.kind == tokOp && t.text == "!") {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(d *discovery.Device) bool { return !inner(d) }, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, fmt.Errorf("expected \")\" at offset %d, got %s", t.pos, t)
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (predicate, error) {
+	ft := p.next()
+	if ft.kind != tokWord {
+		return nil, fmt.Errorf("expected a field at offset %d, got %s", ft.pos, ft)
+	}
+	f, err := lookupField(strings.ToLower(ft.text))
+	if err != nil {
+		return nil, fmt.Errorf("%w at offset %d", err, ft.pos)
+	}
+
+	ot := p.next()
+	op := strings.ToLower(ot.text)
+	if ot.kind != tokOp && ot.kind != tokWord {
+		return nil, fmt.Errorf("expected an operator after %s at offset %d, got %s", ft, ot.pos, ot)
+	}
+
+	values, err := p.parseValues(op == "in")
+	if err != nil {
+		return nil, err
+	}
+
+	pred, err := f.compare(op, values)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s at offset %d: %w", ft.text, ot.text, ot.pos, err)
+	}
+	return pred, nil
+}
+
+// parseValues parses a single value, or a bracketed list of values when list is set.
+func (p *parser) parseValues(list bool) ([]string, error) {
+	if !list || p.peek().kind != tokLBracket {
+		t := p.next()
+		if t.kind != tokWord && t.kind != tokString {
+			return nil, fmt.Errorf("expected a value at offset %d, got %s", t.pos, t)
+		}
+		return []string{t.text}, nil
+	}
+	p.next()
+	var values []string
+	for {
+		t := p.next()
+		if t.kind != tokWord && t.kind != tokString {
+			return nil, fmt.Errorf("expected a value at offset %d, got %s", t.pos, t)
+		}
+		values = append(values, t.text)
+		switch t := p.next(); t.kind {
+		case tokComma:
+		case tokRBracket:
+			return values, nil
+		default:
+			return nil, fmt.Errorf("expected \",\" or \"]\" at offset %d, got %s", t.pos, t)
+		}
+	}
+}
+
+// field is a device property that can be used in comparisons.
+type field interface {
+	compare(op string, values []string) (predicate, error)
+}
+
+// textField is a single-valued field, compared case-insensitively.
+type textField func(d *discovery.Device) string
+
+// listField is a multi-valued field, only supporting "has".
+type listField func(d *discovery.Device) []string
+
+// portField is a list of open port numbers, only supporting "has".
+type portField func(d *discovery.Device) []int
+
+var fields = map[string]field{
+	"mac":          textField(func(d *discovery.Device) string { return d.MAC() }),
+	"name":         textField(func(d *discovery.Device) string { return d.DisplayName() }),
+	"manufacturer": textField(func(d *discovery.Device) string { return d.Manufacturer() }),
+	"category":     textField(func(d *discovery.Device) string { return d.Category() }),
+	"label":        textField(func(d *discovery.Device) string { return d.ExtraData()[LabelKey] }),
+	"sources":      listField(sourcesOf),
+	"tags":         listField(func(d *discovery.Device) []string { return d.Tags() }),
+	"tcp":          portField(func(d *discovery.Device) []int { return d.OpenPorts()["tcp"] }),
+	"udp":          portField(func(d *discovery.Device) []int { return d.OpenPorts()["udp"] }),
+	"ports": portField(func(d *discovery.Device) []int {
+		open := d.OpenPorts()
+		return append(open["tcp"], open["udp"]...)
+	}),
+}
+
+func lookupField(name string) (field, error) {
+	if name == "ip" {
+		return ipField{}, nil
+	}
+	if key, ok := strings.CutPrefix(name, "extra."); ok && key != "" {
+		return textField(func(d *discovery.Device) string { return d.ExtraData()[key] }), nil
+	}
+	if f, ok := fields[name]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown field %q", name)
+}
+
+func sourcesOf(d *discovery.Device) []string {
+	sources := d.Sources()
+	out := make([]string, 0, len(sources))
+	for s := range sources {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (f textField) compare(op string, values []string) (predicate, error) {
+	v := values[0]
+	switch op {
+	case "==":
+		return func(d *discovery.Device) bool { return strings.EqualFold(f(d), v) }, nil
+	case "!=":
+		return func(d *discovery.Device) bool { return !strings.EqualFold(f(d), v) }, nil
+	case "~", "!~":
+		re, err := regexp.Compile("(?i)" + v)
+		if err != nil {
+			return nil, err
+		}
+		want := op == "~"
+		return func(d *discovery.Device) bool { return re.MatchString(f(d)) == want }, nil
+	case "startswith":
+		prefix := strings.ToLower(v)
+		return func(d *discovery.Device) bool { return strings.HasPrefix(strings.ToLower(f(d)), prefix) }, nil
+	case "in":
+		return func(d *discovery.Device) bool {
+			got := f(d)
+			for _, v := range values {
+				if strings.EqualFold(got, v) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, errors.New("unsupported operator, use ==, !=, ~, !~, startswith or in")
+}
+
+func (f listField) compare(op string, values []string) (predicate, error) {
+	if op != "has" {
+		return nil, errors.New("unsupported operator, use has")
+	}
+	v := values[0]
+	return func(d *discovery.Device) bool {
+		for _, got := range f(d) {
+			if strings.EqualFold(got, v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func (f portField) compare(op string, values []string) (predicate, error) {
+	if op != "has" {
+		return nil, errors.New("unsupported operator, use has")
+	}
+	port, err := strconv.Atoi(values[0])
+	if err != nil || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %q", values[0])
+	}
+	return func(d *discovery.Device) bool {
+		for _, p := range f(d) {
+			if p == port {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// ipField is the device IP, matching addresses and CIDR prefixes with "in".
+type ipField struct{}
+
+func ipOf(d *discovery.Device) (netip.Addr, bool) {
+	ip := d.IP()
+	if ip == nil {
+		return netip.Addr{}, false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	return addr.Unmap(), ok
+}
+
+func (ipField) compare(op string, values []string) (predicate, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		if p, err := netip.ParsePrefix(v); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR %q", v)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	contains := func(d *discovery.Device) bool {
+		addr, ok := ipOf(d)
+		if !ok {
+			return false
+		}
+		for _, p := range prefixes {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch op {
+	case "==", "in":
+		if op == "==" && prefixes[0].Bits() != prefixes[0].Addr().BitLen() {
+			return nil, errors.New("use in to match a CIDR")
+		}
+		return contains, nil
+	case "!=":
+		return func(d *discovery.Device) bool { return !contains(d) }, nil
+	}
+	return nil, errors.New("unsupported operator, use ==, != or in")
+}
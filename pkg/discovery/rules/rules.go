@@ -0,0 +1,159 @@
+// Package rules evaluates user-defined rules against discovered devices. A rule
+// combines a match expression with actions: set a label or category, add tags
+// and raise an alert.
+//
+// Match expressions compare device fields with values, combined with and, or,
+// not and parentheses:
+//
+//	manufacturer ~ "Espressif" and tcp has 23
+//	name ~ "^cam-" or extra.mdns_service == "_rtsp._tcp"
+//	ip in [10.0.0.0/8, 192.168.1.5] and not sources has mdns
+//
+// Fields are ip, mac, name, manufacturer, category, label and extra.KEY, which
+// support == and != (case-insensitive), ~ and !~ (case-insensitive regular
+// expression), startswith and in (a value or a [list] of values; CIDRs for ip),
+// and the lists sources, tags, tcp, udp and ports (open TCP and UDP ports), which
+// support has. Values are bare words or quoted strings.
+//
+// Example:
+//
+//	set, err := rules.New([]rules.Rule{{
+//	    Name:  "telnet-on-iot",
+//	    Match: `manufacturer ~ "Espressif" and tcp has 23`,
+//	    Tags:  []string{"risk"},
+//	    Alert: "IoT device with telnet open",
+//	}})
+//	engine, err := discovery.NewEngine(discovery.WithRules(set), ...)
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/classify"
+)
+
+// LabelKey is the extra data key holding the label of a device.
+const LabelKey = "label"
+
+var _ discovery.RuleEvaluator = (*Set)(nil)
+
+var errNoActions = errors.New("at least one of label, category, tags or alert is required")
+
+// Rule matches devices with an expression and applies its actions to them.
+type Rule struct {
+	Name     string   `yaml:"name"`
+	Match    string   `yaml:"match"`
+	Label    string   `yaml:"label"`
+	Category string   `yaml:"category"`
+	Tags     []string `yaml:"tags"`
+	Alert    string   `yaml:"alert"`
+
+	match    predicate
+	category classify.Category
+}
+
+// Set is a compiled, ordered list of rules.
+type Set struct {
+	rules []Rule
+
+	mu      sync.Mutex
+	alerted map[string]struct{}
+}
+
+// New compiles rules. Every rule needs a unique name, a match expression and at
+// least one action; categories are names accepted by classify.Parse.
+func New(rules []Rule) (*Set, error) {
+	s := &Set{
+		rules:   make([]Rule, 0, len(rules)),
+		alerted: make(map[string]struct{}),
+	}
+	names := make(map[string]bool, len(rules))
+	for i, r := range rules {
+		r.Name = strings.TrimSpace(r.Name)
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rule %q: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+
+		if strings.TrimSpace(r.Match) == "" {
+			return nil, fmt.Errorf("rule %q: match is required", r.Name)
+		}
+		pred, err := compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: match: %w", r.Name, err)
+		}
+		r.match = pred
+
+		if r.Category != "" {
+			c, ok := classify.Parse(r.Category)
+			if !ok {
+				return nil, fmt.Errorf("rule %q: unknown category %q", r.Name, r.Category)
+			}
+			r.category = c
+		}
+		if r.Label == "" && r.category == classify.Unknown && len(r.Tags) == 0 && r.Alert == "" {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, errNoActions)
+		}
+		s.rules = append(s.rules, r)
+	}
+	return s, nil
+}
+
+// Len returns the number of rules.
+func (s *Set) Len() int {
+	return len(s.rules)
+}
+
+// Evaluate applies the actions of every rule matching d, in order. A label is
+// only set on devices without one, so labels set by hand are kept, and the first
+// matching rule wins the label and category. An alert is raised once per rule
+// and device IP for the lifetime of the set, however often the device is evaluated.
+func (s *Set) Evaluate(d *discovery.Device) []discovery.Alert {
+	if d == nil || d.IP() == nil {
+		return nil
+	}
+	labelSet := d.ExtraData()[LabelKey] != ""
+	categorySet := false
+
+	var alerts []discovery.Alert
+	for i := range s.rules {
+		r := &s.rules[i]
+		if !r.match(d) {
+			continue
+		}
+		if r.Label != "" && !labelSet {
+			d.AddExtraData(LabelKey, r.Label)
+			labelSet = true
+		}
+		if r.category != classify.Unknown && !categorySet {
+			d.SetCategory(string(r.category), 1)
+			categorySet = true
+		}
+		for _, tag := range r.Tags {
+			d.AddTag(tag)
+		}
+		if r.Alert != "" && s.firstAlert(r.Name, d) {
+			alerts = append(alerts, discovery.Alert{Rule: r.Name, Message: r.Alert, Device: d})
+		}
+	}
+	return alerts
+}
+
+// firstAlert records an alert of rule for d and reports whether it is the first one.
+func (s *Set) firstAlert(rule string, d *discovery.Device) bool {
+	key := rule + "|" + d.IP().String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.alerted[key]; ok {
+		return false
+	}
+	s.alerted[key] = struct{}{}
+	return true
+}
@@ -0,0 +1,156 @@
+package rules
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/ramonvermeulen/whosthere/pkg/discovery/scanners/mdns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDevice() *discovery.Device {
+	d := discovery.NewDevice(net.ParseIP("192.168.1.42"))
+	d.SetMAC("b8:27:eb:12:34:56")
+	d.SetDisplayName("cam-frontdoor")
+	d.SetManufacturer("Espressif Inc.")
+	d.AddSource("arp")
+	d.AddSource("mdns")
+	d.AddExtraData(mdns.ServiceTypeKey, "_rtsp._tcp")
+	d.AddTag("outdoor")
+	d.AddPortResult(discovery.PortResult{Port: 23, Protocol: "tcp", State: discovery.PortOpen})
+	d.AddPortResult(discovery.PortResult{Port: 161, Protocol: "udp", State: discovery.PortOpen})
+	return d
+}
+
+func TestCompile_Matches(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`manufacturer ~ 'Espressif' and tcp has 23`, true},
+		{`manufacturer ~ "^espressif"`, true},
+		{`manufacturer !~ espressif`, false},
+		{`name ~ "^cam-"`, true},
+		{`name == CAM-FRONTDOOR`, true},
+		{`name != cam-frontdoor`, false},
+		{`mac startswith "B8:27:EB"`, true},
+		{`mac startswith aa:bb`, false},
+		{`ip in 192.168.1.0/24`, true},
+		{`ip in [10.0.0.0/8, 192.168.1.42]`, true},
+		{`ip in [10.0.0.0/8]`, false},
+		{`ip == 192.168.1.42`, true},
+		{`ip != 192.168.1.42`, false},
+		{`sources has mdns`, true},
+		{`sources has ssdp`, false},
+		{`tags has OUTDOOR`, true},
+		{`udp has 161`, true},
+		{`tcp has 161`, false},
+		{`ports has 161 && ports has 23`, true},
+		{`extra.mdns_service == "_rtsp._tcp"`, true},
+		{`extra.missing == ""`, true},
+		{`category in [printer, camera]`, false},
+		{`not sources has ssdp`, true},
+		{`!(tcp has 22 || udp has 53)`, true},
+		{`tcp has 22 or tcp has 23 and name ~ cam`, true},
+		{`(tcp has 22 or tcp has 80) and name ~ cam`, false},
+	}
+	d := newDevice()
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			pred, err := compile(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, pred(d))
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`vendor == x`,
+		`name`,
+		`name ==`,
+		`name = x`,
+		`name ~ "("`,
+		`name has x`,
+		`tcp == 23`,
+		`tcp has http`,
+		`tcp has 70000`,
+		`ip in 10.0.0.0/33`,
+		`ip == 10.0.0.0/8`,
+		`ip ~ 10`,
+		`name == "unterminated`,
+		`(name == x`,
+		`name == x)`,
+		`ip in [10.0.0.1,`,
+		`name == x and`,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := compile(expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	valid := Rule{Name: "r", Match: "name ~ x", Tags: []string{"t"}}
+
+	_, err := New([]Rule{valid})
+	require.NoError(t, err)
+
+	for name, rules := range map[string][]Rule{
+		"missing name":     {{Match: "name ~ x", Tags: []string{"t"}}},
+		"duplicate name":   {valid, valid},
+		"missing match":    {{Name: "r", Tags: []string{"t"}}},
+		"invalid match":    {{Name: "r", Match: "name ~", Tags: []string{"t"}}},
+		"unknown category": {{Name: "r", Match: "name ~ x", Category: "toaster"}},
+		"no actions":       {{Name: "r", Match: "name ~ x"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(rules)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSet_Evaluate(t *testing.T) {
+	set, err := New([]Rule{
+		{Name: "telnet", Match: `manufacturer ~ 'Espressif' and tcp has 23`, Tags: []string{"risk"}, Alert: "telnet open"},
+		{Name: "cameras", Match: `name ~ "^cam-"`, Label: "Front door", Category: "camera"},
+		{Name: "fallback", Match: `ip in 192.168.1.0/24`, Label: "LAN", Category: "iot", Tags: []string{"lan"}},
+		{Name: "printers", Match: `tcp has 9100`, Alert: "printer"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, set.Len())
+
+	d := newDevice()
+	alerts := set.Evaluate(d)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "telnet", alerts[0].Rule)
+	assert.Equal(t, "telnet open", alerts[0].Message)
+	assert.Same(t, d, alerts[0].Device)
+
+	assert.Equal(t, "Front door", d.ExtraData()[LabelKey], "first matching rule wins the label")
+	assert.Equal(t, "camera", d.Category(), "first matching rule wins the category")
+	assert.InDelta(t, 1.0, d.CategoryConfidence(), 0)
+	assert.Equal(t, []string{"lan", "outdoor", "risk"}, d.Tags())
+
+	assert.Empty(t, set.Evaluate(d), "alerts are raised once per rule and device")
+	assert.Len(t, set.Evaluate(discovery.NewDevice(net.ParseIP("192.168.1.43"))), 0)
+
+	other := newDevice()
+	other.SetIP(net.ParseIP("192.168.1.44"))
+	assert.Len(t, set.Evaluate(other), 1, "other devices still alert")
+}
+
+func TestSet_Evaluate_KeepsExistingLabel(t *testing.T) {
+	set, err := New([]Rule{{Name: "cameras", Match: `name ~ "^cam-"`, Label: "Camera"}})
+	require.NoError(t, err)
+
+	d := newDevice()
+	d.AddExtraData(LabelKey, "set by hand")
+	set.Evaluate(d)
+	assert.Equal(t, "set by hand", d.ExtraData()[LabelKey])
+}
@@ -102,7 +102,7 @@ type Entry struct {
 func (s *Scanner) emitARPEntries(ctx context.Context, out chan<- *discovery.Device, entries []Entry) error {
 	now := time.Now()
 
-	subnet := s.iface.IPv4Net
+	subnets := s.iface.Subnets()
 
 	for _, entry := range entries {
 		if entry.IP == nil || entry.MAC == nil {
@@ -116,9 +116,9 @@ func (s *Scanner) emitARPEntries(ctx context.Context, out chan<- *discovery.Devi
 		// Filter non-device addresses:
 		// - skip multicast MACs (I/G bit set)
 		// - skip broadcast MAC (FF:FF:FF:FF:FF:FF)
-		// - skip IPv4 broadcast address for our subnet
+		// - skip IPv4 broadcast addresses of our subnets
 		// - skip IPv4 multicast ranges (224.0.0.0/4)
-		if isMulticastMAC(entry.MAC) || isBroadcastMAC(entry.MAC) || isMulticastIPv4(entry.IP) || isBroadcastIPv4(entry.IP, subnet) {
+		if isMulticastMAC(entry.MAC) || isBroadcastMAC(entry.MAC) || isMulticastIPv4(entry.IP) || isSubnetBroadcast(entry.IP, subnets) {
 			continue
 		}
 
@@ -155,6 +155,16 @@ func isBroadcastMAC(mac net.HardwareAddr) bool {
 	return len(mac) == 6 && mac[0] == 0xFF && mac[1] == 0xFF && mac[2] == 0xFF && mac[3] == 0xFF && mac[4] == 0xFF && mac[5] == 0xFF
 }
 
+// isSubnetBroadcast checks if an IPv4 address is the broadcast address of any of subnets.
+func isSubnetBroadcast(ip net.IP, subnets []*net.IPNet) bool {
+	for _, subnet := range subnets {
+		if isBroadcastIPv4(ip, subnet) {
+			return true
+		}
+	}
+	return false
+}
+
 // isBroadcastIPv4 checks if an IPv4 address is a broadcast address for the given subnet.
 func isBroadcastIPv4(ip net.IP, subnet *net.IPNet) bool {
 	if ip == nil || subnet == nil {
@@ -1,10 +1,12 @@
 package arp
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
 	"github.com/ramonvermeulen/whosthere/pkg/discovery/internal/testkit"
 )
 
@@ -126,3 +128,41 @@ func TestWithPollInterval_SetsInterval(t *testing.T) {
 		t.Fatalf("expected pollInterval %s, got %s", interval, s.pollInterval)
 	}
 }
+
+func TestEmitARPEntries_SkipsBroadcastOfEverySubnet(t *testing.T) {
+	ip := net.ParseIP("192.168.1.10").To4()
+	primary := &net.IPNet{IP: ip, Mask: net.CIDRMask(24, 32)}
+	secondary := &net.IPNet{IP: net.ParseIP("10.0.0.1").To4(), Mask: net.CIDRMask(24, 32)}
+	iface := &discovery.InterfaceInfo{
+		Interface: &net.Interface{Name: "test0"},
+		IPv4Addr:  &ip,
+		IPv4Net:   primary,
+		IPv4Nets:  []*net.IPNet{primary, secondary},
+	}
+	s, err := New(iface)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	entries := []Entry{
+		{IP: net.ParseIP("192.168.1.255"), MAC: mac, InterfaceName: "test0"},
+		{IP: net.ParseIP("10.0.0.255"), MAC: mac, InterfaceName: "test0"},
+		{IP: net.ParseIP("192.168.1.20"), MAC: mac, InterfaceName: "test0"},
+		{IP: net.ParseIP("10.0.0.20"), MAC: mac, InterfaceName: "test0"},
+	}
+	out := make(chan *discovery.Device, len(entries))
+	if err := s.emitARPEntries(context.Background(), out, entries); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(out)
+
+	var got []string
+	for d := range out {
+		got = append(got, d.IP().String())
+	}
+	want := []string{"192.168.1.20", "10.0.0.20"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("emitted %v, want %v", got, want)
+	}
+}
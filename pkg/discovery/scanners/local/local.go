@@ -0,0 +1,149 @@
+package local
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// defaultResolvConf is the resolver configuration listing the DNS servers of the host.
+const defaultResolvConf = "/etc/resolv.conf"
+
+var _ discovery.Scanner = (*Scanner)(nil)
+
+// Scanner reports the devices known from the host's own network configuration:
+// the host itself on each of its addresses (RoleSelf), its default gateways from
+// the routing table (RoleGateway) and the DNS servers from resolv.conf (RoleDNS).
+// It doesn't send any packets. Gateways and DNS servers outside the subnets of the interface,
+// e.g. public resolvers, are skipped since they are not part of the local network.
+type Scanner struct {
+	iface  *discovery.InterfaceInfo
+	logger discovery.Logger
+
+	resolvConf string
+	routes     func() ([]discovery.Route, error)
+	hostname   func() (string, error)
+}
+
+// New creates a scanner for the host's own configuration on the specified network interface.
+func New(iface *discovery.InterfaceInfo, opts ...Option) (*Scanner, error) {
+	s := &Scanner{
+		iface:      iface,
+		logger:     discovery.NoOpLogger{},
+		resolvConf: defaultResolvConf,
+		routes:     discovery.DefaultRoutes,
+		hostname:   os.Hostname,
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Name returns the scanner name for engine compatibility.
+func (s *Scanner) Name() string {
+	return "local"
+}
+
+// Scan emits the host, its gateways and DNS servers once and returns.
+func (s *Scanner) Scan(ctx context.Context, out chan<- *discovery.Device) error {
+	for _, d := range s.devices(ctx) {
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- d:
+		}
+	}
+	return nil
+}
+
+func (s *Scanner) devices(ctx context.Context) []*discovery.Device {
+	if s.iface == nil || s.iface.IPv4Addr == nil {
+		return nil
+	}
+
+	name, nameErr := s.hostname()
+	var devices []*discovery.Device
+	for _, subnet := range s.iface.Subnets() {
+		self := s.newDevice(subnet.IP.To4(), discovery.RoleSelf)
+		if s.iface.Interface != nil && len(s.iface.Interface.HardwareAddr) > 0 {
+			self.SetMAC(s.iface.Interface.HardwareAddr.String())
+		}
+		if nameErr == nil {
+			self.SetDisplayName(name)
+		}
+		devices = append(devices, self)
+	}
+
+	routes, err := s.routes()
+	if err != nil && !errors.Is(err, discovery.ErrRoutesUnsupported) {
+		s.logger.Log(ctx, slog.LevelDebug, "failed to read routing table", "error", err)
+	}
+	for _, r := range routes {
+		if s.iface.Interface != nil && r.Interface != s.iface.Interface.Name {
+			continue
+		}
+		if s.local(r.Gateway) {
+			devices = append(devices, s.newDevice(r.Gateway, discovery.RoleGateway))
+		}
+	}
+
+	servers, err := nameservers(s.resolvConf)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.logger.Log(ctx, slog.LevelDebug, "failed to read resolver configuration", "error", err)
+	}
+	for _, ip := range servers {
+		if s.local(ip) {
+			devices = append(devices, s.newDevice(ip, discovery.RoleDNS))
+		}
+	}
+	return devices
+}
+
+func (s *Scanner) newDevice(ip net.IP, role string) *discovery.Device {
+	d := discovery.NewDevice(ip)
+	d.AddRole(role)
+	d.AddSource(s.Name())
+	return d
+}
+
+// local reports whether ip is in one of the subnets of the interface.
+func (s *Scanner) local(ip net.IP) bool {
+	return s.iface.SubnetOf(ip) != nil
+}
+
+// nameservers returns the IPv4 addresses of the nameserver lines in a resolv.conf file.
+func nameservers(path string) ([]net.IP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var servers []net.IP
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		if ip := net.ParseIP(fields[1]); ip != nil && ip.To4() != nil {
+			servers = append(servers, ip.To4())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return servers, nil
+}
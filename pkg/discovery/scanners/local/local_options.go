@@ -0,0 +1,34 @@
+package local
+
+import (
+	"errors"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+)
+
+// Option is a functional option for configuring the local Scanner.
+type Option func(*Scanner) error
+
+// WithLogger sets a custom logger for the local scanner.
+func WithLogger(logger discovery.Logger) Option {
+	return func(s *Scanner) error {
+		if logger == nil {
+			return errors.New("logger cannot be nil")
+		}
+		s.logger = logger
+		return nil
+	}
+}
+
+// WithResolvConf sets the resolver configuration file the DNS servers are read from.
+//
+// Default: /etc/resolv.conf
+func WithResolvConf(path string) Option {
+	return func(s *Scanner) error {
+		if path == "" {
+			return errors.New("resolv.conf path cannot be empty")
+		}
+		s.resolvConf = path
+		return nil
+	}
+}
@@ -0,0 +1,120 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ramonvermeulen/whosthere/pkg/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testInterface(t *testing.T) *discovery.InterfaceInfo {
+	t.Helper()
+	ip, ipNet, err := net.ParseCIDR("192.168.1.42/24")
+	require.NoError(t, err)
+	mac, err := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	require.NoError(t, err)
+	return &discovery.InterfaceInfo{
+		Interface: &net.Interface{Name: "eth0", HardwareAddr: mac},
+		IPv4Addr:  &ip,
+		IPv4Net:   ipNet,
+	}
+}
+
+func writeResolvConf(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestScanner_Scan(t *testing.T) {
+	resolvConf := writeResolvConf(t, `# generated
+search lan
+nameserver 192.168.1.1
+nameserver 192.168.1.53
+nameserver 1.1.1.1
+nameserver fe80::1
+`)
+	s, err := New(testInterface(t), WithResolvConf(resolvConf))
+	require.NoError(t, err)
+	s.hostname = func() (string, error) { return "workstation", nil }
+	s.routes = func() ([]discovery.Route, error) {
+		return []discovery.Route{
+			{Interface: "eth0", Gateway: net.ParseIP("192.168.1.1")},
+			{Interface: "docker0", Gateway: net.ParseIP("172.17.0.1")},
+			{Interface: "eth0", Gateway: net.ParseIP("10.0.0.1")},
+		}, nil
+	}
+
+	out := make(chan *discovery.Device, 10)
+	require.NoError(t, s.Scan(context.Background(), out))
+	close(out)
+
+	var got []string
+	for d := range out {
+		assert.Contains(t, d.Sources(), "local")
+		for _, role := range d.Roles() {
+			got = append(got, d.IP().String()+" "+role)
+		}
+		if d.HasRole(discovery.RoleSelf) {
+			assert.Equal(t, "aa:bb:cc:dd:ee:ff", d.MAC())
+			assert.Equal(t, "workstation", d.DisplayName())
+		}
+	}
+	assert.Equal(t, []string{
+		"192.168.1.42 self",
+		"192.168.1.1 gateway",
+		"192.168.1.1 dns",
+		"192.168.1.53 dns",
+	}, got)
+}
+
+func TestScanner_Scan_WithoutRoutesOrResolvConf(t *testing.T) {
+	s, err := New(testInterface(t), WithResolvConf(filepath.Join(t.TempDir(), "missing")))
+	require.NoError(t, err)
+	s.routes = func() ([]discovery.Route, error) { return nil, errors.New("boom") }
+
+	out := make(chan *discovery.Device, 10)
+	require.NoError(t, s.Scan(context.Background(), out))
+	close(out)
+
+	require.Len(t, out, 1)
+	assert.True(t, (<-out).HasRole(discovery.RoleSelf))
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	_, err := New(testInterface(t), WithLogger(nil))
+	assert.Error(t, err)
+	_, err = New(testInterface(t), WithResolvConf(""))
+	assert.Error(t, err)
+}
+
+func TestScanner_Scan_SecondarySubnet(t *testing.T) {
+	iface := testInterface(t)
+	_, secondary, err := net.ParseCIDR("10.0.0.0/24")
+	require.NoError(t, err)
+	secondary.IP = net.ParseIP("10.0.0.42")
+	iface.IPv4Nets = []*net.IPNet{{IP: *iface.IPv4Addr, Mask: iface.IPv4Net.Mask}, secondary}
+
+	s, err := New(iface, WithResolvConf(filepath.Join(t.TempDir(), "missing")))
+	require.NoError(t, err)
+	s.routes = func() ([]discovery.Route, error) {
+		return []discovery.Route{{Interface: "eth0", Gateway: net.ParseIP("10.0.0.1")}}, nil
+	}
+
+	out := make(chan *discovery.Device, 10)
+	require.NoError(t, s.Scan(context.Background(), out))
+	close(out)
+
+	var got []string
+	for d := range out {
+		got = append(got, d.IP().String()+" "+d.Roles()[0])
+	}
+	assert.Equal(t, []string{"192.168.1.42 self", "10.0.0.42 self", "10.0.0.1 gateway"}, got)
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"log/slog"
+	"regexp"
 	"strings"
 	"time"
 
@@ -14,8 +15,15 @@ import (
 
 const (
 	serviceDiscoveryQuery = "_services._dns-sd._udp"
+
+	// ServiceTypeKey is the extra data key holding the DNS-SD service type a
+	// device was discovered with, e.g. "_ipp._tcp".
+	ServiceTypeKey = "mdns_service"
 )
 
+// serviceTypeRE matches a DNS-SD service type within an instance or service name.
+var serviceTypeRE = regexp.MustCompile(`(?i)(_[a-z0-9-]+\._(?:tcp|udp))(?:\.|$)`)
+
 var _ discovery.Scanner = (*Scanner)(nil)
 
 // Scanner implements the discovery.Scanner interface using hashicorp/mdns
@@ -85,6 +93,9 @@ func (s *Scanner) Scan(ctx context.Context, results chan<- *discovery.Device) er
 			dev := discovery.NewDevice(entry.AddrV4)
 			dev.SetDisplayName(entry.Name)
 			dev.AddSource("mdns")
+			if host := hostname(entry.Host); host != "" {
+				dev.AddExtraData(discovery.HostnameKey, host)
+			}
 			if entry.Info != "" {
 				fields := entry.InfoFields
 				for _, f := range fields {
@@ -95,6 +106,9 @@ func (s *Scanner) Scan(ctx context.Context, results chan<- *discovery.Device) er
 					}
 				}
 			}
+			if svc := serviceType(entry.Name); svc != "" {
+				dev.AddExtraData(ServiceTypeKey, svc)
+			}
 
 			s.logger.Log(ctx, slog.LevelDebug, "discovered device via mDNS", "name", entry.Name, "ip", entry.AddrV4.String())
 
@@ -110,6 +124,24 @@ func (s *Scanner) Scan(ctx context.Context, results chan<- *discovery.Device) er
 	}
 }
 
+// serviceType extracts the service type from a name such as
+// "Office._ipp._tcp.local.", or returns "" when there is none.
+func serviceType(name string) string {
+	if m := serviceTypeRE.FindStringSubmatch(name); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// hostname returns the mDNS host name without the trailing dot and ".local" suffix.
+func hostname(host string) string {
+	host = strings.TrimSuffix(host, ".")
+	if len(host) > len(".local") && strings.EqualFold(host[len(host)-len(".local"):], ".local") {
+		host = host[:len(host)-len(".local")]
+	}
+	return host
+}
+
 // splitKeyValue splits a string like "key=value" and returns [key, value], or nil if not present.
 func splitKeyValue(s string) []string {
 	parts := strings.SplitN(s, "=", 2)
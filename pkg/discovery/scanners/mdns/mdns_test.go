@@ -46,6 +46,31 @@ type testLogger struct{}
 
 func (testLogger) Log(_ context.Context, _ slog.Level, _ string, _ ...any) {}
 
+func Test_serviceType(t *testing.T) {
+	tests := map[string]string{
+		"Office Printer._ipp._tcp.local.": "_ipp._tcp",
+		"_googlecast._tcp.local.":         "_googlecast._tcp",
+		"Living Room._Sonos._TCP.local":   "_sonos._tcp",
+		"testdev":                         "",
+	}
+	for in, want := range tests {
+		require.Equal(t, want, serviceType(in), in)
+	}
+}
+
+func Test_hostname(t *testing.T) {
+	tests := map[string]string{
+		"Ramons-iPhone.local.": "Ramons-iPhone",
+		"printer.LOCAL":        "printer",
+		"nas.example.com.":     "nas.example.com",
+		".local.":              ".local",
+		"":                     "",
+	}
+	for in, want := range tests {
+		require.Equal(t, want, hostname(in), in)
+	}
+}
+
 func Test_splitKeyValue(t *testing.T) {
 	tests := []struct {
 		in   string
@@ -119,6 +144,7 @@ func TestScan_EntryWithInfoFields(t *testing.T) {
 		params.Entries <- &hashimdns.ServiceEntry{
 			AddrV4:     net.ParseIP("1.2.3.4"),
 			Name:       "testdev",
+			Host:       "testdev.local.",
 			Info:       "foo=bar",
 			InfoFields: []string{"foo=bar", "baz"},
 		}
@@ -135,4 +161,5 @@ func TestScan_EntryWithInfoFields(t *testing.T) {
 	require.Equal(t, "testdev", dev.DisplayName())
 	require.Equal(t, "bar", dev.ExtraData()["foo"])
 	require.Equal(t, "true", dev.ExtraData()["baz"])
+	require.Equal(t, "testdev", dev.ExtraData()[discovery.HostnameKey])
 }
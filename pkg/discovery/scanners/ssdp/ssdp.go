@@ -20,6 +20,10 @@ const (
 	HeaderMan     = `"ssdp:discover"`
 	HeaderST      = "ssdp:all"
 	HeaderMX      = 2
+
+	// DeviceTypeKey is the extra data key holding the UPnP device or service
+	// type a device announced, e.g. "urn:schemas-upnp-org:device:MediaRenderer:1".
+	DeviceTypeKey = "ssdp_device_type"
 )
 
 var _ discovery.Scanner = (*Scanner)(nil)
@@ -128,7 +132,7 @@ func applyDeadlineFromContext(conn *net.UDPConn, ctx context.Context) error {
 
 // handlePacket parses the packet and emits a Device if an IP can be resolved.
 func handlePacket(out chan<- *discovery.Device, src *net.UDPAddr, payload []byte) {
-	loc, server := parseHeaders(payload)
+	loc, server, deviceType := parseHeaders(payload)
 	ip := ipFromAddr(src)
 	if ip == nil && loc != "" {
 		ip = ipFromLocation(loc)
@@ -145,14 +149,19 @@ func handlePacket(out chan<- *discovery.Device, src *net.UDPAddr, payload []byte
 	if server != "" {
 		d.AddExtraData("server", server)
 	}
+	if deviceType != "" {
+		d.AddExtraData(DeviceTypeKey, deviceType)
+	}
 	select {
 	case out <- d:
 	default:
 	}
 }
 
-// parseHeaders extracts LOCATION and SERVER using HTTP-like header parsing.
-func parseHeaders(b []byte) (location, server string) {
+// parseHeaders extracts LOCATION, SERVER and the device or service type from
+// ST (search responses) or NT (notifications) using HTTP-like header parsing.
+// Types other than "urn:..." (root device, UUIDs) are ignored.
+func parseHeaders(b []byte) (location, server, deviceType string) {
 	// Ensures the buffer ends with CRLFCRLF to satisfy textproto header reader
 	data := b
 	if !bytes.HasSuffix(data, []byte("\r\n\r\n")) {
@@ -164,10 +173,16 @@ func parseHeaders(b []byte) (location, server string) {
 	_, _ = tr.ReadLine()
 	hdr, err := tr.ReadMIMEHeader()
 	if err != nil {
-		return "", ""
+		return "", "", ""
 	}
 	location = strings.TrimSpace(hdr.Get("Location"))
 	server = strings.TrimSpace(hdr.Get("Server"))
+	for _, h := range []string{"St", "Nt"} {
+		if v := strings.TrimSpace(hdr.Get(h)); strings.HasPrefix(strings.ToLower(v), "urn:") {
+			deviceType = v
+			break
+		}
+	}
 	return
 }
 
@@ -34,14 +34,25 @@ func TestName(t *testing.T) {
 
 func TestParseHeaders_ExtractsLocationAndServer(t *testing.T) {
 	payload := []byte("HTTP/1.1 200 OK\r\nLOCATION: http://10.0.0.2:80/device.xml\r\nServer: test/1.0\r\n\r\n")
-	loc, server := parseHeaders(payload)
+	loc, server, deviceType := parseHeaders(payload)
 	require.Equal(t, "http://10.0.0.2:80/device.xml", loc)
 	require.Equal(t, "test/1.0", server)
+	require.Empty(t, deviceType)
+}
+
+func TestParseHeaders_ExtractsDeviceType(t *testing.T) {
+	payload := []byte("HTTP/1.1 200 OK\r\nST: urn:schemas-upnp-org:device:MediaRenderer:1\r\n\r\n")
+	_, _, deviceType := parseHeaders(payload)
+	require.Equal(t, "urn:schemas-upnp-org:device:MediaRenderer:1", deviceType)
+
+	payload = []byte("NOTIFY * HTTP/1.1\r\nNT: upnp:rootdevice\r\n\r\n")
+	_, _, deviceType = parseHeaders(payload)
+	require.Empty(t, deviceType)
 }
 
 func TestParseHeaders_AppendsTerminatorIfMissing(t *testing.T) {
 	payload := []byte("HTTP/1.1 200 OK\r\nLocation: http://10.0.0.2/device.xml\r\nServer: test\r\n")
-	loc, server := parseHeaders(payload)
+	loc, server, _ := parseHeaders(payload)
 	require.Equal(t, "http://10.0.0.2/device.xml", loc)
 	require.Equal(t, "test", server)
 }
@@ -0,0 +1,183 @@
+// Package services maps port numbers to IANA service names (ssh, http,
+// microsoft-ds, ...), based on an embedded export of the IANA Service Name and
+// Transport Protocol Port Number Registry, trimmed to the named TCP and UDP
+// ports. User overrides can be layered on top for in-house services and ports
+// the registry does not name.
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// generated from https://www.iana.org/assignments/service-names-port-numbers/service-names-port-numbers.csv
+// by "make update-services", which keeps the first three columns of the named TCP and UDP ports
+//
+//go:embed service-names.csv
+var embeddedRegistry []byte
+
+// key identifies a port of a transport protocol.
+type key struct {
+	port  int
+	proto string
+}
+
+// Registry resolves port numbers to service names.
+//
+// Thread-safe for concurrent lookups.
+type Registry struct {
+	names map[key]string
+}
+
+// Option configures a Registry during construction.
+type Option func(*Registry) error
+
+// WithOverrides adds service names that take precedence over the IANA names.
+// Keys are "port/proto" (e.g. "8081/tcp") or a bare port, which applies to
+// both TCP and UDP. A "port/proto" key wins over a bare port for the same port.
+func WithOverrides(overrides map[string]string) Option {
+	return func(r *Registry) error {
+		specific := make(map[key]string, len(overrides))
+		for k, name := range overrides {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return fmt.Errorf("service name for %q cannot be empty", k)
+			}
+			port, proto, err := ParseKey(k)
+			if err != nil {
+				return err
+			}
+			if proto != "" {
+				specific[key{port: port, proto: proto}] = name
+				continue
+			}
+			r.names[key{port: port, proto: "tcp"}] = name
+			r.names[key{port: port, proto: "udp"}] = name
+		}
+		for k, name := range specific {
+			r.names[k] = name
+		}
+		return nil
+	}
+}
+
+// New creates a Registry from the embedded IANA names and applies opts.
+func New(opts ...Option) (*Registry, error) {
+	names, err := parse(bytes.NewReader(embeddedRegistry))
+	if err != nil {
+		return nil, err
+	}
+	r := &Registry{names: names}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultRegistry *Registry
+)
+
+// Default returns the shared Registry with only the embedded IANA names.
+func Default() *Registry {
+	defaultOnce.Do(func() {
+		r, err := New()
+		if err != nil {
+			// the embedded registry is validated by tests, fall back to an empty one
+			r = &Registry{names: map[key]string{}}
+		}
+		defaultRegistry = r
+	})
+	return defaultRegistry
+}
+
+// Name returns the service name for port on proto ("tcp" or "udp"), or ""
+// when the port has no registered service.
+func (r *Registry) Name(port int, proto string) string {
+	if r == nil {
+		return ""
+	}
+	return r.names[key{port: port, proto: strings.ToLower(proto)}]
+}
+
+// ServiceName implements discovery.ServiceNamer.
+func (r *Registry) ServiceName(port int, proto string) string {
+	return r.Name(port, proto)
+}
+
+// Name looks up port on proto in the Default registry.
+func Name(port int, proto string) string {
+	return Default().Name(port, proto)
+}
+
+// ParseKey parses an override key, either "port/proto" or a bare port. For a
+// bare port the returned proto is empty.
+func ParseKey(s string) (port int, proto string, err error) {
+	s = strings.TrimSpace(s)
+	portStr := s
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		portStr, proto = s[:i], strings.ToLower(s[i+1:])
+		if proto != "tcp" && proto != "udp" {
+			return 0, "", fmt.Errorf("invalid service key %q: protocol must be tcp or udp", s)
+		}
+	}
+	port, err = strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, "", fmt.Errorf("invalid service key %q: port must be between 1 and 65535", s)
+	}
+	return port, proto, nil
+}
+
+// parse reads an IANA style CSV with the columns
+// Service Name, Port Number, Transport Protocol[, ...].
+// Rows without a service name, with port ranges or other protocols are skipped.
+func parse(rd io.Reader) (map[key]string, error) {
+	cr := csv.NewReader(rd)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read service names header: %w", err)
+	}
+	if len(header) < 3 || header[0] != "Service Name" {
+		return nil, errors.New("unexpected service names header")
+	}
+
+	names := make(map[key]string)
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read service names: %w", err)
+		}
+		if len(rec) < 3 || rec[0] == "" {
+			continue
+		}
+		proto := strings.ToLower(rec[2])
+		if proto != "tcp" && proto != "udp" {
+			continue
+		}
+		port, err := strconv.Atoi(rec[1])
+		if err != nil {
+			continue
+		}
+		k := key{port: port, proto: proto}
+		// the registry lists the primary name first, later rows are aliases
+		if _, ok := names[k]; !ok {
+			names[k] = rec[0]
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedRegistry(t *testing.T) {
+	names, err := parse(bytes.NewReader(embeddedRegistry))
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+
+	for _, tc := range []struct {
+		port  int
+		proto string
+		want  string
+	}{
+		{22, "tcp", "ssh"},
+		{80, "tcp", "http"},
+		{443, "tcp", "https"},
+		{445, "tcp", "microsoft-ds"},
+		{3389, "tcp", "ms-wbt-server"},
+		{53, "udp", "domain"},
+		{5353, "udp", "mdns"},
+		{1900, "UDP", "ssdp"},
+	} {
+		require.Equal(t, tc.want, Name(tc.port, tc.proto), "%d/%s", tc.port, tc.proto)
+	}
+	require.Empty(t, Name(51820, "udp"))
+}
+
+func TestWithOverrides(t *testing.T) {
+	r, err := New(WithOverrides(map[string]string{
+		"8081/tcp": "grafana",
+		"9999":     "inhouse-api",
+		"9998":     "both",
+		"9998/udp": "udp-only",
+		"22":       "ssh-bastion",
+	}))
+	require.NoError(t, err)
+
+	require.Equal(t, "grafana", r.Name(8081, "tcp"))
+	require.Equal(t, "sunproxyadmin", r.Name(8081, "udp"))
+	require.Equal(t, "inhouse-api", r.Name(9999, "tcp"))
+	require.Equal(t, "inhouse-api", r.Name(9999, "udp"))
+	require.Equal(t, "both", r.Name(9998, "tcp"))
+	require.Equal(t, "udp-only", r.Name(9998, "udp"))
+	require.Equal(t, "ssh-bastion", r.Name(22, "tcp"))
+	require.Equal(t, "ssh", Name(22, "tcp"), "overrides must not leak into the default registry")
+}
+
+func TestWithOverrides_Invalid(t *testing.T) {
+	for _, overrides := range []map[string]string{
+		{"0": "zero"},
+		{"70000/tcp": "big"},
+		{"80/sctp": "web"},
+		{"http": "web"},
+		{"8080": " "},
+	} {
+		_, err := New(WithOverrides(overrides))
+		require.Error(t, err, "%v", overrides)
+	}
+}
+
+func TestParse_SkipsUnusableRows(t *testing.T) {
+	data := "Service Name,Port Number,Transport Protocol,Description\n" +
+		",1,tcp,unnamed\n" +
+		"range,6000-6063,tcp,X Window System\n" +
+		"sctp-only,9,sctp,\n" +
+		"primary,7,tcp,\n" +
+		"alias,7,tcp,\n"
+	names, err := parse(bytes.NewBufferString(data))
+	require.NoError(t, err)
+	require.Equal(t, map[key]string{{port: 7, proto: "tcp"}: "primary"}, names)
+
+	_, err = parse(bytes.NewBufferString("Port,Name\n"))
+	require.Error(t, err)
+}
@@ -31,7 +31,7 @@ var _ discovery.Sweeper = (*Sweeper)(nil)
 // ARP resolution as a side effect. The ARP scanner can then read these cached entries.
 //
 // The sweeper systematically contacts common ports (80, 443 for TCP; 9, 33434 for UDP)
-// on all IPs in the target subnet. Connections are expected to fail - the goal is
+// on all IPs in the subnets of the interface. Connections are expected to fail - the goal is
 // to trigger ARP, not establish connections.
 //
 // Runs continuously at the configured interval when started.
@@ -82,8 +82,8 @@ func New(opts ...Option) (*Sweeper, error) {
 // Performs an immediate sweep, then repeats at the configured interval.
 // If interval is 0 or negative, performs only a single sweep and returns.
 //
-// Each sweep sends UDP/TCP packets to all IPs in the interface's subnet
-// (excluding the host's own IP and broadcast addresses). The OS performs
+// Each sweep sends UDP/TCP packets to all IPs in each of the interface's IPv4
+// subnets (excluding the host's own IPs). The OS performs
 // ARP resolution for reachable IPs, populating the ARP cache.
 //
 // Designed to run in a background goroutine. The engine calls this automatically
@@ -97,38 +97,42 @@ func New(opts ...Option) (*Sweeper, error) {
 //	go sweeper.Start(ctx)
 //	// Sweeper runs until cancel() is called
 func (s *Sweeper) Start(ctx context.Context) {
-	subnet := s.iface.IPv4Net
-	localIP := *s.iface.IPv4Addr
-
 	if s.interval <= 0 {
-		s.runSweep(ctx, subnet, localIP)
+		s.runSweep(ctx)
 		return
 	}
 
 	ticker := time.NewTicker(s.interval)
 	defer ticker.Stop()
 
-	s.runSweep(ctx, subnet, localIP)
+	s.runSweep(ctx)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			s.runSweep(ctx, subnet, localIP)
+			s.runSweep(ctx)
 		}
 	}
 }
 
-func (s *Sweeper) runSweep(ctx context.Context, subnet *net.IPNet, localIP net.IP) {
-	ips := s.generateSubnetIPs(subnet, localIP)
-	if len(ips) == 0 {
-		return
-	}
+// runSweep sweeps every IPv4 subnet of the interface, skipping the host's own
+// address on each of them.
+func (s *Sweeper) runSweep(ctx context.Context) {
+	for _, subnet := range s.iface.Subnets() {
+		if ctx.Err() != nil {
+			return
+		}
+		ips := s.generateSubnetIPs(subnet, subnet.IP)
+		if len(ips) == 0 {
+			continue
+		}
 
-	s.logger.Log(ctx, slog.LevelDebug, "Triggering ARP requests for subnet", "subnet", subnet.Mask.String())
-	s.triggerSubnetSweep(ctx, ips)
-	s.logger.Log(ctx, slog.LevelDebug, "ARP triggering completed", "subnet", subnet.String())
+		s.logger.Log(ctx, slog.LevelDebug, "Triggering ARP requests for subnet", "subnet", subnet.String())
+		s.triggerSubnetSweep(ctx, ips)
+		s.logger.Log(ctx, slog.LevelDebug, "ARP triggering completed", "subnet", subnet.String())
+	}
 }
 
 func (s *Sweeper) triggerSubnetSweep(ctx context.Context, ips []net.IP) {
@@ -0,0 +1,115 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// udpProbes maps well-known UDP ports to a payload the service on that port
+// is expected to answer. UDP services generally ignore malformed datagrams,
+// so an empty probe would make most open ports look open|filtered.
+var udpProbes = map[int][]byte{
+	53:    dnsQuery(0x5748, ".", 2, false),                            // DNS: NS query for the root zone
+	67:    dhcpDiscover(),                                             // DHCP: DHCPDISCOVER
+	123:   ntpClientRequest(),                                         // NTP: mode 3 client request
+	161:   snmpGetSysDescr(),                                          // SNMP: v1 GetRequest sysDescr.0, community "public"
+	514:   []byte("<15>1 - - whosthere - - - udp port probe"),         // Syslog: RFC 5424 user.debug message
+	1900:  ssdpSearch(),                                               // SSDP: unicast M-SEARCH
+	5353:  dnsQuery(0x5749, "_services._dns-sd._udp.local", 12, true), // mDNS: legacy unicast service enumeration
+	51820: wireGuardInitiation(),                                      // WireGuard: handshake initiation frame
+}
+
+// udpProbePayload returns the probe payload for port, or an empty datagram
+// when no protocol-specific probe is known.
+func udpProbePayload(port int) []byte {
+	if p, ok := udpProbes[port]; ok {
+		return p
+	}
+	return []byte{}
+}
+
+// dnsQuery builds a single-question DNS query. Setting unicastResponse sets
+// the QU bit used by mDNS to request a direct (non-multicast) answer.
+func dnsQuery(id uint16, name string, qtype uint16, unicastResponse bool) []byte {
+	b := make([]byte, 12, 64)
+	binary.BigEndian.PutUint16(b[0:], id)
+	if !unicastResponse {
+		binary.BigEndian.PutUint16(b[2:], 0x0100) // recursion desired
+	}
+	binary.BigEndian.PutUint16(b[4:], 1) // QDCOUNT
+
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	b = append(b, 0)
+
+	class := uint16(1) // IN
+	if unicastResponse {
+		class |= 0x8000
+	}
+	b = binary.BigEndian.AppendUint16(b, qtype)
+	b = binary.BigEndian.AppendUint16(b, class)
+	return b
+}
+
+// ntpClientRequest builds a 48-byte NTPv3 client request (LI=0, VN=3, Mode=3).
+func ntpClientRequest() []byte {
+	b := make([]byte, 48)
+	b[0] = 0x1b
+	return b
+}
+
+// snmpGetSysDescr builds an SNMPv1 GetRequest for 1.3.6.1.2.1.1.1.0 using the
+// "public" community, BER encoded.
+func snmpGetSysDescr() []byte {
+	return []byte{
+		0x30, 0x29, // SEQUENCE, 41 bytes
+		0x02, 0x01, 0x00, // version: 0 (v1)
+		0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c', // community
+		0xa0, 0x1c, // GetRequest PDU, 28 bytes
+		0x02, 0x04, 0x57, 0x48, 0x4f, 0x53, // request-id
+		0x02, 0x01, 0x00, // error-status
+		0x02, 0x01, 0x00, // error-index
+		0x30, 0x0e, // varbind list, 14 bytes
+		0x30, 0x0c, // varbind, 12 bytes
+		0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, // OID 1.3.6.1.2.1.1.1.0
+		0x05, 0x00, // NULL
+	}
+}
+
+// ssdpSearch builds an M-SEARCH request suitable for unicast delivery.
+func ssdpSearch() []byte {
+	return []byte("M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: ssdp:all\r\n\r\n")
+}
+
+// dhcpDiscover builds a minimal BOOTP/DHCPDISCOVER message. Servers answer to
+// the client port (68), so a reply is rarely observed; the probe mainly serves
+// to elicit ICMP port unreachable from hosts that do not run a DHCP server.
+func dhcpDiscover() []byte {
+	b := make([]byte, 240, 244)
+	b[0] = 1                                      // op: BOOTREQUEST
+	b[1] = 1                                      // htype: ethernet
+	b[2] = 6                                      // hlen
+	binary.BigEndian.PutUint32(b[4:], 0x57484f53) // xid
+	binary.BigEndian.PutUint16(b[10:], 0x8000)    // flags: broadcast
+	copy(b[28:], []byte{0x02, 0x00, 0x57, 0x48, 0x4f, 0x53})
+	binary.BigEndian.PutUint32(b[236:], 0x63825363) // magic cookie
+	return append(b, 53, 1, 1, 255)                 // option 53: DHCPDISCOVER, end
+}
+
+// wireGuardInitiation builds a frame shaped like a handshake initiation
+// (type 1, 148 bytes). It cannot authenticate, so WireGuard drops it silently;
+// the probe can therefore only tell closed apart from open|filtered.
+func wireGuardInitiation() []byte {
+	b := make([]byte, 148)
+	b[0] = 1
+	return b
+}
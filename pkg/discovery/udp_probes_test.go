@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUDPProbePayloads(t *testing.T) {
+	snmp := udpProbePayload(161)
+	require.Equal(t, byte(0x30), snmp[0])
+	require.Equal(t, len(snmp)-2, int(snmp[1]), "SNMP outer sequence length must match payload")
+
+	ntp := udpProbePayload(123)
+	require.Len(t, ntp, 48)
+	require.Equal(t, byte(0x1b), ntp[0])
+
+	dns := udpProbePayload(53)
+	require.Equal(t, []byte{0, 2, 0, 1}, dns[len(dns)-4:], "root NS query in class IN")
+
+	mdns := udpProbePayload(5353)
+	require.Contains(t, string(mdns), "_services")
+	require.Equal(t, []byte{0, 12, 0x80, 1}, mdns[len(mdns)-4:], "PTR query with QU bit set")
+
+	require.Empty(t, udpProbePayload(4242))
+}
+
+func TestPortScanner_ScanUDP(t *testing.T) {
+	// replies to every datagram
+	echo, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer func() { _ = echo.Close() }()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := echo.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			_, _ = echo.WriteToUDP(buf[:n], addr)
+		}
+	}()
+
+	// bound but never replies
+	silent, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer func() { _ = silent.Close() }()
+
+	// nothing listening, the kernel answers with ICMP port unreachable
+	gone, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	closedPort := gone.LocalAddr().(*net.UDPAddr).Port
+	require.NoError(t, gone.Close())
+
+	openPort := echo.LocalAddr().(*net.UDPAddr).Port
+	silentPort := silent.LocalAddr().(*net.UDPAddr).Port
+
+	ps := NewPortScanner(3, nil, WithPortTimeout(300*time.Millisecond))
+	results, err := ps.ScanUDP(context.Background(), "127.0.0.1", []int{openPort, silentPort, closedPort})
+	require.NoError(t, err)
+
+	got := make(map[int]PortResult)
+	for r := range results {
+		got[r.Port] = r
+	}
+
+	require.Len(t, got, 3)
+	require.Equal(t, PortOpen, got[openPort].State)
+	require.Equal(t, PortOpenFiltered, got[silentPort].State)
+	require.NoError(t, got[silentPort].Err)
+	require.Equal(t, PortClosed, got[closedPort].State)
+	for _, r := range got {
+		require.Equal(t, "udp", r.Protocol)
+	}
+}